@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/journeymidnight/yig/api"
+	"github.com/journeymidnight/yig/backend"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
+)
+
+// pools of a newly added cluster if pool is not specified
+var clusterPools = []string{
+	backend.SMALL_FILE_POOLNAME,
+	backend.BIG_FILE_POOLNAME,
+	backend.GLACIER_FILE_POOLNAME,
+}
+
+type clusterInfoJson struct {
+	Fsid   string
+	Pool   string
+	Weight int
+	Status string
+	// false if the cluster is configured but not added to table cluster yet
+	Registered bool
+	// -1 if usage is not available
+	UsedSpacePercent int
+	// true if the circuit of the cluster is open, i.e. no data is written to it
+	CircuitOpen bool
+	Error       string `json:",omitempty"`
+}
+
+type clustersJson struct {
+	Clusters []clusterInfoJson
+}
+
+type circuitJson struct {
+	Name string
+	Open bool
+}
+
+type circuitsJson struct {
+	Circuits []circuitJson
+}
+
+func claimString(claims jwt.MapClaims, key string) string {
+	s, _ := claims[key].(string)
+	return s
+}
+
+// numbers in json are decoded as float64, returns -1 if not specified
+func claimInt(claims jwt.MapClaims, key string) int {
+	switch v := claims[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return -1
+}
+
+// getClusters lists clusters in table cluster and configured clusters not
+// added yet, with their used space.
+func getClusters(w http.ResponseWriter, r *http.Request) {
+	yig := adminServer.Yig
+	clusters, err := yig.MetaStorage.Client.GetClusters()
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	usages := make(map[string]clusterInfoJson)
+	getUsage := func(fsid string) clusterInfoJson {
+		if usage, ok := usages[fsid]; ok {
+			return usage
+		}
+		usage := clusterInfoJson{UsedSpacePercent: -1}
+		cluster, ok := yig.DataStorage[fsid]
+		if c, isCircuit := cluster.(*backend.CircuitCluster); isCircuit {
+			usage.CircuitOpen = c.IsOpen()
+		}
+		if !ok {
+			usage.Error = "cluster not configured"
+		} else if u, err := cluster.GetUsage(); err == backend.ErrUsageUnknown {
+			// healthy, used space is left as -1
+		} else if err != nil {
+			usage.Error = err.Error()
+		} else {
+			usage.UsedSpacePercent = u.UsedSpacePercent
+		}
+		usages[fsid] = usage
+		return usage
+	}
+
+	var result clustersJson
+	registered := make(map[string]bool)
+	for _, c := range clusters {
+		info := getUsage(c.Fsid)
+		info.Fsid = c.Fsid
+		info.Pool = c.Pool
+		info.Weight = c.Weight
+		info.Status = c.Status.ToString()
+		info.Registered = true
+		result.Clusters = append(result.Clusters, info)
+		registered[c.Fsid] = true
+	}
+	for fsid := range yig.DataStorage {
+		if registered[fsid] {
+			continue
+		}
+		info := getUsage(fsid)
+		info.Fsid = fsid
+		result.Clusters = append(result.Clusters, info)
+	}
+	sort.Slice(result.Clusters, func(i, j int) bool {
+		if result.Clusters[i].Fsid != result.Clusters[j].Fsid {
+			return result.Clusters[i].Fsid < result.Clusters[j].Fsid
+		}
+		return result.Clusters[i].Pool < result.Clusters[j].Pool
+	})
+	b, _ := json.Marshal(result)
+	w.Write(b)
+}
+
+// addCluster adds a configured cluster to table cluster, with all pools
+// if pool is not specified. Weight is 0 if not specified, so the cluster
+// gets no new objects until weight is set.
+func addCluster(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	fsid := claimString(claims, "fsid")
+	pool := claimString(claims, "pool")
+	weight := claimInt(claims, "weight")
+	if _, ok := adminServer.Yig.DataStorage[fsid]; !ok {
+		api.WriteErrorResponse(w, r, ErrNoSuchCluster)
+		return
+	}
+	if _, ok := claims["weight"]; !ok {
+		weight = 0
+	} else if weight < 0 {
+		api.WriteErrorResponse(w, r, ErrInvalidClusterWeight)
+		return
+	}
+	status := meta.ClusterStatusActive
+	if s := claimString(claims, "status"); s != "" {
+		var err error
+		status, err = meta.MatchClusterStatus(s)
+		if err != nil {
+			api.WriteErrorResponse(w, r, err)
+			return
+		}
+	}
+	pools := clusterPools
+	if pool != "" {
+		pools = []string{pool}
+	}
+	clusters := make([]meta.Cluster, 0, len(pools))
+	for _, p := range pools {
+		clusters = append(clusters, meta.Cluster{
+			Fsid:   fsid,
+			Pool:   p,
+			Weight: weight,
+			Status: status,
+		})
+	}
+	err := adminServer.Yig.MetaStorage.PutClusters(clusters)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	helper.Logger.Info("Cluster added:", fsid, pools, weight, status.ToString())
+}
+
+func setClusterWeight(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	fsid := claimString(claims, "fsid")
+	pool := claimString(claims, "pool")
+	weight := claimInt(claims, "weight")
+	if weight < 0 {
+		api.WriteErrorResponse(w, r, ErrInvalidClusterWeight)
+		return
+	}
+	err := adminServer.Yig.MetaStorage.UpdateClusterWeight(fsid, pool, weight)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	helper.Logger.Info("Cluster weight set:", fsid, pool, weight)
+}
+
+// setClusterStatus sets status of all pools of the cluster if pool is not specified
+func setClusterStatus(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	fsid := claimString(claims, "fsid")
+	pool := claimString(claims, "pool")
+	status, err := meta.MatchClusterStatus(claimString(claims, "status"))
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	err = adminServer.Yig.MetaStorage.UpdateClusterStatus(fsid, pool, status)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	helper.Logger.Info("Cluster status set:", fsid, pool, status.ToString())
+}
+
+// getCircuits lists state of circuits of the cache and clusters
+func getCircuits(w http.ResponseWriter, r *http.Request) {
+	var result circuitsJson
+	if redis.CacheCircuit != nil {
+		result.Circuits = append(result.Circuits, circuitJson{
+			Name: redis.CacheCircuit.Name(),
+			Open: redis.CacheCircuit.IsOpen(),
+		})
+	}
+	var clusters []circuitJson
+	for _, cluster := range adminServer.Yig.DataStorage {
+		if c, ok := cluster.(*backend.CircuitCluster); ok {
+			clusters = append(clusters, circuitJson{
+				Name: c.Circuit.Name(),
+				Open: c.IsOpen(),
+			})
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+	result.Circuits = append(result.Circuits, clusters...)
+	b, _ := json.Marshal(result)
+	w.Write(b)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/journeymidnight/yig/api"
+	. "github.com/journeymidnight/yig/error"
+	meta "github.com/journeymidnight/yig/meta/types"
+)
+
+const (
+	DEFAULT_SCRUB_LIST_LIMIT = 100
+	MAX_SCRUB_LIST_LIMIT     = 1000
+)
+
+type scrubFailuresJson struct {
+	Failures  []meta.ScrubFailure
+	Truncated bool
+	// marker of the next page, set if Truncated
+	NextBucketName string `json:",omitempty"`
+	NextObjectName string `json:",omitempty"`
+	NextVersion    string `json:",omitempty"`
+}
+
+// getScrubFailures lists data found corrupted or missing by yig_scrub, after
+// the marker of bucket, object and version(as string since it overflows
+// json numbers)
+func getScrubFailures(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	limit := claimInt(claims, "limit")
+	if limit <= 0 {
+		limit = DEFAULT_SCRUB_LIST_LIMIT
+	} else if limit > MAX_SCRUB_LIST_LIMIT {
+		limit = MAX_SCRUB_LIST_LIMIT
+	}
+	var version uint64
+	if v := claimString(claims, "version"); v != "" {
+		var err error
+		version, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			api.WriteErrorResponse(w, r, ErrInvalidScrubMarker)
+			return
+		}
+	}
+
+	// list one more failure to tell if truncated
+	failures, err := adminServer.Yig.MetaStorage.ListScrubFailures(limit+1,
+		claimString(claims, "bucket"), claimString(claims, "object"), version)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	result := scrubFailuresJson{Failures: failures}
+	if len(failures) > limit {
+		// the marker is per object version, don't split failures of parts
+		// of a version into pages, unless all of the page is the version
+		n := limit
+		for n > 0 && sameVersion(failures[n-1], failures[limit]) {
+			n--
+		}
+		if n == 0 {
+			n = limit
+		}
+		result.Failures = failures[:n]
+		last := failures[n-1]
+		result.Truncated = true
+		result.NextBucketName = last.BucketName
+		result.NextObjectName = last.ObjectName
+		result.NextVersion = strconv.FormatUint(last.Version, 10)
+	}
+	b, _ := json.Marshal(result)
+	w.Write(b)
+}
+
+func sameVersion(a, b meta.ScrubFailure) bool {
+	return a.BucketName == b.BucketName && a.ObjectName == b.ObjectName &&
+		a.Version == b.Version
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net"
 	"net/http"
@@ -19,6 +20,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+const adminShutdownTimeout = 5 * time.Second
+
 type adminServerConfig struct {
 	Address string
 	Logger  log.Logger
@@ -47,6 +50,7 @@ type usageJson struct {
 }
 
 var adminServer *adminServerConfig
+var adminHttpServer *http.Server
 
 type handlerFunc func(http.Handler) http.Handler
 
@@ -149,6 +153,17 @@ func configureAdminHandler() http.Handler {
 	admin.Methods("GET").Path("/bucket").HandlerFunc(SetJwtMiddlewareFunc(getBucketInfo))
 	admin.Methods("GET").Path("/object").HandlerFunc(SetJwtMiddlewareFunc(getObjectInfo))
 	admin.Methods("GET").Path("/cachehit").HandlerFunc(SetJwtMiddlewareFunc(getCacheHitRatio))
+	admin.Methods("GET").Path("/cluster").HandlerFunc(SetJwtMiddlewareFunc(getClusters))
+	admin.Methods("POST").Path("/cluster").HandlerFunc(SetJwtMiddlewareFunc(addCluster))
+	admin.Methods("PUT").Path("/cluster/weight").HandlerFunc(SetJwtMiddlewareFunc(setClusterWeight))
+	admin.Methods("PUT").Path("/cluster/status").HandlerFunc(SetJwtMiddlewareFunc(setClusterStatus))
+	admin.Methods("GET").Path("/circuit").HandlerFunc(SetJwtMiddlewareFunc(getCircuits))
+	admin.Methods("GET").Path("/scrub").HandlerFunc(SetJwtMiddlewareFunc(getScrubFailures))
+
+	// health endpoints are probed by load balancers and orchestrators, so no jwt
+	health := apiRouter.PathPrefix("/health").Subrouter()
+	health.Methods("GET").Path("/live").HandlerFunc(getLiveness)
+	health.Methods("GET").Path("/ready").HandlerFunc(getReadiness)
 
 	metrics := NewMetrics("yig")
 	registry := prometheus.NewRegistry()
@@ -172,7 +187,7 @@ func startAdminServer(c *adminServerConfig) {
 	// Check if requested port is available.
 	checkPortAvailability(getPort(net.JoinHostPort(host, port)))
 
-	adminServer := &http.Server{
+	adminHttpServer = &http.Server{
 		Addr: c.Address,
 		// Adding timeout of 10 minutes for unresponsive client connections.
 		ReadTimeout:    10 * time.Minute,
@@ -181,7 +196,7 @@ func startAdminServer(c *adminServerConfig) {
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	hosts, port := getListenIPs(adminServer) // get listen ips and port.
+	hosts, port := getListenIPs(adminHttpServer) // get listen ips and port.
 
 	helper.Logger.Info("S3 Object Storage:")
 	// Print api listen ips.
@@ -190,11 +205,20 @@ func startAdminServer(c *adminServerConfig) {
 	go func() {
 		var err error
 		// Configure TLS if certs are available.
-		err = adminServer.ListenAndServe()
+		err = adminHttpServer.ListenAndServe()
+		if err == http.ErrServerClosed {
+			return
+		}
 		helper.PanicOnError(err, "API server error.")
 	}()
 }
 
 func stopAdminServer() {
-	// TODO should shutdown admin API server gracefully
+	ctx, cancel := context.WithTimeout(context.Background(), adminShutdownTimeout)
+	defer cancel()
+	if err := adminHttpServer.Shutdown(ctx); err != nil {
+		helper.Logger.Warn("Shutdown admin server error:", err)
+		adminHttpServer.Close()
+	}
+	helper.Logger.Info("Admin server stopped")
 }
@@ -68,6 +68,10 @@ func configureServerHandler(c *ServerConfig) http.Handler {
 		// Add new handlers here.
 
 		api.SetLogHandler,
+		// Limits concurrent requests, request rate and bandwidth.
+		api.SetThrottleHandler,
+		// Rejects new requests when shutting down.
+		api.SetShutdownHandler,
 
 		api.NewAccessLogHandler,
 
@@ -242,6 +246,7 @@ func startApiServer(c *ServerConfig) {
 
 	// Configure server.
 	apiServer := configureServer(c)
+	ApiServer = apiServer
 
 	hosts, port := getListenIPs(apiServer.Server) // get listen ips and port.
 	tls := apiServer.Server.TLSConfig != nil      // 'true' if TLS is enabled.
@@ -259,10 +264,13 @@ func startApiServer(c *ServerConfig) {
 			// Fallback to http.
 			err = apiServer.Server.ListenAndServe()
 		}
+		if err == http.ErrServerClosed {
+			return
+		}
 		helper.PanicOnError(err, "API server error.")
 	}()
 }
 
 func stopApiServer() {
-	ApiServer.Stop()
+	ApiServer.Stop(time.Duration(helper.CONFIG.ShutdownDrainSeconds) * time.Second)
 }
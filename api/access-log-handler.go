@@ -1,12 +1,14 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
 	bus "github.com/journeymidnight/yig/mq"
 	"github.com/journeymidnight/yig/meta"
 )
@@ -24,6 +26,7 @@ type ResponseRecorder struct {
 	targetStorageClass string
 	bucketLogging      bool
 	cdn_request        bool
+	restoreTier        string
 }
 
 const timeLayoutStr = "2006-01-02 15:04:05"
@@ -39,6 +42,38 @@ func (r *ResponseRecorder) Flush() {
 	return
 }
 
+type credentialContextKeyType string
+
+const credentialContextKey credentialContextKeyType = "Credential"
+
+// requestCredential keeps the credential of request once it's authenticated,
+// so access log needn't authenticate the request again
+type requestCredential struct {
+	credential    common.Credential
+	authenticated bool
+}
+
+// setRequestCredential records `c` as the verified credential of request,
+// see postAuthenticate
+func setRequestCredential(c common.Credential, r *http.Request) {
+	holder, ok := r.Context().Value(credentialContextKey).(*requestCredential)
+	if !ok || c.UserId == "" {
+		return
+	}
+	holder.credential = c
+	holder.authenticated = true
+}
+
+// getRequestCredential returns the verified credential of request, `ok` is
+// false if the request is anonymous or not authenticated
+func getRequestCredential(r *http.Request) (c common.Credential, ok bool) {
+	holder, ok := r.Context().Value(credentialContextKey).(*requestCredential)
+	if !ok || !holder.authenticated {
+		return c, false
+	}
+	return holder.credential, true
+}
+
 type AccessLogHandler struct {
 	handler          http.Handler
 	responseRecorder *ResponseRecorder
@@ -47,6 +82,7 @@ type AccessLogHandler struct {
 
 func (a AccessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	a.responseRecorder = NewResponseRecorder(w)
+	r = r.WithContext(context.WithValue(r.Context(), credentialContextKey, new(requestCredential)))
 
 	startTime := time.Now()
 	a.handler.ServeHTTP(a.responseRecorder, r)
@@ -68,7 +104,7 @@ func (a AccessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a AccessLogHandler) notify(elems map[string]string) {
-	if len(elems) == 0 {
+	if len(elems) == 0 || bus.MsgSender == nil {
 		return
 	}
 	val, err := helper.MsgPackMarshal(elems)
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+	"github.com/journeymidnight/yig/log"
+	meta "github.com/journeymidnight/yig/meta/types"
+)
+
+type logBuffer struct {
+	bytes.Buffer
+}
+
+func (*logBuffer) Close() error { return nil }
+
+// Requester and project in access log come from the credential verified
+// when the request is authenticated
+func TestAccessLogRequester(t *testing.T) {
+	var out logBuffer
+	helper.AccessLogger = log.NewLogger(&out, log.InfoLevel)
+	helper.CONFIG.AccessLogFormat = "{requester_id} {project_id}"
+
+	testCases := []struct {
+		credential *common.Credential // nil if not authenticated
+		payer      string
+		expected   string
+	}{
+		{nil, "", "- owner\n"},
+		{&common.Credential{UserId: "requester"}, "", "requester owner\n"},
+		{&common.Credential{UserId: "requester"}, "requester", "requester requester\n"},
+		{&common.Credential{}, "requester", "- owner\n"},
+	}
+	for i, testCase := range testCases {
+		handler := NewAccessLogHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if testCase.credential != nil {
+				postAuthenticate(*testCase.credential, r)
+			}
+		}), nil)
+		r := newTestRequest(http.MethodGet, "/bucket/object",
+			http.Header{"X-Amz-Request-Payer": {testCase.payer}})
+		ctx := getRequestContext(r)
+		ctx.BucketInfo = &meta.Bucket{
+			Name:         "bucket",
+			OwnerId:      "owner",
+			RequestPayer: datatype.PayerRequester,
+		}
+		r = r.WithContext(context.WithValue(r.Context(), RequestContextKey, ctx))
+
+		out.Reset()
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if out.String() != testCase.expected {
+			t.Errorf("Test %d: expected %q, got %q", i+1, testCase.expected, out.String())
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"encoding/xml"
 	"net/http"
 	"strconv"
+	"strings"
 
 	. "github.com/journeymidnight/yig/api/datatype"
 	meta "github.com/journeymidnight/yig/meta/types"
@@ -28,7 +29,7 @@ import (
 
 // Refer: https://docs.aws.amazon.com/AmazonS3/latest/API/RESTCommonResponseHeaders.html
 var CommonS3ResponseHeaders = []string{"Content-Length", "Content-Type", "Connection", "Date", "ETag", "Server",
-	"x-amz-delete-marker", "x-amz-id-2", "x-amz-request-id", "x-amz-version-id"}
+	"x-amz-delete-marker", "x-amz-id-2", "x-amz-request-id", "x-amz-version-id", "x-amz-restore"}
 
 // Encodes the response headers into XML format.
 func EncodeResponse(response interface{}) []byte {
@@ -41,6 +42,29 @@ func EncodeResponse(response interface{}) []byte {
 
 // Write object header
 func SetObjectHeaders(w http.ResponseWriter, object *meta.Object, contentRange *HttpRange, statusCode int) {
+	setObjectMetaHeaders(w, object)
+
+	// for providing ranged content
+	if contentRange != nil && contentRange.OffsetBegin > -1 {
+		// Override content-length
+		w.Header().Set("Content-Length", strconv.FormatInt(contentRange.GetLength(), 10))
+		w.Header().Set("Content-Range", contentRange.String())
+		w.WriteHeader(http.StatusPartialContent)
+	}
+
+	w.WriteHeader(statusCode)
+}
+
+// Write object header for multipart/byteranges response of multiple ranges,
+// `contentLength` is the length of whole multipart body
+func SetByteRangesHeaders(w http.ResponseWriter, object *meta.Object, boundary string, contentLength int64) {
+	setObjectMetaHeaders(w, object)
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+	w.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
+	w.WriteHeader(http.StatusPartialContent)
+}
+
+func setObjectMetaHeaders(w http.ResponseWriter, object *meta.Object) {
 	// set object-related metadata headers
 	lastModified := object.LastModifiedTime.UTC().Format(http.TimeFormat)
 	w.Header().Set("Last-Modified", lastModified)
@@ -65,14 +89,27 @@ func SetObjectHeaders(w http.ResponseWriter, object *meta.Object, contentRange *
 	if object.Type == meta.ObjectTypeAppendable {
 		w.Header().Set("X-Amz-Next-Append-Position", strconv.FormatInt(object.Size, 10))
 	}
+}
 
-	// for providing ranged content
+// Write additional checksum of object if requested by x-amz-checksum-mode,
+// the checksum is of the whole object so it's not returned for ranges
+func SetChecksumHeader(w http.ResponseWriter, r *http.Request, object *meta.Object, contentRange *HttpRange) {
+	if !strings.EqualFold(r.Header.Get("X-Amz-Checksum-Mode"), "ENABLED") ||
+		object.ChecksumAlgorithm == "" {
+		return
+	}
 	if contentRange != nil && contentRange.OffsetBegin > -1 {
-		// Override content-length
-		w.Header().Set("Content-Length", strconv.FormatInt(contentRange.GetLength(), 10))
-		w.Header().Set("Content-Range", contentRange.String())
-		w.WriteHeader(http.StatusPartialContent)
+		return
 	}
+	w.Header().Set(ChecksumHeader(object.ChecksumAlgorithm), object.ChecksumValue)
+}
 
-	w.WriteHeader(statusCode)
+// Write restoration status of glacier object
+func SetRestoreHeader(w http.ResponseWriter, freezer *meta.Freezer) {
+	if freezer.Status == meta.ObjectHasRestored {
+		w.Header().Set("x-amz-restore", "ongoing-request=\"false\", expiry-date=\""+
+			freezer.ExpireTime().UTC().Format(http.TimeFormat)+"\"")
+	} else {
+		w.Header().Set("x-amz-restore", "ongoing-request=\"true\"")
+	}
 }
@@ -39,6 +39,8 @@ func parseListObjectsQuery(query url.Values) (request ListObjectsRequest, err er
 			true, false).(bool)
 	} else {
 		request.Version = 1
+		// owner is always returned by ListObjects V1
+		request.FetchOwner = true
 		request.Marker = query.Get("marker")
 		if !utf8.ValidString(request.Marker) {
 			err = ErrNonUTF8Encode
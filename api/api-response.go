@@ -104,7 +104,9 @@ func GenerateListObjectsResponse(bucketName string, request ListObjectsRequest,
 	response.BucketName = bucketName
 
 	if request.Version == 2 {
-		response.KeyCount = len(response.Contents)
+		// keys and common prefixes are both counted
+		keyCount := len(response.Contents) + len(response.CommonPrefixes)
+		response.KeyCount = &keyCount
 
 		response.ContinuationToken = request.ContinuationToken
 		response.NextContinuationToken = objectsInfo.NextMarker
@@ -72,6 +72,9 @@ func RegisterAPIRouter(mux *router.Router, api ObjectAPIHandlers) {
 		// RestoreObject
 		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(api.RestoreObjectHandler).
 			Queries("restore", "")
+		// SelectObjectContent
+		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(api.SelectObjectContentHandler).
+			Queries("select", "", "select-type", "2")
 		// PutObjectACL
 		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(api.PutObjectAclHandler).
 			Queries("acl", "")
@@ -143,6 +146,24 @@ func RegisterAPIRouter(mux *router.Router, api ObjectAPIHandlers) {
 		bucket.Methods("GET").HandlerFunc(api.GetBucketEncryption).Queries("encryption", "")
 		//
 		bucket.Methods("DELETE").HandlerFunc(api.DeleteBucketEncryption).Queries("encryption", "")
+		// PutBucketTagging
+		bucket.Methods("PUT").HandlerFunc(api.PutBucketTaggingHandler).Queries("tagging", "")
+		// GetBucketTagging
+		bucket.Methods("GET").HandlerFunc(api.GetBucketTaggingHandler).Queries("tagging", "")
+		// DeleteBucketTagging
+		bucket.Methods("DELETE").HandlerFunc(api.DeleteBucketTaggingHandler).Queries("tagging", "")
+		// PutBucketRequestPayment
+		bucket.Methods("PUT").HandlerFunc(api.PutBucketRequestPaymentHandler).Queries("requestPayment", "")
+		// GetBucketRequestPayment
+		bucket.Methods("GET").HandlerFunc(api.GetBucketRequestPaymentHandler).Queries("requestPayment", "")
+		// PutBucketInventoryConfiguration
+		bucket.Methods("PUT").HandlerFunc(api.PutBucketInventoryHandler).Queries("inventory", "")
+		// GetBucketInventoryConfiguration
+		bucket.Methods("GET").HandlerFunc(api.GetBucketInventoryHandler).Queries("inventory", "", "id", "{id:.+}")
+		// ListBucketInventoryConfigurations
+		bucket.Methods("GET").HandlerFunc(api.ListBucketInventoryHandler).Queries("inventory", "")
+		// DeleteBucketInventoryConfiguration
+		bucket.Methods("DELETE").HandlerFunc(api.DeleteBucketInventoryHandler).Queries("inventory", "")
 
 		// HeadBucket
 		bucket.Methods("HEAD").HandlerFunc(api.HeadBucketHandler)
@@ -157,6 +178,8 @@ func RegisterAPIRouter(mux *router.Router, api ObjectAPIHandlers) {
 	}
 	/// Root operation
 
+	// STS AssumeRole and GetSessionToken
+	apiRouter.Methods("POST").Path("/").HandlerFunc(api.StsHandler)
 	// ListBuckets
 	apiRouter.Methods("GET").HandlerFunc(api.ListBucketsHandler)
 }
@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/journeymidnight/yig/api/datatype"
 	"github.com/journeymidnight/yig/api/datatype/policy"
 	. "github.com/journeymidnight/yig/error"
 	"github.com/journeymidnight/yig/helper"
@@ -36,12 +37,23 @@ func checkRequestAuth(r *http.Request, action policy.Action) (c common.Credentia
 			return c, err
 		} else {
 			helper.Logger.Info("Credential:", c)
+			err = checkSessionPolicy(c, r, action, ctx.BucketName, ctx.ObjectName)
+			if err != nil {
+				return c, err
+			}
+			err = postAuthenticate(c, r)
+			if err != nil {
+				return c, err
+			}
 			// check bucket policy
 			isAllow, err := IsBucketPolicyAllowed(c.UserId, ctx.BucketInfo, r, action, ctx.ObjectName)
 			c.AllowOtherUserAccess = isAllow
 			return c, err
 		}
 	case signature.AuthTypeAnonymous:
+		if err = postAuthenticate(c, r); err != nil {
+			return c, err
+		}
 		isAllow, err := IsBucketPolicyAllowed(c.UserId, ctx.BucketInfo, r, action, ctx.ObjectName)
 		c.AllowOtherUserAccess = isAllow
 		return c, err
@@ -49,6 +61,87 @@ func checkRequestAuth(r *http.Request, action policy.Action) (c common.Credentia
 	return c, ErrAccessDenied
 }
 
+// authenticateRequest verifies the signature of requests which are not subject
+// to bucket policies. For temporary credentials with a session policy, `action`
+// must be allowed by the policy, empty `action` is always denied since the
+// operation could not be expressed in a policy.
+func authenticateRequest(r *http.Request, action policy.Action) (c common.Credential, err error) {
+	c, err = signature.IsReqAuthenticated(r)
+	if err != nil {
+		return c, err
+	}
+	if err = postAuthenticate(c, r); err != nil {
+		return c, err
+	}
+	ctx := getRequestContext(r)
+	return c, checkSessionPolicy(c, r, action, ctx.BucketName, ctx.ObjectName)
+}
+
+// postAuthenticate records the credential for access log and applies checks
+// depending on who sends the request, it must be called wherever a request
+// is authenticated, with an empty credential for anonymous requests.
+func postAuthenticate(c common.Credential, r *http.Request) error {
+	setRequestCredential(c, r)
+	if err := throttleAccessKey(c, r); err != nil {
+		return err
+	}
+	return checkRequestPayment(c, r)
+}
+
+// checkRequestPayment denies requests to a requester pays bucket from users
+// other than the bucket owner, unless they agree to pay for the request
+// with "x-amz-request-payer: requester". Anonymous requests are always denied.
+func checkRequestPayment(c common.Credential, r *http.Request) error {
+	bucket := getRequestContext(r).BucketInfo
+	if bucket == nil || bucket.RequestPayer != datatype.PayerRequester {
+		return nil
+	}
+	if c.UserId != "" && c.UserId == bucket.OwnerId {
+		return nil
+	}
+	if c.UserId == "" || !isRequesterCharged(r) {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// isRequesterCharged returns whether the requester agrees to pay for the
+// request, by header or by query parameter for presigned URLs
+func isRequesterCharged(r *http.Request) bool {
+	payer := r.Header.Get("X-Amz-Request-Payer")
+	if payer == "" {
+		payer = r.URL.Query().Get("x-amz-request-payer")
+	}
+	return strings.ToLower(payer) == "requester"
+}
+
+func checkSessionPolicy(c common.Credential, r *http.Request, action policy.Action,
+	bucketName, objectName string) error {
+
+	if c.SessionPolicy == "" {
+		return nil
+	}
+	if action == "" {
+		return ErrAccessDenied
+	}
+	sessionPolicy, err := policy.ParseSessionPolicy([]byte(c.SessionPolicy))
+	if err != nil {
+		helper.Logger.Warn("Parse session policy error:", err)
+		return ErrAccessDenied
+	}
+	allowed := sessionPolicy.IsSessionAllowed(policy.Args{
+		AccountName:     c.UserId,
+		Action:          action,
+		BucketName:      bucketName,
+		ConditionValues: getConditionValues(r, ""),
+		ObjectName:      objectName,
+	})
+	if !allowed {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
 func IsBucketPolicyAllowed(userId string, bucket *meta.Bucket, r *http.Request, action policy.Action, objectName string) (allow bool, err error) {
 	if bucket == nil {
 		return false, ErrAccessDenied
@@ -21,7 +21,7 @@ func (api ObjectAPIHandlers) PutBucketEncryption(w http.ResponseWriter, r *http.
 		WriteErrorResponse(w, r, ErrAccessDenied)
 		return
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, ""); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -73,7 +73,7 @@ func (api ObjectAPIHandlers) GetBucketEncryption(w http.ResponseWriter, r *http.
 		WriteErrorResponse(w, r, ErrAccessDenied)
 		return
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, ""); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -120,7 +120,7 @@ func (api ObjectAPIHandlers) DeleteBucketEncryption(w http.ResponseWriter, r *ht
 		WriteErrorResponse(w, r, ErrAccessDenied)
 		return
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, ""); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
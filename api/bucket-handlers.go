@@ -26,6 +26,7 @@ import (
 
 	"github.com/gorilla/mux"
 	. "github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/api/datatype/policy"
 	. "github.com/journeymidnight/yig/error"
 	"github.com/journeymidnight/yig/helper"
 	"github.com/journeymidnight/yig/iam/common"
@@ -51,7 +52,7 @@ func (api ObjectAPIHandlers) GetBucketLocationHandler(w http.ResponseWriter, r *
 		break
 	case signature.AuthTypeSignedV4, signature.AuthTypePresignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, policy.GetBucketLocationAction); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -96,7 +97,7 @@ func (api ObjectAPIHandlers) ListMultipartUploadsHandler(w http.ResponseWriter,
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, policy.ListBucketMultipartUploadsAction); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -148,7 +149,7 @@ func (api ObjectAPIHandlers) ListObjectsHandler(w http.ResponseWriter, r *http.R
 		break
 	case signature.AuthTypeSignedV4, signature.AuthTypePresignedV4,
 		signature.AuthTypeSignedV2, signature.AuthTypePresignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, policy.ListBucketAction); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -193,7 +194,7 @@ func (api ObjectAPIHandlers) ListVersionedObjectsHandler(w http.ResponseWriter,
 		break
 	case signature.AuthTypeSignedV4, signature.AuthTypePresignedV4,
 		signature.AuthTypeSignedV2, signature.AuthTypePresignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, policy.ListBucketAction); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -232,7 +233,7 @@ func (api ObjectAPIHandlers) ListBucketsHandler(w http.ResponseWriter, r *http.R
 	// List buckets does not support bucket policies.
 	var credential common.Credential
 	var err error
-	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+	if credential, err = authenticateRequest(r, policy.ListAllMyBucketsAction); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
@@ -270,11 +271,16 @@ func (api ObjectAPIHandlers) DeleteMultipleObjectsHandler(w http.ResponseWriter,
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
+		// session policy is checked against every key below
 		if credential, err = signature.IsReqAuthenticated(r); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
 	}
+	if err = postAuthenticate(credential, r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
 
 	// Content-Length is required and should be non-zero
 	// http://docs.aws.amazon.com/AmazonS3/latest/API/multiobjectdeleteapi.html
@@ -315,8 +321,12 @@ func (api ObjectAPIHandlers) DeleteMultipleObjectsHandler(w http.ResponseWriter,
 	var deletedObjects []ObjectIdentifier
 	// Loop through all the objects and delete them sequentially.
 	for _, object := range deleteObjects.Objects {
-		result, err := api.ObjectAPI.DeleteObject(bucket, object.ObjectName,
-			object.VersionId, credential)
+		var result DeleteObjectResult
+		err := checkSessionPolicy(credential, r, policy.DeleteObjectAction, bucket, object.ObjectName)
+		if err == nil {
+			result, err = api.ObjectAPI.DeleteObject(bucket, object.ObjectName,
+				object.VersionId, credential)
+		}
 		if err == nil {
 			deletedObjects = append(deletedObjects, ObjectIdentifier{
 				ObjectName:   object.ObjectName,
@@ -367,7 +377,7 @@ func (api ObjectAPIHandlers) PutBucketHandler(w http.ResponseWriter, r *http.Req
 	}
 	var credential common.Credential
 	var err error
-	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+	if credential, err = authenticateRequest(r, policy.CreateBucketAction); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
@@ -407,7 +417,7 @@ func (api ObjectAPIHandlers) PutBucketLoggingHandler(w http.ResponseWriter, r *h
 	ctx := getRequestContext(r)
 	var credential common.Credential
 	var err error
-	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+	if credential, err = authenticateRequest(r, ""); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
@@ -463,7 +473,7 @@ func (api ObjectAPIHandlers) GetBucketLoggingHandler(w http.ResponseWriter, r *h
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, ""); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -509,7 +519,7 @@ func (api ObjectAPIHandlers) PutBucketLifeCycleHandler(w http.ResponseWriter, r
 
 	var credential common.Credential
 	var err error
-	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+	if credential, err = authenticateRequest(r, ""); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
@@ -556,7 +566,7 @@ func (api ObjectAPIHandlers) GetBucketLifeCycleHandler(w http.ResponseWriter, r
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, ""); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -591,7 +601,7 @@ func (api ObjectAPIHandlers) DelBucketLifeCycleHandler(w http.ResponseWriter, r
 
 	var credential common.Credential
 	var err error
-	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+	if credential, err = authenticateRequest(r, ""); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
@@ -614,7 +624,7 @@ func (api ObjectAPIHandlers) PutBucketAclHandler(w http.ResponseWriter, r *http.
 
 	var credential common.Credential
 	var err error
-	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+	if credential, err = authenticateRequest(r, ""); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
@@ -670,7 +680,7 @@ func (api ObjectAPIHandlers) GetBucketAclHandler(w http.ResponseWriter, r *http.
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, ""); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -705,7 +715,7 @@ func (api ObjectAPIHandlers) PutBucketCorsHandler(w http.ResponseWriter, r *http
 
 	var credential common.Credential
 	var err error
-	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+	if credential, err = authenticateRequest(r, ""); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
@@ -751,7 +761,7 @@ func (api ObjectAPIHandlers) DeleteBucketCorsHandler(w http.ResponseWriter, r *h
 
 	var credential common.Credential
 	var err error
-	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+	if credential, err = authenticateRequest(r, ""); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
@@ -773,7 +783,7 @@ func (api ObjectAPIHandlers) GetBucketCorsHandler(w http.ResponseWriter, r *http
 
 	var credential common.Credential
 	var err error
-	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+	if credential, err = authenticateRequest(r, ""); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
@@ -805,7 +815,7 @@ func (api ObjectAPIHandlers) GetBucketVersioningHandler(w http.ResponseWriter, r
 
 	var credential common.Credential
 	var err error
-	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+	if credential, err = authenticateRequest(r, ""); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
@@ -837,7 +847,7 @@ func (api ObjectAPIHandlers) PutBucketVersioningHandler(w http.ResponseWriter, r
 
 	var credential common.Credential
 	var err error
-	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+	if credential, err = authenticateRequest(r, ""); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
@@ -947,7 +957,7 @@ func (api ObjectAPIHandlers) HeadBucketHandler(w http.ResponseWriter, r *http.Re
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, policy.ListBucketAction); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -971,7 +981,7 @@ func (api ObjectAPIHandlers) DeleteBucketHandler(w http.ResponseWriter, r *http.
 
 	var credential common.Credential
 	var err error
-	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+	if credential, err = authenticateRequest(r, policy.DeleteBucketAction); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
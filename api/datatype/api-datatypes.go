@@ -41,7 +41,7 @@ type ListObjectsResponse struct {
 	EncodingType   string `xml:"Encoding-Type,omitempty"`
 	IsTruncated    bool
 	MaxKeys        int
-	KeyCount       int `xml:",omitempty"`
+	KeyCount       *int `xml:",omitempty"` // v2 only
 	Prefix         string
 	BucketName     string `xml:"Name"`
 
@@ -118,6 +118,7 @@ type Part struct {
 	ETag         string
 	LastModified string
 	Size         int64
+	Checksums
 }
 
 // ListPartsResponse - format for list parts response.
@@ -134,6 +135,8 @@ type ListPartsResponse struct {
 
 	// The class of storage used to store the object.
 	StorageClass string
+	// algorithm of composite checksum requested on creating the upload
+	ChecksumAlgorithm string `xml:",omitempty"`
 
 	PartNumberMarker     int
 	NextPartNumberMarker int
@@ -202,7 +205,8 @@ type Object struct {
 	ETag         string
 	Size         int64
 
-	Owner Owner
+	// only if fetch-owner is set for v2
+	Owner *Owner `xml:",omitempty"`
 
 	// The class of storage used to store the object.
 	StorageClass string
@@ -266,6 +270,7 @@ type CompleteMultipartUploadResponse struct {
 	Bucket   string
 	Key      string
 	ETag     string
+	Checksums
 }
 
 // PostResponse container for completed post upload response
@@ -314,6 +319,9 @@ type PutObjectResult struct {
 	Md5          string
 	VersionId    string
 	LastModified time.Time
+	// additional checksum of data if requested
+	ChecksumAlgorithm string
+	ChecksumValue     string
 }
 
 type RenameObjectResult struct {
@@ -336,6 +344,8 @@ type PutObjectPartResult struct {
 	SseAwsKmsKeyIdBase64    string
 	SseCustomerAlgorithm    string
 	SseCustomerKeyMd5Base64 string
+	ChecksumAlgorithm       string
+	ChecksumValue           string
 }
 
 type CompleteMultipartResult struct {
@@ -345,6 +355,9 @@ type CompleteMultipartResult struct {
 	SseAwsKmsKeyIdBase64    string
 	SseCustomerAlgorithm    string
 	SseCustomerKeyMd5Base64 string
+	// composite checksum of the object if requested on creating the upload
+	ChecksumAlgorithm string
+	ChecksumValue     string
 }
 
 type SseRequest struct {
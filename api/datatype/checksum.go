@@ -0,0 +1,119 @@
+package datatype
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"hash/crc32"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Algorithms of additional checksums, see
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/checking-object-integrity.html
+const (
+	ChecksumCRC32  = "CRC32"
+	ChecksumCRC32C = "CRC32C"
+	ChecksumSHA1   = "SHA1"
+	ChecksumSHA256 = "SHA256"
+)
+
+var ChecksumAlgorithms = []string{ChecksumCRC32, ChecksumCRC32C, ChecksumSHA1, ChecksumSHA256}
+
+// ChecksumRequest is an additional checksum of object data sent by client,
+// in x-amz-checksum-* header or in trailer of aws-chunked body
+type ChecksumRequest struct {
+	Algorithm string // empty if no checksum is sent
+	// base64 encoded checksum, empty if it's in trailer
+	Value    string
+	Trailing bool
+}
+
+// ChecksumHeader returns header of checksum of `algorithm`,
+// e.g. X-Amz-Checksum-Crc32
+func ChecksumHeader(algorithm string) string {
+	return http.CanonicalHeaderKey("X-Amz-Checksum-" + strings.ToLower(algorithm))
+}
+
+// NewChecksumHash returns hash of `algorithm`, nil if not supported
+func NewChecksumHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case ChecksumCRC32:
+		return crc32.NewIEEE()
+	case ChecksumCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case ChecksumSHA1:
+		return sha1.New()
+	case ChecksumSHA256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// IsValidChecksum returns true if `value` is a base64 encoded checksum of
+// `algorithm`
+func IsValidChecksum(algorithm, value string) bool {
+	h := NewChecksumHash(algorithm)
+	if h == nil {
+		return false
+	}
+	sum, err := base64.StdEncoding.DecodeString(value)
+	return err == nil && len(sum) == h.Size()
+}
+
+// CompositeChecksum returns checksum of a multipart object, i.e. checksum of
+// concatenated checksums of its parts, suffixed by number of parts as
+// "<base64>-<N>". `partChecksums` are base64 encoded, in order of parts.
+func CompositeChecksum(algorithm string, partChecksums []string) (string, bool) {
+	h := NewChecksumHash(algorithm)
+	if h == nil {
+		return "", false
+	}
+	for _, c := range partChecksums {
+		sum, err := base64.StdEncoding.DecodeString(c)
+		if err != nil || len(sum) != h.Size() {
+			return "", false
+		}
+		h.Write(sum)
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)) + "-" +
+		strconv.Itoa(len(partChecksums)), true
+}
+
+// Checksums are additional checksums in XML of requests and responses, only
+// the one of the algorithm used is set
+type Checksums struct {
+	ChecksumCRC32  string `xml:",omitempty"`
+	ChecksumCRC32C string `xml:",omitempty"`
+	ChecksumSHA1   string `xml:",omitempty"`
+	ChecksumSHA256 string `xml:",omitempty"`
+}
+
+func (c *Checksums) Set(algorithm, value string) {
+	switch algorithm {
+	case ChecksumCRC32:
+		c.ChecksumCRC32 = value
+	case ChecksumCRC32C:
+		c.ChecksumCRC32C = value
+	case ChecksumSHA1:
+		c.ChecksumSHA1 = value
+	case ChecksumSHA256:
+		c.ChecksumSHA256 = value
+	}
+}
+
+func (c Checksums) Get(algorithm string) string {
+	switch algorithm {
+	case ChecksumCRC32:
+		return c.ChecksumCRC32
+	case ChecksumCRC32C:
+		return c.ChecksumCRC32C
+	case ChecksumSHA1:
+		return c.ChecksumSHA1
+	case ChecksumSHA256:
+		return c.ChecksumSHA256
+	}
+	return ""
+}
@@ -0,0 +1,33 @@
+package datatype
+
+import (
+	. "github.com/journeymidnight/yig/error"
+)
+
+// WriteCondition is the precondition of writing an object, sent in If-Match
+// and If-None-Match headers of PutObject, CopyObject and
+// CompleteMultipartUpload. It's checked against the latest version of the
+// object in the transaction of writing metadata.
+type WriteCondition struct {
+	// ETag the object must have, without quotes, or "*" for any
+	IfMatch string
+	// `If-None-Match: *`, the object must not exist
+	IfNoneMatch bool
+}
+
+// Check returns nil if the condition is met by the latest version of object,
+// `exists` is false if the object doesn't exist or is a delete marker
+func (c *WriteCondition) Check(exists bool, etag string) error {
+	if c.IfNoneMatch && exists {
+		return ErrPreconditionFailed
+	}
+	if c.IfMatch != "" {
+		if !exists {
+			return ErrNoSuchKey
+		}
+		if c.IfMatch != "*" && c.IfMatch != etag {
+			return ErrPreconditionFailed
+		}
+	}
+	return nil
+}
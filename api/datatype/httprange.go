@@ -20,12 +20,21 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 const (
 	byteRangePrefix = "bytes="
+
+	// MaxRequestRanges limits the number of ranges in one request, since every
+	// range is read from backend separately
+	MaxRequestRanges = 64
+
+	// Ranges with gaps smaller than this are coalesced, it's about the overhead
+	// of a part header in multipart/byteranges response
+	rangeCoalesceGap = 80
 )
 
 // Valid byte position regexp
@@ -34,6 +43,9 @@ var validBytePos = regexp.MustCompile(`^[0-9]+$`)
 // ErrorInvalidRange - returned when given range value is not valid.
 var ErrorInvalidRange = errors.New("Invalid range")
 
+// ErrorTooManyRanges - returned when a request has more than MaxRequestRanges ranges.
+var ErrorTooManyRanges = errors.New("Too many ranges")
+
 // HttpRange specifies the byte range to be sent to the client.
 type HttpRange struct {
 	OffsetBegin  int64
@@ -139,3 +151,56 @@ func ParseRequestRange(rangeString string, resourceSize int64) (hrange *HttpRang
 
 	return &HttpRange{offsetBegin, offsetEnd, resourceSize}, nil
 }
+
+// ParseRequestRanges parses Range header with one or more byte ranges,
+// e.g. "bytes=0-99,200-299". Unsatisfiable ranges are ignored if any other
+// range is satisfiable. Returned ranges are sorted by offset, ranges overlapping
+// or close to each other are coalesced, see https://tools.ietf.org/html/rfc7233#section-4.1
+func ParseRequestRanges(rangeString string, resourceSize int64) (hranges []*HttpRange, err error) {
+	if !strings.HasPrefix(rangeString, byteRangePrefix) {
+		return nil, fmt.Errorf("'%s' does not start with '%s'", rangeString, byteRangePrefix)
+	}
+
+	specs := strings.Split(strings.TrimPrefix(rangeString, byteRangePrefix), ",")
+	if len(specs) > MaxRequestRanges {
+		return nil, ErrorTooManyRanges
+	}
+	var hasRange bool
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		hasRange = true
+		hrange, err := ParseRequestRange(byteRangePrefix+spec, resourceSize)
+		if err == ErrorInvalidRange {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		hranges = append(hranges, hrange)
+	}
+	if !hasRange {
+		return nil, fmt.Errorf("'%s' does not have valid range value", rangeString)
+	}
+	if len(hranges) == 0 {
+		return nil, ErrorInvalidRange
+	}
+
+	sort.Slice(hranges, func(i, j int) bool {
+		return hranges[i].OffsetBegin < hranges[j].OffsetBegin
+	})
+	coalesced := hranges[:1]
+	for _, hrange := range hranges[1:] {
+		last := coalesced[len(coalesced)-1]
+		if hrange.OffsetBegin > last.OffsetEnd+rangeCoalesceGap {
+			coalesced = append(coalesced, hrange)
+			continue
+		}
+		if hrange.OffsetEnd > last.OffsetEnd {
+			last.OffsetEnd = hrange.OffsetEnd
+		}
+	}
+	return coalesced, nil
+}
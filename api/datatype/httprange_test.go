@@ -0,0 +1,103 @@
+package datatype
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseRequestRanges(t *testing.T) {
+	testCases := []struct {
+		rangeString string
+		size        int64
+		expected    [][2]int64 // begin and end of ranges expected
+		err         error
+	}{
+		// single range
+		{"bytes=0-99", 1000, [][2]int64{{0, 99}}, nil},
+		{"bytes=900-", 1000, [][2]int64{{900, 999}}, nil},
+		{"bytes=-100", 1000, [][2]int64{{900, 999}}, nil},
+		{"bytes=900-2000", 1000, [][2]int64{{900, 999}}, nil},
+		// multiple ranges are sorted
+		{"bytes=0-99,200-299", 1000, [][2]int64{{0, 99}, {200, 299}}, nil},
+		{"bytes=500-599, 0-99", 1000, [][2]int64{{0, 99}, {500, 599}}, nil},
+		{"bytes=0-9,-10", 1000, [][2]int64{{0, 9}, {990, 999}}, nil},
+		// overlapping or close ranges are merged
+		{"bytes=0-99,50-149", 1000, [][2]int64{{0, 149}}, nil},
+		{"bytes=0-99,10-19", 1000, [][2]int64{{0, 99}}, nil},
+		{"bytes=0-99,100-199", 1000, [][2]int64{{0, 199}}, nil},
+		{"bytes=0-99,150-199", 1000, [][2]int64{{0, 199}}, nil},
+		{"bytes=0-99,181-199", 1000, [][2]int64{{0, 99}, {181, 199}}, nil},
+		{"bytes=300-399,0-99,350-", 1000, [][2]int64{{0, 99}, {300, 999}}, nil},
+		// unsatisfiable ranges are ignored unless all of them are
+		{"bytes=0-99,2000-2099", 1000, [][2]int64{{0, 99}}, nil},
+		{"bytes=2000-2099,3000-", 1000, nil, ErrorInvalidRange},
+		{"bytes=1000-", 1000, nil, ErrorInvalidRange},
+		{"bytes=-0", 1000, nil, ErrorInvalidRange},
+		{"bytes=0-", 0, nil, ErrorInvalidRange},
+	}
+	for i, testCase := range testCases {
+		hranges, err := ParseRequestRanges(testCase.rangeString, testCase.size)
+		if err != testCase.err {
+			t.Errorf("Test %d: expected error %v, got %v", i+1, testCase.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(hranges) != len(testCase.expected) {
+			t.Errorf("Test %d: expected %v, got %v", i+1, testCase.expected, hranges)
+			continue
+		}
+		for j, hrange := range hranges {
+			if hrange.OffsetBegin != testCase.expected[j][0] ||
+				hrange.OffsetEnd != testCase.expected[j][1] ||
+				hrange.ResourceSize != testCase.size {
+				t.Errorf("Test %d: expected %v, got %v", i+1, testCase.expected, hranges)
+				break
+			}
+		}
+	}
+}
+
+func TestParseRequestRangesInvalid(t *testing.T) {
+	for i, rangeString := range []string{
+		"",
+		"0-99",
+		"items=0-99",
+		"bytes=",
+		"bytes=,",
+		"bytes=-",
+		"bytes=99-0",
+		"bytes=0-99,abc",
+		"bytes=0-99,99-0",
+	} {
+		_, err := ParseRequestRanges(rangeString, 1000)
+		if err == nil || err == ErrorInvalidRange {
+			t.Errorf("Test %d: %q should be rejected as malformed, got %v",
+				i+1, rangeString, err)
+		}
+	}
+}
+
+func TestParseRequestRangesLimit(t *testing.T) {
+	hranges, err := ParseRequestRanges("bytes="+manyRanges(MaxRequestRanges), 100000)
+	if err != nil || len(hranges) != MaxRequestRanges {
+		t.Errorf("Expected %d ranges, got %d, err: %v", MaxRequestRanges, len(hranges), err)
+	}
+	_, err = ParseRequestRanges("bytes="+manyRanges(MaxRequestRanges+1), 100000)
+	if err != ErrorTooManyRanges {
+		t.Errorf("Expected error %v, got %v", ErrorTooManyRanges, err)
+	}
+}
+
+// manyRanges returns `n` one-byte ranges far enough from each other not to
+// be merged
+func manyRanges(n int) string {
+	specs := make([]string, n)
+	for i := range specs {
+		offset := strconv.Itoa(i * 100)
+		specs[i] = offset + "-" + offset
+	}
+	return strings.Join(specs, ",")
+}
@@ -0,0 +1,167 @@
+package datatype
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	MaxInventoryConfigurations         = 1000
+	MaxInventoryConfigurationSize      = 20 * humanize.KiByte
+	MaxInventoryConfigurationsListSize = 100
+
+	InventoryFormatCSV  = "CSV"
+	InventoryFormatJSON = "JSON"
+
+	InventoryFrequencyDaily  = "Daily"
+	InventoryFrequencyWeekly = "Weekly"
+
+	InventoryVersionsAll     = "All"
+	InventoryVersionsCurrent = "Current"
+
+	InventoryBucketArnPrefix = "arn:aws:s3:::"
+)
+
+// InventoryOptionalFields are the optional fields of inventory reports,
+// in the order they appear in report files
+var InventoryOptionalFields = []string{
+	"Size",
+	"LastModifiedDate",
+	"StorageClass",
+	"ETag",
+	"EncryptionStatus",
+}
+
+var inventoryIdPattern = regexp.MustCompile(`^[a-zA-Z0-9\-_.]{1,64}$`)
+
+type InventoryConfiguration struct {
+	XMLName                xml.Name             `xml:"InventoryConfiguration" json:"-"`
+	Id                     string               `xml:"Id"`
+	IsEnabled              bool                 `xml:"IsEnabled"`
+	Destination            InventoryDestination `xml:"Destination"`
+	Filter                 *InventoryFilter     `xml:"Filter,omitempty"`
+	IncludedObjectVersions string               `xml:"IncludedObjectVersions"`
+	OptionalFields         []string             `xml:"OptionalFields>Field,omitempty"`
+	Schedule               InventorySchedule    `xml:"Schedule"`
+}
+
+type InventoryDestination struct {
+	S3BucketDestination InventoryBucketDestination `xml:"S3BucketDestination"`
+}
+
+type InventoryBucketDestination struct {
+	AccountId string `xml:"AccountId,omitempty"`
+	Bucket    string `xml:"Bucket"` // in form of "arn:aws:s3:::bucket" or just "bucket"
+	Format    string `xml:"Format"`
+	Prefix    string `xml:"Prefix,omitempty"`
+}
+
+type InventoryFilter struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type InventorySchedule struct {
+	Frequency string `xml:"Frequency"`
+}
+
+type ListInventoryConfigurationsResult struct {
+	XMLName                 xml.Name                 `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListInventoryConfigurationsResult"`
+	ContinuationToken       string                   `xml:"ContinuationToken,omitempty"`
+	InventoryConfigurations []InventoryConfiguration `xml:"InventoryConfiguration"`
+	IsTruncated             bool                     `xml:"IsTruncated"`
+	NextContinuationToken   string                   `xml:"NextContinuationToken,omitempty"`
+}
+
+// DestinationBucket returns name of the bucket that reports are written to
+func (c *InventoryConfiguration) DestinationBucket() string {
+	return strings.TrimPrefix(c.Destination.S3BucketDestination.Bucket, InventoryBucketArnPrefix)
+}
+
+// FilterPrefix returns the object key prefix to include in reports
+func (c *InventoryConfiguration) FilterPrefix() string {
+	if c.Filter == nil {
+		return ""
+	}
+	return c.Filter.Prefix
+}
+
+// HasField returns whether optional field `name` is included in reports
+func (c *InventoryConfiguration) HasField(name string) bool {
+	for _, field := range c.OptionalFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the configuration and that its Id matches `id`, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketInventoryConfiguration.html
+func (c *InventoryConfiguration) Validate(id string) error {
+	if !inventoryIdPattern.MatchString(c.Id) || c.Id != id {
+		return ErrInvalidInventoryConfiguration
+	}
+	destination := c.Destination.S3BucketDestination
+	if c.DestinationBucket() == "" {
+		return ErrInvalidInventoryConfiguration
+	}
+	switch destination.Format {
+	case InventoryFormatCSV, InventoryFormatJSON:
+	case "ORC", "Parquet":
+		return ErrNotImplemented
+	default:
+		return ErrInvalidInventoryConfiguration
+	}
+	switch c.Schedule.Frequency {
+	case InventoryFrequencyDaily, InventoryFrequencyWeekly:
+	default:
+		return ErrInvalidInventoryConfiguration
+	}
+	switch c.IncludedObjectVersions {
+	case InventoryVersionsAll, InventoryVersionsCurrent:
+	default:
+		return ErrInvalidInventoryConfiguration
+	}
+	seen := make(map[string]bool)
+	for _, field := range c.OptionalFields {
+		if seen[field] {
+			return ErrInvalidInventoryConfiguration
+		}
+		seen[field] = true
+		if !helper.StringInSlice(field, InventoryOptionalFields) {
+			return ErrInvalidInventoryConfiguration
+		}
+	}
+	return nil
+}
+
+// ParseInventoryConfig parses inventory configuration from request body,
+// `id` is the id query parameter of request
+func ParseInventoryConfig(reader io.Reader, id string) (*InventoryConfiguration, error) {
+	config := new(InventoryConfiguration)
+	buffer, err := ioutil.ReadAll(reader)
+	if err != nil {
+		helper.Logger.Error("Unable to read inventory config body:", err)
+		return nil, err
+	}
+	if len(buffer) > MaxInventoryConfigurationSize {
+		return nil, ErrEntityTooLarge
+	}
+	err = xml.Unmarshal(buffer, config)
+	if err != nil {
+		helper.Logger.Error("Unable to parse inventory config XML body:", err)
+		return nil, ErrMalformedInventoryConfiguration
+	}
+	err = config.Validate(id)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -0,0 +1,55 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/journeymidnight/yig/api/datatype/policy/condition"
+)
+
+// sessionStatement - statement of an inline session policy, which has no Principal.
+type sessionStatement struct {
+	SID        ID                  `json:"Sid,omitempty"`
+	Effect     Effect              `json:"Effect"`
+	Actions    ActionSet           `json:"Action"`
+	Resources  ResourceSet         `json:"Resource"`
+	Conditions condition.Functions `json:"Condition,omitempty"`
+}
+
+// ParseSessionPolicy - parses the inline session policy passed to AssumeRole or
+// GetSessionToken. The policy applies to whoever holds the temporary credential,
+// so the Principal of every statement is "*".
+func ParseSessionPolicy(data []byte) (*Policy, error) {
+	var sp struct {
+		ID         ID `json:"Id,omitempty"`
+		Version    string
+		Statements []sessionStatement `json:"Statement"`
+	}
+	if err := json.Unmarshal(data, &sp); err != nil {
+		return nil, err
+	}
+	if len(sp.Statements) == 0 {
+		return nil, errors.New("Statement must not be empty")
+	}
+
+	policy := Policy{
+		ID:      sp.ID,
+		Version: sp.Version,
+	}
+	for _, s := range sp.Statements {
+		statement := NewStatement(s.Effect, NewPrincipal("*"), s.Actions, s.Resources, s.Conditions)
+		statement.SID = s.SID
+		policy.Statements = append(policy.Statements, statement)
+	}
+	if err := policy.isValid(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// IsSessionAllowed - checks given args against a session policy. Unlike bucket
+// policies, a session policy only grants what it explicitly allows.
+func (policy Policy) IsSessionAllowed(args Args) bool {
+	args.IsOwner = false
+	return policy.IsAllowed(args) == PolicyAllow
+}
@@ -0,0 +1,96 @@
+package policy
+
+import (
+	"testing"
+)
+
+const testSessionPolicy = `{
+	"Version": "2012-10-17",
+	"Statement": [
+		{
+			"Effect": "Allow",
+			"Action": ["s3:GetObject", "s3:PutObject"],
+			"Resource": ["arn:aws:s3:::bucket/*"]
+		},
+		{
+			"Effect": "Allow",
+			"Action": ["s3:ListBucket"],
+			"Resource": ["arn:aws:s3:::bucket"],
+			"Condition": {"IpAddress": {"aws:SourceIp": "192.168.1.0/24"}}
+		},
+		{
+			"Effect": "Deny",
+			"Action": ["s3:PutObject"],
+			"Resource": ["arn:aws:s3:::bucket/private/*"]
+		}
+	]
+}`
+
+// A session is only allowed what both its parent and the session policy
+// allow, the parent being allowed (as owner or by bucket policy) is not
+// enough to pass the session policy
+func TestSessionPolicyAllowed(t *testing.T) {
+	sessionPolicy, err := ParseSessionPolicy([]byte(testSessionPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inside := map[string][]string{"SourceIp": {"192.168.1.10"}}
+	outside := map[string][]string{"SourceIp": {"10.0.0.1"}}
+
+	testCases := []struct {
+		action     Action
+		bucket     string
+		object     string
+		isOwner    bool
+		conditions map[string][]string
+		allowed    bool
+	}{
+		{GetObjectAction, "bucket", "object", false, inside, true},
+		{PutObjectAction, "bucket", "dir/object", false, inside, true},
+		{PutObjectAction, "bucket", "object", true, inside, true},
+		// actions not in the session policy
+		{DeleteObjectAction, "bucket", "object", false, inside, false},
+		{DeleteObjectAction, "bucket", "object", true, inside, false},
+		// resources not in the session policy
+		{GetObjectAction, "other-bucket", "object", false, inside, false},
+		{GetObjectAction, "other-bucket", "object", true, inside, false},
+		// denied explicitly
+		{PutObjectAction, "bucket", "private/object", false, inside, false},
+		{GetObjectAction, "bucket", "private/object", false, inside, true},
+		// conditions
+		{ListBucketAction, "bucket", "", false, inside, true},
+		{ListBucketAction, "bucket", "", true, outside, false},
+	}
+	for i, testCase := range testCases {
+		allowed := sessionPolicy.IsSessionAllowed(Args{
+			AccountName:     "user",
+			Action:          testCase.action,
+			BucketName:      testCase.bucket,
+			ObjectName:      testCase.object,
+			IsOwner:         testCase.isOwner,
+			ConditionValues: testCase.conditions,
+		})
+		if allowed != testCase.allowed {
+			t.Errorf("Test %d: expected allowed %v, got %v", i+1, testCase.allowed, allowed)
+		}
+	}
+}
+
+func TestParseSessionPolicyInvalid(t *testing.T) {
+	for i, data := range []string{
+		"",
+		"not json",
+		`{"Version": "2012-10-17"}`,
+		`{"Version": "2012-10-17", "Statement": []}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Maybe",
+			"Action": ["s3:GetObject"], "Resource": ["arn:aws:s3:::bucket/*"]}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow",
+			"Action": ["s3:NoSuchAction"], "Resource": ["arn:aws:s3:::bucket/*"]}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow",
+			"Action": ["s3:GetObject"], "Resource": []}]}`,
+	} {
+		if _, err := ParseSessionPolicy([]byte(data)); err == nil {
+			t.Errorf("Test %d: %q should be rejected", i+1, data)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package datatype
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+
+	"github.com/dustin/go-humanize"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	PayerBucketOwner = "BucketOwner"
+	PayerRequester   = "Requester"
+
+	MaxRequestPaymentConfigurationSize = 1 * humanize.KiByte
+)
+
+type RequestPaymentConfiguration struct {
+	XMLName xml.Name `xml:"RequestPaymentConfiguration"`
+	Payer   string   `xml:"Payer"`
+}
+
+func ParseRequestPaymentConfig(reader io.Reader) (*RequestPaymentConfiguration, error) {
+	config := new(RequestPaymentConfiguration)
+	buffer, err := ioutil.ReadAll(reader)
+	if err != nil {
+		helper.Logger.Error("Unable to read request payment config body:", err)
+		return nil, err
+	}
+	if len(buffer) > MaxRequestPaymentConfigurationSize {
+		return nil, ErrEntityTooLarge
+	}
+	err = xml.Unmarshal(buffer, config)
+	if err != nil {
+		helper.Logger.Error("Unable to parse request payment config XML body:", err)
+		return nil, ErrMalformedXML
+	}
+	if config.Payer != PayerBucketOwner && config.Payer != PayerRequester {
+		return nil, ErrMalformedXML
+	}
+	return config, nil
+}
@@ -0,0 +1,37 @@
+package datatype
+
+import "encoding/xml"
+
+type StsCredentials struct {
+	AccessKeyId     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+	Expiration      string `xml:"Expiration"`
+}
+
+type AssumedRoleUser struct {
+	Arn           string `xml:"Arn"`
+	AssumedRoleId string `xml:"AssumedRoleId"`
+}
+
+type StsResponseMetadata struct {
+	RequestId string `xml:"RequestId"`
+}
+
+type AssumeRoleResponse struct {
+	XMLName xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ AssumeRoleResponse"`
+	Result  struct {
+		Credentials      StsCredentials  `xml:"Credentials"`
+		AssumedRoleUser  AssumedRoleUser `xml:"AssumedRoleUser"`
+		PackedPolicySize int             `xml:"PackedPolicySize,omitempty"`
+	} `xml:"AssumeRoleResult"`
+	ResponseMetadata StsResponseMetadata `xml:"ResponseMetadata"`
+}
+
+type GetSessionTokenResponse struct {
+	XMLName xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ GetSessionTokenResponse"`
+	Result  struct {
+		Credentials StsCredentials `xml:"Credentials"`
+	} `xml:"GetSessionTokenResult"`
+	ResponseMetadata StsResponseMetadata `xml:"ResponseMetadata"`
+}
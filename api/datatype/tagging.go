@@ -0,0 +1,82 @@
+package datatype
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dustin/go-humanize"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	MaxBucketTagsCount   = 50
+	MaxTagKeyLength      = 128
+	MaxTagValueLength    = 256
+	MaxBucketTaggingSize = 20 * humanize.KiByte
+	ReservedTagKeyPrefix = "aws:"
+)
+
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging" json:"-"`
+	TagSet  []Tag    `xml:"TagSet>Tag"`
+}
+
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// Reference:https://docs.aws.amazon.com/awsaccountbilling/latest/aboutv2/allocation-tag-restrictions.html
+func (t *Tagging) Validate() error {
+	if len(t.TagSet) > MaxBucketTagsCount {
+		return ErrInvalidTag
+	}
+	keys := make(map[string]bool)
+	for _, tag := range t.TagSet {
+		keyLength := utf8.RuneCountInString(tag.Key)
+		if keyLength == 0 || keyLength > MaxTagKeyLength ||
+			utf8.RuneCountInString(tag.Value) > MaxTagValueLength {
+			return ErrInvalidTag
+		}
+		if !tagPattern.MatchString(tag.Key) || !tagPattern.MatchString(tag.Value) {
+			return ErrInvalidTag
+		}
+		if strings.HasPrefix(tag.Key, ReservedTagKeyPrefix) {
+			return ErrInvalidTag
+		}
+		if keys[tag.Key] {
+			return ErrInvalidTag
+		}
+		keys[tag.Key] = true
+	}
+	return nil
+}
+
+func ParseTagging(reader io.Reader) (*Tagging, error) {
+	tagging := new(Tagging)
+	taggingBuffer, err := ioutil.ReadAll(reader)
+	if err != nil {
+		helper.Logger.Error("Unable to read tagging body:", err)
+		return nil, err
+	}
+	if len(taggingBuffer) > MaxBucketTaggingSize {
+		return nil, ErrEntityTooLarge
+	}
+	err = xml.Unmarshal(taggingBuffer, tagging)
+	if err != nil {
+		helper.Logger.Error("Unable to parse tagging XML body:", err)
+		return nil, ErrMalformedXML
+	}
+	err = tagging.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return tagging, nil
+}
@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/journeymidnight/yig/api/datatype"
 	. "github.com/journeymidnight/yig/error"
 	"github.com/journeymidnight/yig/helper"
 	"github.com/journeymidnight/yig/log"
@@ -51,6 +52,10 @@ type commonHeaderHandler struct {
 
 func (h commonHeaderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Accept-Ranges", "bytes")
+	bucket := getRequestContext(r).BucketInfo
+	if bucket != nil && bucket.RequestPayer == datatype.PayerRequester && isRequesterCharged(r) {
+		w.Header().Set("x-amz-request-charged", "requester")
+	}
 	h.handler.ServeHTTP(w, r)
 }
 
@@ -170,10 +175,8 @@ func ignoreNotImplementedObjectResources(req *http.Request) bool {
 
 // List of not implemented bucket queries
 var notImplementedBucketResourceNames = map[string]bool{
-	"notification":   true,
-	"replication":    true,
-	"tagging":        true,
-	"requestPayment": true,
+	"notification": true,
+	"replication":  true,
 }
 
 // List of not implemented object queries
@@ -21,12 +21,14 @@ import (
 	"encoding/base64"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	. "github.com/journeymidnight/yig/api/datatype"
 	"github.com/journeymidnight/yig/crypto"
 	. "github.com/journeymidnight/yig/error"
 	"github.com/journeymidnight/yig/helper"
+	meta "github.com/journeymidnight/yig/meta/types"
 )
 
 // validates location constraint from the request body.
@@ -173,9 +175,107 @@ func parseSseHeader(header http.Header) (request SseRequest, err error) {
 	return
 }
 
+// parses additional checksum of data, sent in x-amz-checksum-* header, or
+// declared in x-amz-trailer and sent in trailer of aws-chunked body
+func parseChecksumRequest(header http.Header, awsChunked bool) (request ChecksumRequest, err error) {
+	for _, algorithm := range ChecksumAlgorithms {
+		value := header.Get(ChecksumHeader(algorithm))
+		if value == "" {
+			continue
+		}
+		if request.Algorithm != "" {
+			return request, ErrMultipleChecksums
+		}
+		if !IsValidChecksum(algorithm, value) {
+			return request, ErrInvalidChecksum
+		}
+		request.Algorithm = algorithm
+		request.Value = value
+	}
+	if trailer := header.Get("X-Amz-Trailer"); trailer != "" {
+		if request.Algorithm != "" {
+			return request, ErrMultipleChecksums
+		}
+		// trailer could only be sent in aws-chunked body
+		if !awsChunked {
+			return request, ErrInvalidChecksum
+		}
+		for _, algorithm := range ChecksumAlgorithms {
+			if http.CanonicalHeaderKey(trailer) == ChecksumHeader(algorithm) {
+				request.Algorithm = algorithm
+				request.Trailing = true
+			}
+		}
+		if request.Algorithm == "" {
+			return request, ErrInvalidChecksumAlgorithm
+		}
+	}
+	if sdkAlgorithm := header.Get("X-Amz-Sdk-Checksum-Algorithm"); sdkAlgorithm != "" {
+		sdkAlgorithm = strings.ToUpper(sdkAlgorithm)
+		if NewChecksumHash(sdkAlgorithm) == nil {
+			return request, ErrInvalidChecksumAlgorithm
+		}
+		if request.Algorithm != sdkAlgorithm {
+			return request, ErrMissingChecksum
+		}
+	}
+	return request, nil
+}
+
+// parses x-amz-checksum-algorithm of multipart upload
+func parseChecksumAlgorithm(header http.Header) (string, error) {
+	algorithm := strings.ToUpper(header.Get("X-Amz-Checksum-Algorithm"))
+	if algorithm != "" && NewChecksumHash(algorithm) == nil {
+		return "", ErrInvalidChecksumAlgorithm
+	}
+	return algorithm, nil
+}
+
 // Suffix matcher string matches suffix in a platform specific way.
 // For example on windows since its case insensitive we are supposed
 // to do case insensitive checks.
 func hasSuffix(s string, suffix string) bool {
 	return strings.HasSuffix(s, suffix)
 }
+
+// parsePartNumber parses `partNumber` of GET/HEAD object requests and returns
+// the byte range of the part. If object is not uploaded by multipart, only
+// part 1 exists as the whole object, the range is nil if object is empty.
+func parsePartNumber(partNumberString string, object *meta.Object) (hrange *HttpRange, err error) {
+	partNumber, err := strconv.Atoi(partNumberString)
+	if err != nil || partNumber < 1 || isMaxPartID(partNumber) {
+		return nil, ErrInvalidPartNumber
+	}
+	if len(object.Parts) == 0 {
+		if partNumber != 1 {
+			return nil, ErrPartNumberNotSatisfiable
+		}
+		if object.Size == 0 {
+			return nil, nil
+		}
+		return &HttpRange{
+			OffsetBegin:  0,
+			OffsetEnd:    object.Size - 1,
+			ResourceSize: object.Size,
+		}, nil
+	}
+	part, ok := object.Parts[partNumber]
+	if !ok {
+		return nil, ErrPartNumberNotSatisfiable
+	}
+	return &HttpRange{
+		OffsetBegin:  part.Offset,
+		OffsetEnd:    part.Offset + part.Size - 1,
+		ResourceSize: object.Size,
+	}, nil
+}
+
+// setPartsCountHeader sets x-amz-mp-parts-count for requests with partNumber,
+// object not uploaded by multipart has 1 part
+func setPartsCountHeader(w http.ResponseWriter, object *meta.Object) {
+	count := len(object.Parts)
+	if count == 0 {
+		count = 1
+	}
+	w.Header().Set("x-amz-mp-parts-count", strconv.Itoa(count))
+}
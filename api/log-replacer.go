@@ -20,8 +20,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/journeymidnight/yig/api/datatype"
 	"github.com/journeymidnight/yig/helper"
-	"github.com/journeymidnight/yig/signature"
 )
 
 const (
@@ -29,7 +29,7 @@ const (
 		"{object_size} {requester_id} {project_id} {remote_addr} {http_x_real_ip} {request_length} {server_cost} " +
 		"{request_time} {http_status} {error_code} {body_bytes_sent} {http_referer} {http_user_agent}"
 
-	BillingLogFormat = "{is_private_subnet} {storage_class} {target_storage_class} {bucket_logging} {cdn_request}"
+	BillingLogFormat = "{is_private_subnet} {storage_class} {target_storage_class} {bucket_logging} {cdn_request} {restore_tier}"
 )
 
 // Replacer is a type which can replace placeholder
@@ -200,8 +200,7 @@ func (r *replacer) getSubstitution(key string) string {
 		return strconv.FormatInt(objectSize, 10)
 	case "{requester_id}":
 		requester_id := "-"
-		credential, err := signature.IsReqAuthenticated(r.request)
-		if err == nil {
+		if credential, ok := getRequestCredential(r.request); ok {
 			requester_id = credential.UserId
 		}
 		return requester_id
@@ -210,6 +209,12 @@ func (r *replacer) getSubstitution(key string) string {
 		if bucketInfo == nil {
 			return "-"
 		}
+		// usage of requester pays buckets is billed to the requester
+		if bucketInfo.RequestPayer == datatype.PayerRequester && isRequesterCharged(r.request) {
+			if credential, ok := getRequestCredential(r.request); ok {
+				return credential.UserId
+			}
+		}
 		return bucketInfo.OwnerId
 	case "{remote_addr}":
 		return r.request.RemoteAddr
@@ -285,6 +290,12 @@ func (r *replacer) getSubstitution(key string) string {
 			}
 		}
 		return strconv.FormatBool(false)
+	case "{restore_tier}":
+		// only set when a new restoration of glacier object is submitted
+		if r.responseRecorder.restoreTier == "" {
+			return "-"
+		}
+		return r.responseRecorder.restoreTier
 	case "{cdn_request}":
 		// TODO: change to go plugin
 		var judgeFunc JudgeCdnRequest
@@ -21,6 +21,7 @@ import (
 	"strings"
 	"time"
 
+	. "github.com/journeymidnight/yig/api/datatype"
 	. "github.com/journeymidnight/yig/error"
 	meta "github.com/journeymidnight/yig/meta/types"
 )
@@ -146,6 +147,29 @@ func checkPreconditions(header http.Header, object *meta.Object) error {
 	return nil
 }
 
+// Parses the preconditions for PutObject, CopyObject destination and
+// CompleteMultipartUpload, returns nil if none is set.
+// Preconditions supported are:
+//  If-Match
+//  If-None-Match, only `*`
+func parseWriteCondition(header http.Header) (*WriteCondition, error) {
+	ifMatchETagHeader := header.Get("If-Match")
+	ifNoneMatchETagHeader := header.Get("If-None-Match")
+	if ifMatchETagHeader == "" && ifNoneMatchETagHeader == "" {
+		return nil, nil
+	}
+	if ifMatchETagHeader != "" && ifNoneMatchETagHeader != "" {
+		return nil, ErrInvalidPrecondition
+	}
+	if ifNoneMatchETagHeader != "" && ifNoneMatchETagHeader != "*" {
+		return nil, ErrNotImplemented
+	}
+	return &WriteCondition{
+		IfMatch:     canonicalizeETag(ifMatchETagHeader),
+		IfNoneMatch: ifNoneMatchETagHeader == "*",
+	}, nil
+}
+
 // canonicalizeETag returns ETag with leading and trailing double-quotes removed,
 // if any present
 func canonicalizeETag(etag string) string {
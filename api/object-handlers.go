@@ -27,14 +27,18 @@ import (
 	"github.com/journeymidnight/yig/helper"
 	"github.com/journeymidnight/yig/iam/common"
 	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/s3select"
 	"github.com/journeymidnight/yig/signature"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // supportedGetReqParams - supported request parameters for GET presigned request.
@@ -150,10 +154,14 @@ type GetObjectResponseWriter struct {
 	hrange      *HttpRange
 	statusCode  int
 	version     string
+	// boundary and length of multipart/byteranges body if multiple
+	// ranges are requested
+	boundary      string
+	contentLength int64
 }
 
 func newGetObjectResponseWriter(w http.ResponseWriter, r *http.Request, object *meta.Object, hrange *HttpRange, statusCode int, version string) *GetObjectResponseWriter {
-	return &GetObjectResponseWriter{false, w, r, object, hrange, statusCode, version}
+	return &GetObjectResponseWriter{false, w, r, object, hrange, statusCode, version, "", 0}
 }
 
 func (o *GetObjectResponseWriter) Write(p []byte) (int, error) {
@@ -165,7 +173,11 @@ func (o *GetObjectResponseWriter) Write(p []byte) (int, error) {
 		setGetRespHeaders(o.w, o.r.URL.Query())
 		// Set headers on the first write.
 		// Set standard object headers.
-		SetObjectHeaders(o.w, o.object, o.hrange, o.statusCode)
+		if o.boundary != "" {
+			SetByteRangesHeaders(o.w, o.object, o.boundary, o.contentLength)
+		} else {
+			SetObjectHeaders(o.w, o.object, o.hrange, o.statusCode)
+		}
 
 		o.dataWritten = true
 	}
@@ -180,6 +192,61 @@ func (o *GetObjectResponseWriter) Write(p []byte) (int, error) {
 	return n, err
 }
 
+// getObjectRanges writes multiple ranges of object as multipart/byteranges,
+// see https://tools.ietf.org/html/rfc7233#appendix-A
+func (api ObjectAPIHandlers) getObjectRanges(w http.ResponseWriter, r *http.Request, object *meta.Object,
+	hranges []*HttpRange, version string, sseRequest SseRequest) {
+
+	logger := getRequestContext(r).Logger
+	writer := newGetObjectResponseWriter(w, r, object, nil, http.StatusPartialContent, version)
+	mw := multipart.NewWriter(writer)
+	writer.boundary = mw.Boundary()
+	writer.contentLength = byteRangesLength(object, hranges, writer.boundary)
+
+	rangeWriter := func(hrange *HttpRange) (io.Writer, error) {
+		return mw.CreatePart(byteRangeHeader(object, hrange))
+	}
+	err := api.ObjectAPI.GetObjectRanges(object, hranges, rangeWriter, sseRequest)
+	if err == nil {
+		err = mw.Close()
+	}
+	if err != nil {
+		logger.Error("GetObjectRanges error:", err)
+		// Error response only if no data has been written to client yet
+		if !writer.dataWritten {
+			WriteErrorResponse(w, r, err)
+		}
+	}
+}
+
+func byteRangeHeader(object *meta.Object, hrange *HttpRange) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Type":  {object.ContentType},
+		"Content-Range": {hrange.String()},
+	}
+}
+
+// byteRangesLength returns length of multipart/byteranges body of `hranges`
+func byteRangesLength(object *meta.Object, hranges []*HttpRange, boundary string) int64 {
+	var counter countingWriter
+	mw := multipart.NewWriter(&counter)
+	mw.SetBoundary(boundary)
+	var length int64
+	for _, hrange := range hranges {
+		mw.CreatePart(byteRangeHeader(object, hrange))
+		length += hrange.GetLength()
+	}
+	mw.Close()
+	return length + int64(counter)
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
 // GetObjectHandler - GET Object
 // ----------
 // This implementation of the GET operation retrieves object. To use GET,
@@ -234,6 +301,7 @@ func (api ObjectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 			WriteErrorResponse(w, r, ErrInvalidGlacierObject)
 			return
 		}
+		SetRestoreHeader(w, freezer)
 		object.Etag = freezer.Etag
 		object.Size = freezer.Size
 		object.Parts = freezer.Parts
@@ -242,14 +310,28 @@ func (api ObjectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 		object.ObjectId = freezer.ObjectId
 	}
 
-	// Get request range.
-	var hrange *HttpRange
+	// Get request ranges.
+	var hranges []*HttpRange
 	rangeHeader := r.Header.Get("Range")
-	if rangeHeader != "" {
-		if hrange, err = ParseRequestRange(rangeHeader, object.Size); err != nil {
-			// Handle only ErrorInvalidRange
+	if partNumber := r.URL.Query().Get("partNumber"); partNumber != "" {
+		if rangeHeader != "" {
+			WriteErrorResponse(w, r, ErrRangeWithPartNumber)
+			return
+		}
+		partRange, err := parsePartNumber(partNumber, object)
+		if err != nil {
+			WriteErrorResponse(w, r, err)
+			return
+		}
+		if partRange != nil {
+			hranges = []*HttpRange{partRange}
+		}
+		setPartsCountHeader(w, object)
+	} else if rangeHeader != "" {
+		if hranges, err = ParseRequestRanges(rangeHeader, object.Size); err != nil {
+			// Handle only ErrorInvalidRange and ErrorTooManyRanges
 			// Ignore other parse error and treat it as regular Get request like Amazon S3.
-			if err == ErrorInvalidRange {
+			if err == ErrorInvalidRange || err == ErrorTooManyRanges {
 				WriteErrorResponse(w, r, ErrInvalidRange)
 				return
 			}
@@ -258,6 +340,10 @@ func (api ObjectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 			logger.Error("Invalid request range", err)
 		}
 	}
+	var hrange *HttpRange
+	if len(hranges) > 0 {
+		hrange = hranges[0]
+	}
 
 	// Validate pre-conditions if any.
 	if err = checkPreconditions(r.Header, object); err != nil {
@@ -310,9 +396,16 @@ func (api ObjectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 			r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5"))
 	}
 
+	SetChecksumHeader(w, r, object, hrange)
+
 	//ResponseRecorder
 	w.(*ResponseRecorder).operationName = "GetObject"
 
+	if len(hranges) > 1 {
+		api.getObjectRanges(w, r, object, hranges, version, sseRequest)
+		return
+	}
+
 	// Reads the object at startOffset and writes to mw.
 	if err := api.ObjectAPI.GetObject(object, startOffset, length, writer, sseRequest); err != nil {
 		logger.Error("GetObject error:", err)
@@ -361,16 +454,16 @@ func (api ObjectAPIHandlers) HeadObjectHandler(w http.ResponseWriter, r *http.Re
 	}
 
 	if object.StorageClass == meta.ObjectStorageClassGlacier {
-		freezer, err := api.ObjectAPI.GetFreezerStatus(object.BucketName, object.Name, version)
+		freezer, err := api.ObjectAPI.GetFreezer(object.BucketName, object.Name, version)
 		if err != nil && err != ErrNoSuchKey {
 			logger.Error("Unable to get restore object status", object.BucketName, object.Name, version,
 				"error:", err)
 			WriteErrorResponse(w, r, err)
+			return
 		}
-		if freezer.Status == meta.ObjectHasRestored {
-			w.Header().Set("x-amz-restore", "ongoing-request='true'")
-		} else {
-			w.Header().Set("x-amz-restore", "ongoing-request='false'")
+		// no x-amz-restore header if restoration is never requested
+		if err == nil {
+			SetRestoreHeader(w, freezer)
 		}
 	}
 
@@ -381,12 +474,23 @@ func (api ObjectAPIHandlers) HeadObjectHandler(w http.ResponseWriter, r *http.Re
 	}
 
 	// Get request range.
+	var partRange *HttpRange
 	rangeHeader := r.Header.Get("Range")
-	if rangeHeader != "" {
-		if _, err = ParseRequestRange(rangeHeader, object.Size); err != nil {
-			// Handle only ErrorInvalidRange
+	if partNumber := r.URL.Query().Get("partNumber"); partNumber != "" {
+		if rangeHeader != "" {
+			WriteErrorResponse(w, r, ErrRangeWithPartNumber)
+			return
+		}
+		if partRange, err = parsePartNumber(partNumber, object); err != nil {
+			WriteErrorResponse(w, r, err)
+			return
+		}
+		setPartsCountHeader(w, object)
+	} else if rangeHeader != "" {
+		if _, err = ParseRequestRanges(rangeHeader, object.Size); err != nil {
+			// Handle only ErrorInvalidRange and ErrorTooManyRanges
 			// Ignore other parse error and treat it as regular Get request like Amazon S3.
-			if err == ErrorInvalidRange {
+			if err == ErrorInvalidRange || err == ErrorTooManyRanges {
 				WriteErrorResponse(w, r, ErrInvalidRange)
 				return
 			}
@@ -432,12 +536,104 @@ func (api ObjectAPIHandlers) HeadObjectHandler(w http.ResponseWriter, r *http.Re
 			r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5"))
 	}
 
+	SetChecksumHeader(w, r, object, partRange)
+
 	//ResponseRecorder
 	w.(*ResponseRecorder).operationName = "HeadObject"
 
 	// Successful response.
 	// Set standard object headers.
-	SetObjectHeaders(w, object, nil, http.StatusOK)
+	SetObjectHeaders(w, object, partRange, http.StatusOK)
+}
+
+// SelectObjectContentHandler - POST Object?select&select-type=2
+// ----------
+// This operation filters the contents of a CSV or JSON object with a SQL
+// expression, results are sent in AWS event stream messages.
+func (api ObjectAPIHandlers) SelectObjectContentHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := getRequestContext(r)
+	logger := ctx.Logger
+	var credential common.Credential
+	var err error
+	if credential, err = checkRequestAuth(r, policy.GetObjectAction); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	request, err := s3select.ParseRequest(r.Body)
+	if err != nil {
+		logger.Error("Unable to parse select request:", err)
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	query, err := s3select.NewQuery(request)
+	if err != nil {
+		logger.Error("Unable to parse select expression:", err)
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	version := r.URL.Query().Get("versionId")
+	object, err := api.ObjectAPI.GetObjectInfoByCtx(ctx, version, credential)
+	if err != nil {
+		logger.Error("Unable to fetch object info:", err)
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	if object.DeleteMarker {
+		w.Header().Set("x-amz-delete-marker", "true")
+		WriteErrorResponse(w, r, ErrNoSuchKey)
+		return
+	}
+	if object.StorageClass == meta.ObjectStorageClassGlacier {
+		freezer, err := api.ObjectAPI.GetFreezer(ctx.BucketName, ctx.ObjectName, version)
+		if err != nil && err != ErrNoSuchKey {
+			logger.Error("Unable to get glacier object info err:", err)
+			WriteErrorResponse(w, r, ErrInvalidRestoreInfo)
+			return
+		}
+		if err == ErrNoSuchKey || freezer.Status != meta.ObjectHasRestored {
+			logger.Error("Unable to select glacier object with no restore")
+			WriteErrorResponse(w, r, ErrInvalidGlacierObject)
+			return
+		}
+		object.Size = freezer.Size
+		object.Parts = freezer.Parts
+		object.Pool = freezer.Pool
+		object.Location = freezer.Location
+		object.ObjectId = freezer.ObjectId
+	}
+
+	sseRequest, err := parseSseHeader(r.Header)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	if len(sseRequest.CopySourceSseCustomerKey) != 0 {
+		WriteErrorResponse(w, r, ErrInvalidSseHeader)
+		return
+	}
+
+	// object data is streamed to the query through a pipe, closing the
+	// reader side stops GetObject if the query ends early, e.g. by LIMIT
+	pipeReader, pipeWriter := io.Pipe()
+	defer pipeReader.Close()
+	go func() {
+		err := api.ObjectAPI.GetObject(object, 0, object.Size, pipeWriter, sseRequest)
+		pipeWriter.CloseWithError(err)
+	}()
+
+	//ResponseRecorder
+	w.(*ResponseRecorder).operationName = "SelectObjectContent"
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	var written countingWriter
+	err = query.Execute(pipeReader, io.MultiWriter(w, &written))
+	w.(*ResponseRecorder).size += int64(written)
+	if err != nil {
+		logger.Error("SelectObjectContent error:", err)
+	}
 }
 
 // CopyObjectHandler - Copy Object
@@ -505,6 +701,13 @@ func (api ObjectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 	logger.Info("Copying object from", sourceBucketName, sourceObjectName,
 		sourceVersion, "to", targetBucketName, targetObjectName)
 
+	// the copy source is read on behalf of the session as well
+	err = checkSessionPolicy(credential, r, policy.GetObjectAction, sourceBucketName, sourceObjectName)
+	if err != nil {
+		WriteErrorResponseWithResource(w, r, err, copySource)
+		return
+	}
+
 	sourceObject, err := api.ObjectAPI.GetObjectInfo(sourceBucketName, sourceObjectName,
 		sourceVersion, credential)
 	if err != nil {
@@ -619,6 +822,8 @@ func (api ObjectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 	targetObject.Pool = sourceObject.Pool
 	targetObject.Location = sourceObject.Location
 	targetObject.StorageClass = targetStorageClass
+	targetObject.ChecksumAlgorithm = sourceObject.ChecksumAlgorithm
+	targetObject.ChecksumValue = sourceObject.ChecksumValue
 
 	directive := r.Header.Get("X-Amz-Metadata-Directive")
 	if directive == "COPY" || directive == "" {
@@ -647,8 +852,15 @@ func (api ObjectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	condition, err := parseWriteCondition(r.Header)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
 	// Create the object.
-	result, err := api.ObjectAPI.CopyObject(targetObject, truelySourceObject, pipeReader, credential, sseRequest, isMetadataOnly)
+	result, err := api.ObjectAPI.CopyObject(targetObject, truelySourceObject, pipeReader, credential, sseRequest,
+		isMetadataOnly, condition)
 	if err != nil {
 		logger.Error("CopyObject failed:", err)
 		WriteErrorResponse(w, r, err)
@@ -786,7 +998,6 @@ func (api ObjectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 	bucketName := vars["bucket"]
 	objectName := vars["object"]
 
-	var authType = signature.GetRequestAuthType(r)
 	var err error
 	if !isValidObjectName(objectName) {
 		WriteErrorResponse(w, r, ErrInvalidObjectName)
@@ -795,7 +1006,7 @@ func (api ObjectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 
 	// if Content-Length is unknown/missing, deny the request
 	size := r.ContentLength
-	if authType == signature.AuthTypeStreamingSigned {
+	if signature.IsAwsChunked(r) {
 		if sizeStr, ok := r.Header["X-Amz-Decoded-Content-Length"]; ok {
 			if sizeStr[0] == "" {
 				WriteErrorResponse(w, r, ErrMissingContentLength)
@@ -847,7 +1058,7 @@ func (api ObjectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	if authType == signature.AuthTypeStreamingSigned {
+	if signature.IsAwsChunked(r) {
 		if contentEncoding, ok := metadata["content-encoding"]; ok {
 			contentEncoding = signature.TrimAwsChunkedContentEncoding(contentEncoding)
 			if contentEncoding != "" {
@@ -867,6 +1078,12 @@ func (api ObjectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 		}
 	}
 
+	checksumRequest, err := parseChecksumRequest(r.Header, signature.IsAwsChunked(r))
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
 	// Parse SSE related headers
 	// Support SSE-S3 and SSE-C now
 	var sseRequest SseRequest
@@ -890,7 +1107,19 @@ func (api ObjectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	condition, err := parseWriteCondition(r.Header)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
 	credential, dataReadCloser, err := signature.VerifyUpload(r)
+	if err == nil {
+		err = checkSessionPolicy(credential, r, policy.PutObjectAction, bucketName, objectName)
+	}
+	if err == nil {
+		err = postAuthenticate(credential, r)
+	}
 	if err != nil {
 		WriteErrorResponse(w, r, err)
 		return
@@ -898,7 +1127,7 @@ func (api ObjectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 
 	var result PutObjectResult
 	result, err = api.ObjectAPI.PutObject(bucketName, objectName, credential, size, dataReadCloser,
-		metadata, acl, sseRequest, storageClass)
+		metadata, acl, sseRequest, storageClass, checksumRequest, condition)
 	if err != nil {
 		logger.Error("Unable to create object", objectName, "error:", err)
 		WriteErrorResponse(w, r, err)
@@ -911,6 +1140,9 @@ func (api ObjectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 	if result.VersionId != "" {
 		w.Header().Set("x-amz-version-id", result.VersionId)
 	}
+	if result.ChecksumAlgorithm != "" {
+		w.Header().Set(ChecksumHeader(result.ChecksumAlgorithm), result.ChecksumValue)
+	}
 	// Set SSE related headers
 	for _, headerName := range []string{
 		"X-Amz-Server-Side-Encryption",
@@ -941,7 +1173,6 @@ func (api ObjectAPIHandlers) AppendObjectHandler(w http.ResponseWriter, r *http.
 
 	logger.Info("Appending object:", bucketName, objectName)
 
-	var authType = signature.GetRequestAuthType(r)
 	var err error
 
 	if !isValidObjectName(objectName) {
@@ -963,7 +1194,7 @@ func (api ObjectAPIHandlers) AppendObjectHandler(w http.ResponseWriter, r *http.
 
 	// if Content-Length is unknown/missing, deny the request
 	size := r.ContentLength
-	if authType == signature.AuthTypeStreamingSigned {
+	if signature.IsAwsChunked(r) {
 		if sizeStr, ok := r.Header["X-Amz-Decoded-Content-Length"]; ok {
 			if sizeStr[0] == "" {
 				WriteErrorResponse(w, r, ErrMissingContentLength)
@@ -1014,7 +1245,7 @@ func (api ObjectAPIHandlers) AppendObjectHandler(w http.ResponseWriter, r *http.
 		}
 	}
 
-	if authType == signature.AuthTypeStreamingSigned {
+	if signature.IsAwsChunked(r) {
 		if contentEncoding, ok := metadata["content-encoding"]; ok {
 			contentEncoding = signature.TrimAwsChunkedContentEncoding(contentEncoding)
 			if contentEncoding != "" {
@@ -1034,8 +1265,20 @@ func (api ObjectAPIHandlers) AppendObjectHandler(w http.ResponseWriter, r *http.
 		}
 	}
 
+	checksumRequest, err := parseChecksumRequest(r.Header, signature.IsAwsChunked(r))
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
 	// Verify auth
 	credential, dataReadCloser, err := signature.VerifyUpload(r)
+	if err == nil {
+		err = checkSessionPolicy(credential, r, policy.PutObjectAction, bucketName, objectName)
+	}
+	if err == nil {
+		err = postAuthenticate(credential, r)
+	}
 	if err != nil {
 		WriteErrorResponse(w, r, err)
 		return
@@ -1091,7 +1334,7 @@ func (api ObjectAPIHandlers) AppendObjectHandler(w http.ResponseWriter, r *http.
 
 	var result AppendObjectResult
 	result, err = api.ObjectAPI.AppendObject(bucketName, objectName, credential, position, size, dataReadCloser,
-		metadata, acl, sseRequest, storageClass, objInfo)
+		metadata, acl, sseRequest, storageClass, objInfo, checksumRequest)
 	if err != nil {
 		logger.Error("Unable to append object", objectName, "error:", err)
 		WriteErrorResponse(w, r, err)
@@ -1104,6 +1347,9 @@ func (api ObjectAPIHandlers) AppendObjectHandler(w http.ResponseWriter, r *http.
 	if result.VersionId != "" {
 		w.Header().Set("x-amz-version-id", result.VersionId)
 	}
+	if result.ChecksumAlgorithm != "" {
+		w.Header().Set(ChecksumHeader(result.ChecksumAlgorithm), result.ChecksumValue)
+	}
 
 	// Set SSE related headers
 	for _, headerName := range []string{
@@ -1188,7 +1434,7 @@ func (api ObjectAPIHandlers) PutObjectAclHandler(w http.ResponseWriter, r *http.
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, ""); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -1277,6 +1523,7 @@ func (api ObjectAPIHandlers) RestoreObjectHandler(w http.ResponseWriter, r *http
 	if err != nil {
 		logger.Error("Unable to get freezer info:", err)
 		WriteErrorResponse(w, r, ErrInvalidRestoreInfo)
+		return
 	}
 
 	freezer, err := api.ObjectAPI.GetFreezerStatus(object.BucketName, object.Name, object.VersionId)
@@ -1284,12 +1531,20 @@ func (api ObjectAPIHandlers) RestoreObjectHandler(w http.ResponseWriter, r *http
 		logger.Error("Unable to get restore object status", object.BucketName, object.Name,
 			"error:", err)
 		WriteErrorResponse(w, r, err)
+		return
 	}
 	if err == ErrNoSuchKey || freezer.Name == "" {
 		status, err := meta.MatchStatusIndex("READY")
 		if err != nil {
 			logger.Error("Unable to get freezer status:", err)
 			WriteErrorResponse(w, r, ErrInvalidRestoreInfo)
+			return
+		}
+		tier, err := meta.MatchRestoreTier(info.GlacierJobParameters.Tier)
+		if err != nil {
+			logger.Error("Unable to get restore tier:", info.GlacierJobParameters.Tier)
+			WriteErrorResponse(w, r, ErrInvalidRestoreInfo)
+			return
 		}
 
 		lifeTime := info.Days
@@ -1302,24 +1557,34 @@ func (api ObjectAPIHandlers) RestoreObjectHandler(w http.ResponseWriter, r *http
 		targetFreezer.Name = object.Name
 		targetFreezer.Status = status
 		targetFreezer.LifeTime = lifeTime
+		targetFreezer.LastModifiedTime = time.Now()
+		targetFreezer.RequestTime = targetFreezer.LastModifiedTime
+		targetFreezer.Tier = tier
 		err = api.ObjectAPI.CreateFreezer(targetFreezer)
 		if err != nil {
 			logger.Error("Unable to create freezer:", err)
+			if err == ErrGlacierExpeditedRetrievalNotAvailable {
+				WriteErrorResponse(w, r, err)
+				return
+			}
 			WriteErrorResponse(w, r, ErrCreateRestoreObject)
+			return
 		}
-		logger.Info("Submit thaw request successfully")
+		logger.Info("Submit thaw request successfully, tier:", tier.ToString())
 
 		// ResponseRecorder
-		w.WriteHeader(http.StatusAccepted)
 		w.(*ResponseRecorder).operationName = "RestoreObject"
+		w.(*ResponseRecorder).restoreTier = tier.ToString()
 
 		WriteSuccessResponseWithStatus(w, nil, http.StatusAccepted)
+		return
 	}
 	if freezer.Status == meta.ObjectHasRestored {
 		err = api.ObjectAPI.UpdateFreezerDate(freezer, info.Days, true)
 		if err != nil {
 			logger.Error("Unable to Update freezer date:", err)
 			WriteErrorResponse(w, r, ErrInvalidRestoreInfo)
+			return
 		}
 
 		// ResponseRecorder
@@ -1331,6 +1596,7 @@ func (api ObjectAPIHandlers) RestoreObjectHandler(w http.ResponseWriter, r *http
 			if err != nil {
 				logger.Error("Unable to Update freezer date:", err)
 				WriteErrorResponse(w, r, ErrInvalidRestoreInfo)
+				return
 			}
 		}
 		// ResponseRecorder
@@ -1356,7 +1622,7 @@ func (api ObjectAPIHandlers) GetObjectAclHandler(w http.ResponseWriter, r *http.
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, ""); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -1414,7 +1680,7 @@ func (api ObjectAPIHandlers) NewMultipartUploadHandler(w http.ResponseWriter, r
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, policy.PutObjectAction); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -1449,8 +1715,14 @@ func (api ObjectAPIHandlers) NewMultipartUploadHandler(w http.ResponseWriter, r
 		return
 	}
 
+	checksumAlgorithm, err := parseChecksumAlgorithm(r.Header)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
 	uploadID, err := api.ObjectAPI.NewMultipartUpload(credential, bucketName, objectName,
-		metadata, acl, sseRequest, storageClass)
+		metadata, acl, sseRequest, storageClass, checksumAlgorithm)
 	if err != nil {
 		logger.Error("Unable to initiate new multipart upload id:", err)
 		WriteErrorResponse(w, r, err)
@@ -1470,6 +1742,9 @@ func (api ObjectAPIHandlers) NewMultipartUploadHandler(w http.ResponseWriter, r
 			w.Header().Set(headerName, header)
 		}
 	}
+	if checksumAlgorithm != "" {
+		w.Header().Set("X-Amz-Checksum-Algorithm", checksumAlgorithm)
+	}
 
 	//ResponseRecorder
 	w.(*ResponseRecorder).operationName = "NewMultipartUpload"
@@ -1485,8 +1760,6 @@ func (api ObjectAPIHandlers) PutObjectPartHandler(w http.ResponseWriter, r *http
 	bucketName := vars["bucket"]
 	objectName := vars["object"]
 
-	authType := signature.GetRequestAuthType(r)
-
 	var incomingMd5 string
 	// get Content-Md5 sent by client and verify if valid
 	md5Bytes, err := checkValidMD5(r.Header.Get("Content-Md5"))
@@ -1497,7 +1770,7 @@ func (api ObjectAPIHandlers) PutObjectPartHandler(w http.ResponseWriter, r *http
 	}
 
 	size := r.ContentLength
-	if authType == signature.AuthTypeStreamingSigned {
+	if signature.IsAwsChunked(r) {
 		if sizeStr, ok := r.Header["X-Amz-Decoded-Content-Length"]; ok {
 			if sizeStr[0] == "" {
 				WriteErrorResponse(w, r, ErrMissingContentLength)
@@ -1543,7 +1816,19 @@ func (api ObjectAPIHandlers) PutObjectPartHandler(w http.ResponseWriter, r *http
 		return
 	}
 
+	checksumRequest, err := parseChecksumRequest(r.Header, signature.IsAwsChunked(r))
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
 	credential, dataReadCloser, err := signature.VerifyUpload(r)
+	if err == nil {
+		err = checkSessionPolicy(credential, r, policy.PutObjectAction, bucketName, objectName)
+	}
+	if err == nil {
+		err = postAuthenticate(credential, r)
+	}
 	if err != nil {
 		WriteErrorResponse(w, r, err)
 		return
@@ -1552,7 +1837,7 @@ func (api ObjectAPIHandlers) PutObjectPartHandler(w http.ResponseWriter, r *http
 	var result PutObjectPartResult
 	// No need to verify signature, anonymous request access is already allowed.
 	result, err = api.ObjectAPI.PutObjectPart(bucketName, objectName, credential,
-		uploadID, partID, size, dataReadCloser, incomingMd5, sseRequest)
+		uploadID, partID, size, dataReadCloser, incomingMd5, sseRequest, checksumRequest)
 	if err != nil {
 		logger.Error("Unable to create object part for", objectName, "error:", err)
 		// Verify if the underlying error is signature mismatch.
@@ -1563,6 +1848,9 @@ func (api ObjectAPIHandlers) PutObjectPartHandler(w http.ResponseWriter, r *http
 	if result.ETag != "" {
 		w.Header()["ETag"] = []string{"\"" + result.ETag + "\""}
 	}
+	if result.ChecksumAlgorithm != "" {
+		w.Header().Set(ChecksumHeader(result.ChecksumAlgorithm), result.ChecksumValue)
+	}
 	switch result.SseType {
 	case "":
 		break
@@ -1607,7 +1895,7 @@ func (api ObjectAPIHandlers) CopyObjectPartHandler(w http.ResponseWriter, r *htt
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, policy.PutObjectAction); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -1671,6 +1959,13 @@ func (api ObjectAPIHandlers) CopyObjectPartHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
+	// the copy source is read on behalf of the session as well
+	err = checkSessionPolicy(credential, r, policy.GetObjectAction, sourceBucketName, sourceObjectName)
+	if err != nil {
+		WriteErrorResponseWithResource(w, r, err, copySource)
+		return
+	}
+
 	sourceObject, err := api.ObjectAPI.GetObjectInfo(sourceBucketName, sourceObjectName,
 		sourceVersion, credential)
 	if err != nil {
@@ -1809,7 +2104,7 @@ func (api ObjectAPIHandlers) AbortMultipartUploadHandler(w http.ResponseWriter,
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, policy.AbortMultipartUploadAction); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -1848,7 +2143,7 @@ func (api ObjectAPIHandlers) ListObjectPartsHandler(w http.ResponseWriter, r *ht
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, policy.ListMultipartUploadPartsAction); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -1896,7 +2191,7 @@ func (api ObjectAPIHandlers) CompleteMultipartUploadHandler(w http.ResponseWrite
 		break
 	case signature.AuthTypePresignedV4, signature.AuthTypeSignedV4,
 		signature.AuthTypePresignedV2, signature.AuthTypeSignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, policy.PutObjectAction); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -1935,9 +2230,15 @@ func (api ObjectAPIHandlers) CompleteMultipartUploadHandler(w http.ResponseWrite
 		completeParts = append(completeParts, part)
 	}
 
+	condition, err := parseWriteCondition(r.Header)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
 	var result CompleteMultipartResult
 	result, err = api.ObjectAPI.CompleteMultipartUpload(credential, bucketName,
-		objectName, uploadId, completeParts)
+		objectName, uploadId, completeParts, condition)
 
 	if err != nil {
 		logger.Error("Unable to complete multipart upload:", err)
@@ -1956,6 +2257,7 @@ func (api ObjectAPIHandlers) CompleteMultipartUploadHandler(w http.ResponseWrite
 	location := GetLocation(r)
 	// Generate complete multipart response.
 	response := GenerateCompleteMultpartUploadResponse(bucketName, objectName, location, result.ETag)
+	response.Checksums.Set(result.ChecksumAlgorithm, result.ChecksumValue)
 	encodedSuccessResponse, err := xmlFormat(response)
 	if err != nil {
 		logger.Error("Unable to parse CompleteMultipartUpload response:", err)
@@ -2008,7 +2310,7 @@ func (api ObjectAPIHandlers) DeleteObjectHandler(w http.ResponseWriter, r *http.
 		break
 	case signature.AuthTypeSignedV4, signature.AuthTypePresignedV4,
 		signature.AuthTypeSignedV2, signature.AuthTypePresignedV2:
-		if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		if credential, err = authenticateRequest(r, policy.DeleteObjectAction); err != nil {
 			WriteErrorResponse(w, r, err)
 			return
 		}
@@ -2083,6 +2385,9 @@ func (api ObjectAPIHandlers) PostObjectHandler(w http.ResponseWriter, r *http.Re
 		credential, err = signature.DoesPolicySignatureMatchV2(formValues)
 	case signature.PostPolicyV4:
 		credential, err = signature.DoesPolicySignatureMatchV4(formValues)
+		if err == nil {
+			err = checkSessionPolicy(credential, r, policy.PutObjectAction, bucketName, objectName)
+		}
 	case signature.PostPolicyAnonymous:
 		if bucket.ACL.CannedAcl != "public-read-write" {
 			WriteErrorResponse(w, r, ErrAccessDenied)
@@ -2092,6 +2397,9 @@ func (api ObjectAPIHandlers) PostObjectHandler(w http.ResponseWriter, r *http.Re
 		WriteErrorResponse(w, r, ErrMalformedPOSTRequest)
 		return
 	}
+	if err == nil {
+		err = postAuthenticate(credential, r)
+	}
 	if err != nil {
 		WriteErrorResponse(w, r, err)
 		return
@@ -2127,6 +2435,12 @@ func (api ObjectAPIHandlers) PostObjectHandler(w http.ResponseWriter, r *http.Re
 		WriteErrorResponse(w, r, err)
 		return
 	}
+
+	checksumRequest, err := parseChecksumRequest(headerfiedFormValues, false)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
 	if sseRequest.Type == "" {
 		if configuration, ok := api.ObjectAPI.CheckBucketEncryption(bucketName); ok {
 			if configuration.SSEAlgorithm == crypto.SSEAlgorithmAES256 {
@@ -2143,12 +2457,15 @@ func (api ObjectAPIHandlers) PostObjectHandler(w http.ResponseWriter, r *http.Re
 	}
 
 	result, err := api.ObjectAPI.PutObject(bucketName, objectName, credential, -1, fileBody,
-		metadata, acl, sseRequest, storageClass)
+		metadata, acl, sseRequest, storageClass, checksumRequest, nil)
 	if err != nil {
 		logger.Error("Unable to create object", objectName, "error:", err)
 		WriteErrorResponse(w, r, err)
 		return
 	}
+	if result.ChecksumAlgorithm != "" {
+		w.Header().Set(ChecksumHeader(result.ChecksumAlgorithm), result.ChecksumValue)
+	}
 	if result.Md5 != "" {
 		w.Header().Set("ETag", "\""+result.Md5+"\"")
 	}
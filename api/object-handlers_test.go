@@ -0,0 +1,307 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/journeymidnight/yig/api/datatype"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/iam/common"
+	"github.com/journeymidnight/yig/log"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/signature"
+)
+
+// objectLayer serves one object from memory
+type objectLayer struct {
+	ObjectLayer
+	object *meta.Object
+	data   []byte
+}
+
+func (o *objectLayer) CheckBucketEncryption(bucket string) (*ApplyServerSideEncryptionByDefault, bool) {
+	return nil, false
+}
+
+// GetObjectInfo only finds the object in "bucket"
+func (o *objectLayer) GetObjectInfo(bucket, object, version string,
+	credential common.Credential) (*meta.Object, error) {
+
+	if bucket != o.object.BucketName || object != o.object.Name {
+		return nil, ErrNoSuchKey
+	}
+	objectCopy := *o.object
+	return &objectCopy, nil
+}
+
+func (o *objectLayer) GetObjectInfoByCtx(ctx RequestContext, version string,
+	credential common.Credential) (*meta.Object, error) {
+
+	object := *o.object
+	return &object, nil
+}
+
+func (o *objectLayer) GetObject(object *meta.Object, startOffset int64, length int64,
+	writer io.Writer, sse SseRequest) error {
+
+	_, err := writer.Write(o.data[startOffset : startOffset+length])
+	return err
+}
+
+func (o *objectLayer) GetObjectRanges(object *meta.Object, ranges []*HttpRange,
+	rangeWriter func(hrange *HttpRange) (io.Writer, error), sse SseRequest) error {
+
+	for _, hrange := range ranges {
+		w, err := rangeWriter(hrange)
+		if err != nil {
+			return err
+		}
+		err = o.GetObject(object, hrange.OffsetBegin, hrange.GetLength(), w, sse)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTestObject(size int) *objectLayer {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte('a' + i%26)
+	}
+	return &objectLayer{
+		object: &meta.Object{
+			BucketName:       "bucket",
+			Name:             "object",
+			Size:             int64(size),
+			Etag:             "etag",
+			ContentType:      "text/plain",
+			LastModifiedTime: time.Now().UTC(),
+			Type:             meta.ObjectTypeNormal,
+		},
+		data: data,
+	}
+}
+
+func newTestRequest(method, url string, header http.Header) *http.Request {
+	r := httptest.NewRequest(method, url, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	ctx := RequestContext{
+		RequestID:  "test",
+		Logger:     log.NewLogger(os.Stderr, log.ErrorLevel),
+		BucketName: "bucket",
+		ObjectName: "object",
+		BucketInfo: &meta.Bucket{Name: "bucket"},
+		AuthType:   signature.AuthTypeAnonymous,
+	}
+	return r.WithContext(context.WithValue(r.Context(), RequestContextKey, ctx))
+}
+
+func TestGetObjectRanges(t *testing.T) {
+	o := newTestObject(1000)
+	api := ObjectAPIHandlers{ObjectAPI: o}
+	hranges, err := ParseRequestRanges("bytes=0-9,500-509,-10", o.object.Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	w := &ResponseRecorder{ResponseWriter: recorder}
+	r := newTestRequest(http.MethodGet, "/bucket/object", nil)
+	api.getObjectRanges(w, r, o.object, hranges, "", SseRequest{})
+
+	response := recorder.Result()
+	if response.StatusCode != http.StatusPartialContent {
+		t.Fatal("Expected status 206, got", response.StatusCode)
+	}
+	mediaType, params, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" || params["boundary"] == "" {
+		t.Fatal("Invalid Content-Type:", response.Header.Get("Content-Type"))
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	contentLength := response.Header.Get("Content-Length")
+	if contentLength != strconv.Itoa(len(body)) {
+		t.Fatal("Content-Length", contentLength, "differs from body length", len(body))
+	}
+	if !strings.HasSuffix(string(body), "\r\n--"+params["boundary"]+"--\r\n") {
+		t.Fatal("Body does not end with closing boundary")
+	}
+
+	reader := multipart.NewReader(strings.NewReader(string(body)), params["boundary"])
+	for i, hrange := range hranges {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatal("Part", i, "error:", err)
+		}
+		if part.Header.Get("Content-Range") != hrange.String() {
+			t.Error("Part", i, "expected Content-Range", hrange.String(),
+				"got", part.Header.Get("Content-Range"))
+		}
+		if part.Header.Get("Content-Type") != o.object.ContentType {
+			t.Error("Part", i, "expected Content-Type", o.object.ContentType,
+				"got", part.Header.Get("Content-Type"))
+		}
+		data, _ := ioutil.ReadAll(part)
+		if string(data) != string(o.data[hrange.OffsetBegin:hrange.OffsetEnd+1]) {
+			t.Error("Part", i, "has wrong data", string(data))
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Error("Expected no more parts, got", err)
+	}
+}
+
+// serve handles request to the object by `handler`
+func serve(handler http.HandlerFunc, r *http.Request) *http.Response {
+	recorder := httptest.NewRecorder()
+	handler(&ResponseRecorder{ResponseWriter: recorder}, r)
+	return recorder.Result()
+}
+
+func TestGetObjectPartNumber(t *testing.T) {
+	multipart := newTestObject(300)
+	multipart.object.Parts = map[int]*meta.Part{
+		1: {PartNumber: 1, Size: 200, Offset: 0},
+		2: {PartNumber: 2, Size: 100, Offset: 200},
+	}
+	testCases := []struct {
+		object       *objectLayer
+		partNumber   string
+		status       int
+		contentRange string
+		partsCount   string
+	}{
+		{newTestObject(100), "1", http.StatusPartialContent, "bytes 0-99/100", "1"},
+		{newTestObject(100), "2", http.StatusRequestedRangeNotSatisfiable, "", ""},
+		{newTestObject(100), "0", http.StatusBadRequest, "", ""},
+		{newTestObject(0), "1", http.StatusOK, "", "1"},
+		{multipart, "1", http.StatusPartialContent, "bytes 0-199/300", "2"},
+		{multipart, "2", http.StatusPartialContent, "bytes 200-299/300", "2"},
+		{multipart, "3", http.StatusRequestedRangeNotSatisfiable, "", ""},
+	}
+	for i, testCase := range testCases {
+		api := ObjectAPIHandlers{ObjectAPI: testCase.object}
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			handler := api.GetObjectHandler
+			if method == http.MethodHead {
+				handler = api.HeadObjectHandler
+			}
+			r := newTestRequest(method, "/bucket/object?partNumber="+testCase.partNumber, nil)
+			response := serve(handler, r)
+			if response.StatusCode != testCase.status {
+				t.Errorf("Test %d %s: expected status %d, got %d", i+1, method,
+					testCase.status, response.StatusCode)
+				continue
+			}
+			if testCase.status >= 300 {
+				continue
+			}
+			if got := response.Header.Get("Content-Range"); got != testCase.contentRange {
+				t.Errorf("Test %d %s: expected Content-Range %q, got %q", i+1, method,
+					testCase.contentRange, got)
+			}
+			if got := response.Header.Get("x-amz-mp-parts-count"); got != testCase.partsCount {
+				t.Errorf("Test %d %s: expected x-amz-mp-parts-count %q, got %q", i+1, method,
+					testCase.partsCount, got)
+			}
+			if method == http.MethodHead {
+				continue
+			}
+			body, _ := ioutil.ReadAll(response.Body)
+			if testCase.contentRange != "" {
+				var begin, end, size int64
+				fmt.Sscanf(testCase.contentRange, "bytes %d-%d/%d", &begin, &end, &size)
+				if string(body) != string(testCase.object.data[begin:end+1]) {
+					t.Errorf("Test %d: wrong body %q", i+1, body)
+				}
+			}
+		}
+	}
+}
+
+func TestGetObjectConditions(t *testing.T) {
+	o := newTestObject(100)
+	api := ObjectAPIHandlers{ObjectAPI: o}
+	testCases := []struct {
+		header string
+		value  string
+		status int
+	}{
+		{"If-Match", `"etag"`, http.StatusOK},
+		{"If-Match", "etag", http.StatusOK},
+		{"If-Match", `"other"`, http.StatusPreconditionFailed},
+		{"If-None-Match", `"other"`, http.StatusOK},
+		{"If-None-Match", `"etag"`, http.StatusNotModified},
+		{"If-None-Match", "etag", http.StatusNotModified},
+	}
+	for i, testCase := range testCases {
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			handler := api.GetObjectHandler
+			if method == http.MethodHead {
+				handler = api.HeadObjectHandler
+			}
+			r := newTestRequest(method, "/bucket/object",
+				http.Header{testCase.header: {testCase.value}})
+			response := serve(handler, r)
+			if response.StatusCode != testCase.status {
+				t.Errorf("Test %d %s: expected status %d, got %d", i+1, method,
+					testCase.status, response.StatusCode)
+			}
+		}
+	}
+}
+
+func TestWriteConditions(t *testing.T) {
+	testCases := []struct {
+		header http.Header
+		exists bool
+		etag   string
+		err    error
+	}{
+		{http.Header{}, true, "etag", nil},
+		{http.Header{"If-None-Match": {"*"}}, false, "", nil},
+		{http.Header{"If-None-Match": {"*"}}, true, "etag", ErrPreconditionFailed},
+		{http.Header{"If-Match": {`"etag"`}}, true, "etag", nil},
+		{http.Header{"If-Match": {`"other"`}}, true, "etag", ErrPreconditionFailed},
+		{http.Header{"If-Match": {`"etag"`}}, false, "", ErrNoSuchKey},
+		{http.Header{"If-Match": {"*"}}, true, "etag", nil},
+		{http.Header{"If-Match": {"*"}}, false, "", ErrNoSuchKey},
+	}
+	for i, testCase := range testCases {
+		condition, err := parseWriteCondition(testCase.header)
+		if err != nil {
+			t.Errorf("Test %d: parse error %v", i+1, err)
+			continue
+		}
+		if condition == nil {
+			if len(testCase.header) != 0 {
+				t.Errorf("Test %d: condition is not parsed", i+1)
+			}
+			continue
+		}
+		if err = condition.Check(testCase.exists, testCase.etag); err != testCase.err {
+			t.Errorf("Test %d: expected error %v, got %v", i+1, testCase.err, err)
+		}
+	}
+
+	for i, header := range []http.Header{
+		{"If-Match": {`"etag"`}, "If-None-Match": {"*"}},
+		{"If-None-Match": {`"etag"`}},
+	} {
+		if _, err := parseWriteCondition(header); err == nil {
+			t.Errorf("Test %d: %v should be rejected", i+1, header)
+		}
+	}
+}
@@ -69,20 +69,41 @@ type ObjectLayer interface {
 	DeleteBucketEncryption(bucket *meta.Bucket) error
 	CheckBucketEncryption(bucket string) (*datatype.ApplyServerSideEncryptionByDefault, bool)
 
+	// Tagging operations
+	SetBucketTagging(bucket *meta.Bucket, tagging datatype.Tagging) error
+	GetBucketTagging(bucket string) (datatype.Tagging, error)
+	DeleteBucketTagging(bucket *meta.Bucket) error
+
+	// Request payment operations
+	SetBucketRequestPayment(bucket *meta.Bucket, config datatype.RequestPaymentConfiguration) error
+	GetBucketRequestPayment(bucket string) (datatype.RequestPaymentConfiguration, error)
+
+	// Inventory operations
+	SetBucketInventory(bucket *meta.Bucket, config datatype.InventoryConfiguration) error
+	GetBucketInventory(bucket, id string) (datatype.InventoryConfiguration, error)
+	DeleteBucketInventory(bucket, id string) error
+	ListBucketInventory(bucket, continuationToken string) (datatype.ListInventoryConfigurationsResult, error)
+
 	// Object operations.
 	GetObject(object *meta.Object, startOffset int64, length int64, writer io.Writer,
 		sse datatype.SseRequest) (err error)
+	GetObjectRanges(object *meta.Object, ranges []*datatype.HttpRange,
+		rangeWriter func(hrange *datatype.HttpRange) (io.Writer, error),
+		sse datatype.SseRequest) (err error)
 	GetObjectInfo(bucket, object, version string, credential common.Credential) (objInfo *meta.Object, err error)
 	GetObjectInfoByCtx(ctx RequestContext, version string, credential common.Credential) (objInfo *meta.Object, err error)
 	PutObject(bucket, object string, credential common.Credential, size int64, data io.ReadCloser,
 		metadata map[string]string, acl datatype.Acl,
-		sse datatype.SseRequest, storageClass meta.StorageClass) (result datatype.PutObjectResult, err error)
+		sse datatype.SseRequest, storageClass meta.StorageClass,
+		checksum datatype.ChecksumRequest, condition *datatype.WriteCondition) (result datatype.PutObjectResult, err error)
 	AppendObject(bucket, object string, credential common.Credential, offset uint64, size int64, data io.ReadCloser,
 		metadata map[string]string, acl datatype.Acl,
-		sse datatype.SseRequest, storageClass meta.StorageClass, objInfo *meta.Object) (result datatype.AppendObjectResult, err error)
+		sse datatype.SseRequest, storageClass meta.StorageClass, objInfo *meta.Object,
+		checksum datatype.ChecksumRequest) (result datatype.AppendObjectResult, err error)
 
 	CopyObject(targetObject *meta.Object, sourceObject *meta.Object, source io.Reader, credential common.Credential,
-		sseRequest datatype.SseRequest, isMetadataOnly bool) (result datatype.PutObjectResult, err error)
+		sseRequest datatype.SseRequest, isMetadataOnly bool,
+		condition *datatype.WriteCondition) (result datatype.PutObjectResult, err error)
 	RenameObject(targetObject *meta.Object, sourceObject string, credential common.Credential) (result datatype.RenameObjectResult, err error)
 	PutObjectMeta(bucket *meta.Bucket, targetObject *meta.Object, credential common.Credential) (err error)
 	SetObjectAcl(bucket string, object string, version string, policy datatype.AccessControlPolicy,
@@ -97,10 +118,11 @@ type ObjectLayer interface {
 		request datatype.ListUploadsRequest) (result datatype.ListMultipartUploadsResponse, err error)
 	NewMultipartUpload(credential common.Credential, bucket, object string,
 		metadata map[string]string, acl datatype.Acl,
-		sse datatype.SseRequest, storageClass meta.StorageClass) (uploadID string, err error)
+		sse datatype.SseRequest, storageClass meta.StorageClass,
+		checksumAlgorithm string) (uploadID string, err error)
 	PutObjectPart(bucket, object string, credential common.Credential, uploadID string, partID int,
 		size int64, data io.ReadCloser, md5Hex string,
-		sse datatype.SseRequest) (result datatype.PutObjectPartResult, err error)
+		sse datatype.SseRequest, checksum datatype.ChecksumRequest) (result datatype.PutObjectPartResult, err error)
 	CopyObjectPart(bucketName, objectName, uploadId string, partId int, size int64, data io.Reader,
 		credential common.Credential, sse datatype.SseRequest) (result datatype.PutObjectResult,
 		err error)
@@ -108,7 +130,8 @@ type ObjectLayer interface {
 		request datatype.ListPartsRequest) (result datatype.ListPartsResponse, err error)
 	AbortMultipartUpload(credential common.Credential, bucket, object, uploadID string) error
 	CompleteMultipartUpload(credential common.Credential, bucket, object, uploadID string,
-		uploadedParts []meta.CompletePart) (result datatype.CompleteMultipartResult, err error)
+		uploadedParts []meta.CompletePart,
+		condition *datatype.WriteCondition) (result datatype.CompleteMultipartResult, err error)
 
 	// Freezer operations.
 	GetFreezer(bucketName string, objectName string, version string) (freezer *meta.Freezer, err error)
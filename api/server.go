@@ -1,6 +1,9 @@
 package api
 
 import (
+	"context"
+	"time"
+
 	"github.com/journeymidnight/yig/helper"
 	"github.com/journeymidnight/yig/log"
 	"github.com/journeymidnight/yig/meta/types"
@@ -32,6 +35,25 @@ type Server struct {
 	Server *http.Server
 }
 
-func (s *Server) Stop() {
+// Stop rejects new requests and waits for in-flight requests to complete,
+// then shuts down the http server. Connections are closed forcibly if
+// they're still active after `drainTimeout`.
+func (s *Server) Stop(drainTimeout time.Duration) {
+	helper.Logger.Info("Stopping server, drain timeout:", drainTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	// new connections are still accepted so clients get 503 instead of
+	// connection refused, keep-alive connections are closed after responses
+	s.Server.SetKeepAlivesEnabled(false)
+	requestDrainer.startDraining()
+	if err := requestDrainer.wait(ctx); err != nil {
+		helper.Logger.Warn("In-flight requests not completed before drain timeout")
+	}
+
+	if err := s.Server.Shutdown(ctx); err != nil {
+		helper.Logger.Warn("Shutdown server error:", err, "close connections forcibly")
+		s.Server.Close()
+	}
 	helper.Logger.Info("Server stopped")
 }
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/meta"
+)
+
+// drainer counts in-flight requests, once draining starts new requests
+// are rejected and `idle` is closed when all in-flight requests complete.
+type drainer struct {
+	lock     sync.Mutex
+	draining bool
+	inflight int64
+	idle     chan struct{}
+}
+
+var requestDrainer = &drainer{
+	idle: make(chan struct{}),
+}
+
+func (d *drainer) enter() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.draining {
+		return false
+	}
+	d.inflight++
+	return true
+}
+
+func (d *drainer) leave() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.inflight--
+	if d.draining && d.inflight == 0 {
+		close(d.idle)
+	}
+}
+
+func (d *drainer) startDraining() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.draining {
+		return
+	}
+	d.draining = true
+	if d.inflight == 0 {
+		close(d.idle)
+	}
+}
+
+func (d *drainer) isDraining() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.draining
+}
+
+// wait blocks until all in-flight requests complete or ctx is done
+func (d *drainer) wait(ctx context.Context) error {
+	select {
+	case <-d.idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type shutdownHandler struct {
+	handler http.Handler
+}
+
+func (h shutdownHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !requestDrainer.enter() {
+		w.Header().Set("Connection", "close")
+		WriteErrorResponse(w, r, ErrServiceUnavailable)
+		return
+	}
+	defer requestDrainer.leave()
+	h.handler.ServeHTTP(w, r)
+}
+
+// SetShutdownHandler tracks in-flight requests so they could complete
+// on shutdown, and rejects new requests with 503 once the server is draining.
+func SetShutdownHandler(h http.Handler, _ *meta.Meta) http.Handler {
+	return shutdownHandler{h}
+}
+
+// IsDraining returns true once the server starts to shut down
+func IsDraining() bool {
+	return requestDrainer.isDraining()
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/api/datatype/policy"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam"
+	"github.com/journeymidnight/yig/iam/common"
+	"github.com/journeymidnight/yig/signature"
+)
+
+const (
+	stsMinDurationSeconds = helper.MIN_STS_DURATION_SECONDS
+	// AssumeRole defaults to 1 hour and at most 12 hours
+	assumeRoleDefaultDurationSeconds = 3600
+	assumeRoleMaxDurationSeconds     = 43200
+	// GetSessionToken defaults to 12 hours and at most 36 hours
+	sessionTokenDefaultDurationSeconds = 43200
+	sessionTokenMaxDurationSeconds     = 129600
+
+	maxSessionPolicySize = 2048
+)
+
+var roleSessionNameRegexp = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+
+// StsHandler - handles STS actions posted to "/", currently AssumeRole and
+// GetSessionToken. Requests must be signed by a long-term access key.
+func (api ObjectAPIHandlers) StsHandler(w http.ResponseWriter, r *http.Request) {
+	logger := ContextLogger(r)
+	if !iam.StsEnabled() {
+		WriteErrorResponse(w, r, ErrStsNotEnabled)
+		return
+	}
+
+	credential, err := signature.IsStsReqAuthenticated(r)
+	if err == nil {
+		err = postAuthenticate(credential, r)
+	}
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	if credential.IsTemporary() {
+		WriteErrorResponse(w, r, ErrTemporaryCredentialNotAllowed)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		logger.Error("Unable to parse STS request form:", err)
+		WriteErrorResponse(w, r, ErrMalformedPOSTRequest)
+		return
+	}
+
+	switch r.Form.Get("Action") {
+	case "AssumeRole":
+		api.assumeRole(w, r, credential)
+	case "GetSessionToken":
+		api.getSessionToken(w, r, credential)
+	default:
+		WriteErrorResponse(w, r, ErrInvalidStsAction)
+	}
+}
+
+// AssumeRole - yig has no IAM roles, the temporary credential always acts as
+// the caller, RoleArn and RoleSessionName only show up in AssumedRoleUser.
+// Use an inline session policy to narrow down its permissions.
+func (api ObjectAPIHandlers) assumeRole(w http.ResponseWriter, r *http.Request,
+	credential common.Credential) {
+
+	roleArn := r.Form.Get("RoleArn")
+	if roleArn == "" {
+		WriteErrorResponse(w, r, ErrMissingRoleArn)
+		return
+	}
+	sessionName := r.Form.Get("RoleSessionName")
+	if !roleSessionNameRegexp.MatchString(sessionName) {
+		WriteErrorResponse(w, r, ErrInvalidRoleSessionName)
+		return
+	}
+	duration, err := parseStsDuration(r.Form.Get("DurationSeconds"),
+		assumeRoleDefaultDurationSeconds, assumeRoleMaxDurationSeconds)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	sessionPolicy, err := parseStsSessionPolicy(r.Form.Get("Policy"))
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	session, err := iam.NewSessionCredential(credential, duration, sessionPolicy)
+	if err != nil {
+		ContextLogger(r).Error("Unable to issue session credential:", err)
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	roleName := roleArn[strings.LastIndex(roleArn, "/")+1:]
+	var response AssumeRoleResponse
+	response.Result.Credentials = generateStsCredentials(session)
+	response.Result.AssumedRoleUser = AssumedRoleUser{
+		Arn:           "arn:aws:sts::" + credential.UserId + ":assumed-role/" + roleName + "/" + sessionName,
+		AssumedRoleId: credential.UserId + ":" + sessionName,
+	}
+	response.ResponseMetadata.RequestId = getRequestContext(r).RequestID
+	encodedSuccessResponse := EncodeResponse(response)
+	// ResponseRecorder
+	w.(*ResponseRecorder).operationName = "AssumeRole"
+	WriteSuccessResponse(w, encodedSuccessResponse)
+}
+
+func (api ObjectAPIHandlers) getSessionToken(w http.ResponseWriter, r *http.Request,
+	credential common.Credential) {
+
+	duration, err := parseStsDuration(r.Form.Get("DurationSeconds"),
+		sessionTokenDefaultDurationSeconds, sessionTokenMaxDurationSeconds)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	sessionPolicy, err := parseStsSessionPolicy(r.Form.Get("Policy"))
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	session, err := iam.NewSessionCredential(credential, duration, sessionPolicy)
+	if err != nil {
+		ContextLogger(r).Error("Unable to issue session credential:", err)
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	var response GetSessionTokenResponse
+	response.Result.Credentials = generateStsCredentials(session)
+	response.ResponseMetadata.RequestId = getRequestContext(r).RequestID
+	encodedSuccessResponse := EncodeResponse(response)
+	// ResponseRecorder
+	w.(*ResponseRecorder).operationName = "GetSessionToken"
+	WriteSuccessResponse(w, encodedSuccessResponse)
+}
+
+func parseStsDuration(value string, defaultSeconds, maxSeconds int64) (time.Duration, error) {
+	if maxSeconds > helper.CONFIG.StsMaxDurationSeconds {
+		maxSeconds = helper.CONFIG.StsMaxDurationSeconds
+	}
+	seconds := defaultSeconds
+	if value != "" {
+		var err error
+		seconds, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, ErrInvalidDurationSeconds
+		}
+	} else if seconds > maxSeconds {
+		seconds = maxSeconds
+	}
+	if seconds < stsMinDurationSeconds || seconds > maxSeconds {
+		return 0, ErrInvalidDurationSeconds
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+func parseStsSessionPolicy(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if len(value) > maxSessionPolicySize {
+		return "", ErrMalformedSessionPolicy
+	}
+	if _, err := policy.ParseSessionPolicy([]byte(value)); err != nil {
+		helper.Logger.Info("Invalid session policy:", err)
+		return "", ErrMalformedSessionPolicy
+	}
+	return value, nil
+}
+
+func generateStsCredentials(session common.Credential) StsCredentials {
+	return StsCredentials{
+		AccessKeyId:     session.AccessKeyID,
+		SecretAccessKey: session.SecretAccessKey,
+		SessionToken:    session.SessionToken,
+		Expiration:      session.Expiration.Format(timeFormatAMZ),
+	}
+}
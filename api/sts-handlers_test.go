@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/journeymidnight/aws-sdk-go/aws/credentials"
+	v4 "github.com/journeymidnight/aws-sdk-go/aws/signer/v4"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam"
+	"github.com/journeymidnight/yig/iam/cache"
+	"github.com/journeymidnight/yig/iam/common"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/signature"
+)
+
+const readOnlySessionPolicy = `{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Effect": "Allow",
+		"Action": ["s3:GetObject"],
+		"Resource": ["arn:aws:s3:::bucket/*"]
+	}]
+}`
+
+const writeOnlySessionPolicy = `{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Effect": "Allow",
+		"Action": ["s3:PutObject"],
+		"Resource": ["arn:aws:s3:::bucket/*"]
+	}]
+}`
+
+// newSessionCredential issues a temporary credential with `sessionPolicy`
+// for a parent key known to IAM cache
+func newSessionCredential(t *testing.T, sessionPolicy string) common.Credential {
+	helper.CONFIG.StsKey = "sts key of test, 0123456789abcdefghijklmnopqrstuvwxyz"
+	helper.CONFIG.StsMaxDurationSeconds = 43200
+	parent := common.Credential{
+		UserId:          "user",
+		DisplayName:     "user",
+		AccessKeyID:     "PARENTACCESSKEY",
+		SecretAccessKey: "parent secret key",
+	}
+	cache.InitializeIamCache()
+	cache.IamCache.Set(parent.AccessKeyID, parent)
+	credential, err := iam.NewSessionCredential(parent, time.Hour, sessionPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return credential
+}
+
+// newSignedRequest returns a request to bucket/object signed by `credential`
+func newSignedRequest(t *testing.T, method, url string, header http.Header,
+	credential common.Credential) *http.Request {
+
+	r := newTestRequest(method, url, header)
+	body := []byte("data")
+	r.Body = httptest.NewRequest(method, url, bytes.NewReader(body)).Body
+	r.ContentLength = int64(len(body))
+	signer := v4.NewSigner(credentials.NewStaticCredentials(credential.AccessKeyID,
+		credential.SecretAccessKey, credential.SessionToken))
+	_, err := signer.Sign(r, bytes.NewReader(body), "s3", "us-east-1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := getRequestContext(r)
+	ctx.AuthType = signature.GetRequestAuthType(r)
+	ctx.BucketInfo = &meta.Bucket{Name: "bucket", OwnerId: "user"}
+	r = r.WithContext(context.WithValue(r.Context(), RequestContextKey, ctx))
+	return mux.SetURLVars(r, map[string]string{"bucket": "bucket", "object": "object"})
+}
+
+// A session whose policy doesn't allow writing must not write anything,
+// neither could it copy objects its policy doesn't allow reading
+func TestSessionPolicyDeniesWrites(t *testing.T) {
+	readOnly := newSessionCredential(t, readOnlySessionPolicy)
+	writeOnly := newSessionCredential(t, writeOnlySessionPolicy)
+	o := newTestObject(100)
+	api := ObjectAPIHandlers{ObjectAPI: o}
+	copySource := http.Header{"X-Amz-Copy-Source": {"/other-bucket/object"}}
+
+	testCases := []struct {
+		name       string
+		handler    http.HandlerFunc
+		method     string
+		url        string
+		header     http.Header
+		credential common.Credential
+	}{
+		{"PutObject", api.PutObjectHandler, http.MethodPut,
+			"/bucket/object", nil, readOnly},
+		{"AppendObject", api.AppendObjectHandler, http.MethodPost,
+			"/bucket/object?append&position=0", nil, readOnly},
+		{"UploadPart", api.PutObjectPartHandler, http.MethodPut,
+			"/bucket/object?partNumber=1&uploadId=upload", nil, readOnly},
+		{"CopyObject", api.CopyObjectHandler, http.MethodPut,
+			"/bucket/object", copySource, writeOnly},
+		{"UploadPartCopy", api.CopyObjectPartHandler, http.MethodPut,
+			"/bucket/object?partNumber=1&uploadId=upload", copySource, writeOnly},
+	}
+	for _, testCase := range testCases {
+		r := newSignedRequest(t, testCase.method, testCase.url, testCase.header,
+			testCase.credential)
+		response := serve(testCase.handler, r)
+		if response.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: expected status %d, got %d", testCase.name,
+				http.StatusForbidden, response.StatusCode)
+		}
+	}
+}
+
+func TestParseStsDuration(t *testing.T) {
+	testCases := []struct {
+		value            string
+		defaultSeconds   int64
+		maxSeconds       int64
+		configMaxSeconds int64
+		expected         time.Duration
+		err              error
+	}{
+		{"", 3600, 43200, 43200, time.Hour, nil},
+		{"900", 3600, 43200, 43200, 900 * time.Second, nil},
+		{"43200", 3600, 43200, 43200, 43200 * time.Second, nil},
+		{"899", 3600, 43200, 43200, 0, ErrInvalidDurationSeconds},
+		{"43201", 3600, 43200, 43200, 0, ErrInvalidDurationSeconds},
+		{"-1", 3600, 43200, 43200, 0, ErrInvalidDurationSeconds},
+		{"1h", 3600, 43200, 43200, 0, ErrInvalidDurationSeconds},
+		// limited by sts_max_duration_seconds
+		{"", 43200, 129600, 7200, 2 * time.Hour, nil},
+		{"7200", 3600, 43200, 7200, 2 * time.Hour, nil},
+		{"7201", 3600, 43200, 7200, 0, ErrInvalidDurationSeconds},
+	}
+	for i, testCase := range testCases {
+		helper.CONFIG.StsMaxDurationSeconds = testCase.configMaxSeconds
+		duration, err := parseStsDuration(testCase.value, testCase.defaultSeconds,
+			testCase.maxSeconds)
+		if err != testCase.err || duration != testCase.expected {
+			t.Errorf("Test %d: expected %v %v, got %v %v", i+1, testCase.expected,
+				testCase.err, duration, err)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+	"github.com/journeymidnight/yig/meta"
+	"github.com/journeymidnight/yig/redis"
+)
+
+const (
+	// bytes transferred are accounted in batches to reduce calls to redis
+	throttleBatchSize = 256 << 10
+	// idle token buckets are dropped after this
+	throttleBucketIdleTime = 10 * time.Minute
+)
+
+// Operation classes
+const (
+	OperationClassRead    = "read"
+	OperationClassWrite   = "write"
+	OperationClassList    = "list"
+	OperationClassDelete  = "delete"
+	OperationClassOther   = "other"
+	OperationClassDefault = "default"
+)
+
+type throttleContextKeyType string
+
+const throttleContextKey throttleContextKeyType = "Throttle"
+
+// requestThrottle keeps what's needed to apply limits of the access key
+// once the request is authenticated, see throttleAccessKey
+type requestThrottle struct {
+	throttler *throttler
+	class     string
+	accessKey string
+	// nil if the request transfers nothing limited
+	upload, download *throttledStream
+}
+
+type throttleHandler struct {
+	handler     http.Handler
+	concurrency chan struct{}
+	throttler   *throttler
+}
+
+// SetThrottleHandler limits the number of concurrent requests by
+// ConcurrentRequestLimit, and applies token bucket limits on request rate
+// and bandwidth keyed by bucket and operation class. Limits keyed by access
+// key are applied by throttleAccessKey after the signature is verified,
+// otherwise anyone could exhaust the limits of others by forged requests.
+func SetThrottleHandler(h http.Handler, _ *meta.Meta) http.Handler {
+	t := &throttler{
+		buckets: make(map[string]*tokenBucket),
+	}
+	go t.dropIdleBuckets()
+	return throttleHandler{
+		handler:     h,
+		concurrency: make(chan struct{}, helper.CONFIG.ConcurrentRequestLimit),
+		throttler:   t,
+	}
+}
+
+func (h throttleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := ContextLogger(r)
+	select {
+	case h.concurrency <- struct{}{}:
+		defer func() { <-h.concurrency }()
+	default:
+		logger.Warn("Concurrent request limit exceeded:", helper.CONFIG.ConcurrentRequestLimit)
+		WriteErrorResponse(w, r, ErrSlowDown)
+		return
+	}
+
+	config := helper.CONFIG.Throttle
+	if !config.Enable {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	ctx := getRequestContext(r)
+	class := getOperationClass(r, ctx)
+	burst := float64(config.BurstSeconds)
+
+	// request rate
+	if ctx.BucketName != "" {
+		key := "bucket:" + ctx.BucketName + ":" + class
+		if rate := requestRateOfClass(config.BucketRequestRate, class); rate > 0 &&
+			!h.throttler.allow(key, float64(rate), maxFloat(float64(rate)*burst, 1)) {
+			logger.Warn("Request rate exceeded:", key, rate)
+			WriteErrorResponse(w, r, ErrSlowDown)
+			return
+		}
+	}
+
+	// bandwidth, streams are set up even without bucket limits if there're
+	// access key limits to be added
+	var upload, download []bandwidthLimit
+	if ctx.BucketName != "" {
+		upload = appendBandwidthLimit(upload, "bucket:"+ctx.BucketName+":upload", config.BucketUploadRate)
+		download = appendBandwidthLimit(download, "bucket:"+ctx.BucketName+":download", config.BucketDownloadRate)
+	}
+	t := &requestThrottle{
+		throttler: h.throttler,
+		class:     class,
+	}
+	if r.Method == http.MethodPut || r.Method == http.MethodPost {
+		if (len(upload) > 0 || config.AccessKeyUploadRate > 0) && r.Body != nil {
+			t.upload = &throttledStream{throttler: h.throttler, limits: upload, burst: burst}
+			if !t.upload.allow() {
+				logger.Warn("Upload bandwidth exceeded")
+				WriteErrorResponse(w, r, ErrSlowDown)
+				return
+			}
+			r.Body = &throttledReader{ReadCloser: r.Body, stream: t.upload}
+		}
+	} else if len(download) > 0 || config.AccessKeyDownloadRate > 0 {
+		t.download = &throttledStream{throttler: h.throttler, limits: download, burst: burst}
+		if !t.download.allow() {
+			logger.Warn("Download bandwidth exceeded")
+			WriteErrorResponse(w, r, ErrSlowDown)
+			return
+		}
+		// handlers rely on w being a *ResponseRecorder, so wrap the writer inside it
+		if recorder, ok := w.(*ResponseRecorder); ok {
+			recorder.ResponseWriter = &throttledWriter{ResponseWriter: recorder.ResponseWriter, stream: t.download}
+		}
+	}
+
+	h.handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), throttleContextKey, t)))
+}
+
+// throttleAccessKey applies limits keyed by the access key of `c`, it
+// should be called once the request is authenticated.
+func throttleAccessKey(c common.Credential, r *http.Request) error {
+	t, ok := r.Context().Value(throttleContextKey).(*requestThrottle)
+	if !ok || c.AccessKeyID == "" || t.accessKey != "" {
+		return nil
+	}
+	t.accessKey = c.AccessKeyID
+	logger := ContextLogger(r)
+	config := helper.CONFIG.Throttle
+	burst := float64(config.BurstSeconds)
+
+	key := "ak:" + c.AccessKeyID + ":" + t.class
+	if rate := requestRateOfClass(config.AccessKeyRequestRate, t.class); rate > 0 &&
+		!t.throttler.allow(key, float64(rate), maxFloat(float64(rate)*burst, 1)) {
+		logger.Warn("Request rate exceeded:", key, rate)
+		return ErrSlowDown
+	}
+	if t.upload != nil {
+		t.upload.limits = appendBandwidthLimit(t.upload.limits,
+			"ak:"+c.AccessKeyID+":upload", config.AccessKeyUploadRate)
+		if !t.upload.allow() {
+			logger.Warn("Upload bandwidth exceeded:", c.AccessKeyID)
+			return ErrSlowDown
+		}
+	}
+	if t.download != nil {
+		t.download.limits = appendBandwidthLimit(t.download.limits,
+			"ak:"+c.AccessKeyID+":download", config.AccessKeyDownloadRate)
+		if !t.download.allow() {
+			logger.Warn("Download bandwidth exceeded:", c.AccessKeyID)
+			return ErrSlowDown
+		}
+	}
+	return nil
+}
+
+func getOperationClass(r *http.Request, ctx RequestContext) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if ctx.ObjectName == "" {
+			return OperationClassList
+		}
+		return OperationClassRead
+	case http.MethodPut, http.MethodPost:
+		return OperationClassWrite
+	case http.MethodDelete:
+		return OperationClassDelete
+	}
+	return OperationClassOther
+}
+
+func requestRateOfClass(rates map[string]int64, class string) int64 {
+	if rate, ok := rates[class]; ok {
+		return rate
+	}
+	return rates[OperationClassDefault]
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+type bandwidthLimit struct {
+	key  string
+	rate float64
+}
+
+func appendBandwidthLimit(limits []bandwidthLimit, key string, rate int64) []bandwidthLimit {
+	if rate <= 0 {
+		return limits
+	}
+	return append(limits, bandwidthLimit{key: key, rate: float64(rate)})
+}
+
+// throttledStream accounts bytes transferred to bandwidth limits, and sleeps
+// if the limits are exceeded.
+type throttledStream struct {
+	throttler *throttler
+	limits    []bandwidthLimit
+	burst     float64
+	pending   int64
+}
+
+// allow returns false if the stream needs to wait longer than
+// max_wait_seconds before transferring anything
+func (s *throttledStream) allow() bool {
+	maxWait := time.Duration(helper.CONFIG.Throttle.MaxWaitSeconds) * time.Second
+	return s.wait() <= maxWait
+}
+
+// wait returns how long the stream should wait before transferring more
+func (s *throttledStream) wait() (wait time.Duration) {
+	for _, l := range s.limits {
+		if w := s.throttler.reserve(l.key, l.rate, l.rate*s.burst, 0); w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+func (s *throttledStream) account(n int, flush bool) {
+	s.pending += int64(n)
+	if s.pending == 0 || (s.pending < throttleBatchSize && !flush) {
+		return
+	}
+	var wait time.Duration
+	for _, l := range s.limits {
+		if w := s.throttler.reserve(l.key, l.rate, l.rate*s.burst, float64(s.pending)); w > wait {
+			wait = w
+		}
+	}
+	s.pending = 0
+	time.Sleep(wait)
+}
+
+type throttledReader struct {
+	io.ReadCloser
+	stream *throttledStream
+}
+
+func (r *throttledReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	r.stream.account(n, err != nil)
+	return
+}
+
+type throttledWriter struct {
+	http.ResponseWriter
+	stream *throttledStream
+}
+
+func (w *throttledWriter) Write(p []byte) (n int, err error) {
+	n, err = w.ResponseWriter.Write(p)
+	w.stream.account(n, false)
+	return
+}
+
+func (w *throttledWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastUpdate time.Time
+}
+
+// throttler keeps token buckets in redis if redis is enabled, so the limits
+// apply to the whole cluster. Local buckets are used if redis is not enabled
+// or unavailable.
+type throttler struct {
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func (t *throttler) allow(key string, rate, burst float64) bool {
+	taken, _ := t.take(key, rate, burst, 1, true)
+	return taken
+}
+
+// reserve takes n tokens and returns how long the caller should wait to
+// pay off the debt
+func (t *throttler) reserve(key string, rate, burst, n float64) time.Duration {
+	_, tokens := t.take(key, rate, burst, n, false)
+	if tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tokens / rate * float64(time.Second))
+}
+
+func (t *throttler) take(key string, rate, burst, n float64, onlyIfEnough bool) (taken bool, tokens float64) {
+	if redis.Pool() != nil {
+		taken, tokens, err := redis.TakeTokens(key, rate, burst, n, onlyIfEnough)
+		if err == nil {
+			return taken, tokens
+		}
+		helper.Logger.Warn("Take tokens from redis error, fallback to local:", err)
+	}
+
+	now := time.Now()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	b, ok := t.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, lastUpdate: now}
+		t.buckets[key] = b
+	}
+	if now.After(b.lastUpdate) {
+		b.tokens += now.Sub(b.lastUpdate).Seconds() * rate
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+		b.lastUpdate = now
+	}
+	if onlyIfEnough && b.tokens < n {
+		return false, b.tokens
+	}
+	b.tokens -= n
+	return true, b.tokens
+}
+
+func (t *throttler) dropIdleBuckets() {
+	for {
+		time.Sleep(throttleBucketIdleTime)
+		now := time.Now()
+		t.lock.Lock()
+		for key, b := range t.buckets {
+			if now.Sub(b.lastUpdate) > throttleBucketIdleTime {
+				delete(t.buckets, key)
+			}
+		}
+		t.lock.Unlock()
+	}
+}
@@ -1,9 +1,12 @@
 package backend
 
 import (
+	"context"
+	"errors"
+	"io"
+
 	"github.com/journeymidnight/yig/helper"
 	"github.com/journeymidnight/yig/log"
-	"io"
 )
 
 const (
@@ -16,6 +19,10 @@ type Usage struct {
 	UsedSpacePercent int // range 0 ~ 100
 }
 
+// ErrUsageUnknown is returned by GetUsage of healthy clusters whose used
+// space could not be told, e.g. remote S3 endpoints
+var ErrUsageUnknown = errors.New("used space of cluster is unknown")
+
 type Cluster interface {
 	// get cluster ID
 	ID() string
@@ -34,6 +41,17 @@ type Cluster interface {
 	Remove(poolName, objectName string) error
 }
 
+// ContextCluster is implemented by clusters whose calls could be canceled,
+// calls taking too long are canceled by `ctx` instead of left running.
+// Readers returned by GetReaderWithContext are valid until `ctx` is canceled.
+type ContextCluster interface {
+	Cluster
+	GetUsageWithContext(ctx context.Context) (Usage, error)
+	GetReaderWithContext(ctx context.Context, poolName, objectName string,
+		offset int64, length uint64) (io.ReadCloser, error)
+	RemoveWithContext(ctx context.Context, poolName, objectName string) error
+}
+
 // Backend plugins should implement this interface
 type Plugin interface {
 	// initialize backend cluster handlers,
@@ -0,0 +1,408 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cep21/circuit"
+	"github.com/journeymidnight/yig/circuitbreak"
+	"github.com/journeymidnight/yig/helper"
+)
+
+// calls abandoned on timeout but not returned yet, new calls to the cluster
+// fail fast once there are so many of them
+const MAX_ABANDONED_CALLS = 64
+
+var (
+	ErrClusterTimeout = errors.New("cluster made no progress before timeout")
+)
+
+// CircuitCluster wraps a Cluster with a circuit breaker, so calls to a
+// cluster failing or hanging fail fast once the circuit is open.
+// Missing objects and errors reading the data to put are not counted as
+// failures of the cluster.
+type CircuitCluster struct {
+	Cluster
+	Circuit *circuit.Circuit
+	// calls abandoned on timeout and not returned yet
+	abandoned int64
+}
+
+func NewCircuitCluster(cluster Cluster) *CircuitCluster {
+	c := &CircuitCluster{
+		Cluster: cluster,
+		Circuit: circuitbreak.NewClusterCircuit(cluster.ID()),
+	}
+	helper.SubscribeConfig("circuit of cluster "+cluster.ID(), []string{
+		"cluster_circuit_close_sleep_window", "cluster_circuit_close_required_count",
+		"cluster_circuit_open_threshold"},
+		func(_ *helper.Config) error {
+			circuitbreak.ReconfigureClusterCircuit(c.Circuit)
+			return nil
+		})
+	return c
+}
+
+// UncommittedError is returned by Put if no data has been read yet when it
+// fails, so the same data could be put to another cluster.
+type UncommittedError struct {
+	Err error
+}
+
+func (e UncommittedError) Error() string {
+	return e.Err.Error()
+}
+
+// IsUncommitted returns true if the failed Put could be retried on another cluster
+func IsUncommitted(err error) bool {
+	_, ok := err.(UncommittedError)
+	return ok
+}
+
+func (c *CircuitCluster) IsOpen() bool {
+	return c.Circuit.IsOpen()
+}
+
+func execTimeout() time.Duration {
+	return time.Duration(helper.CONFIG.ClusterCircuitExecTimeout) * time.Second
+}
+
+// IsNotFound returns true if the object is not found in the cluster, ceph
+// returns "ret=-2" and remote S3 clusters return NoSuchKey
+func IsNotFound(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "ret=-2") ||
+		strings.Contains(err.Error(), "NoSuchKey"))
+}
+
+// execute runs `f` in the circuit, errors `f` returns with notFailure true
+// are not counted as failures of the cluster
+func (c *CircuitCluster) execute(f func() (err error, notFailure bool)) error {
+	var ignored error
+	err := c.Circuit.Execute(context.Background(), func(ctx context.Context) error {
+		err, notFailure := f()
+		if err != nil && notFailure {
+			ignored = err
+			return circuit.SimpleBadRequest{Err: err}
+		}
+		return err
+	}, nil)
+	if ignored != nil {
+		return ignored
+	}
+	return err
+}
+
+// withTimeout runs `f` in another goroutine and returns ErrClusterTimeout if
+// it doesn't return in time, `ctx` passed to `f` is canceled then and
+// `abandon` is called with the result of `f` once it returns. Calls of
+// clusters not implementing ContextCluster keep running until they return,
+// so at most MAX_ABANDONED_CALLS of them are left behind.
+// Otherwise `ctx` is valid until `release` is called.
+func (c *CircuitCluster) withTimeout(f func(ctx context.Context) interface{},
+	abandon func(result interface{})) (result interface{}, release context.CancelFunc, err error) {
+
+	if atomic.LoadInt64(&c.abandoned) >= MAX_ABANDONED_CALLS {
+		return nil, func() {}, ErrClusterTimeout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan interface{}, 1)
+	go func() {
+		done <- f(ctx)
+	}()
+	timer := time.NewTimer(execTimeout())
+	defer timer.Stop()
+	select {
+	case result = <-done:
+		return result, cancel, nil
+	case <-timer.C:
+		cancel()
+		atomic.AddInt64(&c.abandoned, 1)
+		go func() {
+			r := <-done
+			atomic.AddInt64(&c.abandoned, -1)
+			if abandon != nil {
+				abandon(r)
+			}
+		}()
+		return nil, cancel, ErrClusterTimeout
+	}
+}
+
+func (c *CircuitCluster) getUsage(ctx context.Context) (Usage, error) {
+	if cc, ok := c.Cluster.(ContextCluster); ok {
+		return cc.GetUsageWithContext(ctx)
+	}
+	return c.Cluster.GetUsage()
+}
+
+func (c *CircuitCluster) getReader(ctx context.Context, poolName, objectName string,
+	offset int64, length uint64) (io.ReadCloser, error) {
+
+	if cc, ok := c.Cluster.(ContextCluster); ok {
+		return cc.GetReaderWithContext(ctx, poolName, objectName, offset, length)
+	}
+	return c.Cluster.GetReader(poolName, objectName, offset, length)
+}
+
+func (c *CircuitCluster) remove(ctx context.Context, poolName, objectName string) error {
+	if cc, ok := c.Cluster.(ContextCluster); ok {
+		return cc.RemoveWithContext(ctx, poolName, objectName)
+	}
+	return c.Cluster.Remove(poolName, objectName)
+}
+
+func (c *CircuitCluster) GetUsage() (usage Usage, err error) {
+	var unknown bool
+	err = c.execute(func() (error, bool) {
+		type result struct {
+			usage Usage
+			err   error
+		}
+		r, release, err := c.withTimeout(func(ctx context.Context) interface{} {
+			u, err := c.getUsage(ctx)
+			return result{u, err}
+		}, nil)
+		release()
+		if err != nil {
+			return err, false
+		}
+		usage = r.(result).usage
+		err = r.(result).err
+		// the cluster answered, counted as a success
+		if err == ErrUsageUnknown {
+			unknown = true
+			return nil, false
+		}
+		return err, false
+	})
+	if err == nil && unknown {
+		err = ErrUsageUnknown
+	}
+	return
+}
+
+func (c *CircuitCluster) Remove(poolName, objectName string) error {
+	return c.execute(func() (error, bool) {
+		r, release, err := c.withTimeout(func(ctx context.Context) interface{} {
+			return c.remove(ctx, poolName, objectName)
+		}, nil)
+		release()
+		if err != nil {
+			return err, false
+		}
+		if r != nil {
+			err = r.(error)
+		}
+		return err, IsNotFound(err)
+	})
+}
+
+// GetReader opens the object and reads it in one run of the circuit, which
+// ends when the reader is closed.
+func (c *CircuitCluster) GetReader(poolName, objectName string,
+	offset int64, length uint64) (io.ReadCloser, error) {
+
+	type result struct {
+		reader io.ReadCloser
+		err    error
+	}
+	opened := make(chan result, 1)
+	reader := &circuitReader{closed: make(chan error, 1)}
+	go func() {
+		sent := false
+		err := c.execute(func() (error, bool) {
+			r, release, err := c.withTimeout(func(ctx context.Context) interface{} {
+				reader, err := c.getReader(ctx, poolName, objectName, offset, length)
+				return result{reader, err}
+			}, func(r interface{}) {
+				if r.(result).err == nil {
+					r.(result).reader.Close()
+				}
+			})
+			defer release()
+			if err != nil {
+				return err, false
+			}
+			if err = r.(result).err; err != nil {
+				return err, IsNotFound(err)
+			}
+			sent = true
+			opened <- r.(result)
+			return <-reader.closed, false
+		})
+		if !sent {
+			opened <- result{nil, err}
+		}
+	}()
+	r := <-opened
+	if r.err != nil {
+		return nil, r.err
+	}
+	reader.ReadCloser = r.reader
+	return reader, nil
+}
+
+// circuitReader reports the first error reading from the cluster, or nil if
+// none, to the run of the circuit once closed
+type circuitReader struct {
+	io.ReadCloser
+	lock   sync.Mutex
+	err    error
+	closed chan error
+	once   sync.Once
+}
+
+func (r *circuitReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		r.lock.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.lock.Unlock()
+	}
+	return
+}
+
+func (r *circuitReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(func() {
+		r.lock.Lock()
+		r.closed <- r.err
+		r.lock.Unlock()
+	})
+	return err
+}
+
+func (c *CircuitCluster) Put(poolName string, data io.Reader) (oid string, size uint64, err error) {
+	oid, size, err = c.write(data, func(reader io.Reader) (string, uint64, error) {
+		return c.Cluster.Put(poolName, reader)
+	}, func(oid string) {
+		c.Cluster.Remove(poolName, oid)
+	})
+	return
+}
+
+func (c *CircuitCluster) Append(poolName, existName string, objectChunk io.Reader,
+	offset int64) (oid string, bytesWritten uint64, err error) {
+
+	return c.write(objectChunk, func(reader io.Reader) (string, uint64, error) {
+		return c.Cluster.Append(poolName, existName, reader, offset)
+	}, func(oid string) {
+		// appended data is not referenced by metadata, and will be
+		// overwritten by next append at the same offset
+	})
+}
+
+// write runs `put` with a watchdog, it fails with ErrClusterTimeout if the
+// cluster makes no progress for ClusterCircuitExecTimeout, time waiting for
+// data from `data` is not counted. `abandon` is called with the oid
+// if `put` succeeds after timeout.
+func (c *CircuitCluster) write(data io.Reader, put func(io.Reader) (string, uint64, error),
+	abandon func(oid string)) (oid string, size uint64, err error) {
+
+	reader := &progressReader{Reader: data, lastActive: time.Now()}
+	err = c.execute(func() (error, bool) {
+		type result struct {
+			oid  string
+			size uint64
+			err  error
+		}
+		if atomic.LoadInt64(&c.abandoned) >= MAX_ABANDONED_CALLS {
+			return ErrClusterTimeout, false
+		}
+		done := make(chan result, 1)
+		go func() {
+			oid, size, err := put(reader)
+			done <- result{oid, size, err}
+		}()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case r := <-done:
+				oid, size = r.oid, r.size
+				// failed to read data, not the cluster's fault
+				return r.err, r.err != nil && reader.readError() != nil
+			case <-ticker.C:
+				if reader.abandonIfStalled(execTimeout()) {
+					atomic.AddInt64(&c.abandoned, 1)
+					go func() {
+						r := <-done
+						atomic.AddInt64(&c.abandoned, -1)
+						if r.err == nil {
+							abandon(r.oid)
+						}
+					}()
+					return ErrClusterTimeout, false
+				}
+			}
+		}
+	})
+	if err != nil && reader.consumed() == 0 {
+		err = UncommittedError{Err: err}
+	}
+	return
+}
+
+var errAbandoned = errors.New("write abandoned")
+
+// progressReader tracks whether the cluster is waiting for data or working
+type progressReader struct {
+	io.Reader
+	lock       sync.Mutex
+	reading    bool
+	abandoned  bool
+	lastActive time.Time
+	n          int64
+	err        error
+}
+
+func (r *progressReader) Read(p []byte) (n int, err error) {
+	r.lock.Lock()
+	// data may be put to another cluster now
+	if r.abandoned {
+		r.lock.Unlock()
+		return 0, errAbandoned
+	}
+	r.reading = true
+	r.lock.Unlock()
+	n, err = r.Reader.Read(p)
+	r.lock.Lock()
+	r.reading = false
+	r.lastActive = time.Now()
+	r.n += int64(n)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	r.lock.Unlock()
+	return
+}
+
+// abandonIfStalled returns true if the cluster hasn't asked for more data
+// for `timeout`, and no more data could be read from `r` after that.
+func (r *progressReader) abandonIfStalled(timeout time.Duration) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.reading && time.Since(r.lastActive) > timeout {
+		r.abandoned = true
+	}
+	return r.abandoned
+}
+
+func (r *progressReader) consumed() int64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.n
+}
+
+func (r *progressReader) readError() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/log"
+	"github.com/journeymidnight/yig/mods"
+)
+
+var pluginTypeNames = map[int]string{
+	mods.IAM_PLUGIN:      "IAM",
+	mods.MQ_PLUGIN:       "message queue",
+	mods.KMS_PLUGIN:      "KMS",
+	mods.COMPRESS_PLUGIN: "compression",
+}
+
+// checkConfig implements `yig check-config [path]`, all problems found in
+// the config file are printed, returns exit code of the command
+func checkConfig(args []string) int {
+	path := helper.YIG_CONF_PATH
+	if len(args) > 0 {
+		path = args[0]
+	}
+	config, problems := helper.CheckConfig(path)
+	problems = append(problems, checkPlugins(config)...)
+	if len(problems) != 0 {
+		fmt.Printf("%d problems found in %s:\n", len(problems), path)
+		for _, problem := range problems {
+			fmt.Println("  -", problem)
+		}
+		return 1
+	}
+	fmt.Println(path, "is valid")
+	return 0
+}
+
+// checkPlugins loads enabled plugins, and checks plugins required by YIG
+// and features enabled are present. Message queue plugin is not required
+// since it's only needed where access log is sent to a message queue
+func checkPlugins(config helper.Config) (problems []string) {
+	helper.CONFIG.Plugins = config.Plugins
+	// errors of loading plugins are logged to stderr
+	helper.Logger = log.NewLogger(os.Stderr, log.ErrorLevel)
+	plugins := mods.InitialPlugins()
+
+	required := []int{mods.IAM_PLUGIN, mods.KMS_PLUGIN}
+	if config.EnableCompression {
+		required = append(required, mods.COMPRESS_PLUGIN)
+	}
+	for _, pluginType := range required {
+		found := false
+		for _, p := range plugins {
+			if p.PluginType == pluginType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("no %s plugin is enabled and loaded",
+				pluginTypeNames[pluginType]))
+		}
+	}
+	return problems
+}
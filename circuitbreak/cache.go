@@ -16,13 +16,8 @@ var (
 func NewCacheCircuit() *circuit.Circuit {
 	return circuit.NewCircuitFromConfig("YigCache", circuit.Config{
 		General: circuit.GeneralConfig{
-			OpenToClosedFactory: hystrix.CloserFactory(hystrix.ConfigureCloser{
-				SleepWindow:                  time.Duration(helper.CONFIG.CacheCircuitCloseSleepWindow) * time.Second,
-				RequiredConcurrentSuccessful: int64(helper.CONFIG.CacheCircuitCloseRequiredCount),
-			}),
-			ClosedToOpenFactory: hystrix.OpenerFactory(hystrix.ConfigureOpener{
-				RequestVolumeThreshold: int64(helper.CONFIG.CacheCircuitOpenThreshold),
-			}),
+			OpenToClosedFactory: hystrix.CloserFactory(cacheCloserConfig()),
+			ClosedToOpenFactory: hystrix.OpenerFactory(cacheOpenerConfig()),
 		},
 		Execution: circuit.ExecutionConfig{
 			Timeout:               time.Duration(helper.CONFIG.CacheCircuitExecTimeout) * time.Second,
@@ -30,3 +25,40 @@ func NewCacheCircuit() *circuit.Circuit {
 		},
 	})
 }
+
+// ReconfigureCacheCircuit applies current config to the cache circuit
+func ReconfigureCacheCircuit(c *circuit.Circuit) {
+	config := c.Config()
+	config.Execution.Timeout = time.Duration(helper.CONFIG.CacheCircuitExecTimeout) * time.Second
+	config.Execution.MaxConcurrentRequests = helper.CONFIG.CacheCircuitExecMaxConcurrent
+	c.SetConfigThreadSafe(config)
+	reconfigureHystrix(c, cacheCloserConfig(), cacheOpenerConfig())
+}
+
+func cacheCloserConfig() hystrix.ConfigureCloser {
+	return hystrix.ConfigureCloser{
+		SleepWindow:                  time.Duration(helper.CONFIG.CacheCircuitCloseSleepWindow) * time.Second,
+		RequiredConcurrentSuccessful: int64(helper.CONFIG.CacheCircuitCloseRequiredCount),
+	}
+}
+
+func cacheOpenerConfig() hystrix.ConfigureOpener {
+	return hystrix.ConfigureOpener{
+		RequestVolumeThreshold: int64(helper.CONFIG.CacheCircuitOpenThreshold),
+	}
+}
+
+// reconfigureHystrix changes thresholds of the circuit while it's in use,
+// values not set are kept
+func reconfigureHystrix(c *circuit.Circuit, closerConfig hystrix.ConfigureCloser,
+	openerConfig hystrix.ConfigureOpener) {
+
+	if closer, ok := c.OpenToClose.(*hystrix.Closer); ok {
+		closerConfig.Merge(closer.Config())
+		closer.SetConfigThreadSafe(closerConfig)
+	}
+	if opener, ok := c.ClosedToOpen.(*hystrix.Opener); ok {
+		openerConfig.Merge(opener.Config())
+		opener.SetConfigThreadSafe(openerConfig)
+	}
+}
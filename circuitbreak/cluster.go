@@ -0,0 +1,43 @@
+package circuitbreak
+
+import (
+	"time"
+
+	"github.com/cep21/circuit"
+	"github.com/cep21/circuit/closers/hystrix"
+	"github.com/journeymidnight/yig/helper"
+)
+
+// NewClusterCircuit creates the circuit of a ceph cluster. Timeout is not
+// set since transferring large objects could take long, clusters detect
+// stalled calls themselves.
+func NewClusterCircuit(fsid string) *circuit.Circuit {
+	return circuit.NewCircuitFromConfig("YigCluster:"+fsid, circuit.Config{
+		General: circuit.GeneralConfig{
+			OpenToClosedFactory: hystrix.CloserFactory(clusterCloserConfig()),
+			ClosedToOpenFactory: hystrix.OpenerFactory(clusterOpenerConfig()),
+		},
+		Execution: circuit.ExecutionConfig{
+			Timeout:               -1,
+			MaxConcurrentRequests: -1,
+		},
+	})
+}
+
+// ReconfigureClusterCircuit applies current config to circuit of a cluster
+func ReconfigureClusterCircuit(c *circuit.Circuit) {
+	reconfigureHystrix(c, clusterCloserConfig(), clusterOpenerConfig())
+}
+
+func clusterCloserConfig() hystrix.ConfigureCloser {
+	return hystrix.ConfigureCloser{
+		SleepWindow:                  time.Duration(helper.CONFIG.ClusterCircuitCloseSleepWindow) * time.Second,
+		RequiredConcurrentSuccessful: int64(helper.CONFIG.ClusterCircuitCloseRequiredCount),
+	}
+}
+
+func clusterOpenerConfig() hystrix.ConfigureOpener {
+	return hystrix.ConfigureOpener{
+		RequestVolumeThreshold: int64(helper.CONFIG.ClusterCircuitOpenThreshold),
+	}
+}
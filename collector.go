@@ -39,6 +39,7 @@ func NewMetrics(namespace string) *Metrics {
 		metrics: map[string]*prometheus.Desc{
 			"bucket_usage_byte_metric": newGlobalMetric(namespace, "bucket_usage_byte_metric", "The description of bucket_usage_byte_metric", []string{"bucket_name", "owner", "storage_class"}),
 			"user_usage_byte_metric":   newGlobalMetric(namespace, "user_usage_byte_metric", "The description of User_usage_byte_metric", []string{"owner_id", "storage_class"}),
+			"scrub_failures":           newGlobalMetric(namespace, "scrub_failures", "Number of object data found corrupted or missing by scrub", []string{"location", "reason"}),
 		},
 	}
 }
@@ -66,6 +67,15 @@ func (c *Metrics) Collect(ch chan<- prometheus.Metric) {
 			ch <- prometheus.MustNewConstMetric(c.metrics["user_usage_byte_metric"], prometheus.GaugeValue, float64(v.value), uid, v.storageClass)
 		}
 	}
+
+	scrubFailures, err := adminServer.Yig.MetaStorage.CountScrubFailures()
+	if err != nil {
+		helper.Logger.Error("Get scrub failures for prometheus failed:", err.Error())
+		return
+	}
+	for _, f := range scrubFailures {
+		ch <- prometheus.MustNewConstMetric(c.metrics["scrub_failures"], prometheus.GaugeValue, float64(f.Count), f.Location, f.Reason)
+	}
 }
 
 // Get bucket usage cache which like <key><value> = <u_b_test><STANDARD:233333>
@@ -177,6 +177,55 @@ const (
 	ErrInvalidRestoreInfo
 	ErrCreateRestoreObject
 	ErrInvalidGlacierObject
+	ErrInvalidToken
+	ErrExpiredToken
+	ErrStsNotEnabled
+	ErrInvalidStsAction
+	ErrInvalidDurationSeconds
+	ErrMalformedSessionPolicy
+	ErrMissingRoleArn
+	ErrInvalidRoleSessionName
+	ErrTemporaryCredentialNotAllowed
+	ErrSlowDown
+	ErrServiceUnavailable
+	ErrGlacierExpeditedRetrievalNotAvailable
+	ErrNoSuchCluster
+	ErrClusterAlreadyExists
+	ErrInvalidClusterWeight
+	ErrInvalidClusterStatus
+	ErrInvalidScrubMarker
+	ErrInvalidChecksumAlgorithm
+	ErrMultipleChecksums
+	ErrInvalidChecksum
+	ErrMissingChecksum
+	ErrChecksumMismatch
+	ErrChecksumAlgorithmMismatch
+	ErrInvalidPartNumber
+	ErrPartNumberNotSatisfiable
+	ErrRangeWithPartNumber
+	ErrConditionalRequestConflict
+	ErrInvalidExpressionType
+	ErrMissingRequiredParameter
+	ErrInvalidRequestParameter
+	ErrInvalidCompressionFormat
+	ErrUnsupportedSerialization
+	ErrSQLParse
+	ErrUnsupportedSqlOperation
+	ErrInvalidDataType
+	ErrCastFailed
+	ErrEvaluatorInvalidArguments
+	ErrDivisionByZero
+	ErrCSVParsing
+	ErrJSONParsing
+	ErrOverMaxRecordSize
+	ErrMissingInventoryId
+	ErrInvalidInventoryConfiguration
+	ErrMalformedInventoryConfiguration
+	ErrInvalidInventoryDestination
+	ErrNoSuchInventoryConfiguration
+	ErrTooManyInventoryConfigurations
+	ErrInvalidTag
+	ErrNoSuchTagSet
 )
 
 // error code to APIError structure, these fields carry respective
@@ -243,8 +292,8 @@ var ErrorCodeResponse = map[ApiErrorCode]ApiErrorStruct{
 		HttpStatusCode: http.StatusBadRequest,
 	},
 	ErrInvalidContinuationToken: {
-		AwsErrorCode:   "ErrInvalidContinuationToken",
-		Description:    "The continuation token you provided is invalid.",
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "The continuation token provided is incorrect.",
 		HttpStatusCode: http.StatusBadRequest,
 	},
 	ErrInvalidMaxUploads: {
@@ -828,6 +877,251 @@ var ErrorCodeResponse = map[ApiErrorCode]ApiErrorStruct{
 		Description:    "Create object thaw operation failed",
 		HttpStatusCode: http.StatusInternalServerError,
 	},
+	ErrInvalidToken: {
+		AwsErrorCode:   "InvalidToken",
+		Description:    "The provided token is malformed or otherwise invalid.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrExpiredToken: {
+		AwsErrorCode:   "ExpiredToken",
+		Description:    "The provided token has expired.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrStsNotEnabled: {
+		AwsErrorCode:   "NotImplemented",
+		Description:    "STS is not enabled on this server.",
+		HttpStatusCode: http.StatusNotImplemented,
+	},
+	ErrInvalidStsAction: {
+		AwsErrorCode:   "InvalidAction",
+		Description:    "The action or operation requested is invalid.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidDurationSeconds: {
+		AwsErrorCode:   "ValidationError",
+		Description:    "The requested DurationSeconds is out of range.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrMalformedSessionPolicy: {
+		AwsErrorCode:   "MalformedPolicyDocument",
+		Description:    "The session policy document is malformed.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrMissingRoleArn: {
+		AwsErrorCode:   "MissingParameter",
+		Description:    "The request must contain the parameter RoleArn.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidRoleSessionName: {
+		AwsErrorCode:   "ValidationError",
+		Description:    "RoleSessionName must be 2 to 64 characters of [\\w+=,.@-].",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrTemporaryCredentialNotAllowed: {
+		AwsErrorCode:   "AccessDenied",
+		Description:    "Cannot call this operation with temporary credentials.",
+		HttpStatusCode: http.StatusForbidden,
+	},
+	ErrSlowDown: {
+		AwsErrorCode:   "SlowDown",
+		Description:    "Please reduce your request rate.",
+		HttpStatusCode: http.StatusServiceUnavailable,
+	},
+	ErrServiceUnavailable: {
+		AwsErrorCode:   "ServiceUnavailable",
+		Description:    "Server is shutting down, please retry.",
+		HttpStatusCode: http.StatusServiceUnavailable,
+	},
+	ErrGlacierExpeditedRetrievalNotAvailable: {
+		AwsErrorCode:   "GlacierExpeditedRetrievalNotAvailable",
+		Description:    "Glacier expedited retrievals are currently not available, please try again later.",
+		HttpStatusCode: http.StatusServiceUnavailable,
+	},
+	ErrNoSuchCluster: {
+		AwsErrorCode:   "NoSuchCluster",
+		Description:    "The specified cluster or pool does not exist.",
+		HttpStatusCode: http.StatusNotFound,
+	},
+	ErrClusterAlreadyExists: {
+		AwsErrorCode:   "ClusterAlreadyExists",
+		Description:    "The specified cluster and pool already exists.",
+		HttpStatusCode: http.StatusConflict,
+	},
+	ErrInvalidClusterWeight: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "Cluster weight must be a non-negative integer.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidClusterStatus: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "Cluster status must be one of active, readonly and draining.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidScrubMarker: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "Version of scrub marker must be an unsigned integer.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidChecksumAlgorithm: {
+		AwsErrorCode:   "InvalidRequest",
+		Description:    "Value for x-amz-checksum-algorithm header is invalid.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrMultipleChecksums: {
+		AwsErrorCode:   "InvalidRequest",
+		Description:    "Expecting a single x-amz-checksum- header. Multiple checksum Types are not allowed.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidChecksum: {
+		AwsErrorCode:   "InvalidRequest",
+		Description:    "Value for x-amz-checksum- header is invalid.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrMissingChecksum: {
+		AwsErrorCode:   "InvalidRequest",
+		Description:    "x-amz-sdk-checksum-algorithm specified, but no corresponding x-amz-checksum-* or x-amz-trailer headers were found.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrChecksumMismatch: {
+		AwsErrorCode:   "BadDigest",
+		Description:    "The x-amz-checksum- you specified did not match the calculated checksum.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrChecksumAlgorithmMismatch: {
+		AwsErrorCode:   "InvalidRequest",
+		Description:    "The upload was created using a different checksum algorithm, checksum of parts must be of the same algorithm.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidPartNumber: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "Part number must be an integer between 1 and 10000, inclusive.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrPartNumberNotSatisfiable: {
+		AwsErrorCode:   "InvalidPartNumber",
+		Description:    "The requested partnumber is not satisfiable.",
+		HttpStatusCode: http.StatusRequestedRangeNotSatisfiable,
+	},
+	ErrRangeWithPartNumber: {
+		AwsErrorCode:   "InvalidRequest",
+		Description:    "Cannot specify both Range header and partNumber query parameter.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrConditionalRequestConflict: {
+		AwsErrorCode:   "ConditionalRequestConflict",
+		Description:    "A conflicting operation occurred. If using PutObject you can retry the request.",
+		HttpStatusCode: http.StatusConflict,
+	},
+	ErrInvalidExpressionType: {
+		AwsErrorCode:   "InvalidExpressionType",
+		Description:    "The ExpressionType is invalid. Only SQL expressions are supported.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrMissingRequiredParameter: {
+		AwsErrorCode:   "MissingRequiredParameter",
+		Description:    "The SelectRequest entity is missing a required parameter. Check the service documentation and try again.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidRequestParameter: {
+		AwsErrorCode:   "InvalidRequestParameter",
+		Description:    "The value of a parameter in SelectRequest element is invalid. Check the service API documentation and try again.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidCompressionFormat: {
+		AwsErrorCode:   "InvalidCompressionFormat",
+		Description:    "The file is not in a supported compression format. Only GZIP is supported.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrUnsupportedSerialization: {
+		AwsErrorCode:   "UnsupportedSerialization",
+		Description:    "The SelectRequest entity can only contain one of CSV or JSON. Check the service documentation and try again.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrSQLParse: {
+		AwsErrorCode:   "ParseUnexpectedToken",
+		Description:    "The SQL expression contains an unexpected token.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrUnsupportedSqlOperation: {
+		AwsErrorCode:   "UnsupportedSqlOperation",
+		Description:    "Encountered an unsupported SQL operation.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidDataType: {
+		AwsErrorCode:   "InvalidDataType",
+		Description:    "The SQL expression contains an invalid data type.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrCastFailed: {
+		AwsErrorCode:   "CastFailed",
+		Description:    "Attempt to convert from one data type to another using CAST failed in the SQL expression.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrEvaluatorInvalidArguments: {
+		AwsErrorCode:   "EvaluatorInvalidArguments",
+		Description:    "Incorrect number of arguments in the function call in the SQL expression.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrDivisionByZero: {
+		AwsErrorCode:   "DivisionByZero",
+		Description:    "Division by zero in the SQL expression.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrCSVParsing: {
+		AwsErrorCode:   "CSVParsingError",
+		Description:    "Encountered an error parsing the CSV file. Check the file and try again.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrJSONParsing: {
+		AwsErrorCode:   "JSONParsingError",
+		Description:    "Encountered an error parsing the JSON file. Check the file and try again.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrOverMaxRecordSize: {
+		AwsErrorCode:   "OverMaxRecordSize",
+		Description:    "The length of a record in the input or result is greater than maxCharsPerRecord of 1 MB.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrMissingInventoryId: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "The id query parameter of inventory configuration is missing.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidInventoryConfiguration: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "The inventory configuration is invalid.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrMalformedInventoryConfiguration: {
+		AwsErrorCode:   "MalformedXML",
+		Description:    "The XML you provided was not well-formed or did not validate against our published schema.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidInventoryDestination: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "The destination bucket of inventory does not exist or is not owned by the owner of source bucket.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrNoSuchInventoryConfiguration: {
+		AwsErrorCode:   "NoSuchConfiguration",
+		Description:    "The specified inventory configuration does not exist.",
+		HttpStatusCode: http.StatusNotFound,
+	},
+	ErrTooManyInventoryConfigurations: {
+		AwsErrorCode:   "TooManyConfigurations",
+		Description:    "You are attempting to create a new inventory configuration but have already reached the 1,000-configuration limit.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidTag: {
+		AwsErrorCode:   "InvalidTag",
+		Description:    "The TagSet provided is invalid, or has more than 50 tags or duplicate keys.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrNoSuchTagSet: {
+		AwsErrorCode:   "NoSuchTagSet",
+		Description:    "The TagSet does not exist.",
+		HttpStatusCode: http.StatusNotFound,
+	},
 }
 
 func (e ApiErrorCode) AwsErrorCode() string {
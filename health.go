@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/journeymidnight/yig/api"
+	"github.com/journeymidnight/yig/backend"
+	"github.com/journeymidnight/yig/crypto"
+	bus "github.com/journeymidnight/yig/mq"
+	"github.com/journeymidnight/yig/redis"
+)
+
+const healthCheckTimeout = 5 * time.Second
+
+const (
+	HealthStatusOk       = "ok"
+	HealthStatusFail     = "fail"
+	HealthStatusDisabled = "disabled"
+
+	ServerStatusAlive    = "alive"
+	ServerStatusReady    = "ready"
+	ServerStatusNotReady = "not_ready"
+	ServerStatusStopping = "stopping"
+)
+
+var errHealthCheckTimeout = errors.New("health check timeout")
+
+type dependencyJson struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+type healthJson struct {
+	Status string                    `json:"status"`
+	Checks map[string]dependencyJson `json:"checks,omitempty"`
+}
+
+// Ping is optionally implemented by MQ plugins, timeout is in ms.
+type mqPinger interface {
+	Ping(timeout int) error
+}
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error // nil if the dependency is disabled
+}
+
+func writeHealthResponse(w http.ResponseWriter, statusCode int, health healthJson) {
+	b, _ := json.Marshal(health)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(b)
+}
+
+// getLiveness reports the process is up and serving
+func getLiveness(w http.ResponseWriter, r *http.Request) {
+	writeHealthResponse(w, http.StatusOK, healthJson{Status: ServerStatusAlive})
+}
+
+// getReadiness checks all the dependencies and reports whether the server
+// could serve requests. It's always not ready once the server is stopping.
+func getReadiness(w http.ResponseWriter, r *http.Request) {
+	yig := adminServer.Yig
+	if yig.Stopping || api.IsDraining() {
+		writeHealthResponse(w, http.StatusServiceUnavailable, healthJson{Status: ServerStatusStopping})
+		return
+	}
+
+	checks := []healthCheck{
+		{name: "tidb", check: yig.MetaStorage.Ping},
+	}
+	for id, cluster := range yig.DataStorage {
+		cluster := cluster
+		checks = append(checks, healthCheck{
+			name: "cluster:" + id,
+			check: func(ctx context.Context) error {
+				_, err := cluster.GetUsage()
+				if err == backend.ErrUsageUnknown {
+					return nil
+				}
+				return err
+			},
+		})
+	}
+	redisCheck := healthCheck{name: "redis"}
+	if redis.Pool() != nil {
+		redisCheck.check = func(ctx context.Context) error {
+			if redis.CacheCircuit.IsOpen() {
+				return errors.New("circuit is open")
+			}
+			return nil
+		}
+	}
+	checks = append(checks, redisCheck)
+	kmsCheck := healthCheck{name: "kms"}
+	if yig.KMS != nil {
+		kmsCheck.check = func(ctx context.Context) error {
+			_, _, err := yig.KMS.GenerateKey(yig.KMS.GetKeyID(), crypto.Context{"health": "check"})
+			return err
+		}
+	}
+	checks = append(checks, kmsCheck)
+	mqCheck := healthCheck{name: "mq"}
+	if bus.MsgSender != nil {
+		mqCheck.check = func(ctx context.Context) error {
+			if pinger, ok := bus.MsgSender.(mqPinger); ok {
+				return pinger.Ping(int(healthCheckTimeout / time.Millisecond))
+			}
+			return nil
+		}
+	}
+	checks = append(checks, mqCheck)
+
+	health := healthJson{
+		Status: ServerStatusReady,
+		Checks: make(map[string]dependencyJson),
+	}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		if c.check == nil {
+			health.Checks[c.name] = dependencyJson{Status: HealthStatusDisabled}
+			continue
+		}
+		wg.Add(1)
+		go func(c healthCheck) {
+			defer wg.Done()
+			result := runHealthCheck(r.Context(), c.check)
+			lock.Lock()
+			health.Checks[c.name] = result
+			lock.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	statusCode := http.StatusOK
+	for _, result := range health.Checks {
+		if result.Status == HealthStatusFail {
+			health.Status = ServerStatusNotReady
+			statusCode = http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeHealthResponse(w, statusCode, health)
+}
+
+// runHealthCheck runs `check` with a timeout, some checks(e.g. GetUsage of
+// ceph cluster) do not accept a context so they're abandoned when timeout.
+func runHealthCheck(ctx context.Context, check func(ctx context.Context) error) dependencyJson {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errHealthCheckTimeout
+	}
+	result := dependencyJson{
+		Status:    HealthStatusOk,
+		LatencyMs: int64(time.Since(start) / time.Millisecond),
+	}
+	if err != nil {
+		result.Status = HealthStatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -1,7 +1,13 @@
 package helper
 
 import (
+	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/BurntSushi/toml"
 )
@@ -10,6 +16,8 @@ const (
 	YIG_CONF_PATH   = "/etc/yig/yig.toml"
 	MIN_BUFFER_SIZE = 512 << 10 // 512k
 	MAX_BUFEER_SIZE = 8 << 20   // 8M
+	// temporary credentials of STS live at least 15 minutes
+	MIN_STS_DURATION_SECONDS = 900
 )
 
 type Config struct {
@@ -58,6 +66,11 @@ type Config struct {
 	RedisPoolMaxIdle      int    `toml:"redis_pool_max_idle"`
 	RedisPoolIdleTimeout  int    `toml:"redis_pool_idle_timeout"`
 
+	// max number of entries of in-memory metadata cache, entries expire
+	// after MemoryCacheEntryTTL seconds in case invalid messages are lost
+	MemoryCacheMaxEntryCount int `toml:"memory_cache_max_entry_count"`
+	MemoryCacheEntryTTL      int `toml:"memory_cache_entry_ttl"`
+
 	// DB Connection parameters
 	DbMaxOpenConns       int `toml:"db_max_open_conns"`
 	DbMaxIdleConns       int `toml:"db_max_idle_conns"`
@@ -75,9 +88,104 @@ type Config struct {
 	CacheCircuitExecTimeout       uint  `toml:"cache_circuit_exec_timeout"`
 	CacheCircuitExecMaxConcurrent int64 `toml:"cache_circuit_exec_max_concurrent"`
 
+	// Circuit breakers of ceph clusters, clusters with open circuit get no new objects.
+	// Seconds between probes of clusters with open circuit.
+	ClusterCircuitCheckInterval int `toml:"cluster_circuit_check_interval"`
+	// Seconds to reject requests after tripping, before allowing attempts to close the circuit
+	ClusterCircuitCloseSleepWindow int `toml:"cluster_circuit_close_sleep_window"`
+	// How many consecutive passing requests are required before the circuit is closed
+	ClusterCircuitCloseRequiredCount int `toml:"cluster_circuit_close_required_count"`
+	// The minimum number of requests in a rolling window that will trip the circuit
+	ClusterCircuitOpenThreshold int `toml:"cluster_circuit_open_threshold"`
+	// Seconds a call to cluster could make no progress before it's considered failed
+	ClusterCircuitExecTimeout int `toml:"cluster_circuit_exec_timeout"`
+
 	DownloadBufPoolSize int64 `toml:"download_buf_pool_size"`
 	UploadMinChunkSize  int64 `toml:"upload_min_chunk_size"`
 	UploadMaxChunkSize  int64 `toml:"upload_max_chunk_size"`
+
+	// STS, session tokens are sealed with StsKey so every yig instance sharing
+	// the same key could verify them. STS is disabled if StsKey is empty.
+	StsKey                string `toml:"sts_key"`
+	StsMaxDurationSeconds int64  `toml:"sts_max_duration_seconds"`
+
+	Throttle ThrottleConfig `toml:"throttle"`
+
+	// Glacier restore tiers, keyed by "Expedited", "Standard" and "Bulk"
+	RestoreTiers map[string]RestoreTierConfig `toml:"restore_tiers"`
+
+	// On shutdown, new requests are rejected while in-flight requests are
+	// given this long to complete before connections are closed.
+	ShutdownDrainSeconds int64 `toml:"shutdown_drain_seconds"`
+
+	// Clusters with used space over this percent are not picked for new objects,
+	// used space is checked every ClusterUsageCheckInterval seconds.
+	ClusterMaxUsedSpacePercent int   `toml:"cluster_max_used_space_percent"`
+	ClusterUsageCheckInterval  int64 `toml:"cluster_usage_check_interval"`
+
+	// Pack small objects into shared volumes, objects no larger than
+	// PackObjectMaxSize are appended to one of PackVolumeCount open volumes
+	// of each instance, volumes are sealed at PackVolumeSize. yig_compact
+	// rewrites volumes with over PackCompactDeletedPercent of data deleted.
+	PackSmallObjects          bool  `toml:"pack_small_objects"`
+	PackObjectMaxSize         int64 `toml:"pack_object_max_size"`
+	PackVolumeSize            int64 `toml:"pack_volume_size"`
+	PackVolumeCount           int   `toml:"pack_volume_count"`
+	PackCompactDeletedPercent int   `toml:"pack_compact_deleted_percent"`
+
+	// Deduplicate objects and multipart parts by SHA-256 of their data.
+	// SSE-C objects are never deduplicated, SSE-S3 objects only in their bucket.
+	EnableDedup bool `toml:"enable_dedup"`
+
+	// Remote S3 compatible endpoints used as clusters, keyed by cluster ID,
+	// i.e. the fsid in table cluster
+	RemoteClusters map[string]RemoteClusterConfig `toml:"remote_clusters"`
+}
+
+type RemoteClusterConfig struct {
+	Endpoint  string `toml:"endpoint"` // e.g. http://10.0.0.1:9000
+	Region    string `toml:"region"`
+	AccessKey string `toml:"access_key"`
+	SecretKey string `toml:"secret_key"`
+	Bucket    string `toml:"bucket"`
+	// path style, i.e. endpoint/bucket/key is used unless set
+	VirtualHostStyle bool `toml:"virtual_host_style"`
+	// Only objects of these storage classes are put to the cluster,
+	// default GLACIER and DEEP_ARCHIVE
+	StorageClasses []string `toml:"storage_classes"`
+}
+
+type ThrottleConfig struct {
+	Enable bool `toml:"enable"`
+	// Requests per second of each operation class, i.e. "read", "write", "list",
+	// "delete" and "other", "default" applies to classes not listed. 0 means unlimited.
+	AccessKeyRequestRate map[string]int64 `toml:"access_key_request_rate"`
+	BucketRequestRate    map[string]int64 `toml:"bucket_request_rate"`
+	// Bytes per second, 0 means unlimited
+	AccessKeyUploadRate   int64 `toml:"access_key_upload_rate"`
+	AccessKeyDownloadRate int64 `toml:"access_key_download_rate"`
+	BucketUploadRate      int64 `toml:"bucket_upload_rate"`
+	BucketDownloadRate    int64 `toml:"bucket_download_rate"`
+	// Seconds of traffic allowed to burst above the rates
+	BurstSeconds int64 `toml:"burst_seconds"`
+	// Requests which need to wait longer than this for bandwidth are rejected, in seconds
+	MaxWaitSeconds int64 `toml:"max_wait_seconds"`
+}
+
+type RestoreTierConfig struct {
+	// Number of objects restored concurrently by each restore daemon
+	Concurrency int `toml:"concurrency"`
+	// Restorations are expected to complete within this long after requested
+	TargetCompletionMinutes int64 `toml:"target_completion_minutes"`
+	// Max number of restorations pending or in progress, 0 means unlimited.
+	// Only applies to Expedited tier, requests beyond are rejected.
+	Capacity int64 `toml:"capacity"`
+}
+
+var defaultRestoreTiers = map[string]RestoreTierConfig{
+	"Expedited": {Concurrency: 2, TargetCompletionMinutes: 5, Capacity: 100},
+	"Standard":  {Concurrency: 4, TargetCompletionMinutes: 5 * 60},
+	"Bulk":      {Concurrency: 1, TargetCompletionMinutes: 12 * 60},
 }
 
 type PluginConfig struct {
@@ -93,80 +201,488 @@ func SetupConfig() {
 }
 
 func MarshalTOMLConfig() error {
-	data, err := ioutil.ReadFile(YIG_CONF_PATH)
+	config, err := loadConfig(YIG_CONF_PATH)
 	if err != nil {
-		if err != nil {
-			panic("Cannot open yig.toml")
-		}
+		panic(err.Error())
+	}
+	CONFIG = config
+	return nil
+}
+
+// ConfigError lists all problems found in a config file
+type ConfigError struct {
+	Path     string
+	Problems []string
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%d problems found in %s: %s", len(e.Problems), e.Path,
+		strings.Join(e.Problems, "; "))
+}
+
+// CheckConfig returns all problems found in config file at `path`, including
+// files it refers to, and the config decoded without defaults filled
+func CheckConfig(path string) (Config, []string) {
+	c, problems := decodeConfig(path)
+	return c, append(problems, validateConfigFiles(c)...)
+}
+
+// CheckConfigFiles panics if any file referred to by CONFIG is missing,
+// it should be called on server startup after SetupConfig
+func CheckConfigFiles() {
+	problems := validateConfigFiles(CONFIG)
+	if len(problems) != 0 {
+		panic(ConfigError{Path: YIG_CONF_PATH, Problems: problems}.Error())
 	}
-	var c Config
-	_, err = toml.Decode(string(data), &c)
+}
+
+// decodeConfig decodes config file at `path` strictly, i.e. unknown keys are
+// problems, and validates values decoded
+func decodeConfig(path string) (c Config, problems []string) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		panic("load yig.toml error: " + err.Error())
+		return c, []string{"cannot open config file: " + err.Error()}
+	}
+	md, err := toml.Decode(string(data), &c)
+	if err != nil {
+		return c, []string{"cannot decode config file: " + err.Error()}
+	}
+	for _, key := range md.Undecoded() {
+		problems = append(problems, fmt.Sprintf("unknown key %s", key.String()))
+	}
+	return c, append(problems, validateConfig(c)...)
+}
+
+// loadConfig reads config file at `path`, validates it and fills defaults
+func loadConfig(path string) (config Config, err error) {
+	c, problems := decodeConfig(path)
+	if len(problems) != 0 {
+		return config, ConfigError{Path: path, Problems: problems}
 	}
 	// setup CONFIG with defaults
-	CONFIG.S3Domain = c.S3Domain
-	CONFIG.Region = c.Region
-	CONFIG.Plugins = c.Plugins
-	CONFIG.PiggybackUpdateUsage = c.PiggybackUpdateUsage
-	CONFIG.LogPath = c.LogPath
-	CONFIG.AccessLogPath = c.AccessLogPath
-	CONFIG.AccessLogFormat = c.AccessLogFormat
-	CONFIG.PanicLogPath = c.PanicLogPath
-	CONFIG.PidFile = c.PidFile
-	CONFIG.BindApiAddress = c.BindApiAddress
-	CONFIG.BindAdminAddress = c.BindAdminAddress
-	CONFIG.SSLKeyPath = c.SSLKeyPath
-	CONFIG.SSLCertPath = c.SSLCertPath
-	CONFIG.ZookeeperAddress = c.ZookeeperAddress
-	CONFIG.DebugMode = c.DebugMode
-	CONFIG.EnablePProf = c.EnablePProf
-	CONFIG.BindPProfAddress = c.BindPProfAddress
-	CONFIG.AdminKey = c.AdminKey
-	CONFIG.CephConfigPattern = c.CephConfigPattern
-	CONFIG.ReservedOrigins = c.ReservedOrigins
-	CONFIG.TidbInfo = c.TidbInfo
-	CONFIG.KeepAlive = c.KeepAlive
-	CONFIG.EnableCompression = c.EnableCompression
-	CONFIG.InstanceId = Ternary(c.InstanceId == "",
+	config.S3Domain = c.S3Domain
+	config.Region = c.Region
+	config.Plugins = c.Plugins
+	config.PiggybackUpdateUsage = c.PiggybackUpdateUsage
+	config.LogPath = c.LogPath
+	config.AccessLogPath = c.AccessLogPath
+	config.AccessLogFormat = c.AccessLogFormat
+	config.PanicLogPath = c.PanicLogPath
+	config.PidFile = c.PidFile
+	config.BindApiAddress = c.BindApiAddress
+	config.BindAdminAddress = c.BindAdminAddress
+	config.SSLKeyPath = c.SSLKeyPath
+	config.SSLCertPath = c.SSLCertPath
+	config.ZookeeperAddress = c.ZookeeperAddress
+	config.DebugMode = c.DebugMode
+	config.EnablePProf = c.EnablePProf
+	config.BindPProfAddress = c.BindPProfAddress
+	config.AdminKey = c.AdminKey
+	config.CephConfigPattern = c.CephConfigPattern
+	config.ReservedOrigins = c.ReservedOrigins
+	config.TidbInfo = c.TidbInfo
+	config.KeepAlive = c.KeepAlive
+	config.EnableCompression = c.EnableCompression
+	config.InstanceId = Ternary(c.InstanceId == "",
 		string(GenerateRandomId()), c.InstanceId).(string)
-	CONFIG.ConcurrentRequestLimit = Ternary(c.ConcurrentRequestLimit == 0,
+	config.ConcurrentRequestLimit = Ternary(c.ConcurrentRequestLimit == 0,
 		10000, c.ConcurrentRequestLimit).(int)
-	CONFIG.GcThread = Ternary(c.GcThread == 0,
+	config.GcThread = Ternary(c.GcThread == 0,
 		1, c.GcThread).(int)
-	CONFIG.LcThread = Ternary(c.LcThread == 0,
+	config.LcThread = Ternary(c.LcThread == 0,
 		1, c.LcThread).(int)
-	CONFIG.LogLevel = Ternary(len(c.LogLevel) == 0, "info", c.LogLevel).(string)
-	CONFIG.MetaStore = Ternary(c.MetaStore == "", "tidb", c.MetaStore).(string)
+	config.LogLevel = Ternary(len(c.LogLevel) == 0, "info", c.LogLevel).(string)
+	config.MetaStore = Ternary(c.MetaStore == "", "tidb", c.MetaStore).(string)
 
-	CONFIG.EnableUsagePush = c.EnableUsagePush
-	CONFIG.RedisAddress = c.RedisAddress
-	CONFIG.RedisPassword = c.RedisPassword
-	CONFIG.RedisConnectionNumber = Ternary(c.RedisConnectionNumber == 0,
+	config.EnableUsagePush = c.EnableUsagePush
+	config.RedisAddress = c.RedisAddress
+	config.RedisPassword = c.RedisPassword
+	config.RedisConnectionNumber = Ternary(c.RedisConnectionNumber == 0,
 		10, c.RedisConnectionNumber).(int)
-	CONFIG.EnableDataCache = c.EnableDataCache
-	CONFIG.MetaCacheType = c.MetaCacheType
-	CONFIG.RedisConnectTimeout = Ternary(c.RedisConnectTimeout < 0, 0, c.RedisConnectTimeout).(int)
-	CONFIG.RedisReadTimeout = Ternary(c.RedisReadTimeout < 0, 0, c.RedisReadTimeout).(int)
-	CONFIG.RedisWriteTimeout = Ternary(c.RedisWriteTimeout < 0, 0, c.RedisWriteTimeout).(int)
-	CONFIG.RedisKeepAlive = Ternary(c.RedisKeepAlive < 0, 0, c.RedisKeepAlive).(int)
-	CONFIG.RedisPoolMaxIdle = Ternary(c.RedisPoolMaxIdle < 0, 0, c.RedisPoolMaxIdle).(int)
-	CONFIG.RedisPoolIdleTimeout = Ternary(c.RedisPoolIdleTimeout < 0, 0, c.RedisPoolIdleTimeout).(int)
-
-	CONFIG.DbMaxOpenConns = Ternary(c.DbMaxOpenConns < 0, 0, c.DbMaxOpenConns).(int)
-	CONFIG.DbMaxIdleConns = Ternary(c.DbMaxIdleConns < 0, 0, c.DbMaxIdleConns).(int)
-	CONFIG.DbConnMaxLifeSeconds = Ternary(c.DbConnMaxLifeSeconds < 0, 0, c.DbConnMaxLifeSeconds).(int)
-
-	CONFIG.CacheCircuitCheckInterval = Ternary(c.CacheCircuitCheckInterval < 0, 0, c.CacheCircuitCheckInterval).(int)
-	CONFIG.CacheCircuitCloseSleepWindow = Ternary(c.CacheCircuitCloseSleepWindow < 0, 0, c.CacheCircuitCloseSleepWindow).(int)
-	CONFIG.CacheCircuitCloseRequiredCount = Ternary(c.CacheCircuitCloseRequiredCount < 0, 0, c.CacheCircuitCloseRequiredCount).(int)
-	CONFIG.CacheCircuitOpenThreshold = Ternary(c.CacheCircuitOpenThreshold < 0, 0, c.CacheCircuitOpenThreshold).(int)
-	CONFIG.CacheCircuitExecTimeout = Ternary(c.CacheCircuitExecTimeout == 0, 1, c.CacheCircuitExecTimeout).(uint)
-	CONFIG.CacheCircuitExecMaxConcurrent = c.CacheCircuitExecMaxConcurrent
-
-	CONFIG.DownloadBufPoolSize = Ternary(c.DownloadBufPoolSize < MIN_BUFFER_SIZE || c.DownloadBufPoolSize > MAX_BUFEER_SIZE, MIN_BUFFER_SIZE, c.DownloadBufPoolSize).(int64)
-	CONFIG.UploadMinChunkSize = Ternary(c.UploadMinChunkSize < MIN_BUFFER_SIZE || c.UploadMinChunkSize > MAX_BUFEER_SIZE, MIN_BUFFER_SIZE, c.UploadMinChunkSize).(int64)
-	CONFIG.UploadMaxChunkSize = Ternary(c.UploadMaxChunkSize < CONFIG.UploadMinChunkSize || c.UploadMaxChunkSize > MAX_BUFEER_SIZE, MAX_BUFEER_SIZE, c.UploadMaxChunkSize).(int64)
+	config.EnableDataCache = c.EnableDataCache
+	config.MetaCacheType = c.MetaCacheType
+	config.MemoryCacheMaxEntryCount = Ternary(c.MemoryCacheMaxEntryCount <= 0,
+		100000, c.MemoryCacheMaxEntryCount).(int)
+	config.MemoryCacheEntryTTL = Ternary(c.MemoryCacheEntryTTL <= 0,
+		60, c.MemoryCacheEntryTTL).(int)
+	config.RedisConnectTimeout = Ternary(c.RedisConnectTimeout < 0, 0, c.RedisConnectTimeout).(int)
+	config.RedisReadTimeout = Ternary(c.RedisReadTimeout < 0, 0, c.RedisReadTimeout).(int)
+	config.RedisWriteTimeout = Ternary(c.RedisWriteTimeout < 0, 0, c.RedisWriteTimeout).(int)
+	config.RedisKeepAlive = Ternary(c.RedisKeepAlive < 0, 0, c.RedisKeepAlive).(int)
+	config.RedisPoolMaxIdle = Ternary(c.RedisPoolMaxIdle < 0, 0, c.RedisPoolMaxIdle).(int)
+	config.RedisPoolIdleTimeout = Ternary(c.RedisPoolIdleTimeout < 0, 0, c.RedisPoolIdleTimeout).(int)
+
+	config.DbMaxOpenConns = Ternary(c.DbMaxOpenConns < 0, 0, c.DbMaxOpenConns).(int)
+	config.DbMaxIdleConns = Ternary(c.DbMaxIdleConns < 0, 0, c.DbMaxIdleConns).(int)
+	config.DbConnMaxLifeSeconds = Ternary(c.DbConnMaxLifeSeconds < 0, 0, c.DbConnMaxLifeSeconds).(int)
+
+	config.CacheCircuitCheckInterval = Ternary(c.CacheCircuitCheckInterval < 0, 0, c.CacheCircuitCheckInterval).(int)
+	config.CacheCircuitCloseSleepWindow = Ternary(c.CacheCircuitCloseSleepWindow < 0, 0, c.CacheCircuitCloseSleepWindow).(int)
+	config.CacheCircuitCloseRequiredCount = Ternary(c.CacheCircuitCloseRequiredCount < 0, 0, c.CacheCircuitCloseRequiredCount).(int)
+	config.CacheCircuitOpenThreshold = Ternary(c.CacheCircuitOpenThreshold < 0, 0, c.CacheCircuitOpenThreshold).(int)
+	config.CacheCircuitExecTimeout = Ternary(c.CacheCircuitExecTimeout == 0, 1, c.CacheCircuitExecTimeout).(uint)
+	config.CacheCircuitExecMaxConcurrent = c.CacheCircuitExecMaxConcurrent
+
+	config.ClusterCircuitCheckInterval = Ternary(c.ClusterCircuitCheckInterval <= 0, 10, c.ClusterCircuitCheckInterval).(int)
+	config.ClusterCircuitCloseSleepWindow = Ternary(c.ClusterCircuitCloseSleepWindow <= 0, 30, c.ClusterCircuitCloseSleepWindow).(int)
+	config.ClusterCircuitCloseRequiredCount = Ternary(c.ClusterCircuitCloseRequiredCount <= 0, 3, c.ClusterCircuitCloseRequiredCount).(int)
+	config.ClusterCircuitOpenThreshold = Ternary(c.ClusterCircuitOpenThreshold <= 0, 10, c.ClusterCircuitOpenThreshold).(int)
+	config.ClusterCircuitExecTimeout = Ternary(c.ClusterCircuitExecTimeout <= 0, 30, c.ClusterCircuitExecTimeout).(int)
 
+	config.DownloadBufPoolSize = Ternary(c.DownloadBufPoolSize < MIN_BUFFER_SIZE || c.DownloadBufPoolSize > MAX_BUFEER_SIZE, MIN_BUFFER_SIZE, c.DownloadBufPoolSize).(int64)
+	config.UploadMinChunkSize = Ternary(c.UploadMinChunkSize < MIN_BUFFER_SIZE || c.UploadMinChunkSize > MAX_BUFEER_SIZE, MIN_BUFFER_SIZE, c.UploadMinChunkSize).(int64)
+	config.UploadMaxChunkSize = Ternary(c.UploadMaxChunkSize < config.UploadMinChunkSize || c.UploadMaxChunkSize > MAX_BUFEER_SIZE, MAX_BUFEER_SIZE, c.UploadMaxChunkSize).(int64)
+
+	config.StsKey = c.StsKey
+	config.StsMaxDurationSeconds = Ternary(c.StsMaxDurationSeconds <= 0, int64(129600), c.StsMaxDurationSeconds).(int64)
+
+	config.Throttle = c.Throttle
+	config.Throttle.BurstSeconds = Ternary(c.Throttle.BurstSeconds <= 0, int64(1), c.Throttle.BurstSeconds).(int64)
+	config.Throttle.MaxWaitSeconds = Ternary(c.Throttle.MaxWaitSeconds <= 0, int64(10), c.Throttle.MaxWaitSeconds).(int64)
+
+	config.RestoreTiers = make(map[string]RestoreTierConfig)
+	for tier, d := range defaultRestoreTiers {
+		t, ok := c.RestoreTiers[tier]
+		if !ok {
+			config.RestoreTiers[tier] = d
+			continue
+		}
+		t.Concurrency = Ternary(t.Concurrency <= 0, d.Concurrency, t.Concurrency).(int)
+		t.TargetCompletionMinutes = Ternary(t.TargetCompletionMinutes <= 0,
+			d.TargetCompletionMinutes, t.TargetCompletionMinutes).(int64)
+		t.Capacity = Ternary(t.Capacity < 0, int64(0), t.Capacity).(int64)
+		config.RestoreTiers[tier] = t
+	}
+
+	config.PackSmallObjects = c.PackSmallObjects
+	config.PackObjectMaxSize = Ternary(c.PackObjectMaxSize <= 0, int64(64<<10), c.PackObjectMaxSize).(int64)
+	config.PackVolumeSize = Ternary(c.PackVolumeSize <= 0, int64(64<<20), c.PackVolumeSize).(int64)
+	config.PackVolumeCount = Ternary(c.PackVolumeCount <= 0, 4, c.PackVolumeCount).(int)
+	config.PackCompactDeletedPercent = Ternary(c.PackCompactDeletedPercent <= 0 || c.PackCompactDeletedPercent > 100,
+		50, c.PackCompactDeletedPercent).(int)
+	config.EnableDedup = c.EnableDedup
+
+	config.RemoteClusters = make(map[string]RemoteClusterConfig)
+	for id, r := range c.RemoteClusters {
+		r.Region = Ternary(r.Region == "", "us-east-1", r.Region).(string)
+		if len(r.StorageClasses) == 0 {
+			r.StorageClasses = []string{"GLACIER", "DEEP_ARCHIVE"}
+		}
+		config.RemoteClusters[id] = r
+	}
+
+	config.ShutdownDrainSeconds = Ternary(c.ShutdownDrainSeconds <= 0, int64(60), c.ShutdownDrainSeconds).(int64)
+
+	config.ClusterMaxUsedSpacePercent = Ternary(c.ClusterMaxUsedSpacePercent <= 0 || c.ClusterMaxUsedSpacePercent > 100,
+		85, c.ClusterMaxUsedSpacePercent).(int)
+	config.ClusterUsageCheckInterval = Ternary(c.ClusterUsageCheckInterval <= 0,
+		int64(3600), c.ClusterUsageCheckInterval).(int64)
+
+	return config, nil
+}
+
+// validateConfig returns problems of values decoded, zero values mean
+// defaults unless the option is required
+func validateConfig(c Config) (problems []string) {
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// required
+	required := []struct {
+		key   string
+		empty bool
+	}{
+		{"s3domain", len(c.S3Domain) == 0},
+		{"region", c.Region == ""},
+		{"log_path", c.LogPath == ""},
+		{"access_log_path", c.AccessLogPath == ""},
+		{"api_listener", c.BindApiAddress == ""},
+		{"admin_listener", c.BindAdminAddress == ""},
+		{"admin_key", c.AdminKey == ""},
+		{"tidb_info", c.TidbInfo == ""},
+	}
+	for _, r := range required {
+		if r.empty {
+			problem("%s is required", r.key)
+		}
+	}
+
+	// ranges
+	switch strings.ToLower(c.LogLevel) {
+	case "", "info", "warn", "error":
+	default:
+		problem("log_level %q is not one of info, warn and error", c.LogLevel)
+	}
+	if c.MetaStore != "" && c.MetaStore != "tidb" {
+		problem("meta_store %q is not supported, only tidb is", c.MetaStore)
+	}
+	if c.MetaCacheType < 0 || c.MetaCacheType > 2 {
+		problem("meta_cache_type %d is not one of 0, 1 and 2", c.MetaCacheType)
+	}
+	nonNegative := []struct {
+		key   string
+		value int64
+	}{
+		{"gc_thread", int64(c.GcThread)},
+		{"memory_cache_max_entry_count", int64(c.MemoryCacheMaxEntryCount)},
+		{"memory_cache_entry_ttl", int64(c.MemoryCacheEntryTTL)},
+		{"redis_connection_number", int64(c.RedisConnectionNumber)},
+		{"redis_connect_timeout", int64(c.RedisConnectTimeout)},
+		{"redis_read_timeout", int64(c.RedisReadTimeout)},
+		{"redis_write_timeout", int64(c.RedisWriteTimeout)},
+		{"redis_keepalive", int64(c.RedisKeepAlive)},
+		{"redis_pool_max_idle", int64(c.RedisPoolMaxIdle)},
+		{"redis_pool_idle_timeout", int64(c.RedisPoolIdleTimeout)},
+		{"db_max_open_conns", int64(c.DbMaxOpenConns)},
+		{"db_max_idle_conns", int64(c.DbMaxIdleConns)},
+		{"db_conn_max_life_seconds", int64(c.DbConnMaxLifeSeconds)},
+		{"cache_circuit_check_interval", int64(c.CacheCircuitCheckInterval)},
+		{"cluster_circuit_check_interval", int64(c.ClusterCircuitCheckInterval)},
+		{"sts_max_duration_seconds", c.StsMaxDurationSeconds},
+		{"shutdown_drain_seconds", c.ShutdownDrainSeconds},
+		{"cluster_usage_check_interval", c.ClusterUsageCheckInterval},
+		{"pack_object_max_size", c.PackObjectMaxSize},
+		{"pack_volume_size", c.PackVolumeSize},
+		{"pack_volume_count", int64(c.PackVolumeCount)},
+	}
+	for _, n := range nonNegative {
+		if n.value < 0 {
+			problem("%s %d is negative", n.key, n.value)
+		}
+	}
+	for _, p := range []struct {
+		key   string
+		value int
+	}{
+		{"cluster_max_used_space_percent", c.ClusterMaxUsedSpacePercent},
+		{"pack_compact_deleted_percent", c.PackCompactDeletedPercent},
+	} {
+		if p.value < 0 || p.value > 100 {
+			problem("%s %d is not between 0 and 100", p.key, p.value)
+		}
+	}
+	for _, b := range []struct {
+		key   string
+		value int64
+	}{
+		{"download_buf_pool_size", c.DownloadBufPoolSize},
+		{"upload_min_chunk_size", c.UploadMinChunkSize},
+		{"upload_max_chunk_size", c.UploadMaxChunkSize},
+	} {
+		if b.value != 0 && (b.value < MIN_BUFFER_SIZE || b.value > MAX_BUFEER_SIZE) {
+			problem("%s %d is not between %d and %d", b.key, b.value,
+				MIN_BUFFER_SIZE, MAX_BUFEER_SIZE)
+		}
+	}
+	if c.UploadMinChunkSize != 0 && c.UploadMaxChunkSize != 0 &&
+		c.UploadMaxChunkSize < c.UploadMinChunkSize {
+		problem("upload_max_chunk_size is less than upload_min_chunk_size")
+	}
+	for tier := range c.RestoreTiers {
+		if _, ok := defaultRestoreTiers[tier]; !ok {
+			problem("restore tier %s is not one of Expedited, Standard and Bulk", tier)
+		}
+	}
+
+	// combinations
+	if (c.MetaCacheType > 0 || c.EnableDataCache) && c.RedisAddress == "" {
+		problem("redis_address is required by meta_cache_type and enable_data_cache")
+	}
+	if c.StsMaxDurationSeconds > 0 && c.StsMaxDurationSeconds < MIN_STS_DURATION_SECONDS {
+		problem("sts_max_duration_seconds %d is less than %d", c.StsMaxDurationSeconds,
+			MIN_STS_DURATION_SECONDS)
+	}
+	if c.StsKey != "" && !IsStrongSecret(c.StsKey) {
+		problem("sts_key is too weak, use at least %d random characters, "+
+			"e.g. `openssl rand -base64 32`", MIN_SECRET_LENGTH)
+	}
+	if (c.SSLKeyPath == "") != (c.SSLCertPath == "") {
+		problem("ssl_key_path and ssl_cert_path should be set together")
+	}
+	for id, r := range c.RemoteClusters {
+		if r.Endpoint == "" || r.Bucket == "" {
+			problem("endpoint and bucket of remote cluster %s are required", id)
+		}
+		if r.AccessKey == "" || r.SecretKey == "" {
+			problem("access_key and secret_key of remote cluster %s are required", id)
+		}
+	}
+
+	return problems
+}
+
+// validateConfigFiles returns problems of files referred to by config, they
+// are checked only by check-config and on server startup, tools running on
+// other hosts have no need of them
+func validateConfigFiles(c Config) (problems []string) {
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	for _, f := range []struct {
+		key  string
+		path string
+	}{
+		{"ssl_key_path", c.SSLKeyPath},
+		{"ssl_cert_path", c.SSLCertPath},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			problem("%s: %v", f.key, err)
+		}
+	}
+	if c.CephConfigPattern != "" {
+		files, err := filepath.Glob(c.CephConfigPattern)
+		if err != nil || len(files) == 0 {
+			problem("ceph_config_pattern %s matches no ceph config file", c.CephConfigPattern)
+		}
+	}
+	for name, p := range c.Plugins {
+		if !p.Enable {
+			continue
+		}
+		if p.Path == "" {
+			problem("path of enabled plugin %s is required", name)
+		} else if _, err := os.Stat(p.Path); err != nil {
+			problem("plugin %s: %v", name, err)
+		}
+	}
+	return problems
+}
+
+// Config keys which are only read on startup, including those passed to
+// plugins and clusters when they are created, reloading changes of them is
+// refused since they could not be applied without restarting
+var restartRequiredKeys = map[string]bool{
+	"s3domain":                       true,
+	"plugins":                        true,
+	"log_path":                       true,
+	"access_log_path":                true,
+	"access_log_format":              true,
+	"panic_log_path":                 true,
+	"pid_file":                       true,
+	"api_listener":                   true,
+	"admin_listener":                 true,
+	"ssl_key_path":                   true,
+	"ssl_cert_path":                  true,
+	"zk_address":                     true,
+	"ConcurrentRequestLimit":         true,
+	"enable_pprof":                   true,
+	"pprof_listener":                 true,
+	"ceph_config_pattern":            true,
+	"meta_store":                     true,
+	"tidb_info":                      true,
+	"keepalive":                      true,
+	"enable_compression":             true,
+	"meta_cache_type":                true,
+	"enable_data_cache":              true,
+	"memory_cache_max_entry_count":   true,
+	"memory_cache_entry_ttl":         true,
+	"cache_circuit_check_interval":   true,
+	"cluster_circuit_check_interval": true,
+	"pack_volume_count":              true,
+	"remote_clusters":                true,
+}
+
+type configSubscriber struct {
+	name     string
+	keys     []string
+	onChange func(config *Config) error
+}
+
+var (
+	configLock        sync.Mutex
+	configSubscribers []configSubscriber
+)
+
+// SubscribeConfig registers `onChange` of component `name`, which is called
+// with the new config when any of `keys` changes on reloading.
+// Keys are as in yig.toml, or names of fields without toml key.
+func SubscribeConfig(name string, keys []string, onChange func(config *Config) error) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	configSubscribers = append(configSubscribers, configSubscriber{
+		name:     name,
+		keys:     keys,
+		onChange: onChange,
+	})
+}
+
+// ReloadConfig reads yig.toml again and applies it. The config is kept
+// unchanged if the new one is invalid or needs restarting to apply.
+// Subscribers of changed keys are notified after CONFIG is replaced.
+func ReloadConfig() error {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	config, err := loadConfig(YIG_CONF_PATH)
+	if err != nil {
+		Logger.Error("Config reload failed:", err)
+		return err
+	}
+	// instance ID is generated on startup if not set
+	config.InstanceId = CONFIG.InstanceId
+
+	changed := diffConfig(CONFIG, config)
+	if len(changed) == 0 {
+		Logger.Info("Config reloaded, nothing changed")
+		return nil
+	}
+	var needRestart []string
+	for _, key := range changed {
+		if restartRequiredKeys[key] {
+			needRestart = append(needRestart, key)
+		}
+	}
+	if len(needRestart) != 0 {
+		err = fmt.Errorf("changes of %s need restarting YIG, config is not reloaded",
+			strings.Join(needRestart, ", "))
+		Logger.Error("Config reload refused:", err)
+		return err
+	}
+
+	CONFIG = config
+	Logger.Info("Config reloaded, changed:", strings.Join(changed, ", "))
+	for _, s := range configSubscribers {
+		if !containsAny(changed, s.keys) {
+			continue
+		}
+		if err := s.onChange(&config); err != nil {
+			Logger.Error("Config reload failed to apply to", s.name, "err:", err)
+		} else {
+			Logger.Info("Config reload applied to", s.name)
+		}
+	}
 	return nil
 }
+
+// diffConfig returns keys of fields differ between `a` and `b`
+func diffConfig(a, b Config) (keys []string) {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			continue
+		}
+		key := t.Field(i).Tag.Get("toml")
+		if key == "" {
+			key = t.Field(i).Name
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func containsAny(keys []string, wanted []string) bool {
+	for _, key := range keys {
+		for _, w := range wanted {
+			if key == w {
+				return true
+			}
+		}
+	}
+	return false
+}
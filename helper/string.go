@@ -1,6 +1,9 @@
 package helper
 
-import "strings"
+import (
+	"math"
+	"strings"
+)
 
 func HasBucketInDomain(host string, prefix string, domains []string) (ok bool, bucket string)  {
 	for _, d := range domains {
@@ -56,3 +59,28 @@ func SubString(str string, begin, length int) (substr string) {
 	}
 	return string(rs[begin:end])
 }
+
+// Secrets shorter than MIN_SECRET_LENGTH or with less than MIN_SECRET_ENTROPY
+// bits estimated are considered guessable.
+const (
+	MIN_SECRET_LENGTH  = 32
+	MIN_SECRET_ENTROPY = 96
+)
+
+// IsStrongSecret estimates the entropy of `secret` by its character
+// frequencies, which rejects short keys and repeated or sample values.
+func IsStrongSecret(secret string) bool {
+	if len(secret) < MIN_SECRET_LENGTH {
+		return false
+	}
+	counts := make(map[byte]int)
+	for i := 0; i < len(secret); i++ {
+		counts[secret[i]]++
+	}
+	var bitsPerChar float64
+	for _, n := range counts {
+		p := float64(n) / float64(len(secret))
+		bitsPerChar -= p * math.Log2(p)
+	}
+	return bitsPerChar*float64(len(secret)) >= MIN_SECRET_ENTROPY
+}
@@ -75,3 +75,16 @@ func (c *cache) Set(key string, credential common.Credential) {
 	c.cache[key] = entry
 	c.lock.Unlock()
 }
+
+// Invalidate removes cached credentials of the given access keys, IAM plugins
+// should call it whenever keys are changed, disabled or expired.
+func Invalidate(keys ...string) {
+	if IamCache == nil {
+		return
+	}
+	IamCache.lock.Lock()
+	for _, key := range keys {
+		delete(IamCache.cache, key)
+	}
+	IamCache.lock.Unlock()
+}
@@ -1,6 +1,10 @@
 package common
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
+
 // credential container for access and secret keys.
 type Credential struct {
 	UserId               string
@@ -8,6 +12,16 @@ type Credential struct {
 	AccessKeyID          string
 	SecretAccessKey      string
 	AllowOtherUserAccess bool
+
+	// Only set for temporary credentials issued by STS
+	SessionToken  string
+	SessionPolicy string // inline session policy in JSON, empty means no extra restriction
+	Expiration    time.Time
+}
+
+// IsTemporary returns true if the credential is issued by STS
+func (a Credential) IsTemporary() bool {
+	return a.SessionToken != ""
 }
 
 func (a Credential) String() string {
@@ -18,6 +32,8 @@ func (a Credential) String() string {
 }
 
 var ErrAccessKeyNotExist = errors.New("Access key does not exist")
+var ErrInvalidSessionToken = errors.New("Session token is invalid")
+var ErrExpiredSessionToken = errors.New("Session token has expired")
 
 
 
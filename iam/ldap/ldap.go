@@ -0,0 +1,165 @@
+// Package ldap looks up entries needed by IAM plugins in a directory.
+// Connections are bound once and reused from a pool, and are always
+// encrypted, either by ldaps:// or StartTLS, unless StartTLS is turned off
+// explicitly.
+package ldap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	DefaultMaxPacketSize = 1 << 20
+	DefaultPoolSize      = 4
+)
+
+type Config struct {
+	Url          string // ldap://host:389 or ldaps://host:636
+	StartTLS     bool   // upgrade ldap:// connections by StartTLS
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	Timeout      time.Duration
+	// responses larger than MaxPacketSize bytes are rejected instead of
+	// being buffered
+	MaxPacketSize int64
+	// at most PoolSize idle connections are kept
+	PoolSize int
+}
+
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+func (e Entry) Get(attribute string) string {
+	if values := e.Attributes[attribute]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+type Pool struct {
+	config    Config
+	tlsConfig *tls.Config
+	conns     chan *ldap.Conn
+}
+
+func NewPool(config Config) (*Pool, error) {
+	u, err := url.Parse(config.Url)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return nil, fmt.Errorf("unsupported LDAP scheme %s", u.Scheme)
+	}
+	if config.MaxPacketSize <= 0 {
+		config.MaxPacketSize = DefaultMaxPacketSize
+	}
+	if config.PoolSize <= 0 {
+		config.PoolSize = DefaultPoolSize
+	}
+	// the limit is global in asn1-ber, applies to every LDAP response read
+	ber.MaxPacketLengthBytes = config.MaxPacketSize
+	return &Pool{
+		config:    config,
+		tlsConfig: &tls.Config{ServerName: u.Hostname()},
+		conns:     make(chan *ldap.Conn, config.PoolSize),
+	}, nil
+}
+
+// dial connects to the directory, secures the connection and binds with
+// BindDN and BindPassword, or stays anonymous if BindDN is empty.
+func (p *Pool) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(p.config.Url,
+		ldap.DialWithDialer(&net.Dialer{Timeout: p.config.Timeout}),
+		ldap.DialWithTLSConfig(p.tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(p.config.Timeout)
+	if _, isTLS := conn.TLSConnectionState(); !isTLS && p.config.StartTLS {
+		if err = conn.StartTLS(p.tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if p.config.BindDN != "" {
+		if err = conn.Bind(p.config.BindDN, p.config.BindPassword); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// get returns an idle connection if any, `pooled` is true if so.
+func (p *Pool) get() (conn *ldap.Conn, pooled bool, err error) {
+	for {
+		select {
+		case conn := <-p.conns:
+			if conn.IsClosing() {
+				continue
+			}
+			return conn, true, nil
+		default:
+			conn, err = p.dial()
+			return conn, false, err
+		}
+	}
+}
+
+func (p *Pool) put(conn *ldap.Conn) {
+	select {
+	case p.conns <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// Search returns entries under BaseDN whose `attribute` equals `value`,
+// only `attributes` are fetched. A failed pooled connection might just have
+// been closed by the server while idle, so the search is retried on
+// another connection.
+func (p *Pool) Search(attribute, value string, attributes []string) ([]Entry, error) {
+	request := ldap.NewSearchRequest(p.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0,
+		int(p.config.Timeout/time.Second), false,
+		fmt.Sprintf("(%s=%s)", ldap.EscapeFilter(attribute), ldap.EscapeFilter(value)),
+		attributes, nil)
+	for {
+		conn, pooled, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		result, err := conn.Search(request)
+		if err != nil {
+			conn.Close()
+			if pooled && ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+				continue
+			}
+			return nil, err
+		}
+		p.put(conn)
+
+		entries := make([]Entry, 0, len(result.Entries))
+		for _, e := range result.Entries {
+			entry := Entry{
+				DN:         e.DN,
+				Attributes: make(map[string][]string),
+			}
+			for _, a := range e.Attributes {
+				entry.Attributes[a.Name] = a.Values
+			}
+			entries = append(entries, entry)
+		}
+		return entries, nil
+	}
+}
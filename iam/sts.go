@@ -0,0 +1,169 @@
+package iam
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+)
+
+// Temporary credentials issued by STS are not persisted anywhere, all the
+// information needed to verify a request signed by them is sealed into
+// the session token with AES-GCM. The key is derived from `sts_key` in config.
+
+const (
+	sessionAccessKeyPrefix = "ASIA"
+	sessionAccessKeyLength = 20
+	sessionSecretKeyLength = 40
+)
+
+var ErrStsDisabled = errors.New("STS is not enabled")
+
+type sessionClaims struct {
+	AccessKey       string `json:"ak"`
+	SecretKey       string `json:"sk"`
+	ParentAccessKey string `json:"pk"`
+	Expiration      int64  `json:"exp"`
+	Policy          string `json:"pol,omitempty"`
+}
+
+var accessKeyTable = []byte("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+var secretKeyTable = []byte("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz+/")
+
+// randomString picks `length` characters from `table` uniformly, random bytes
+// not below the largest multiple of len(table) are discarded so that
+// every character is equally likely
+func randomString(table []byte, length int) (string, error) {
+	limit := 256 - 256%len(table)
+	result := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(result) < length {
+		if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			result = append(result, table[int(b)%len(table)])
+			if len(result) == length {
+				break
+			}
+		}
+	}
+	return string(result), nil
+}
+
+func sessionCipher() (cipher.AEAD, error) {
+	if !StsEnabled() {
+		return nil, ErrStsDisabled
+	}
+	key := sha256.Sum256([]byte(helper.CONFIG.StsKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// StsEnabled - anyone knowing the key could forge session tokens, so STS is
+// never enabled with a weak key even if config validation is bypassed.
+func StsEnabled() bool {
+	return helper.IsStrongSecret(helper.CONFIG.StsKey)
+}
+
+// NewSessionCredential issues a temporary credential for `parent`, which
+// expires after `duration`. `policy` is an optional inline session policy,
+// requests signed by the temporary credential are further restricted by it.
+func NewSessionCredential(parent common.Credential, duration time.Duration,
+	policy string) (credential common.Credential, err error) {
+
+	aead, err := sessionCipher()
+	if err != nil {
+		return
+	}
+	accessKey, err := randomString(accessKeyTable,
+		sessionAccessKeyLength-len(sessionAccessKeyPrefix))
+	if err != nil {
+		return
+	}
+	secretKey, err := randomString(secretKeyTable, sessionSecretKeyLength)
+	if err != nil {
+		return
+	}
+	expiration := time.Now().UTC().Add(duration).Truncate(time.Second)
+	claims := sessionClaims{
+		AccessKey:       sessionAccessKeyPrefix + accessKey,
+		SecretKey:       secretKey,
+		ParentAccessKey: parent.AccessKeyID,
+		Expiration:      expiration.Unix(),
+		Policy:          policy,
+	}
+	plain, err := json.Marshal(claims)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return
+	}
+	sealed := aead.Seal(nonce, nonce, plain, nil)
+
+	credential = parent
+	credential.AccessKeyID = claims.AccessKey
+	credential.SecretAccessKey = claims.SecretKey
+	credential.SessionToken = base64.RawURLEncoding.EncodeToString(sealed)
+	credential.SessionPolicy = policy
+	credential.Expiration = expiration
+	return credential, nil
+}
+
+// GetSessionCredential opens `token` and returns the temporary credential
+// for `accessKey`. The parent key is looked up again so revoking it also
+// revokes all the sessions derived from it.
+func GetSessionCredential(accessKey, token string) (credential common.Credential, err error) {
+	aead, err := sessionCipher()
+	if err != nil {
+		return credential, common.ErrInvalidSessionToken
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return credential, common.ErrInvalidSessionToken
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return credential, common.ErrInvalidSessionToken
+	}
+	var claims sessionClaims
+	if err = json.Unmarshal(plain, &claims); err != nil {
+		return credential, common.ErrInvalidSessionToken
+	}
+	if claims.AccessKey != accessKey {
+		return credential, common.ErrInvalidSessionToken
+	}
+	expiration := time.Unix(claims.Expiration, 0).UTC()
+	if time.Now().After(expiration) {
+		return credential, common.ErrExpiredSessionToken
+	}
+
+	parent, err := GetCredential(claims.ParentAccessKey)
+	if err != nil {
+		return credential, err
+	}
+	credential = parent
+	credential.AccessKeyID = claims.AccessKey
+	credential.SecretAccessKey = claims.SecretKey
+	credential.SessionToken = token
+	credential.SessionPolicy = claims.Policy
+	credential.Expiration = expiration
+	return credential, nil
+}
@@ -0,0 +1,190 @@
+package iam
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+)
+
+var errNoSuchKey = errors.New("no such key")
+
+// testIamClient only knows the keys in it
+type testIamClient map[string]common.Credential
+
+func (c testIamClient) GetKeysByUid(uid string) ([]common.Credential, error) {
+	return nil, nil
+}
+
+func (c testIamClient) GetCredential(accessKey string) (common.Credential, error) {
+	credential, ok := c[accessKey]
+	if !ok {
+		return credential, errNoSuchKey
+	}
+	return credential, nil
+}
+
+const testSessionPolicy = `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow",
+	"Action": ["s3:GetObject"], "Resource": ["arn:aws:s3:::bucket/*"]}]}`
+
+func newTestParent() common.Credential {
+	helper.CONFIG.StsKey = "sts key of test, 0123456789abcdefghijklmnopqrstuvwxyz"
+	parent := common.Credential{
+		UserId:          "user",
+		DisplayName:     "user",
+		AccessKeyID:     "PARENTACCESSKEY",
+		SecretAccessKey: "parent secret key",
+	}
+	iamClient = testIamClient{parent.AccessKeyID: parent}
+	return parent
+}
+
+func TestSessionCredential(t *testing.T) {
+	parent := newTestParent()
+	issued, err := NewSessionCredential(parent, time.Hour, testSessionPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issued.AccessKeyID) != sessionAccessKeyLength ||
+		!strings.HasPrefix(issued.AccessKeyID, sessionAccessKeyPrefix) ||
+		len(issued.SecretAccessKey) != sessionSecretKeyLength {
+		t.Fatalf("Malformed keys %q %q", issued.AccessKeyID, issued.SecretAccessKey)
+	}
+	if issued.AccessKeyID == parent.AccessKeyID ||
+		issued.SecretAccessKey == parent.SecretAccessKey {
+		t.Fatal("Temporary keys should differ from the parent ones")
+	}
+
+	credential, err := GetSessionCredential(issued.AccessKeyID, issued.SessionToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credential != issued {
+		t.Errorf("Expected %+v, got %+v", issued, credential)
+	}
+	if credential.UserId != parent.UserId || credential.SessionPolicy != testSessionPolicy {
+		t.Errorf("Session should act as %q with its policy, got %+v", parent.UserId, credential)
+	}
+	if credential.Expiration.Before(time.Now().Add(59*time.Minute)) ||
+		credential.Expiration.After(time.Now().Add(time.Hour)) {
+		t.Error("Wrong expiration", credential.Expiration)
+	}
+
+	another, err := NewSessionCredential(parent, time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if another.AccessKeyID == issued.AccessKeyID || another.SessionToken == issued.SessionToken {
+		t.Error("Every session should have its own keys")
+	}
+}
+
+func TestSessionCredentialExpired(t *testing.T) {
+	parent := newTestParent()
+	issued, err := NewSessionCredential(parent, -time.Second, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = GetSessionCredential(issued.AccessKeyID, issued.SessionToken)
+	if err != common.ErrExpiredSessionToken {
+		t.Errorf("Expected error %v, got %v", common.ErrExpiredSessionToken, err)
+	}
+}
+
+func TestSessionCredentialInvalid(t *testing.T) {
+	parent := newTestParent()
+	issued, err := NewSessionCredential(parent, time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewSessionCredential(parent, time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := base64.RawURLEncoding.DecodeString(issued.SessionToken)
+	tampered := make([]byte, len(data))
+	copy(tampered, data)
+	tampered[len(tampered)/2] ^= 1
+
+	testCases := []struct {
+		accessKey string
+		token     string
+	}{
+		{issued.AccessKeyID, ""},
+		{issued.AccessKeyID, "!not base64!"},
+		{issued.AccessKeyID, base64.RawURLEncoding.EncodeToString(tampered)},
+		{issued.AccessKeyID, base64.RawURLEncoding.EncodeToString(data[:len(data)-1])},
+		{issued.AccessKeyID, base64.RawURLEncoding.EncodeToString(data[:8])},
+		// token presented with another access key
+		{other.AccessKeyID, issued.SessionToken},
+		{parent.AccessKeyID, issued.SessionToken},
+	}
+	for i, testCase := range testCases {
+		_, err := GetSessionCredential(testCase.accessKey, testCase.token)
+		if err != common.ErrInvalidSessionToken {
+			t.Errorf("Test %d: expected error %v, got %v", i+1, common.ErrInvalidSessionToken, err)
+		}
+	}
+
+	helper.CONFIG.StsKey = "another sts key, 0123456789abcdefghijklmnopqrstuvwxyz"
+	_, err = GetSessionCredential(issued.AccessKeyID, issued.SessionToken)
+	if err != common.ErrInvalidSessionToken {
+		t.Error("Token should be rejected after sts_key changed, got", err)
+	}
+}
+
+// Revoking the parent key revokes the sessions derived from it
+func TestSessionCredentialParentRevoked(t *testing.T) {
+	parent := newTestParent()
+	parent.AccessKeyID = "REVOKEDACCESSKEY"
+	issued, err := NewSessionCredential(parent, time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = GetSessionCredential(issued.AccessKeyID, issued.SessionToken)
+	if err != errNoSuchKey {
+		t.Errorf("Expected error %v, got %v", errNoSuchKey, err)
+	}
+}
+
+// allBytes reads every byte value in turn
+type allBytes struct {
+	next byte
+}
+
+func (r *allBytes) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.next
+		r.next++
+	}
+	return len(p), nil
+}
+
+func TestRandomString(t *testing.T) {
+	defer func(reader io.Reader) { rand.Reader = reader }(rand.Reader)
+	rand.Reader = &allBytes{}
+
+	// 10 rounds of all the byte values, only 252 of 256 are used in each
+	s, err := randomString(accessKeyTable, 2520)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[byte]int)
+	for i := range s {
+		counts[s[i]]++
+	}
+	for _, c := range accessKeyTable {
+		if counts[c] != 70 {
+			t.Errorf("Character %q drawn %d times, expected 70", c, counts[c])
+		}
+	}
+	if len(counts) != len(accessKeyTable) {
+		t.Errorf("Expected %d characters, got %d", len(accessKeyTable), len(counts))
+	}
+}
@@ -50,4 +50,130 @@ CREATE TABLE `objects` (
    UNIQUE KEY `rowkey` (`bucketname`,`name`,`version`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
 
-INSERT INTO `objects` SELECT * FROM `objects_bak`;
\ No newline at end of file
+INSERT INTO `objects` SELECT * FROM `objects_bak`;
+
+-- restore tier of glacier objects
+
+ALTER TABLE `restoreobjects`
+	ADD COLUMN `tier` tinyint(1) DEFAULT '0';
+
+-- status of ceph cluster, 0 for active, 1 for readonly, 2 for draining
+
+ALTER TABLE `cluster`
+	ADD COLUMN `status` tinyint(1) DEFAULT '0';
+
+-- small objects packed into volumes
+
+ALTER TABLE `objects`
+	ADD COLUMN `packed` tinyint(1) DEFAULT '0',
+	ADD COLUMN `volumeoffset` bigint(20) DEFAULT '0',
+	ADD INDEX `objectid` (`objectid`);
+
+CREATE TABLE IF NOT EXISTS `volumes` (
+  `location` varchar(255) DEFAULT NULL,
+  `pool` varchar(255) DEFAULT NULL,
+  `volumeid` varchar(255) DEFAULT NULL,
+  `size` bigint(20) DEFAULT 0,
+  `deletedsize` bigint(20) DEFAULT 0,
+  `sealed` tinyint(1) DEFAULT 0,
+  `mtime` datetime DEFAULT NULL,
+   UNIQUE KEY `rowkey` (`location`,`pool`,`volumeid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+-- deduplicated data shared by objects and parts
+
+ALTER TABLE `objects`
+	ADD COLUMN `contenthash` varchar(64) DEFAULT '';
+
+ALTER TABLE `objectpart`
+	ADD COLUMN `contenthash` varchar(64) DEFAULT '';
+
+ALTER TABLE `multipartpart`
+	ADD COLUMN `contenthash` varchar(64) DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS `blobs` (
+  `hash` varchar(64) NOT NULL,
+  `bucketname` varchar(255) NOT NULL DEFAULT '',
+  `location` varchar(255) DEFAULT NULL,
+  `pool` varchar(255) DEFAULT NULL,
+  `objectid` varchar(255) DEFAULT NULL,
+  `size` bigint(20) DEFAULT 0,
+  `refcount` bigint(20) DEFAULT 0,
+  `encryptionkey` blob DEFAULT NULL,
+  `initializationvector` blob DEFAULT NULL,
+  `mtime` datetime DEFAULT NULL,
+   PRIMARY KEY (`hash`,`bucketname`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+-- checksums of data, and data found lost or corrupted by scrubbing
+
+ALTER TABLE `objects`
+	ADD COLUMN `checksum` varchar(16) DEFAULT '';
+
+ALTER TABLE `objectpart`
+	ADD COLUMN `checksum` varchar(16) DEFAULT '';
+
+ALTER TABLE `multipartpart`
+	ADD COLUMN `checksum` varchar(16) DEFAULT '';
+
+ALTER TABLE `blobs`
+	ADD COLUMN `checksum` varchar(16) DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS `scrubfailures` (
+  `bucketname` varchar(255) NOT NULL,
+  `objectname` varchar(255) NOT NULL,
+  `version` bigint(20) UNSIGNED NOT NULL,
+  `partnumber` int(11) NOT NULL DEFAULT 0,
+  `location` varchar(255) DEFAULT NULL,
+  `pool` varchar(255) DEFAULT NULL,
+  `objectid` varchar(255) DEFAULT NULL,
+  `reason` varchar(64) DEFAULT NULL,
+  `expected` varchar(255) DEFAULT NULL,
+  `actual` varchar(255) DEFAULT NULL,
+  `mtime` datetime DEFAULT NULL,
+   PRIMARY KEY (`bucketname`,`objectname`,`version`,`partnumber`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+-- additional checksums of data sent by client, x-amz-checksum-*
+
+ALTER TABLE `objects`
+	ADD COLUMN `checksumalgorithm` varchar(16) DEFAULT '',
+	ADD COLUMN `checksumvalue` varchar(64) DEFAULT '';
+
+ALTER TABLE `objectpart`
+	ADD COLUMN `checksumalgorithm` varchar(16) DEFAULT '',
+	ADD COLUMN `checksumvalue` varchar(64) DEFAULT '';
+
+ALTER TABLE `multipartpart`
+	ADD COLUMN `checksumalgorithm` varchar(16) DEFAULT '',
+	ADD COLUMN `checksumvalue` varchar(64) DEFAULT '';
+
+ALTER TABLE `multiparts`
+	ADD COLUMN `checksumalgorithm` varchar(16) DEFAULT '';
+
+-- bucket inventory configurations
+
+CREATE TABLE IF NOT EXISTS `inventories` (
+  `bucketname` varchar(255) NOT NULL,
+  `id` varchar(64) NOT NULL,
+  `config` text DEFAULT NULL,
+  `lastrun` datetime DEFAULT NULL,
+   PRIMARY KEY (`bucketname`,`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+-- bucket tagging and requester pays
+
+ALTER TABLE `buckets`
+	ADD COLUMN `tagging` JSON DEFAULT NULL,
+	ADD COLUMN `requestpayer` varchar(16) DEFAULT '';
+
+-- time restoration was requested, and rows locked to serialize transactions
+
+ALTER TABLE `restoreobjects`
+	ADD COLUMN `requesttime` datetime DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS `locks` (
+  `name` varchar(255) NOT NULL,
+  `version` bigint(20) DEFAULT 0,
+   PRIMARY KEY (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
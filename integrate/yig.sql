@@ -35,6 +35,8 @@ CREATE TABLE `buckets` (
   `createtime` datetime DEFAULT NULL,
   `usages` bigint(20) DEFAULT NULL,
   `versioning` varchar(255) DEFAULT NULL,
+  `tagging` JSON DEFAULT NULL,
+  `requestpayer` varchar(16) DEFAULT '',
   PRIMARY KEY (`bucketname`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -50,6 +52,7 @@ CREATE TABLE `cluster` (
   `fsid` varchar(255) DEFAULT NULL,
   `pool` varchar(255) DEFAULT NULL,
   `weight` int(11) DEFAULT NULL,
+  `status` tinyint(1) DEFAULT '0',
    UNIQUE KEY `rowkey` (`fsid`,`pool`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -113,6 +116,10 @@ CREATE TABLE `multipartpart` (
   `etag` varchar(255) DEFAULT NULL,
   `lastmodified` datetime DEFAULT NULL,
   `initializationvector` blob DEFAULT NULL,
+  `contenthash` varchar(64) DEFAULT '',
+  `checksum` varchar(16) DEFAULT '',
+  `checksumalgorithm` varchar(16) DEFAULT '',
+  `checksumvalue` varchar(64) DEFAULT '',
   `bucketname` varchar(255) DEFAULT NULL,
   `objectname` varchar(255) DEFAULT NULL,
   `uploadtime` bigint(20) UNSIGNED DEFAULT NULL,
@@ -142,6 +149,7 @@ CREATE TABLE `multiparts` (
   `cipher` blob DEFAULT NULL,
   `attrs` JSON DEFAULT NULL,
   `storageclass` tinyint(1) DEFAULT 0,
+  `checksumalgorithm` varchar(16) DEFAULT '',
   UNIQUE KEY `rowkey` (`bucketname`,`objectname`,`uploadtime`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -161,6 +169,10 @@ CREATE TABLE `objectpart` (
   `etag` varchar(255) DEFAULT NULL,
   `lastmodified` datetime DEFAULT NULL,
   `initializationvector` blob DEFAULT NULL,
+  `contenthash` varchar(64) DEFAULT '',
+  `checksum` varchar(16) DEFAULT '',
+  `checksumalgorithm` varchar(16) DEFAULT '',
+  `checksumvalue` varchar(64) DEFAULT '',
   `bucketname` varchar(255) DEFAULT NULL,
   `objectname` varchar(255) DEFAULT NULL,
   `version` varchar(255) DEFAULT NULL,
@@ -197,7 +209,14 @@ CREATE TABLE `objects` (
   `initializationvector` blob DEFAULT NULL,
   `type` tinyint(1) DEFAULT 0,
   `storageclass` tinyint(1) DEFAULT 0,
-   UNIQUE KEY `rowkey` (`bucketname`,`name`,`version`)
+  `packed` tinyint(1) DEFAULT 0,
+  `volumeoffset` bigint(20) DEFAULT 0,
+  `contenthash` varchar(64) DEFAULT '',
+  `checksum` varchar(16) DEFAULT '',
+  `checksumalgorithm` varchar(16) DEFAULT '',
+  `checksumvalue` varchar(64) DEFAULT '',
+   UNIQUE KEY `rowkey` (`bucketname`,`name`,`version`),
+   KEY `objectid` (`objectid`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
@@ -243,6 +262,8 @@ CREATE TABLE `restoreobjects` (
   `size` bigint(20) DEFAULT NULL,
   `objectid` varchar(255) DEFAULT NULL,
   `etag` varchar(255) DEFAULT NULL,
+  `tier` tinyint(1) DEFAULT '0',
+  `requesttime` datetime DEFAULT NULL,
   UNIQUE KEY `rowkey` (`bucketname`,`objectname`,`version`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -274,6 +295,100 @@ CREATE TABLE `users` (
   `bucketname` varchar(255) DEFAULT NULL
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
 /*!40101 SET character_set_client = @saved_cs_client */;
+--
+-- Table structure for table `volumes`
+--
+
+DROP TABLE IF EXISTS `volumes`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `volumes` (
+  `location` varchar(255) DEFAULT NULL,
+  `pool` varchar(255) DEFAULT NULL,
+  `volumeid` varchar(255) DEFAULT NULL,
+  `size` bigint(20) DEFAULT 0,
+  `deletedsize` bigint(20) DEFAULT 0,
+  `sealed` tinyint(1) DEFAULT 0,
+  `mtime` datetime DEFAULT NULL,
+   UNIQUE KEY `rowkey` (`location`,`pool`,`volumeid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `blobs`
+--
+
+DROP TABLE IF EXISTS `blobs`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `blobs` (
+  `hash` varchar(64) NOT NULL,
+  `bucketname` varchar(255) NOT NULL DEFAULT '',
+  `location` varchar(255) DEFAULT NULL,
+  `pool` varchar(255) DEFAULT NULL,
+  `objectid` varchar(255) DEFAULT NULL,
+  `size` bigint(20) DEFAULT 0,
+  `refcount` bigint(20) DEFAULT 0,
+  `encryptionkey` blob DEFAULT NULL,
+  `initializationvector` blob DEFAULT NULL,
+  `checksum` varchar(16) DEFAULT '',
+  `mtime` datetime DEFAULT NULL,
+   PRIMARY KEY (`hash`,`bucketname`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `scrubfailures`
+--
+
+DROP TABLE IF EXISTS `scrubfailures`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `scrubfailures` (
+  `bucketname` varchar(255) NOT NULL,
+  `objectname` varchar(255) NOT NULL,
+  `version` bigint(20) UNSIGNED NOT NULL,
+  `partnumber` int(11) NOT NULL DEFAULT 0,
+  `location` varchar(255) DEFAULT NULL,
+  `pool` varchar(255) DEFAULT NULL,
+  `objectid` varchar(255) DEFAULT NULL,
+  `reason` varchar(64) DEFAULT NULL,
+  `expected` varchar(255) DEFAULT NULL,
+  `actual` varchar(255) DEFAULT NULL,
+  `mtime` datetime DEFAULT NULL,
+   PRIMARY KEY (`bucketname`,`objectname`,`version`,`partnumber`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `inventories`
+--
+
+DROP TABLE IF EXISTS `inventories`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `inventories` (
+  `bucketname` varchar(255) NOT NULL,
+  `id` varchar(64) NOT NULL,
+  `config` text DEFAULT NULL,
+  `lastrun` datetime DEFAULT NULL,
+   PRIMARY KEY (`bucketname`,`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `locks`
+--
+
+DROP TABLE IF EXISTS `locks`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `locks` (
+  `name` varchar(255) NOT NULL,
+  `version` bigint(20) DEFAULT 0,
+   PRIMARY KEY (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+/*!40101 SET character_set_client = @saved_cs_client */;
 /*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;
 
 /*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
@@ -7,6 +7,7 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"sync/atomic"
 )
 
 type Level int
@@ -32,7 +33,7 @@ func ParseLevel(levelString string) Level {
 
 type Logger struct {
 	out       io.WriteCloser
-	level     Level
+	level     *int32 // shared by loggers derived, so level could be changed
 	logger    *log.Logger
 	requestID string
 }
@@ -50,12 +51,25 @@ func NewFileLogger(path string, logLevel Level) Logger {
 func NewLogger(out io.WriteCloser, logLevel Level) Logger {
 	l := Logger{
 		out:    out,
-		level:  logLevel,
+		level:  new(int32),
 		logger: log.New(out, "", logFlags),
 	}
+	l.SetLevel(logLevel)
 	return l
 }
 
+// SetLevel changes level of the logger and all loggers derived from it
+func (l Logger) SetLevel(level Level) {
+	atomic.StoreInt32(l.level, int32(level))
+}
+
+func (l Logger) getLevel() Level {
+	if l.level == nil { // zero Logger
+		return ErrorLevel
+	}
+	return Level(atomic.LoadInt32(l.level))
+}
+
 func (l Logger) NewWithRequestID(requestID string) Logger {
 	return Logger{
 		out:       l.out,
@@ -85,7 +99,7 @@ func (l Logger) prefixArray() []interface{} {
 }
 
 func (l Logger) Info(args ...interface{}) {
-	if l.level < InfoLevel {
+	if l.getLevel() < InfoLevel {
 		return
 	}
 	prefixArray := l.prefixArray()
@@ -95,7 +109,7 @@ func (l Logger) Info(args ...interface{}) {
 }
 
 func (l Logger) Warn(args ...interface{}) {
-	if l.level < WarnLevel {
+	if l.getLevel() < WarnLevel {
 		return
 	}
 	prefixArray := l.prefixArray()
@@ -105,7 +119,7 @@ func (l Logger) Warn(args ...interface{}) {
 }
 
 func (l Logger) Error(args ...interface{}) {
-	if l.level < ErrorLevel {
+	if l.getLevel() < ErrorLevel {
 		return
 	}
 	prefixArray := l.prefixArray()
@@ -120,6 +134,14 @@ func (l Logger) Println(args ...interface{}) {
 	_, _ = l.out.Write([]byte(fmt.Sprintln(args...)))
 }
 
+// Flush commits logs written to stable storage, if supported by the output
+func (l Logger) Flush() error {
+	if s, ok := l.out.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
 func (l Logger) Close() error {
 	return l.out.Close()
 }
@@ -29,17 +29,36 @@ func DumpStacks() {
 	helper.Logger.Error("*** dump end")
 }
 
+// flushMessageSender sends out messages queued, e.g. access log entries
+// of requests completed during draining
+func flushMessageSender(sender bus.MessageSender) {
+	timeout := int(helper.CONFIG.ShutdownDrainSeconds * 1000)
+	if err := sender.Flush(timeout); err != nil {
+		helper.Logger.Error("Failed to flush message queue sender, err:", err)
+	}
+	sender.Close()
+}
+
 func main() {
 	// Errors should cause panic so as to log to stderr for initialization functions
 
 	rand.Seed(time.Now().UnixNano())
 
+	if len(os.Args) > 1 && os.Args[1] == "check-config" {
+		os.Exit(checkConfig(os.Args[2:]))
+	}
+
 	helper.SetupConfig()
+	helper.CheckConfigFiles()
 
 	// yig log
 	logLevel := log.ParseLevel(helper.CONFIG.LogLevel)
 	helper.Logger = log.NewFileLogger(helper.CONFIG.LogPath, logLevel)
 	defer helper.Logger.Close()
+	helper.SubscribeConfig("logger", []string{"log_level"}, func(config *helper.Config) error {
+		helper.Logger.SetLevel(log.ParseLevel(config.LogLevel))
+		return nil
+	})
 	helper.Logger.Info("YIG conf:", helper.CONFIG)
 	helper.Logger.Info("YIG instance ID:", helper.CONFIG.InstanceId)
 	// access log
@@ -65,6 +84,9 @@ func main() {
 	if redis.Pool() != nil && helper.CONFIG.CacheCircuitCheckInterval != 0 {
 		go yig.PingCache(time.Duration(helper.CONFIG.CacheCircuitCheckInterval) * time.Second)
 	}
+	if helper.CONFIG.ClusterCircuitCheckInterval != 0 {
+		go yig.PingClusters(time.Duration(helper.CONFIG.ClusterCircuitCheckInterval) * time.Second)
+	}
 
 	// try to create message queue sender if message bus is enabled.
 	// message queue sender is singleton so create it beforehand.
@@ -74,10 +96,10 @@ func main() {
 		panic("failed to create message bus sender")
 	}
 	if mqSender == nil {
-		helper.Logger.Error("Failed to create message queue sender, sender is nil.")
-		panic("failed to create message queue sender, sender is nil.")
+		helper.Logger.Warn("No message queue plugin is enabled, access log is not sent to message queue.")
+	} else {
+		helper.Logger.Info("Succeed to create message queue sender.")
 	}
-	helper.Logger.Info("Succeed to create message queue sender.")
 
 	// try to create compression if it is enabled.
 	if helper.CONFIG.EnableCompression == true {
@@ -123,15 +145,22 @@ func main() {
 		s := <-signalQueue
 		switch s {
 		case syscall.SIGHUP:
-			// reload config file
-			helper.SetupConfig()
+			// reload config file, failures are logged and the config in
+			// use is kept
+			helper.ReloadConfig()
 		case syscall.SIGUSR1:
 			go DumpStacks()
 		default:
-			// stop YIG server, order matters
-			stopAdminServer()
+			// stop YIG server, order matters.
+			// admin server is stopped last so readiness probes could see
+			// the server is draining.
 			stopApiServer()
 			yig.Stop()
+			if mqSender != nil {
+				flushMessageSender(mqSender)
+			}
+			helper.AccessLogger.Flush()
+			stopAdminServer()
 			return
 		}
 	}
@@ -0,0 +1,13 @@
+package meta
+
+import (
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+func (m *Meta) RefBlob(blob Blob) (Blob, error) {
+	return m.Client.RefBlob(blob)
+}
+
+func (m *Meta) UnrefBlob(hash, bucketName string) (bool, error) {
+	return m.Client.UnrefBlob(hash, bucketName, nil)
+}
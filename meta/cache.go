@@ -1,6 +1,11 @@
 package meta
 
 import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/journeymidnight/yig/helper"
 	"github.com/journeymidnight/yig/redis"
 	"database/sql"
@@ -27,14 +32,21 @@ type MetaCache interface {
 type disabledMetaCache struct{}
 
 type entry struct {
-	table redis.RedisDatabase
-	key   string
-	value interface{}
+	table  redis.RedisDatabase
+	key    string // hashed key, as in redis
+	value  []byte // msgpack encoded, so callers never share a cached value
+	expire time.Time
 }
 
 func newMetaCache(myType CacheType) (m MetaCache) {
 
 	helper.Logger.Info("Setting Up Metadata Cache:", cacheNames[int(myType)])
+	if myType == EnableCache {
+		m := newEnabledMetaCache(helper.CONFIG.MemoryCacheMaxEntryCount,
+			time.Duration(helper.CONFIG.MemoryCacheEntryTTL)*time.Second)
+		go redis.SubscribeInvalid(redis.MetadataTables, m.purge, m.invalid)
+		return m
+	}
 	if myType == SimpleCache {
 		m := new(enabledSimpleMetaCache)
 		m.Hit = 0
@@ -110,3 +122,153 @@ func (m *enabledSimpleMetaCache) Remove(table redis.RedisDatabase, key string) {
 func (m *enabledSimpleMetaCache) GetCacheHitRatio() float64 {
 	return float64(m.Hit) / float64(m.Hit+m.Miss)
 }
+
+// enabledMetaCache is an in-memory LRU cache in front of redis, entries
+// removed by other YIG instances are invalidated through redis pub/sub, and
+// expire after `ttl` in case invalid messages are lost
+type enabledMetaCache struct {
+	simple enabledSimpleMetaCache
+
+	lock       sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	lru        *list.List
+	entries    map[redis.RedisDatabase]map[string]*list.Element
+	// increased on every invalidation, so values read before it are not
+	// cached after it
+	generation uint64
+
+	Hit  int64
+	Miss int64
+}
+
+func newEnabledMetaCache(maxEntries int, ttl time.Duration) *enabledMetaCache {
+	m := &enabledMetaCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		lru:        list.New(),
+		entries:    make(map[redis.RedisDatabase]map[string]*list.Element),
+	}
+	for _, table := range redis.MetadataTables {
+		m.entries[table] = make(map[string]*list.Element)
+	}
+	return m
+}
+
+func (m *enabledMetaCache) Get(table redis.RedisDatabase, key string,
+	onCacheMiss func() (interface{}, error),
+	unmarshaller func([]byte) (interface{}, error), willNeed bool) (value interface{}, err error) {
+
+	if _, ok := m.entries[table]; !ok {
+		return m.simple.Get(table, key, onCacheMiss, unmarshaller, willNeed)
+	}
+	hashkey, err := redis.HashSum(key)
+	if err != nil {
+		return nil, err
+	}
+	encoded, generation, ok := m.get(table, hashkey)
+	if ok {
+		value, err = unmarshaller(encoded)
+		if err == nil {
+			atomic.AddInt64(&m.Hit, 1)
+			return value, nil
+		}
+		helper.Logger.Warn("enabledMetaCache.Get unmarshal err:", err,
+			"table:", table, "key:", key)
+	}
+	atomic.AddInt64(&m.Miss, 1)
+
+	value, err = m.simple.Get(table, key, onCacheMiss, unmarshaller, willNeed)
+	if err != nil || value == nil || !willNeed {
+		return
+	}
+	encoded, err = helper.MsgPackMarshal(value)
+	if err != nil {
+		helper.Logger.Warn("enabledMetaCache.Get marshal err:", err,
+			"table:", table, "key:", key)
+		return value, nil
+	}
+	m.set(table, hashkey, encoded, generation)
+	return value, nil
+}
+
+func (m *enabledMetaCache) Remove(table redis.RedisDatabase, key string) {
+	hashkey, err := redis.HashSum(key)
+	if err == nil {
+		m.invalid(table, hashkey)
+	}
+	m.simple.Remove(table, key)
+	redis.Invalid(table, key)
+}
+
+func (m *enabledMetaCache) GetCacheHitRatio() float64 {
+	hit, miss := atomic.LoadInt64(&m.Hit), atomic.LoadInt64(&m.Miss)
+	return float64(hit) / float64(hit+miss)
+}
+
+func (m *enabledMetaCache) get(table redis.RedisDatabase, hashkey string) ([]byte, uint64, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	element, ok := m.entries[table][hashkey]
+	if !ok {
+		return nil, m.generation, false
+	}
+	if time.Now().After(element.Value.(*entry).expire) {
+		m.lru.Remove(element)
+		delete(m.entries[table], hashkey)
+		return nil, m.generation, false
+	}
+	m.lru.MoveToFront(element)
+	return element.Value.(*entry).value, m.generation, true
+}
+
+// set caches `value` read at `generation`, unless invalidated since then
+func (m *enabledMetaCache) set(table redis.RedisDatabase, hashkey string, value []byte,
+	generation uint64) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if generation != m.generation {
+		return
+	}
+	expire := time.Now().Add(m.ttl)
+	if element, ok := m.entries[table][hashkey]; ok {
+		element.Value.(*entry).value = value
+		element.Value.(*entry).expire = expire
+		m.lru.MoveToFront(element)
+		return
+	}
+	m.entries[table][hashkey] = m.lru.PushFront(&entry{
+		table:  table,
+		key:    hashkey,
+		value:  value,
+		expire: expire,
+	})
+	for m.lru.Len() > m.maxEntries {
+		oldest := m.lru.Remove(m.lru.Back()).(*entry)
+		delete(m.entries[oldest.table], oldest.key)
+	}
+}
+
+// invalid removes entry from memory only, called on invalid messages
+func (m *enabledMetaCache) invalid(table redis.RedisDatabase, hashkey string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.generation++
+	if element, ok := m.entries[table][hashkey]; ok {
+		m.lru.Remove(element)
+		delete(m.entries[table], hashkey)
+	}
+}
+
+// purge removes all entries from memory, since invalid messages may be lost
+// while not subscribing
+func (m *enabledMetaCache) purge() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.generation++
+	m.lru.Init()
+	for _, table := range redis.MetadataTables {
+		m.entries[table] = make(map[string]*list.Element)
+	}
+}
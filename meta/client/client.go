@@ -1,9 +1,11 @@
 package client
 
 import (
+	"context"
 	"database/sql"
 	"github.com/journeymidnight/yig/api/datatype"
 	. "github.com/journeymidnight/yig/meta/types"
+	"time"
 )
 
 //DB Client Interface
@@ -15,6 +17,7 @@ type Client interface {
 	//object
 	GetObject(bucketName, objectName, version string) (object *Object, err error)
 	GetAllObject(bucketName, objectName, version string) (object []*Object, err error)
+	CheckObjectCondition(bucketName, objectName string, condition *datatype.WriteCondition, tx DB) error
 	PutObject(object *Object, tx DB) error
 	UpdateAppendObject(object *Object, tx DB) error
 	RenameObjectPart(object *Object, sourceObject string, tx DB) (err error)
@@ -24,6 +27,9 @@ type Client interface {
 	UpdateObject(object *Object, tx DB) (err error)
 	UpdateObjectAcl(object *Object) error
 	UpdateObjectAttrs(object *Object) error
+	ScanObjectsByLocation(location, pool string, limit int, bucketName, objectName string, version uint64) (result ScanObjectResult, err error)
+	MigrateObject(object, source *Object, tx DB) (migrated bool, err error)
+	ScanBucketObjects(bucketName, prefix string, limit int, objectName string, version uint64) (result ScanObjectResult, err error)
 	//bucket
 	GetBucket(bucketName string) (bucket *Bucket, err error)
 	GetBuckets() (buckets []Bucket, err error)
@@ -39,12 +45,39 @@ type Client interface {
 	PutObjectPart(multipart *Multipart, part *Part, tx DB) (err error)
 	DeleteMultipart(multipart *Multipart, tx DB) (err error)
 	ListMultipartUploads(bucketName, keyMarker, uploadIdMarker, prefix, delimiter, encodingType string, maxUploads int) (uploads []datatype.Upload, prefixs []string, isTruncated bool, nextKeyMarker, nextUploadIdMarker string, err error)
+	CountMultipartsByLocation(location, pool string) (count int64, err error)
 	//objmap
 	GetObjectMap(bucketName, objectName string) (objMap *ObjMap, err error)
 	PutObjectMap(objMap *ObjMap, tx DB) error
 	DeleteObjectMap(objMap *ObjMap, tx DB) error
 	//cluster
 	GetClusters() (cluster []Cluster, err error)
+	PutCluster(cluster Cluster, tx DB) (err error)
+	UpdateClusterWeight(fsid, pool string, weight int) (err error)
+	UpdateClusterStatus(fsid, pool string, status ClusterStatus) (err error)
+	//volume
+	PutVolume(volume Volume) (err error)
+	SealVolume(location, pool, volumeId string, size int64) (err error)
+	AddVolumeDeletedSize(location, pool, volumeId string, size int64, tx DB) (err error)
+	ScanVolumes(limit int, location, pool, volumeId string) (volumes []Volume, err error)
+	ListVolumeObjects(volume Volume) (objects []*Object, err error)
+	DeleteVolume(volume Volume, tx DB) (err error)
+	//blob
+	RefBlob(blob Blob) (referenced Blob, err error)
+	UnrefBlob(hash, bucketName string, tx DB) (released bool, err error)
+	//scrub
+	PutScrubFailure(failure ScrubFailure) (err error)
+	RemoveScrubFailures(bucketName, objectName string, version uint64) (err error)
+	ListScrubFailures(limit int, bucketName, objectName string, version uint64) (failures []ScrubFailure, err error)
+	CountScrubFailures() (counts []ScrubFailureCount, err error)
+	//inventory
+	PutInventory(inventory Inventory) (err error)
+	GetInventory(bucketName, id string) (inventory Inventory, err error)
+	DeleteInventory(bucketName, id string) (err error)
+	DeleteBucketInventories(bucketName string) (err error)
+	ListInventories(bucketName, marker string, limit int) (inventories []Inventory, err error)
+	ScanInventories(limit int, bucketName, id string) (inventories []Inventory, err error)
+	UpdateInventoryLastRun(bucketName, id string, lastRun time.Time) (err error)
 	//lc
 	PutBucketToLifeCycle(lifeCycle LifeCycle) error
 	RemoveBucketFromLifeCycle(bucket Bucket) error
@@ -59,9 +92,15 @@ type Client interface {
 	ScanGarbageCollection(limit int, startRowKey string) ([]GarbageCollection, error)
 	RemoveGarbageCollection(garbage GarbageCollection) error
 	//freezer
-	CreateFreezer(freezer *Freezer) (err error)
+	CreateFreezer(freezer *Freezer, capacity int64) (err error)
 	GetFreezer(bucketName, objectName, version string) (freezer *Freezer, err error)
 	GetFreezerStatus(bucketName, objectName, version string) (freezer *Freezer, err error)
 	UploadFreezerDate(bucketName, objectName string, lifetime int) (err error)
 	DeleteFreezer(bucketName, objectName string, tx DB) (err error)
+	ScanFreezers(limit int, bucketName, objectName string, status Status) (result ScanFreezerResult, err error)
+	ListFreezersByTier(tier RestoreTier, status Status, requestedBefore time.Time, limit int) (freezers []Freezer, err error)
+	UpdateFreezerStatus(bucketName, objectName string, status, targetStatus Status) (updated bool, err error)
+	PutFreezer(freezer *Freezer, status Status, tx DB) (err error)
+	//health
+	Ping(ctx context.Context) error
 }
@@ -0,0 +1,92 @@
+package tidbclient
+
+import (
+	"database/sql"
+	"time"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+// RefBlob adds a reference to the blob with the same hash, or creates `blob`
+// with one reference if there is none. It returns the referenced blob, data
+// in `blob` is not referenced if it's not the returned one.
+func (t *TidbClient) RefBlob(blob Blob) (referenced Blob, err error) {
+	tx, err := t.Client.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	mtime := blob.MTime.Format(TIME_LAYOUT_TIDB)
+	sqltext := "insert into blobs(hash,bucketname,location,pool,objectid,size,refcount,encryptionkey," +
+		"initializationvector,checksum,mtime) values(?,?,?,?,?,?,1,?,?,?,?) on duplicate key update refcount=refcount+1"
+	_, err = tx.Exec(sqltext, blob.Hash, blob.BucketName, blob.Location, blob.Pool, blob.ObjectId,
+		blob.Size, blob.EncryptionKey, blob.InitializationVector, blob.Checksum, mtime)
+	if err != nil {
+		return
+	}
+	sqltext = "select location,pool,objectid,size,refcount,encryptionkey,initializationvector,checksum,mtime " +
+		"from blobs where hash=? and bucketname=?"
+	referenced = Blob{Hash: blob.Hash, BucketName: blob.BucketName}
+	err = tx.QueryRow(sqltext, blob.Hash, blob.BucketName).Scan(
+		&referenced.Location,
+		&referenced.Pool,
+		&referenced.ObjectId,
+		&referenced.Size,
+		&referenced.RefCount,
+		&referenced.EncryptionKey,
+		&referenced.InitializationVector,
+		&referenced.Checksum,
+		&mtime,
+	)
+	if err != nil {
+		return
+	}
+	referenced.MTime, err = time.Parse(TIME_LAYOUT_TIDB, mtime)
+	return
+}
+
+// UnrefBlob removes a reference to the blob, `released` is true if it's the
+// last reference and the blob is deleted, data of the blob should be removed then.
+func (t *TidbClient) UnrefBlob(hash, bucketName string, tx DB) (released bool, err error) {
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		if err != nil {
+			return false, err
+		}
+		defer func() {
+			if err == nil {
+				err = tx.(*sql.Tx).Commit()
+			}
+			if err != nil {
+				tx.(*sql.Tx).Rollback()
+				released = false
+			}
+		}()
+	}
+
+	var refCount int64
+	sqltext := "select refcount from blobs where hash=? and bucketname=? for update"
+	err = tx.QueryRow(sqltext, hash, bucketName).Scan(&refCount)
+	if err == sql.ErrNoRows {
+		// already released
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if refCount > 1 {
+		sqltext = "update blobs set refcount=refcount-1 where hash=? and bucketname=?"
+		_, err = tx.Exec(sqltext, hash, bucketName)
+		return false, err
+	}
+	sqltext = "delete from blobs where hash=? and bucketname=?"
+	_, err = tx.Exec(sqltext, hash, bucketName)
+	return err == nil, err
+}
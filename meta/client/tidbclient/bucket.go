@@ -14,8 +14,8 @@ import (
 )
 
 func (t *TidbClient) GetBucket(bucketName string) (bucket *Bucket, err error) {
-	var acl, cors, logging, lc, policy, website, encryption, createTime string
-	sqltext := "select bucketname,acl,cors,COALESCE(logging,\"\"),lc,uid,policy,website,COALESCE(encryption,\"\"),createtime,usages,versioning from buckets where bucketname=?;"
+	var acl, cors, logging, lc, policy, website, encryption, createTime, tagging string
+	sqltext := "select bucketname,acl,cors,COALESCE(logging,\"\"),lc,uid,policy,website,COALESCE(encryption,\"\"),createtime,usages,versioning,COALESCE(tagging,\"{}\"),COALESCE(requestpayer,\"\") from buckets where bucketname=?;"
 	bucket = new(Bucket)
 	err = t.Client.QueryRow(sqltext, bucketName).Scan(
 		&bucket.Name,
@@ -30,6 +30,8 @@ func (t *TidbClient) GetBucket(bucketName string) (bucket *Bucket, err error) {
 		&createTime,
 		&bucket.Usage,
 		&bucket.Versioning,
+		&tagging,
+		&bucket.RequestPayer,
 	)
 	if err != nil && err == sql.ErrNoRows {
 		err = ErrNoSuchBucket
@@ -69,11 +71,15 @@ func (t *TidbClient) GetBucket(bucketName string) (bucket *Bucket, err error) {
 	if err != nil {
 		return
 	}
+	err = json.Unmarshal([]byte(tagging), &bucket.Tagging)
+	if err != nil {
+		return
+	}
 	return
 }
 
 func (t *TidbClient) GetBuckets() (buckets []Bucket, err error) {
-	sqltext := "select bucketname,acl,cors,COALESCE(logging,\"\"),lc,uid,policy,website,COALESCE(encryption,\"\"),createtime,usages,versioning from buckets;"
+	sqltext := "select bucketname,acl,cors,COALESCE(logging,\"\"),lc,uid,policy,website,COALESCE(encryption,\"\"),createtime,usages,versioning,COALESCE(tagging,\"{}\"),COALESCE(requestpayer,\"\") from buckets;"
 	rows, err := t.Client.Query(sqltext)
 	if err == sql.ErrNoRows {
 		err = nil
@@ -85,7 +91,7 @@ func (t *TidbClient) GetBuckets() (buckets []Bucket, err error) {
 
 	for rows.Next() {
 		var tmp Bucket
-		var acl, cors, logging, lc, policy, website,encryption, createTime string
+		var acl, cors, logging, lc, policy, website,encryption, createTime, tagging string
 		err = rows.Scan(
 			&tmp.Name,
 			&acl,
@@ -98,7 +104,9 @@ func (t *TidbClient) GetBuckets() (buckets []Bucket, err error) {
 			&encryption,
 			&createTime,
 			&tmp.Usage,
-			&tmp.Versioning)
+			&tmp.Versioning,
+			&tagging,
+			&tmp.RequestPayer)
 		if err != nil {
 			return
 		}
@@ -134,6 +142,10 @@ func (t *TidbClient) GetBuckets() (buckets []Bucket, err error) {
 		if err != nil {
 			return
 		}
+		err = json.Unmarshal([]byte(tagging), &tmp.Tagging)
+		if err != nil {
+			return
+		}
 		buckets = append(buckets, tmp)
 	}
 	return
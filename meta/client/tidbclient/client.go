@@ -1,6 +1,7 @@
 package tidbclient
 
 import (
+	"context"
 	"database/sql"
 	"os"
 	"time"
@@ -23,5 +24,17 @@ func NewTidbClient() *TidbClient {
 	conn.SetMaxOpenConns(helper.CONFIG.DbMaxOpenConns)
 	conn.SetConnMaxLifetime(time.Duration(helper.CONFIG.DbConnMaxLifeSeconds) * time.Second)
 	cli.Client = conn
+	helper.SubscribeConfig("tidb connection pool", []string{"db_max_open_conns",
+		"db_max_idle_conns", "db_conn_max_life_seconds"},
+		func(config *helper.Config) error {
+			conn.SetMaxIdleConns(config.DbMaxIdleConns)
+			conn.SetMaxOpenConns(config.DbMaxOpenConns)
+			conn.SetConnMaxLifetime(time.Duration(config.DbConnMaxLifeSeconds) * time.Second)
+			return nil
+		})
 	return cli
 }
+
+func (t *TidbClient) Ping(ctx context.Context) error {
+	return t.Client.PingContext(ctx)
+}
@@ -1,11 +1,14 @@
 package tidbclient
 
 import (
+	"database/sql"
+
+	. "github.com/journeymidnight/yig/error"
 	. "github.com/journeymidnight/yig/meta/types"
 )
 
 func (t *TidbClient) GetClusters() (cluster []Cluster, err error) {
-	sqltext := "select fsid,pool,weight from cluster"
+	sqltext := "select fsid,pool,weight,IFNULL(status,0) from cluster"
 	rows, err := t.Client.Query(sqltext)
 	if err != nil {
 		return nil, err
@@ -13,7 +16,7 @@ func (t *TidbClient) GetClusters() (cluster []Cluster, err error) {
 	defer rows.Close()
 	for rows.Next() {
 		c := Cluster{}
-		err = rows.Scan(&c.Fsid, &c.Pool, &c.Weight)
+		err = rows.Scan(&c.Fsid, &c.Pool, &c.Weight, &c.Status)
 		cluster = append(cluster, c)
 		if err != nil {
 			return nil, err
@@ -21,3 +24,73 @@ func (t *TidbClient) GetClusters() (cluster []Cluster, err error) {
 	}
 	return cluster, nil
 }
+
+func (t *TidbClient) PutCluster(cluster Cluster, tx DB) (err error) {
+	if tx == nil {
+		tx = t.Client
+	}
+	sqltext := "insert ignore into cluster(fsid,pool,weight,status) values(?,?,?,?)"
+	result, err := tx.Exec(sqltext, cluster.Fsid, cluster.Pool, cluster.Weight, cluster.Status)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrClusterAlreadyExists
+	}
+	return nil
+}
+
+func (t *TidbClient) UpdateClusterWeight(fsid, pool string, weight int) (err error) {
+	sqltext := "update cluster set weight=? where fsid=? and pool=?"
+	result, err := t.Client.Exec(sqltext, weight, fsid, pool)
+	if err != nil {
+		return err
+	}
+	return t.checkClusterUpdated(result, fsid, pool)
+}
+
+// UpdateClusterStatus updates status of all pools of the cluster if `pool` is empty
+func (t *TidbClient) UpdateClusterStatus(fsid, pool string, status ClusterStatus) (err error) {
+	sqltext := "update cluster set status=? where fsid=?"
+	args := []interface{}{status, fsid}
+	if pool != "" {
+		sqltext += " and pool=?"
+		args = append(args, pool)
+	}
+	result, err := t.Client.Exec(sqltext, args...)
+	if err != nil {
+		return err
+	}
+	return t.checkClusterUpdated(result, fsid, pool)
+}
+
+// rows not changed by update are not counted as affected, so query
+// the cluster before reporting ErrNoSuchCluster
+func (t *TidbClient) checkClusterUpdated(result sql.Result, fsid, pool string) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+	sqltext := "select count(*) from cluster where fsid=?"
+	args := []interface{}{fsid}
+	if pool != "" {
+		sqltext += " and pool=?"
+		args = append(args, pool)
+	}
+	var count int
+	err = t.Client.QueryRow(sqltext, args...).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNoSuchCluster
+	}
+	return nil
+}
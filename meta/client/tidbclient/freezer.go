@@ -2,20 +2,52 @@ package tidbclient
 
 import (
 	"database/sql"
+	"strconv"
+	"time"
+
 	. "github.com/journeymidnight/yig/error"
 	. "github.com/journeymidnight/yig/meta/types"
-	"time"
 )
 
-func (t *TidbClient) CreateFreezer(freezer *Freezer) (err error) {
+// CreateFreezer creates the freezer, if `capacity` is positive, only when
+// there're less than `capacity` freezers of the tier pending. Freezers of
+// the same tier are created one by one, so the capacity is never exceeded.
+func (t *TidbClient) CreateFreezer(freezer *Freezer, capacity int64) (err error) {
+	tx, err := t.Client.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if capacity > 0 {
+		err = lockName(tx, "freezer-tier:"+strconv.Itoa(int(freezer.Tier)))
+		if err != nil {
+			return err
+		}
+		var pending int64
+		sqltext := "select count(*) from restoreobjects where IFNULL(tier,0)=? and status in (?,?);"
+		err = tx.QueryRow(sqltext, freezer.Tier, ObjectNeedRestore, ObjectRestoring).Scan(&pending)
+		if err != nil {
+			return err
+		}
+		if pending >= capacity {
+			return ErrGlacierExpeditedRetrievalNotAvailable
+		}
+	}
 	sql, args := freezer.GetCreateSql()
-	_, err = t.Client.Exec(sql, args...)
-	return
+	_, err = tx.Exec(sql, args...)
+	return err
 }
 
 func (t *TidbClient) GetFreezer(bucketName, objectName, version string) (freezer *Freezer, err error) {
 	var lastmodifiedtime string
-	sqltext := "select bucketname,objectname,IFNULL(version,''),status,lifetime,lastmodifiedtime,IFNULL(location,''),IFNULL(pool,''),IFNULL(ownerid,''),IFNULL(size,'0'),IFNULL(objectid,''),IFNULL(etag,'') from restoreobjects where bucketname=? and objectname=?;"
+	sqltext := "select bucketname,objectname,IFNULL(version,''),status,lifetime,lastmodifiedtime,IFNULL(location,''),IFNULL(pool,''),IFNULL(ownerid,''),IFNULL(size,'0'),IFNULL(objectid,''),IFNULL(etag,''),IFNULL(tier,0) from restoreobjects where bucketname=? and objectname=?;"
 	row := t.Client.QueryRow(sqltext, bucketName, objectName)
 	freezer = &Freezer{}
 	err = row.Scan(
@@ -31,6 +63,7 @@ func (t *TidbClient) GetFreezer(bucketName, objectName, version string) (freezer
 		&freezer.Size,
 		&freezer.ObjectId,
 		&freezer.Etag,
+		&freezer.Tier,
 	)
 	if err == sql.ErrNoRows {
 		err = ErrNoSuchKey
@@ -98,7 +131,7 @@ func (t *TidbClient) DeleteFreezer(bucketName, objectName string, tx DB) (err er
 	if err != nil {
 		return err
 	}
-	sqltext = "delete from restoreobjectpart where objectname=? and bucketname=?;"
+	sqltext = "delete from restoreobjectpart where bucketname=? and objectname=?;"
 	_, err = tx.Exec(sqltext, bucketName, objectName)
 	if err != nil {
 		return err
@@ -106,6 +139,138 @@ func (t *TidbClient) DeleteFreezer(bucketName, objectName string, tx DB) (err er
 	return nil
 }
 
+// ScanFreezers lists freezers in `status` after (bucketName, objectName)
+func (t *TidbClient) ScanFreezers(limit int, bucketName, objectName string, status Status) (result ScanFreezerResult, err error) {
+	sqltext := "select bucketname,objectname,status,lifetime,lastmodifiedtime,IFNULL(tier,0) from restoreobjects " +
+		"where status=? and (bucketname>? or (bucketname=? and objectname>?)) " +
+		"order by bucketname,objectname limit ?;"
+	rows, err := t.Client.Query(sqltext, status, bucketName, bucketName, objectName, limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	local, _ := time.LoadLocation("Local")
+	result.Freezers = make([]Freezer, 0, limit)
+	for rows.Next() {
+		var freezer Freezer
+		var lastmodifiedtime string
+		err = rows.Scan(
+			&freezer.BucketName,
+			&freezer.Name,
+			&freezer.Status,
+			&freezer.LifeTime,
+			&lastmodifiedtime,
+			&freezer.Tier,
+		)
+		if err != nil {
+			return
+		}
+		freezer.LastModifiedTime, _ = time.ParseInLocation(TIME_LAYOUT_TIDB, lastmodifiedtime, local)
+		result.Freezers = append(result.Freezers, freezer)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	if len(result.Freezers) == limit {
+		result.Truncated = true
+		result.NextBucketName = result.Freezers[limit-1].BucketName
+		result.NextObjectName = result.Freezers[limit-1].Name
+	}
+	return result, nil
+}
+
+// ListFreezersByTier lists freezers of `tier` in `status` requested before
+// `requestedBefore`, earliest requested first
+func (t *TidbClient) ListFreezersByTier(tier RestoreTier, status Status, requestedBefore time.Time,
+	limit int) (freezers []Freezer, err error) {
+
+	sqltext := "select bucketname,objectname,status,lifetime,lastmodifiedtime,IFNULL(tier,0)," +
+		"IFNULL(requesttime,lastmodifiedtime) as requested from restoreobjects " +
+		"where IFNULL(tier,0)=? and status=? and IFNULL(requesttime,lastmodifiedtime)<? " +
+		"order by requested limit ?;"
+	rows, err := t.Client.Query(sqltext, tier, status, requestedBefore.Format(TIME_LAYOUT_TIDB), limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	local, _ := time.LoadLocation("Local")
+	for rows.Next() {
+		var freezer Freezer
+		var lastmodifiedtime, requesttime string
+		err = rows.Scan(
+			&freezer.BucketName,
+			&freezer.Name,
+			&freezer.Status,
+			&freezer.LifeTime,
+			&lastmodifiedtime,
+			&freezer.Tier,
+			&requesttime,
+		)
+		if err != nil {
+			return
+		}
+		freezer.LastModifiedTime, _ = time.ParseInLocation(TIME_LAYOUT_TIDB, lastmodifiedtime, local)
+		freezer.RequestTime, _ = time.ParseInLocation(TIME_LAYOUT_TIDB, requesttime, local)
+		freezers = append(freezers, freezer)
+	}
+	return freezers, rows.Err()
+}
+
+// UpdateFreezerStatus sets status of the freezer to `targetStatus` only if
+// it's in `status` now, returns false if the freezer is not in `status`.
+func (t *TidbClient) UpdateFreezerStatus(bucketName, objectName string, status, targetStatus Status) (updated bool, err error) {
+	lastModifiedTime := time.Now().Format(TIME_LAYOUT_TIDB)
+	sqltext := "update restoreobjects set status=?,lastmodifiedtime=? where bucketname=? and objectname=? and status=?;"
+	result, err := t.Client.Exec(sqltext, targetStatus, lastModifiedTime, bucketName, objectName, status)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// PutFreezer saves location of the restored copy, and sets status of the
+// freezer to `status`
+func (t *TidbClient) PutFreezer(freezer *Freezer, status Status, tx DB) (err error) {
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err == nil {
+				err = tx.(*sql.Tx).Commit()
+			}
+			if err != nil {
+				tx.(*sql.Tx).Rollback()
+			}
+		}()
+	}
+	sqltext, args := freezer.GetUpdateSql(status)
+	result, err := tx.Exec(sqltext, args...)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return ErrInvalidStatus
+	}
+	for _, p := range freezer.Parts {
+		sqltext, args := p.GetCreateFreezerSql(freezer.BucketName, freezer.Name)
+		_, err = tx.Exec(sqltext, args...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 //util function
 func getFreezerParts(bucketName, objectName string, cli *sql.DB) (parts map[int]*Part, err error) {
 	parts = make(map[int]*Part)
@@ -25,6 +25,16 @@ func (t *TidbClient) PutObjectToGarbageCollection(object *Object, tx DB) (err er
 		}()
 	}
 
+	// volume of packed objects is removed by compaction, not gc
+	if object.Packed {
+		return t.AddVolumeDeletedSize(object.Location, object.Pool, object.ObjectId, object.Size, tx)
+	}
+	// deduplicated data is only removed after its last reference is gone
+	object, err = t.releaseBlobs(object, tx)
+	if err != nil || object == nil {
+		return err
+	}
+
 	o := GarbageCollectionFromObject(object)
 	var hasPart bool
 	if len(o.Parts) > 0 {
@@ -239,3 +249,37 @@ func GarbageCollectionFromFreeze(f *Freezer) (gc GarbageCollection) {
 	gc.TriedTimes = 0
 	return
 }
+
+// releaseBlobs removes references of `object` and its parts to blobs, it
+// returns the object with only data to remove, or nil if there is none.
+func (t *TidbClient) releaseBlobs(object *Object, tx DB) (*Object, error) {
+	blobBucket := BlobBucketName(object.BucketName, object.SseType)
+	if object.ContentHash != "" {
+		released, err := t.UnrefBlob(object.ContentHash, blobBucket, tx)
+		if err != nil || !released {
+			return nil, err
+		}
+		return object, nil
+	}
+	if len(object.Parts) == 0 {
+		return object, nil
+	}
+	garbage := *object
+	garbage.Parts = make(map[int]*Part, len(object.Parts))
+	for n, p := range object.Parts {
+		if p.ContentHash != "" {
+			released, err := t.UnrefBlob(p.ContentHash, blobBucket, tx)
+			if err != nil {
+				return nil, err
+			}
+			if !released {
+				continue
+			}
+		}
+		garbage.Parts[n] = p
+	}
+	if len(garbage.Parts) == 0 {
+		return nil, nil
+	}
+	return &garbage, nil
+}
@@ -0,0 +1,123 @@
+package tidbclient
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+const inventoryColumns = "bucketname,config,lastrun"
+
+// PutInventory creates or replaces inventory configuration, LastRun is
+// kept when replacing
+func (t *TidbClient) PutInventory(inventory Inventory) (err error) {
+	config, err := json.Marshal(inventory.Config)
+	if err != nil {
+		return err
+	}
+	sqltext := "insert into inventories(bucketname,id,config) values(?,?,?) " +
+		"on duplicate key update config=values(config)"
+	_, err = t.Client.Exec(sqltext, inventory.BucketName, inventory.Config.Id, string(config))
+	return err
+}
+
+func (t *TidbClient) GetInventory(bucketName, id string) (inventory Inventory, err error) {
+	sqltext := "select " + inventoryColumns + " from inventories where bucketname=? and id=?"
+	row := t.Client.QueryRow(sqltext, bucketName, id)
+	inventory, err = scanInventory(row)
+	if err == sql.ErrNoRows {
+		err = ErrNoSuchInventoryConfiguration
+	}
+	return
+}
+
+func (t *TidbClient) DeleteInventory(bucketName, id string) (err error) {
+	sqltext := "delete from inventories where bucketname=? and id=?"
+	result, err := t.Client.Exec(sqltext, bucketName, id)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNoSuchInventoryConfiguration
+	}
+	return nil
+}
+
+// DeleteBucketInventories removes all inventory configurations of the bucket
+func (t *TidbClient) DeleteBucketInventories(bucketName string) (err error) {
+	sqltext := "delete from inventories where bucketname=?"
+	_, err = t.Client.Exec(sqltext, bucketName)
+	return err
+}
+
+// ListInventories lists inventory configurations of the bucket with id after `marker`
+func (t *TidbClient) ListInventories(bucketName, marker string, limit int) (inventories []Inventory, err error) {
+	sqltext := "select " + inventoryColumns + " from inventories where bucketname=? and id>? " +
+		"order by id limit ?"
+	rows, err := t.Client.Query(sqltext, bucketName, marker, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		inventory, err := scanInventory(rows)
+		if err != nil {
+			return nil, err
+		}
+		inventories = append(inventories, inventory)
+	}
+	return inventories, rows.Err()
+}
+
+// ScanInventories lists inventory configurations of all buckets after (bucketName, id)
+func (t *TidbClient) ScanInventories(limit int, bucketName, id string) (inventories []Inventory, err error) {
+	sqltext := "select " + inventoryColumns + " from inventories where bucketname>? or " +
+		"(bucketname=? and id>?) order by bucketname,id limit ?"
+	rows, err := t.Client.Query(sqltext, bucketName, bucketName, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		inventory, err := scanInventory(rows)
+		if err != nil {
+			return nil, err
+		}
+		inventories = append(inventories, inventory)
+	}
+	return inventories, rows.Err()
+}
+
+func (t *TidbClient) UpdateInventoryLastRun(bucketName, id string, lastRun time.Time) (err error) {
+	sqltext := "update inventories set lastrun=? where bucketname=? and id=?"
+	_, err = t.Client.Exec(sqltext, lastRun.Format(TIME_LAYOUT_TIDB), bucketName, id)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanInventory(row rowScanner) (inventory Inventory, err error) {
+	var config string
+	var lastRun sql.NullString
+	err = row.Scan(&inventory.BucketName, &config, &lastRun)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal([]byte(config), &inventory.Config)
+	if err != nil {
+		return
+	}
+	if lastRun.Valid {
+		inventory.LastRun, err = time.Parse(TIME_LAYOUT_TIDB, lastRun.String)
+	}
+	return
+}
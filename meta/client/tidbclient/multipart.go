@@ -30,7 +30,7 @@ func (t *TidbClient) GetMultipart(bucketName, objectName, uploadId string) (mult
 	}
 	uploadTime = math.MaxUint64 - uploadTime
 	sqltext := "select bucketname,objectname,uploadtime,initiatorid,ownerid,contenttype,location,pool,acl,sserequest," +
-		"encryption,COALESCE(cipher,\"\"),attrs,storageclass from multiparts where bucketname=? and objectname=? and uploadtime=?;"
+		"encryption,COALESCE(cipher,\"\"),attrs,storageclass,checksumalgorithm from multiparts where bucketname=? and objectname=? and uploadtime=?;"
 	var initialTime uint64
 	var acl, sseRequest, attrs string
 	err = t.Client.QueryRow(sqltext, bucketName, objectName, uploadTime).Scan(
@@ -48,6 +48,7 @@ func (t *TidbClient) GetMultipart(bucketName, objectName, uploadId string) (mult
 		&multipart.Metadata.CipherKey,
 		&attrs,
 		&multipart.Metadata.StorageClass,
+		&multipart.Metadata.ChecksumAlgorithm,
 	)
 	if err != nil && err == sql.ErrNoRows {
 		err = ErrNoSuchUpload
@@ -72,7 +73,8 @@ func (t *TidbClient) GetMultipart(bucketName, objectName, uploadId string) (mult
 		return
 	}
 
-	sqltext = "select partnumber,size,objectid,offset,etag,lastmodified,initializationvector from multipartpart where bucketname=? and objectname=? and uploadtime=?;"
+	sqltext = "select partnumber,size,objectid,offset,etag,lastmodified,initializationvector,contenthash,checksum," +
+		"checksumalgorithm,checksumvalue from multipartpart where bucketname=? and objectname=? and uploadtime=?;"
 	rows, err := t.Client.Query(sqltext, bucketName, objectName, uploadTime)
 	if err != nil {
 		return
@@ -88,6 +90,10 @@ func (t *TidbClient) GetMultipart(bucketName, objectName, uploadId string) (mult
 			&p.Etag,
 			&p.LastModified,
 			&p.InitializationVector,
+			&p.ContentHash,
+			&p.Checksum,
+			&p.ChecksumAlgorithm,
+			&p.ChecksumValue,
 		)
 		ts, e := time.Parse(TIME_LAYOUT_TIDB, p.LastModified)
 		if e != nil {
@@ -108,9 +114,9 @@ func (t *TidbClient) CreateMultipart(multipart Multipart) (err error) {
 	acl, _ := json.Marshal(m.Acl)
 	sseRequest, _ := json.Marshal(m.SseRequest)
 	attrs, _ := json.Marshal(m.Attrs)
-	sqltext := "insert into multiparts(bucketname,objectname,uploadtime,initiatorid,ownerid,contenttype,location,pool,acl,sserequest,encryption,cipher,attrs,storageclass) " +
-		"values(?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
-	_, err = t.Client.Exec(sqltext, multipart.BucketName, multipart.ObjectName, uploadtime, m.InitiatorId, m.OwnerId, m.ContentType, m.Location, m.Pool, acl, sseRequest, m.EncryptionKey,m.CipherKey, attrs, m.StorageClass)
+	sqltext := "insert into multiparts(bucketname,objectname,uploadtime,initiatorid,ownerid,contenttype,location,pool,acl,sserequest,encryption,cipher,attrs,storageclass,checksumalgorithm) " +
+		"values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+	_, err = t.Client.Exec(sqltext, multipart.BucketName, multipart.ObjectName, uploadtime, m.InitiatorId, m.OwnerId, m.ContentType, m.Location, m.Pool, acl, sseRequest, m.EncryptionKey,m.CipherKey, attrs, m.StorageClass, m.ChecksumAlgorithm)
 	return
 }
 
@@ -125,9 +131,10 @@ func (t *TidbClient) PutObjectPart(multipart *Multipart, part *Part, tx DB) (err
 		return
 	}
 	lastModified := lastt.Format(TIME_LAYOUT_TIDB)
-	sqltext := "insert into multipartpart(partnumber,size,objectid,offset,etag,lastmodified,initializationvector,bucketname,objectname,uploadtime) " +
-		"values(?,?,?,?,?,?,?,?,?,?)"
-	_, err = tx.Exec(sqltext, part.PartNumber, part.Size, part.ObjectId, part.Offset, part.Etag, lastModified, part.InitializationVector, multipart.BucketName, multipart.ObjectName, uploadtime)
+	sqltext := "insert into multipartpart(partnumber,size,objectid,offset,etag,lastmodified,initializationvector,contenthash,checksum," +
+		"checksumalgorithm,checksumvalue,bucketname,objectname,uploadtime) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+	_, err = tx.Exec(sqltext, part.PartNumber, part.Size, part.ObjectId, part.Offset, part.Etag, lastModified, part.InitializationVector, part.ContentHash, part.Checksum,
+		part.ChecksumAlgorithm, part.ChecksumValue, multipart.BucketName, multipart.ObjectName, uploadtime)
 	return
 }
 
@@ -157,6 +164,19 @@ func (t *TidbClient) DeleteMultipart(multipart *Multipart, tx DB) (err error) {
 	return err
 }
 
+// CountMultipartsByLocation counts multipart uploads in progress whose parts
+// are stored in `location` and `pool`, all pools if `pool` is empty
+func (t *TidbClient) CountMultipartsByLocation(location, pool string) (count int64, err error) {
+	sqltext := "select count(*) from multiparts where location=?"
+	args := []interface{}{location}
+	if pool != "" {
+		sqltext += " and pool=?"
+		args = append(args, pool)
+	}
+	err = t.Client.QueryRow(sqltext, args...).Scan(&count)
+	return
+}
+
 func (t *TidbClient) ListMultipartUploads(bucketName, keyMarker, uploadIdMarker, prefix, delimiter, encodingType string, maxUploads int) (uploads []datatype.Upload, prefixs []string, isTruncated bool, nextKeyMarker, nextUploadIdMarker string, err error) {
 	var count int
 	var exit bool
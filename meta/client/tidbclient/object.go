@@ -4,10 +4,14 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"hash/crc32"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
+	"github.com/journeymidnight/yig/api/datatype"
 	. "github.com/journeymidnight/yig/error"
 	. "github.com/journeymidnight/yig/meta/types"
 	"github.com/xxtea/xxtea-go/xxtea"
@@ -19,7 +23,8 @@ func (t *TidbClient) GetObject(bucketName, objectName, version string) (object *
 
 	var row *sql.Row
 	sqltext := "select bucketname,name,version,location,pool,ownerid,size,objectid,lastmodifiedtime,etag,contenttype," +
-		"customattributes,acl,nullversion,deletemarker,ssetype,encryptionkey,initializationvector,type,storageclass from objects where bucketname=? and name=? "
+		"customattributes,acl,nullversion,deletemarker,ssetype,encryptionkey,initializationvector,type,storageclass," +
+		"packed,volumeoffset,contenthash,checksum,checksumalgorithm,checksumvalue from objects where bucketname=? and name=? "
 	if version == "" {
 		sqltext += "order by bucketname,name,version limit 1;"
 		row = t.Client.QueryRow(sqltext, bucketName, objectName)
@@ -49,6 +54,12 @@ func (t *TidbClient) GetObject(bucketName, objectName, version string) (object *
 		&object.InitializationVector,
 		&object.Type,
 		&object.StorageClass,
+		&object.Packed,
+		&object.VolumeOffset,
+		&object.ContentHash,
+		&object.Checksum,
+		&object.ChecksumAlgorithm,
+		&object.ChecksumValue,
 	)
 	if err == sql.ErrNoRows {
 		err = ErrNoSuchKey
@@ -148,8 +159,76 @@ func (t *TidbClient) UpdateAppendObject(object *Object, tx DB) (err error) {
 		tx = t.Client
 	}
 	sql, args := object.GetAppendSql()
-	_, err = tx.Exec(sql, args...)
-	return err
+	result, err := tx.Exec(sql, args...)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	// data of the object is moved by migration after the append is written,
+	// the client could retry on the new location
+	if rows == 0 {
+		return ErrConditionalRequestConflict
+	}
+	return nil
+}
+
+// Number of lock names objects are striped over for conditional writes
+const objectLockStripes = 65536
+
+// CheckObjectCondition checks `condition` against the latest version of
+// object. Within a transaction the lock name of object is held, so
+// conditional writes of the same object are serialized even if the object
+// doesn't exist yet, and the condition holds until the transaction ends.
+func (t *TidbClient) CheckObjectCondition(bucketName, objectName string,
+	condition *datatype.WriteCondition, tx DB) (err error) {
+
+	if tx == nil {
+		tx = t.Client
+	} else {
+		stripe := crc32.ChecksumIEEE([]byte(bucketName+"/"+objectName)) % objectLockStripes
+		err = lockName(tx, "object:"+strconv.FormatUint(uint64(stripe), 10))
+		if err != nil {
+			if isConflictError(err) {
+				return ErrConditionalRequestConflict
+			}
+			return err
+		}
+	}
+	var etag string
+	var deleteMarker bool
+	sqltext := "select etag,deletemarker from objects where bucketname=? and name=? " +
+		"order by bucketname,name,version limit 1 for update;"
+	err = tx.QueryRow(sqltext, bucketName, objectName).Scan(&etag, &deleteMarker)
+	if err == sql.ErrNoRows {
+		return condition.Check(false, "")
+	}
+	if err != nil {
+		if isConflictError(err) {
+			return ErrConditionalRequestConflict
+		}
+		return err
+	}
+	return condition.Check(!deleteMarker, etag)
+}
+
+// isConflictError returns true if `err` is caused by concurrent transactions
+// locking or writing the same rows
+func isConflictError(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	if !ok {
+		return false
+	}
+	switch mysqlErr.Number {
+	case 1205, // lock wait timeout
+		1213, // deadlock
+		8002, // TiDB: select for update conflicts
+		9007: // TiDB: write conflict
+		return true
+	}
+	return false
 }
 
 func (t *TidbClient) PutObject(object *Object, tx DB) (err error) {
@@ -252,10 +331,165 @@ func (t *TidbClient) DeleteObject(object *Object, tx DB) (err error) {
 	return nil
 }
 
+// ScanObjectsByLocation lists objects stored in `location` and `pool` after
+// (bucketName, objectName, version), all pools are listed if `pool` is empty,
+// and all objects if `location` is empty
+func (t *TidbClient) ScanObjectsByLocation(location, pool string, limit int,
+	bucketName, objectName string, version uint64) (result ScanObjectResult, err error) {
+
+	sqltext := "select bucketname,name,version,size from objects where "
+	var args []interface{}
+	if location != "" {
+		sqltext += "location=? and "
+		args = append(args, location)
+	}
+	if pool != "" {
+		sqltext += "pool=? and "
+		args = append(args, pool)
+	}
+	sqltext += "(bucketname>? or (bucketname=? and name>?) or (bucketname=? and name=? and version>?)) " +
+		"order by bucketname,name,version limit ?;"
+	args = append(args, bucketName, bucketName, objectName, bucketName, objectName, version, limit)
+	rows, err := t.Client.Query(sqltext, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	result.Objects = make([]*Object, 0, limit)
+	var iversion uint64
+	for rows.Next() {
+		object := &Object{}
+		err = rows.Scan(
+			&object.BucketName,
+			&object.Name,
+			&iversion,
+			&object.Size,
+		)
+		if err != nil {
+			return
+		}
+		rversion := math.MaxUint64 - iversion
+		object.LastModifiedTime = time.Unix(0, int64(rversion))
+		result.Objects = append(result.Objects, object)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	if len(result.Objects) == limit {
+		result.Truncated = true
+		result.NextBucketName = result.Objects[limit-1].BucketName
+		result.NextObjectName = result.Objects[limit-1].Name
+		result.NextVersion = iversion
+	}
+	return result, nil
+}
+
+// ScanBucketObjects lists objects of the bucket with `prefix` after (objectName, version),
+// newest version first for each object. To start a scan, pass (prefix, 0) as
+// marker. Only Name, LastModifiedTime, Size, Etag, NullVersion, DeleteMarker,
+// SseType and StorageClass of objects are filled.
+func (t *TidbClient) ScanBucketObjects(bucketName, prefix string, limit int,
+	objectName string, version uint64) (result ScanObjectResult, err error) {
+
+	sqltext := "select name,version,size,etag,nullversion,deletemarker,ssetype,storageclass from objects " +
+		"where bucketname=? and (name>? or (name=? and version>?)) order by name,version limit ?;"
+	rows, err := t.Client.Query(sqltext, bucketName, objectName, objectName, version, limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	result.Objects = make([]*Object, 0, limit)
+	var count int
+	var iversion uint64
+	for rows.Next() {
+		object := &Object{BucketName: bucketName}
+		err = rows.Scan(
+			&object.Name,
+			&iversion,
+			&object.Size,
+			&object.Etag,
+			&object.NullVersion,
+			&object.DeleteMarker,
+			&object.SseType,
+			&object.StorageClass,
+		)
+		if err != nil {
+			return
+		}
+		count++
+		if !strings.HasPrefix(object.Name, prefix) {
+			// objects are ordered by name, so no more objects with prefix
+			return result, rows.Err()
+		}
+		rversion := math.MaxUint64 - iversion
+		object.LastModifiedTime = time.Unix(0, int64(rversion))
+		result.Objects = append(result.Objects, object)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	if count == limit {
+		result.Truncated = true
+		result.NextBucketName = bucketName
+		result.NextObjectName = result.Objects[limit-1].Name
+		result.NextVersion = iversion
+	}
+	return result, nil
+}
+
+// MigrateObject updates data location of `object` and its parts to the
+// migrated ones, `migrated` is false if data of `object` is no longer in
+// location of `source`, e.g. the object is overwritten or appended.
+func (t *TidbClient) MigrateObject(object, source *Object, tx DB) (migrated bool, err error) {
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		if err != nil {
+			return false, err
+		}
+		defer func() {
+			if err == nil && migrated {
+				err = tx.(*sql.Tx).Commit()
+			}
+			if err != nil || !migrated {
+				tx.(*sql.Tx).Rollback()
+				migrated = false
+			}
+		}()
+	}
+
+	sqltext, args := object.GetMigrateSql(source)
+	result, err := tx.Exec(sqltext, args...)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil || rows != 1 {
+		return false, err
+	}
+	version := math.MaxUint64 - uint64(object.LastModifiedTime.UnixNano())
+	for n, part := range object.Parts {
+		sourcePart, ok := source.Parts[n]
+		if !ok {
+			return false, nil
+		}
+		sqltext, args := part.GetMigrateSql(object.BucketName, object.Name, version, sourcePart.ObjectId)
+		result, err := tx.Exec(sqltext, args...)
+		if err != nil {
+			return false, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil || rows != 1 {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
 //util function
 func getParts(bucketName, objectName string, version uint64, cli *sql.DB) (parts map[int]*Part, err error) {
 	parts = make(map[int]*Part)
-	sqltext := "select partnumber,size,objectid,offset,etag,lastmodified,initializationvector from objectpart where bucketname=? and objectname=? and version=?;"
+	sqltext := "select partnumber,size,objectid,offset,etag,lastmodified,initializationvector,contenthash,checksum," +
+		"checksumalgorithm,checksumvalue from objectpart where bucketname=? and objectname=? and version=?;"
 	rows, err := cli.Query(sqltext, bucketName, objectName, version)
 	if err != nil {
 		return
@@ -271,6 +505,10 @@ func getParts(bucketName, objectName string, version uint64, cli *sql.DB) (parts
 			&p.Etag,
 			&p.LastModified,
 			&p.InitializationVector,
+			&p.ContentHash,
+			&p.Checksum,
+			&p.ChecksumAlgorithm,
+			&p.ChecksumValue,
 		)
 		parts[p.PartNumber] = p
 	}
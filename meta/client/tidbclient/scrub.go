@@ -0,0 +1,75 @@
+package tidbclient
+
+import (
+	"time"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+func (t *TidbClient) PutScrubFailure(failure ScrubFailure) (err error) {
+	mtime := failure.MTime.Format(TIME_LAYOUT_TIDB)
+	sqltext := "insert into scrubfailures(bucketname,objectname,version,partnumber,location,pool,objectid," +
+		"reason,expected,actual,mtime) values(?,?,?,?,?,?,?,?,?,?,?) on duplicate key update " +
+		"location=values(location),pool=values(pool),objectid=values(objectid),reason=values(reason)," +
+		"expected=values(expected),actual=values(actual),mtime=values(mtime)"
+	_, err = t.Client.Exec(sqltext, failure.BucketName, failure.ObjectName, failure.Version,
+		failure.PartNumber, failure.Location, failure.Pool, failure.ObjectId, failure.Reason,
+		failure.Expected, failure.Actual, mtime)
+	return err
+}
+
+// RemoveScrubFailures removes failures of the object version, e.g. when
+// it's scrubbed again without failures
+func (t *TidbClient) RemoveScrubFailures(bucketName, objectName string, version uint64) (err error) {
+	sqltext := "delete from scrubfailures where bucketname=? and objectname=? and version=?"
+	_, err = t.Client.Exec(sqltext, bucketName, objectName, version)
+	return err
+}
+
+// ListScrubFailures lists failures of objects after (bucketName, objectName, version)
+func (t *TidbClient) ListScrubFailures(limit int, bucketName, objectName string,
+	version uint64) (failures []ScrubFailure, err error) {
+
+	sqltext := "select bucketname,objectname,version,partnumber,location,pool,objectid,reason,expected,actual,mtime " +
+		"from scrubfailures where bucketname>? or (bucketname=? and objectname>?) or " +
+		"(bucketname=? and objectname=? and version>?) order by bucketname,objectname,version,partnumber limit ?"
+	rows, err := t.Client.Query(sqltext, bucketName, bucketName, objectName, bucketName, objectName,
+		version, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f ScrubFailure
+		var mtime string
+		err = rows.Scan(&f.BucketName, &f.ObjectName, &f.Version, &f.PartNumber, &f.Location,
+			&f.Pool, &f.ObjectId, &f.Reason, &f.Expected, &f.Actual, &mtime)
+		if err != nil {
+			return nil, err
+		}
+		f.MTime, err = time.Parse(TIME_LAYOUT_TIDB, mtime)
+		if err != nil {
+			return nil, err
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
+func (t *TidbClient) CountScrubFailures() (counts []ScrubFailureCount, err error) {
+	sqltext := "select location,reason,count(*) from scrubfailures group by location,reason"
+	rows, err := t.Client.Query(sqltext)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c ScrubFailureCount
+		err = rows.Scan(&c.Location, &c.Reason, &c.Count)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
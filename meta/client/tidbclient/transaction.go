@@ -1,6 +1,10 @@
 package tidbclient
 
-import "database/sql"
+import (
+	"database/sql"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
 
 func (t *TidbClient) NewTrans() (tx *sql.Tx, err error) {
 	tx, err = t.Client.Begin()
@@ -15,4 +19,14 @@ func (t *TidbClient) AbortTrans(tx *sql.Tx) (err error) {
 func (t *TidbClient) CommitTrans(tx *sql.Tx) (err error) {
 	err = tx.Commit()
 	return
-}
\ No newline at end of file
+}
+
+// lockName serializes transactions on `name` by writing its row in `locks`,
+// the lock is held until `tx` ends. With optimistic transactions, the
+// later one to commit fails with a write conflict instead of waiting.
+// Rows are never removed, so names should come from a bounded set.
+func lockName(tx DB, name string) error {
+	sqltext := "insert into locks(name,version) values(?,1) on duplicate key update version=version+1;"
+	_, err := tx.Exec(sqltext, name)
+	return err
+}
@@ -0,0 +1,91 @@
+package tidbclient
+
+import (
+	"math"
+	"time"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+func (t *TidbClient) PutVolume(volume Volume) (err error) {
+	mtime := volume.MTime.Format(TIME_LAYOUT_TIDB)
+	sqltext := "insert into volumes(location,pool,volumeid,size,deletedsize,sealed,mtime) values(?,?,?,?,?,?,?)"
+	_, err = t.Client.Exec(sqltext, volume.Location, volume.Pool, volume.VolumeId,
+		volume.Size, volume.DeletedSize, volume.Sealed, mtime)
+	return err
+}
+
+// SealVolume saves final size of the volume, no more objects are packed into it
+func (t *TidbClient) SealVolume(location, pool, volumeId string, size int64) (err error) {
+	mtime := time.Now().UTC().Format(TIME_LAYOUT_TIDB)
+	sqltext := "update volumes set size=?,sealed=1,mtime=? where location=? and pool=? and volumeid=?"
+	_, err = t.Client.Exec(sqltext, size, mtime, location, pool, volumeId)
+	return err
+}
+
+func (t *TidbClient) AddVolumeDeletedSize(location, pool, volumeId string, size int64, tx DB) (err error) {
+	if tx == nil {
+		tx = t.Client
+	}
+	sqltext := "update volumes set deletedsize=deletedsize+? where location=? and pool=? and volumeid=?"
+	_, err = tx.Exec(sqltext, size, location, pool, volumeId)
+	return err
+}
+
+// ScanVolumes lists volumes after (location, pool, volumeId)
+func (t *TidbClient) ScanVolumes(limit int, location, pool, volumeId string) (volumes []Volume, err error) {
+	sqltext := "select location,pool,volumeid,size,deletedsize,sealed,mtime from volumes " +
+		"where location>? or (location=? and pool>?) or (location=? and pool=? and volumeid>?) " +
+		"order by location,pool,volumeid limit ?"
+	rows, err := t.Client.Query(sqltext, location, location, pool, location, pool, volumeId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v Volume
+		var mtime string
+		err = rows.Scan(&v.Location, &v.Pool, &v.VolumeId, &v.Size, &v.DeletedSize, &v.Sealed, &mtime)
+		if err != nil {
+			return nil, err
+		}
+		v.MTime, err = time.Parse(TIME_LAYOUT_TIDB, mtime)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, v)
+	}
+	return volumes, rows.Err()
+}
+
+// ListVolumeObjects lists objects packed in the volume, only BucketName, Name,
+// LastModifiedTime and Size are filled
+func (t *TidbClient) ListVolumeObjects(volume Volume) (objects []*Object, err error) {
+	sqltext := "select bucketname,name,version,size from objects " +
+		"where objectid=? and location=? and pool=? and packed=1"
+	rows, err := t.Client.Query(sqltext, volume.VolumeId, volume.Location, volume.Pool)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		object := &Object{}
+		var iversion uint64
+		err = rows.Scan(&object.BucketName, &object.Name, &iversion, &object.Size)
+		if err != nil {
+			return nil, err
+		}
+		object.LastModifiedTime = time.Unix(0, int64(math.MaxUint64-iversion))
+		objects = append(objects, object)
+	}
+	return objects, rows.Err()
+}
+
+func (t *TidbClient) DeleteVolume(volume Volume, tx DB) (err error) {
+	if tx == nil {
+		tx = t.Client
+	}
+	sqltext := "delete from volumes where location=? and pool=? and volumeid=?"
+	_, err = tx.Exec(sqltext, volume.Location, volume.Pool, volume.VolumeId)
+	return err
+}
@@ -7,14 +7,16 @@ import (
 	"github.com/journeymidnight/yig/redis"
 )
 
+const clusterCacheKey = "cephClusters"
+
 func (m *Meta) GetClusters() (cluster []Cluster, err error) {
-	rowKey := "cephClusters"
+	rowKey := clusterCacheKey
 	getCluster := func() (c interface{}, err error) {
 		helper.Logger.Info("GetClusters CacheMiss")
 		return m.Client.GetClusters()
 	}
 	unmarshaller := func(in []byte) (interface{}, error) {
-		var cluster Cluster
+		var cluster []Cluster
 		err := helper.MsgPackUnMarshal(in, &cluster)
 		return cluster, err
 	}
@@ -29,3 +31,43 @@ func (m *Meta) GetClusters() (cluster []Cluster, err error) {
 	}
 	return cluster, nil
 }
+
+// PutClusters adds all `clusters` or none of them, in one transaction
+func (m *Meta) PutClusters(clusters []Cluster) (err error) {
+	tx, err := m.Client.NewTrans()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			m.Client.AbortTrans(tx)
+		}
+	}()
+	for _, cluster := range clusters {
+		err = m.Client.PutCluster(cluster, tx)
+		if err != nil {
+			return err
+		}
+	}
+	err = m.Client.CommitTrans(tx)
+	if err == nil {
+		m.Cache.Remove(redis.ClusterTable, clusterCacheKey)
+	}
+	return err
+}
+
+func (m *Meta) UpdateClusterWeight(fsid, pool string, weight int) error {
+	err := m.Client.UpdateClusterWeight(fsid, pool, weight)
+	if err == nil {
+		m.Cache.Remove(redis.ClusterTable, clusterCacheKey)
+	}
+	return err
+}
+
+func (m *Meta) UpdateClusterStatus(fsid, pool string, status ClusterStatus) error {
+	err := m.Client.UpdateClusterStatus(fsid, pool, status)
+	if err == nil {
+		m.Cache.Remove(redis.ClusterTable, clusterCacheKey)
+	}
+	return err
+}
@@ -2,11 +2,15 @@ package meta
 
 import (
 	"database/sql"
+	"time"
+
 	"github.com/journeymidnight/yig/meta/types"
 )
 
-func (m *Meta) CreateFreezer(freezer *types.Freezer) error {
-	return m.Client.CreateFreezer(freezer)
+// CreateFreezer creates the freezer if there're less than `capacity`
+// freezers of its tier pending, 0 means unlimited
+func (m *Meta) CreateFreezer(freezer *types.Freezer, capacity int64) error {
+	return m.Client.CreateFreezer(freezer, capacity)
 }
 
 func (m *Meta) GetFreezer(bucketName string, objectName string, version string) (freezer *types.Freezer, err error) {
@@ -21,6 +25,23 @@ func (m *Meta) UpdateFreezerDate(freezer *types.Freezer) error {
 	return m.Client.UploadFreezerDate(freezer.BucketName, freezer.Name, freezer.LifeTime)
 }
 
+func (m *Meta) ScanFreezers(limit int, bucketName, objectName string, status types.Status) (result types.ScanFreezerResult, err error) {
+	return m.Client.ScanFreezers(limit, bucketName, objectName, status)
+}
+
+func (m *Meta) ListFreezersByTier(tier types.RestoreTier, status types.Status, requestedBefore time.Time,
+	limit int) ([]types.Freezer, error) {
+	return m.Client.ListFreezersByTier(tier, status, requestedBefore, limit)
+}
+
+func (m *Meta) UpdateFreezerStatus(freezer *types.Freezer, targetStatus types.Status) (updated bool, err error) {
+	return m.Client.UpdateFreezerStatus(freezer.BucketName, freezer.Name, freezer.Status, targetStatus)
+}
+
+func (m *Meta) PutFreezer(freezer *types.Freezer, status types.Status) error {
+	return m.Client.PutFreezer(freezer, status, nil)
+}
+
 func (m *Meta) DeleteFreezer(freezer *types.Freezer) (err error) {
 	var tx *sql.Tx
 	tx, err = m.Client.NewTrans()
@@ -41,9 +62,12 @@ func (m *Meta) DeleteFreezer(freezer *types.Freezer) (err error) {
 		return err
 	}
 
-	err = m.Client.PutFreezerToGarbageCollection(freezer, tx)
-	if err != nil {
-		return err
+	// freezers not restored yet have no data to remove
+	if freezer.Location != "" {
+		err = m.Client.PutFreezerToGarbageCollection(freezer, tx)
+		if err != nil {
+			return err
+		}
 	}
 
 	return err
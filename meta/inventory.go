@@ -0,0 +1,35 @@
+package meta
+
+import (
+	"time"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+func (m *Meta) PutInventory(inventory Inventory) error {
+	return m.Client.PutInventory(inventory)
+}
+
+func (m *Meta) GetInventory(bucketName, id string) (Inventory, error) {
+	return m.Client.GetInventory(bucketName, id)
+}
+
+func (m *Meta) DeleteInventory(bucketName, id string) error {
+	return m.Client.DeleteInventory(bucketName, id)
+}
+
+func (m *Meta) DeleteBucketInventories(bucketName string) error {
+	return m.Client.DeleteBucketInventories(bucketName)
+}
+
+func (m *Meta) ListInventories(bucketName, marker string, limit int) ([]Inventory, error) {
+	return m.Client.ListInventories(bucketName, marker, limit)
+}
+
+func (m *Meta) ScanInventories(limit int, bucketName, id string) ([]Inventory, error) {
+	return m.Client.ScanInventories(limit, bucketName, id)
+}
+
+func (m *Meta) UpdateInventoryLastRun(bucketName, id string, lastRun time.Time) error {
+	return m.Client.UpdateInventoryLastRun(bucketName, id, lastRun)
+}
@@ -1,6 +1,8 @@
 package meta
 
 import (
+	"context"
+
 	"github.com/journeymidnight/yig/helper"
 	"github.com/journeymidnight/yig/meta/client"
 	"github.com/journeymidnight/yig/meta/client/tidbclient"
@@ -25,4 +27,8 @@ func New(myCacheType CacheType) *Meta {
 		panic("unsupport metastore")
 	}
 	return &meta
-}
\ No newline at end of file
+}
+// Ping checks connectivity to the meta store, bypassing cache
+func (m *Meta) Ping(ctx context.Context) error {
+	return m.Client.Ping(ctx)
+}
@@ -9,6 +9,10 @@ func (m *Meta) GetMultipart(bucketName, objectName, uploadId string) (multipart
 	return m.Client.GetMultipart(bucketName, objectName, uploadId)
 }
 
+func (m *Meta) CountMultipartsByLocation(location, pool string) (count int64, err error) {
+	return m.Client.CountMultipartsByLocation(location, pool)
+}
+
 func (m *Meta) DeleteMultipart(multipart Multipart) (err error) {
 	tx, err := m.Client.NewTrans()
 	if err != nil {
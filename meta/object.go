@@ -2,10 +2,12 @@ package meta
 
 import (
 	"database/sql"
+	"github.com/journeymidnight/yig/api/datatype"
 	. "github.com/journeymidnight/yig/error"
 	"github.com/journeymidnight/yig/helper"
 	. "github.com/journeymidnight/yig/meta/types"
 	"github.com/journeymidnight/yig/redis"
+	"time"
 )
 
 func (m *Meta) GetObject(bucketName string, objectName string, willNeed bool) (object *Object, err error) {
@@ -81,7 +83,17 @@ func (m *Meta) GetObjectVersion(bucketName, objectName, version string, willNeed
 	return object, nil
 }
 
-func (m *Meta) PutObject(object *Object, multipart *Multipart, objMap *ObjMap, updateUsage bool) error {
+// CheckObjectCondition checks `condition` against the latest version of
+// object, without locking it
+func (m *Meta) CheckObjectCondition(bucketName, objectName string, condition *datatype.WriteCondition) error {
+	return m.Client.CheckObjectCondition(bucketName, objectName, condition, nil)
+}
+
+// PutObject puts metadata of `object`. If `condition` is not nil, it's checked
+// in the same transaction, and the object is put only if it's met.
+func (m *Meta) PutObject(object *Object, multipart *Multipart, objMap *ObjMap, updateUsage bool,
+	condition *datatype.WriteCondition) (err error) {
+
 	tx, err := m.Client.NewTrans()
 	if err != nil {
 		return err
@@ -92,6 +104,12 @@ func (m *Meta) PutObject(object *Object, multipart *Multipart, objMap *ObjMap, u
 		}
 	}()
 
+	if condition != nil {
+		err = m.Client.CheckObjectCondition(object.BucketName, object.Name, condition, tx)
+		if err != nil {
+			return err
+		}
+	}
 	err = m.Client.PutObject(object, tx)
 	if err != nil {
 		return err
@@ -117,7 +135,14 @@ func (m *Meta) PutObject(object *Object, multipart *Multipart, objMap *ObjMap, u
 			return err
 		}
 	}
-	return m.Client.CommitTrans(tx)
+	err = m.Client.CommitTrans(tx)
+	if err != nil && condition != nil {
+		// concurrent writes of the object fail the transaction
+		helper.Logger.Warn("Commit conditional put of", object.BucketName, object.Name,
+			"error:", err)
+		err = ErrConditionalRequestConflict
+	}
+	return err
 }
 
 func (m *Meta) PutObjectEntry(object *Object) error {
@@ -253,3 +278,46 @@ func (m *Meta) AppendObject(object *Object, isExist bool) error {
 	}
 	return m.Client.CommitTrans(tx)
 }
+
+// MigrateObject switches data location of `object` to the migrated one and
+// puts data in location of `source` to gc, in one transaction.
+func (m *Meta) MigrateObject(object, source *Object) (migrated bool, err error) {
+	var tx *sql.Tx
+	tx, err = m.Client.NewTrans()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err == nil && migrated {
+			err = m.Client.CommitTrans(tx)
+		}
+		if err != nil || !migrated {
+			m.Client.AbortTrans(tx)
+			migrated = false
+		}
+	}()
+
+	migrated, err = m.Client.MigrateObject(object, source, tx)
+	if err != nil || !migrated {
+		return false, err
+	}
+	// gc entries are keyed by object version, use migration time instead so
+	// it won't collide with the entry when the object is deleted later
+	garbage := *source
+	garbage.LastModifiedTime = time.Now()
+	err = m.Client.PutObjectToGarbageCollection(&garbage, tx)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *Meta) ScanObjectsByLocation(location, pool string, limit int,
+	bucketName, objectName string, version uint64) (result ScanObjectResult, err error) {
+	return m.Client.ScanObjectsByLocation(location, pool, limit, bucketName, objectName, version)
+}
+
+func (m *Meta) ScanBucketObjects(bucketName, prefix string, limit int,
+	objectName string, version uint64) (result ScanObjectResult, err error) {
+	return m.Client.ScanBucketObjects(bucketName, prefix, limit, objectName, version)
+}
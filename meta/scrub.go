@@ -0,0 +1,22 @@
+package meta
+
+import (
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+func (m *Meta) PutScrubFailure(failure ScrubFailure) error {
+	return m.Client.PutScrubFailure(failure)
+}
+
+func (m *Meta) RemoveScrubFailures(bucketName, objectName string, version uint64) error {
+	return m.Client.RemoveScrubFailures(bucketName, objectName, version)
+}
+
+func (m *Meta) ListScrubFailures(limit int, bucketName, objectName string,
+	version uint64) ([]ScrubFailure, error) {
+	return m.Client.ListScrubFailures(limit, bucketName, objectName, version)
+}
+
+func (m *Meta) CountScrubFailures() ([]ScrubFailureCount, error) {
+	return m.Client.CountScrubFailures()
+}
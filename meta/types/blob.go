@@ -0,0 +1,35 @@
+package types
+
+import (
+	"time"
+
+	"github.com/journeymidnight/yig/crypto"
+)
+
+// Blob is data shared by objects and parts with the same content, keyed by
+// SHA-256 of the plain data. Objects referencing a blob hold a copy of its
+// location, the data is removed once RefCount drops to 0.
+type Blob struct {
+	Hash string
+	// SSE-S3 objects are only deduplicated in their bucket, since they
+	// share the encryption key. Empty for unencrypted data.
+	BucketName           string
+	Location             string
+	Pool                 string
+	ObjectId             string
+	Size                 int64
+	RefCount             int64
+	EncryptionKey        []byte
+	InitializationVector []byte
+	Checksum             string
+	MTime                time.Time
+}
+
+// BlobBucketName returns the bucket data of an object with `sseType` is
+// deduplicated in
+func BlobBucketName(bucketName, sseType string) string {
+	if sseType == crypto.S3.String() {
+		return bucketName
+	}
+	return ""
+}
@@ -30,6 +30,10 @@ type Bucket struct {
 	Encryption datatype.EncryptionConfiguration
 	Versioning string // actually enum: Disabled/Enabled/Suspended
 	Usage      int64
+	Tagging    datatype.Tagging
+	// who pays for requests and data transfer, BucketOwner or Requester,
+	// empty means BucketOwner
+	RequestPayer string
 }
 
 func (b *Bucket) String() (s string) {
@@ -45,6 +49,8 @@ func (b *Bucket) String() (s string) {
 	s += "Encryption" + fmt.Sprintf("%+v", b.Encryption) + "\t"
 	s += "Version: " + b.Versioning + "\t"
 	s += "Usage: " + humanize.Bytes(uint64(b.Usage)) + "\t"
+	s += "Tagging: " + fmt.Sprintf("%+v", b.Tagging) + "\t"
+	s += "RequestPayer: " + b.RequestPayer + "\t"
 	return
 }
 
@@ -57,8 +63,11 @@ func (b Bucket) GetUpdateSql() (string, []interface{}) {
 	bucket_policy, _ := json.Marshal(b.Policy)
 	website, _ := json.Marshal(b.Website)
 	encryption,_ := json.Marshal(b.Encryption)
-	sql := "update buckets set bucketname=?,acl=?,policy=?,cors=?,logging=?,lc=?,website=?,encryption=?,uid=?,versioning=? where bucketname=?"
-	args := []interface{}{b.Name, acl, bucket_policy, cors, logging, lc, website, encryption, b.OwnerId, b.Versioning, b.Name}
+	tagging, _ := json.Marshal(b.Tagging)
+	sql := "update buckets set bucketname=?,acl=?,policy=?,cors=?,logging=?,lc=?,website=?,encryption=?,uid=?,versioning=?," +
+		"tagging=?,requestpayer=? where bucketname=?"
+	args := []interface{}{b.Name, acl, bucket_policy, cors, logging, lc, website, encryption, b.OwnerId, b.Versioning,
+		tagging, b.RequestPayer, b.Name}
 	return sql, args
 }
 
@@ -70,9 +79,11 @@ func (b Bucket) GetCreateSql() (string, []interface{}) {
 	bucket_policy, _ := json.Marshal(b.Policy)
 	website, _ := json.Marshal(b.Website)
 	encryption,_ := json.Marshal(b.Encryption)
+	tagging, _ := json.Marshal(b.Tagging)
 	createTime := b.CreateTime.Format(TIME_LAYOUT_TIDB)
-	sql := "insert into buckets(bucketname,acl,cors,logging,lc,uid,policy,website,encryption,createtime,usages,versioning) " +
-		"values(?,?,?,?,?,?,?,?,?,?,?,?);"
-	args := []interface{}{b.Name, acl, cors, logging, lc, b.OwnerId, bucket_policy, website, encryption, createTime, b.Usage, b.Versioning}
+	sql := "insert into buckets(bucketname,acl,cors,logging,lc,uid,policy,website,encryption,createtime,usages,versioning," +
+		"tagging,requestpayer) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?);"
+	args := []interface{}{b.Name, acl, cors, logging, lc, b.OwnerId, bucket_policy, website, encryption, createTime, b.Usage, b.Versioning,
+		tagging, b.RequestPayer}
 	return sql, args
 }
@@ -1,8 +1,52 @@
 package types
 
+import . "github.com/journeymidnight/yig/error"
+
 type Cluster struct {
 	Fsid   string
 	Pool   string
 	Weight int
+	Status ClusterStatus
+}
+
+// Writable returns true if new objects could be written to the cluster
+func (c Cluster) Writable() bool {
+	return c.Status == ClusterStatusActive && c.Weight > 0
+}
+
+// Objects are still readable from clusters in any status, but only
+// active clusters accept new objects. A draining cluster is one whose
+// objects are being migrated out.
+type ClusterStatus uint8
+
+const (
+	ClusterStatusActive ClusterStatus = iota
+	ClusterStatusReadOnly
+	ClusterStatusDraining
+)
+
+var (
+	ClusterStatusIndexMap = map[ClusterStatus]string{
+		ClusterStatusActive:   "active",
+		ClusterStatusReadOnly: "readonly",
+		ClusterStatusDraining: "draining",
+	}
+
+	ClusterStatusStringMap = map[string]ClusterStatus{
+		"active":   ClusterStatusActive,
+		"readonly": ClusterStatusReadOnly,
+		"draining": ClusterStatusDraining,
+	}
+)
+
+func (s ClusterStatus) ToString() string {
+	return ClusterStatusIndexMap[s]
 }
 
+func MatchClusterStatus(status string) (ClusterStatus, error) {
+	if index, ok := ClusterStatusStringMap[status]; ok {
+		return index, nil
+	} else {
+		return 0, ErrInvalidClusterStatus
+	}
+}
@@ -18,13 +18,17 @@ type Freezer struct {
 	VersionId        string // version cache
 	Status           Status
 	LifeTime         int
+	Tier             RestoreTier
+	RequestTime      time.Time // when restoration was requested
 }
 
 func (o *Freezer) GetCreateSql() (string, []interface{}) {
 	// TODO Multi-version control
 	lastModifiedTime := o.LastModifiedTime.Format(TIME_LAYOUT_TIDB)
-	sql := "insert into restoreobjects(bucketname,objectname,status,lifetime,lastmodifiedtime) values(?,?,?,?,?)"
-	args := []interface{}{o.BucketName, o.Name, o.Status, o.LifeTime, lastModifiedTime}
+	requestTime := o.RequestTime.Format(TIME_LAYOUT_TIDB)
+	sql := "insert into restoreobjects(bucketname,objectname,status,lifetime,lastmodifiedtime,tier,requesttime) " +
+		"values(?,?,?,?,?,?,?)"
+	args := []interface{}{o.BucketName, o.Name, o.Status, o.LifeTime, lastModifiedTime, o.Tier, requestTime}
 	return sql, args
 }
 
@@ -32,9 +36,24 @@ func (o *Freezer) GetUpdateSql(status Status) (string, []interface{}) {
 	// TODO Multi-version control
 	// version := math.MaxUint64 - uint64(o.LastModifiedTime.UnixNano())
 	lastModifiedTime := o.LastModifiedTime.Format(TIME_LAYOUT_TIDB)
-	sql := "update restoreobjects set status,=?,lastmodifiedtime=?,location=?,pool=?," +
-		"ownerid=?,size=?,etag=? where bucketname=? and objectname=? and status=?"
-	args := []interface{}{status, lastModifiedTime, o.Location, o.Pool, o.OwnerId, o.Size, o.Etag, o.BucketName, o.Name, o.Status}
+	sql := "update restoreobjects set status=?,lastmodifiedtime=?,location=?,pool=?," +
+		"ownerid=?,size=?,objectid=?,etag=? where bucketname=? and objectname=? and status=?"
+	args := []interface{}{status, lastModifiedTime, o.Location, o.Pool, o.OwnerId, o.Size, o.ObjectId, o.Etag,
+		o.BucketName, o.Name, o.Status}
 
 	return sql, args
 }
+
+// ExpireTime returns when the restored copy should be removed, LastModifiedTime
+// of a restored freezer is the time restoration finished.
+func (o *Freezer) ExpireTime() time.Time {
+	return o.LastModifiedTime.Add(time.Duration(o.LifeTime) * 24 * time.Hour)
+}
+
+type ScanFreezerResult struct {
+	Truncated bool
+	// marker of next scan
+	NextBucketName string
+	NextObjectName string
+	Freezers       []Freezer
+}
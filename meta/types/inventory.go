@@ -0,0 +1,15 @@
+package types
+
+import (
+	"time"
+
+	"github.com/journeymidnight/yig/api/datatype"
+)
+
+// Inventory is an inventory configuration of a bucket. LastRun is when
+// its latest report is generated, zero if never.
+type Inventory struct {
+	BucketName string
+	Config     datatype.InventoryConfiguration
+	LastRun    time.Time
+}
@@ -23,6 +23,13 @@ type Part struct {
 	Etag                 string
 	LastModified         string // time string of format "2006-01-02T15:04:05.000Z"
 	InitializationVector []byte
+	// SHA-256 of data if the data is a deduplicated blob, see Blob
+	ContentHash string
+	// CRC32C of data as stored in hex
+	Checksum string
+	// additional checksum of plain data sent by client in base64
+	ChecksumAlgorithm string
+	ChecksumValue     string
 }
 
 type MultipartMetadata struct {
@@ -37,6 +44,10 @@ type MultipartMetadata struct {
 	CipherKey     []byte
 	Attrs         map[string]string
 	StorageClass  StorageClass
+	// algorithm of composite checksum of the object, requested by
+	// x-amz-checksum-algorithm on creating the upload. Parts must have
+	// checksums of the algorithm if set.
+	ChecksumAlgorithm string
 }
 
 type Multipart struct {
@@ -86,9 +97,10 @@ func valuesForParts(parts map[int]*Part) (values map[string][]byte, err error) {
 }
 
 func (p *Part) GetCreateSql(bucketname, objectname, version string) (string, []interface{}) {
-	sql := "insert into objectpart(partnumber,size,objectid,offset,etag,lastmodified,initializationvector,bucketname,objectname,version) " +
-		"values(?,?,?,?,?,?,?,?,?,?)"
-	args := []interface{}{p.PartNumber, p.Size, p.ObjectId, p.Offset, p.Etag, p.LastModified, p.InitializationVector, bucketname, objectname, version}
+	sql := "insert into objectpart(partnumber,size,objectid,offset,etag,lastmodified,initializationvector,contenthash,checksum," +
+		"checksumalgorithm,checksumvalue,bucketname,objectname,version) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+	args := []interface{}{p.PartNumber, p.Size, p.ObjectId, p.Offset, p.Etag, p.LastModified, p.InitializationVector, p.ContentHash, p.Checksum,
+		p.ChecksumAlgorithm, p.ChecksumValue, bucketname, objectname, version}
 	return sql, args
 }
 
@@ -99,6 +111,20 @@ func (p *Part) GetCreateGcSql(bucketname, objectname string, version uint64) (st
 	return sql, args
 }
 
+func (p *Part) GetCreateFreezerSql(bucketname, objectname string) (string, []interface{}) {
+	sql := "insert into restoreobjectpart(partnumber,size,objectid,offset,etag,lastmodified,initializationvector,bucketname,objectname) " +
+		"values(?,?,?,?,?,?,?,?,?)"
+	args := []interface{}{p.PartNumber, p.Size, p.ObjectId, p.Offset, p.Etag, p.LastModified, p.InitializationVector, bucketname, objectname}
+	return sql, args
+}
+
+// GetMigrateSql moves data of the part, only if it's still `sourceObjectId`
+func (p *Part) GetMigrateSql(bucketname, objectname string, version uint64, sourceObjectId string) (string, []interface{}) {
+	sql := "update objectpart set objectid=?,contenthash=? where bucketname=? and objectname=? and version=? and partnumber=? and objectid=?"
+	args := []interface{}{p.ObjectId, p.ContentHash, bucketname, objectname, version, p.PartNumber, sourceObjectId}
+	return sql, args
+}
+
 func (o *Object) GetUpdateObjectPartNameSql(sourceObject string) (string, []interface{}) {
 	version := math.MaxUint64 - uint64(o.LastModifiedTime.UnixNano())
 	sql := "update objectpart set objectname=? where bucketname=? and objectname=? and version=?"
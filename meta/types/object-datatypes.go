@@ -59,6 +59,10 @@ type CompletePart struct {
 
 	// Entity tag returned when the part was uploaded.
 	ETag string
+
+	// Additional checksum returned when the part was uploaded, verified
+	// if set
+	datatype.Checksums
 }
 
 // completedParts - is a collection satisfying sort.Interface.
@@ -46,6 +46,30 @@ type Object struct {
 	// ObjectType include `Normal`, `Appendable`, 'Multipart'
 	Type         ObjectType
 	StorageClass StorageClass
+	// small objects could be packed into a volume, ObjectId is the volume id
+	// and data of the object starts at VolumeOffset of the volume
+	Packed       bool
+	VolumeOffset int64
+	// SHA-256 of data if the data is a deduplicated blob, see Blob
+	ContentHash string
+	// CRC32C of data as stored in hex, empty for multipart objects and
+	// objects stored before checksums are recorded
+	Checksum string
+	// additional checksum of plain data sent by client in base64, see
+	// datatype.ChecksumRequest. It's composite checksum with "-N" suffix for
+	// multipart objects.
+	ChecksumAlgorithm string
+	ChecksumValue     string
+}
+
+type ScanObjectResult struct {
+	Truncated bool
+	// marker of next scan
+	NextBucketName string
+	NextObjectName string
+	NextVersion    uint64
+	// only some fields are filled, see the scan methods
+	Objects []*Object
 }
 
 type ObjectType int
@@ -146,27 +170,49 @@ func (o *Object) GetCreateSql() (string, []interface{}) {
 	acl, _ := json.Marshal(o.ACL)
 	lastModifiedTime := o.LastModifiedTime.Format(TIME_LAYOUT_TIDB)
 	sql := "insert into objects(bucketname,name,version,location,pool,ownerid,size,objectid,lastmodifiedtime,etag," +
-		"contenttype,customattributes,acl,nullversion,deletemarker,ssetype,encryptionkey,initializationvector,type,storageclass) " +
-		"values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+		"contenttype,customattributes,acl,nullversion,deletemarker,ssetype,encryptionkey,initializationvector,type,storageclass," +
+		"packed,volumeoffset,contenthash,checksum,checksumalgorithm,checksumvalue) " +
+		"values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
 	args := []interface{}{o.BucketName, o.Name, version, o.Location, o.Pool, o.OwnerId, o.Size, o.ObjectId,
 		lastModifiedTime, o.Etag, o.ContentType, customAttributes, acl, o.NullVersion, o.DeleteMarker,
-		o.SseType, o.EncryptionKey, o.InitializationVector, o.Type, o.StorageClass}
+		o.SseType, o.EncryptionKey, o.InitializationVector, o.Type, o.StorageClass, o.Packed, o.VolumeOffset,
+		o.ContentHash, o.Checksum, o.ChecksumAlgorithm, o.ChecksumValue}
 	return sql, args
 }
 
+// GetAppendSql updates the object only if its data is still where it's
+// appended to, i.e. not moved by migration meanwhile
 func (o *Object) GetAppendSql() (string, []interface{}) {
 	version := math.MaxUint64 - uint64(o.LastModifiedTime.UnixNano())
 	lastModifiedTime := o.LastModifiedTime.Format(TIME_LAYOUT_TIDB)
-	sql := "update objects set lastmodifiedtime=?, size=?, version=? where bucketname=? and name=?"
-	args := []interface{}{lastModifiedTime, o.Size, version, o.BucketName, o.Name}
+	sql := "update objects set lastmodifiedtime=?, size=?, version=?, checksum=?, checksumalgorithm=?, checksumvalue=? " +
+		"where bucketname=? and name=? and location=? and pool=? and objectid=?"
+	args := []interface{}{lastModifiedTime, o.Size, version, o.Checksum, o.ChecksumAlgorithm, o.ChecksumValue,
+		o.BucketName, o.Name, o.Location, o.Pool, o.ObjectId}
 	return sql, args
 }
 
 func (o *Object) GetUpdateSql() (string, []interface{}) {
 	version := math.MaxUint64 - uint64(o.LastModifiedTime.UnixNano())
-	sql := "update objects set location=?,pool=?," +
-		"size=?,objectid=?,etag=?,initializationvector=?,storageclass=? where bucketname=? and name=? and version=?"
-	args := []interface{}{o.Location, o.Pool, o.Size, o.ObjectId, o.Etag, o.InitializationVector, o.StorageClass, o.BucketName, o.Name, version}
+	sql := "update objects set location=?,pool=?,size=?,objectid=?,etag=?,initializationvector=?,storageclass=?," +
+		"packed=?,volumeoffset=?,contenthash=?,checksum=?,checksumalgorithm=?,checksumvalue=? " +
+		"where bucketname=? and name=? and version=?"
+	args := []interface{}{o.Location, o.Pool, o.Size, o.ObjectId, o.Etag, o.InitializationVector, o.StorageClass,
+		o.Packed, o.VolumeOffset, o.ContentHash, o.Checksum, o.ChecksumAlgorithm, o.ChecksumValue,
+		o.BucketName, o.Name, version}
+	return sql, args
+}
+
+// GetMigrateSql moves data location of the object, only if its data is
+// still in location of `source`
+func (o *Object) GetMigrateSql(source *Object) (string, []interface{}) {
+	version := math.MaxUint64 - uint64(o.LastModifiedTime.UnixNano())
+	sql := "update objects set location=?,pool=?,objectid=?,packed=?,volumeoffset=?,contenthash=? " +
+		"where bucketname=? and name=? and version=? " +
+		"and location=? and pool=? and objectid=? and volumeoffset=?"
+	args := []interface{}{o.Location, o.Pool, o.ObjectId, o.Packed, o.VolumeOffset, o.ContentHash,
+		o.BucketName, o.Name, version,
+		source.Location, source.Pool, source.ObjectId, source.VolumeOffset}
 	return sql, args
 }
 
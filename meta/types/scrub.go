@@ -0,0 +1,33 @@
+package types
+
+import "time"
+
+const (
+	ScrubDataMissing      = "missing"
+	ScrubSizeMismatch     = "size mismatch"
+	ScrubChecksumMismatch = "checksum mismatch"
+)
+
+// ScrubFailure is data of an object, or a part of it, found lost or
+// corrupted by scrubbing. Expected and Actual are sizes or checksums
+// depending on Reason.
+type ScrubFailure struct {
+	BucketName string
+	ObjectName string
+	Version    uint64
+	PartNumber int // 0 if the object is not multipart
+	Location   string
+	Pool       string
+	ObjectId   string
+	Reason     string
+	Expected   string
+	Actual     string
+	MTime      time.Time
+}
+
+// ScrubFailureCount is number of scrub failures of a cluster for a reason
+type ScrubFailureCount struct {
+	Location string
+	Reason   string
+	Count    int64
+}
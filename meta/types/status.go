@@ -35,3 +35,42 @@ func MatchStatusIndex(status string) (Status, error) {
 		return 0, ErrInvalidStatus
 	}
 }
+
+// Restore tiers of glacier objects, with different retrieval time and price
+type RestoreTier uint8
+
+const (
+	RestoreTierStandard RestoreTier = iota
+	RestoreTierBulk
+	RestoreTierExpedited
+)
+
+var (
+	RestoreTierIndexMap = map[RestoreTier]string{
+		RestoreTierStandard:  "Standard",
+		RestoreTierBulk:      "Bulk",
+		RestoreTierExpedited: "Expedited",
+	}
+
+	RestoreTierStringMap = map[string]RestoreTier{
+		"Standard":  RestoreTierStandard,
+		"Bulk":      RestoreTierBulk,
+		"Expedited": RestoreTierExpedited,
+	}
+)
+
+func (t RestoreTier) ToString() string {
+	return RestoreTierIndexMap[t]
+}
+
+// MatchRestoreTier returns RestoreTierStandard for empty tier
+func MatchRestoreTier(tier string) (RestoreTier, error) {
+	if tier == "" {
+		return RestoreTierStandard, nil
+	}
+	if index, ok := RestoreTierStringMap[tier]; ok {
+		return index, nil
+	} else {
+		return 0, ErrInvalidRestoreInfo
+	}
+}
@@ -0,0 +1,25 @@
+package types
+
+import "time"
+
+// Volume is a backend object small objects are packed into. Volumes are
+// appended by one yig instance until sealed, objects deleted from a volume
+// are accounted in DeletedSize, and the volume is compacted once enough of
+// it is deleted.
+type Volume struct {
+	Location    string
+	Pool        string
+	VolumeId    string
+	Size        int64
+	DeletedSize int64
+	Sealed      bool
+	MTime       time.Time // when the volume is created or sealed
+}
+
+// DeletedPercent returns how much of the volume is deleted, in 0 ~ 100
+func (v Volume) DeletedPercent() int {
+	if v.Size <= 0 {
+		return 0
+	}
+	return int(v.DeletedSize * 100 / v.Size)
+}
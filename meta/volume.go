@@ -0,0 +1,60 @@
+package meta
+
+import (
+	"database/sql"
+	"time"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+func (m *Meta) PutVolume(volume Volume) error {
+	return m.Client.PutVolume(volume)
+}
+
+func (m *Meta) SealVolume(location, pool, volumeId string, size int64) error {
+	return m.Client.SealVolume(location, pool, volumeId, size)
+}
+
+func (m *Meta) AddVolumeDeletedSize(location, pool, volumeId string, size int64) error {
+	return m.Client.AddVolumeDeletedSize(location, pool, volumeId, size, nil)
+}
+
+func (m *Meta) ScanVolumes(limit int, location, pool, volumeId string) ([]Volume, error) {
+	return m.Client.ScanVolumes(limit, location, pool, volumeId)
+}
+
+func (m *Meta) ListVolumeObjects(volume Volume) ([]*Object, error) {
+	return m.Client.ListVolumeObjects(volume)
+}
+
+// RemoveVolume deletes the volume and puts its data to gc, in one transaction.
+// The volume should have no objects packed in it.
+func (m *Meta) RemoveVolume(volume Volume) (err error) {
+	var tx *sql.Tx
+	tx, err = m.Client.NewTrans()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			err = m.Client.CommitTrans(tx)
+		}
+		if err != nil {
+			m.Client.AbortTrans(tx)
+		}
+	}()
+
+	err = m.Client.DeleteVolume(volume, tx)
+	if err != nil {
+		return err
+	}
+	// volumes are not objects of any bucket, gc entry is keyed by volume id
+	garbage := &Object{
+		Name:             volume.VolumeId,
+		Location:         volume.Location,
+		Pool:             volume.Pool,
+		ObjectId:         volume.VolumeId,
+		LastModifiedTime: time.Now(),
+	}
+	return m.Client.PutObjectToGarbageCollection(garbage, tx)
+}
@@ -16,7 +16,8 @@ type MessageSender interface {
 
 var MsgSender MessageSender
 
-// create the singleton MessageSender
+// create the singleton MessageSender, returns nil if no message queue
+// plugin is enabled
 func InitMessageSender(plugins map[string]*mods.YigPlugin) (MessageSender, error) {
 	for name, p := range plugins {
 		if p.PluginType == mods.MQ_PLUGIN {
@@ -30,5 +31,5 @@ func InitMessageSender(plugins map[string]*mods.YigPlugin) (MessageSender, error
 			return MsgSender, nil
 		}
 	}
-	panic("Failed to initialize any MessageQueue plugin, quiting...\n")
+	return nil, nil
 }
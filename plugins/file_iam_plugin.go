@@ -0,0 +1,448 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/cache"
+	"github.com/journeymidnight/yig/iam/common"
+	"github.com/journeymidnight/yig/iam/ldap"
+	"github.com/journeymidnight/yig/mods"
+)
+
+const (
+	pluginName = "file_iam"
+
+	defaultReloadInterval      = 10 * time.Second
+	defaultRotationGracePeriod = 24 * time.Hour
+	defaultLdapTimeout         = 5 * time.Second
+	defaultLdapCacheTTL        = 5 * time.Minute
+
+	KeyStatusActive   = "active"
+	KeyStatusRotating = "rotating" // still valid until rotated_at + rotation grace period
+	KeyStatusDisabled = "disabled"
+)
+
+var (
+	ErrKeyDisabled = errors.New("Access key is disabled")
+	ErrKeyExpired  = errors.New("Access key has expired")
+	ErrNoKeyOwner  = errors.New("Access key has no owner")
+)
+
+// The variable MUST be named as Exported.
+// the code in yig-plugin will lookup this symbol
+var Exported = mods.YigPlugin{
+	Name:       pluginName,
+	PluginType: mods.IAM_PLUGIN,
+	Create:     GetIamClient,
+}
+
+// Format of the users file, e.g.
+//
+//	[[users]]
+//	user_id = "u-1"
+//	display_name = "Alice"
+//	  [[users.keys]]
+//	  access_key = "AKIDEXAMPLE00001"
+//	  secret_key = "secret-key-of-alice"
+//	  status = "rotating"
+//	  rotated_at = 2019-09-01T00:00:00Z
+//	  [[users.keys]]
+//	  access_key = "AKIDEXAMPLE00002"
+//	  secret_key = "new-secret-key-of-alice"
+//	  expire_at = 2020-09-01T00:00:00Z
+//
+//	# Owner of keys listed here are looked up in LDAP, by `ldap_key_attr`
+//	[[keys]]
+//	access_key = "AKIDEXAMPLE00003"
+//	secret_key = "secret-key-of-bob"
+type UsersFile struct {
+	Users []FileUser `toml:"users"`
+	Keys  []FileKey  `toml:"keys"`
+}
+
+type FileUser struct {
+	UserId      string    `toml:"user_id"`
+	DisplayName string    `toml:"display_name"`
+	Keys        []FileKey `toml:"keys"`
+}
+
+type FileKey struct {
+	AccessKey            string    `toml:"access_key"`
+	SecretKey            string    `toml:"secret_key"`
+	Status               string    `toml:"status"` // "active"(default), "rotating" or "disabled"
+	ExpireAt             time.Time `toml:"expire_at"`
+	RotatedAt            time.Time `toml:"rotated_at"`
+	AllowOtherUserAccess bool      `toml:"allow_other_user_access"`
+}
+
+type keyEntry struct {
+	FileKey
+	UserId      string // empty if the owner should be looked up in LDAP
+	DisplayName string
+}
+
+// check returns nil if the key could be used at `now`
+func (k keyEntry) check(now time.Time, gracePeriod time.Duration) error {
+	switch k.Status {
+	case KeyStatusDisabled:
+		return ErrKeyDisabled
+	case KeyStatusRotating:
+		if !now.Before(k.RotatedAt.Add(gracePeriod)) {
+			return ErrKeyExpired
+		}
+	}
+	if !k.ExpireAt.IsZero() && !now.Before(k.ExpireAt) {
+		return ErrKeyExpired
+	}
+	return nil
+}
+
+type LdapConfig struct {
+	ldap.Config
+	UserAttr string // attribute of user id, e.g "uid"
+	NameAttr string // attribute of display name, e.g "cn"
+	KeyAttr  string // multi-valued attribute of access keys belong to the user
+	// owners looked up are cached for CacheTTL, changes in LDAP take effect
+	// after it
+	CacheTTL time.Duration
+}
+
+type keyOwner struct {
+	UserId      string
+	DisplayName string
+	resolvedAt  time.Time
+}
+
+type FileIamClient struct {
+	path                string
+	reloadInterval      time.Duration
+	rotationGracePeriod time.Duration
+	ldap                *LdapConfig
+	ldapPool            *ldap.Pool
+
+	lock    sync.RWMutex
+	keys    map[string]keyEntry // access key -> key
+	users   map[string][]string // user id -> access keys, only for users in file
+	modTime time.Time
+	// fingerprints of usable keys at last check, used to find out which
+	// keys should be invalidated in iam cache
+	usable map[string]keyEntry
+
+	ownerLock sync.Mutex
+	owners    map[string]keyOwner // access key -> owner looked up in LDAP
+}
+
+func GetIamClient(config map[string]interface{}) (interface{}, error) {
+	helper.Logger.Info("Get plugin config:", config)
+
+	path, _ := config["path"].(string)
+	if path == "" {
+		return nil, errors.New("path of users file is required")
+	}
+	c := &FileIamClient{
+		path:                path,
+		reloadInterval:      durationArg(config, "reload_interval", defaultReloadInterval),
+		rotationGracePeriod: durationArg(config, "rotation_grace_period", defaultRotationGracePeriod),
+		owners:              make(map[string]keyOwner),
+	}
+	if url, _ := config["ldap_url"].(string); url != "" {
+		c.ldap = &LdapConfig{
+			Config: ldap.Config{
+				Url:           url,
+				StartTLS:      boolArg(config, "ldap_start_tls", true),
+				Timeout:       durationArg(config, "ldap_timeout", defaultLdapTimeout),
+				MaxPacketSize: int64Arg(config, "ldap_max_packet_size", ldap.DefaultMaxPacketSize),
+				PoolSize:      int(int64Arg(config, "ldap_pool_size", ldap.DefaultPoolSize)),
+			},
+			UserAttr: stringArg(config, "ldap_user_attr", "uid"),
+			NameAttr: stringArg(config, "ldap_name_attr", "cn"),
+			KeyAttr:  stringArg(config, "ldap_key_attr", "yigAccessKey"),
+			CacheTTL: durationArg(config, "ldap_cache_ttl", defaultLdapCacheTTL),
+		}
+		c.ldap.BindDN, _ = config["ldap_bind_dn"].(string)
+		c.ldap.BindPassword, _ = config["ldap_bind_password"].(string)
+		c.ldap.BaseDN, _ = config["ldap_base_dn"].(string)
+		pool, err := ldap.NewPool(c.ldap.Config)
+		if err != nil {
+			return nil, err
+		}
+		c.ldapPool = pool
+	}
+
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	go c.reloadLoop()
+	return c, nil
+}
+
+// durations are in seconds
+func durationArg(config map[string]interface{}, name string, defaultValue time.Duration) time.Duration {
+	switch v := config[name].(type) {
+	case int64:
+		return time.Duration(v) * time.Second
+	case float64:
+		return time.Duration(v * float64(time.Second))
+	}
+	return defaultValue
+}
+
+func int64Arg(config map[string]interface{}, name string, defaultValue int64) int64 {
+	switch v := config[name].(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return defaultValue
+}
+
+func boolArg(config map[string]interface{}, name string, defaultValue bool) bool {
+	if v, ok := config[name].(bool); ok {
+		return v
+	}
+	return defaultValue
+}
+
+func stringArg(config map[string]interface{}, name string, defaultValue string) string {
+	if v, ok := config[name].(string); ok && v != "" {
+		return v
+	}
+	return defaultValue
+}
+
+func (c *FileIamClient) reloadLoop() {
+	for {
+		time.Sleep(c.reloadInterval)
+		info, err := os.Stat(c.path)
+		if err != nil {
+			helper.Logger.Error("Stat IAM users file error:", err)
+		} else if !info.ModTime().Equal(c.modTime) {
+			if err = c.reload(); err != nil {
+				// keep serving with keys loaded last time
+				helper.Logger.Error("Reload IAM users file error:", err)
+			}
+		}
+		c.invalidateChangedKeys()
+		c.expireOwners()
+	}
+}
+
+func (c *FileIamClient) reload() error {
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return err
+	}
+	var f UsersFile
+	if _, err = toml.DecodeFile(c.path, &f); err != nil {
+		return err
+	}
+
+	keys := make(map[string]keyEntry)
+	users := make(map[string][]string)
+	addKey := func(key FileKey, userId, displayName string) error {
+		if key.AccessKey == "" || key.SecretKey == "" {
+			return errors.New("access_key and secret_key are required")
+		}
+		if _, ok := keys[key.AccessKey]; ok {
+			return errors.New("duplicated access key " + key.AccessKey)
+		}
+		switch key.Status {
+		case "":
+			key.Status = KeyStatusActive
+		case KeyStatusActive, KeyStatusDisabled:
+		case KeyStatusRotating:
+			if key.RotatedAt.IsZero() {
+				return errors.New("rotated_at is required for rotating key " + key.AccessKey)
+			}
+		default:
+			return errors.New("invalid status " + key.Status + " of key " + key.AccessKey)
+		}
+		keys[key.AccessKey] = keyEntry{
+			FileKey:     key,
+			UserId:      userId,
+			DisplayName: displayName,
+		}
+		if userId != "" {
+			users[userId] = append(users[userId], key.AccessKey)
+		}
+		return nil
+	}
+	for _, user := range f.Users {
+		if user.UserId == "" {
+			return errors.New("user_id is required")
+		}
+		displayName := helper.Ternary(user.DisplayName == "", user.UserId, user.DisplayName).(string)
+		for _, key := range user.Keys {
+			if err = addKey(key, user.UserId, displayName); err != nil {
+				return err
+			}
+		}
+	}
+	if len(f.Keys) > 0 && c.ldap == nil {
+		return errors.New("keys without user require LDAP")
+	}
+	for _, key := range f.Keys {
+		if err = addKey(key, "", ""); err != nil {
+			return err
+		}
+	}
+
+	c.lock.Lock()
+	c.keys = keys
+	c.users = users
+	c.modTime = info.ModTime()
+	c.lock.Unlock()
+	helper.Logger.Info("Loaded IAM users file", c.path, "users:", len(users), "keys:", len(keys))
+	c.invalidateChangedKeys()
+	return nil
+}
+
+// invalidateChangedKeys drops keys which are changed, removed, disabled or
+// expired since last check from iam cache.
+func (c *FileIamClient) invalidateChangedKeys() {
+	now := time.Now()
+	usable := make(map[string]keyEntry)
+	c.lock.RLock()
+	for accessKey, key := range c.keys {
+		if key.check(now, c.rotationGracePeriod) == nil {
+			usable[accessKey] = key
+		}
+	}
+	c.lock.RUnlock()
+
+	var invalid []string
+	for accessKey, key := range c.usable {
+		if current, ok := usable[accessKey]; !ok || current != key {
+			invalid = append(invalid, accessKey)
+		}
+	}
+	c.usable = usable
+	if len(invalid) > 0 {
+		helper.Logger.Info("Invalidate IAM cache for keys:", invalid)
+		cache.Invalidate(invalid...)
+	}
+}
+
+func (c *FileIamClient) getKey(accessKey string) (key keyEntry, err error) {
+	c.lock.RLock()
+	key, ok := c.keys[accessKey]
+	c.lock.RUnlock()
+	if !ok {
+		return key, common.ErrAccessKeyNotExist
+	}
+	if err = key.check(time.Now(), c.rotationGracePeriod); err != nil {
+		return key, err
+	}
+	return key, nil
+}
+
+func (c *FileIamClient) GetKeysByUid(uid string) (credentials []common.Credential, err error) {
+	c.lock.RLock()
+	accessKeys := append([]string{}, c.users[uid]...)
+	c.lock.RUnlock()
+
+	if len(accessKeys) == 0 && c.ldap != nil {
+		entries, err := c.ldapSearch(c.ldap.UserAttr, uid)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			accessKeys = append(accessKeys, entry.Attributes[c.ldap.KeyAttr]...)
+		}
+	}
+
+	for _, accessKey := range accessKeys {
+		credential, err := c.GetCredential(accessKey)
+		if err != nil || credential.UserId != uid {
+			continue
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials, nil
+}
+
+func (c *FileIamClient) GetCredential(accessKey string) (credential common.Credential, err error) {
+	key, err := c.getKey(accessKey)
+	if err != nil {
+		return credential, err
+	}
+	if key.UserId == "" {
+		owner, err := c.getOwner(accessKey)
+		if err != nil {
+			return credential, err
+		}
+		key.UserId = owner.UserId
+		key.DisplayName = owner.DisplayName
+	}
+	return common.Credential{
+		UserId:               key.UserId,
+		DisplayName:          key.DisplayName,
+		AccessKeyID:          key.AccessKey,
+		SecretAccessKey:      key.SecretKey,
+		AllowOtherUserAccess: key.AllowOtherUserAccess,
+	}, nil
+}
+
+// getOwner looks up the owner of `accessKey` in LDAP, unless it's cached.
+// Keys without an owner are not cached, so they work once added to LDAP.
+func (c *FileIamClient) getOwner(accessKey string) (owner keyOwner, err error) {
+	c.ownerLock.Lock()
+	owner, ok := c.owners[accessKey]
+	c.ownerLock.Unlock()
+	if ok && time.Since(owner.resolvedAt) < c.ldap.CacheTTL {
+		return owner, nil
+	}
+
+	entries, err := c.ldapSearch(c.ldap.KeyAttr, accessKey)
+	if err != nil {
+		return owner, err
+	}
+	if len(entries) != 1 || entries[0].Get(c.ldap.UserAttr) == "" {
+		return owner, ErrNoKeyOwner
+	}
+	owner = keyOwner{
+		UserId: entries[0].Get(c.ldap.UserAttr),
+		DisplayName: helper.Ternary(entries[0].Get(c.ldap.NameAttr) == "",
+			entries[0].Get(c.ldap.UserAttr), entries[0].Get(c.ldap.NameAttr)).(string),
+		resolvedAt: time.Now(),
+	}
+	c.ownerLock.Lock()
+	c.owners[accessKey] = owner
+	c.ownerLock.Unlock()
+	return owner, nil
+}
+
+// expireOwners drops owners cached longer than CacheTTL, and their
+// credentials from iam cache, so owners changed in LDAP are looked up again.
+func (c *FileIamClient) expireOwners() {
+	if c.ldap == nil {
+		return
+	}
+	var expired []string
+	c.ownerLock.Lock()
+	for accessKey, owner := range c.owners {
+		if time.Since(owner.resolvedAt) >= c.ldap.CacheTTL {
+			delete(c.owners, accessKey)
+			expired = append(expired, accessKey)
+		}
+	}
+	c.ownerLock.Unlock()
+	if len(expired) > 0 {
+		cache.Invalidate(expired...)
+	}
+}
+
+func (c *FileIamClient) ldapSearch(attribute, value string) ([]ldap.Entry, error) {
+	entries, err := c.ldapPool.Search(attribute, value,
+		[]string{c.ldap.UserAttr, c.ldap.NameAttr, c.ldap.KeyAttr})
+	if err != nil {
+		helper.Logger.Error("Search LDAP error:", err)
+	}
+	return entries, err
+}
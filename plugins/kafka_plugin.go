@@ -140,3 +140,13 @@ func (kf *Kafka) AsyncSend(value []byte) error {
 	kf.producer.ProduceChannel() <- &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &kf.Topic, Partition: kafka.PartitionAny}, Key: []byte(""), Value: value, Opaque: nil}
 	return nil
 }
+
+// Ping fetches metadata of the topic to check if brokers are reachable,
+// timeout is in ms.
+func (kf *Kafka) Ping(timeout int) error {
+	if nil == kf.producer {
+		return errors.New("Kafka is not created correctly yet.")
+	}
+	_, err := kf.producer.GetMetadata(&kf.Topic, false, timeout)
+	return err
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 
 	"context"
 	"github.com/cep21/circuit"
@@ -18,6 +19,7 @@ import (
 
 var (
 	redisPool    *redigo.Pool
+	poolLock     sync.RWMutex
 	CacheCircuit *circuit.Circuit
 )
 
@@ -47,7 +49,30 @@ var MetadataTables = []RedisDatabase{UserTable, BucketTable, ObjectTable, Cluste
 var DataTables = []RedisDatabase{FileTable}
 
 func Initialize() {
+	CacheCircuit = circuitbreak.NewCacheCircuit()
+	redisPool = newPool()
+
+	helper.SubscribeConfig("redis pool", []string{"redis_address", "redis_password",
+		"redis_connect_timeout", "redis_read_timeout", "redis_write_timeout",
+		"redis_keepalive", "redis_pool_max_idle", "redis_pool_idle_timeout"},
+		func(_ *helper.Config) error {
+			poolLock.Lock()
+			old := redisPool
+			redisPool = newPool()
+			poolLock.Unlock()
+			// connections in use are closed once put back
+			return old.Close()
+		})
+	helper.SubscribeConfig("cache circuit", []string{"cache_circuit_close_sleep_window",
+		"cache_circuit_close_required_count", "cache_circuit_open_threshold",
+		"cache_circuit_exec_timeout", "cache_circuit_exec_max_concurrent"},
+		func(_ *helper.Config) error {
+			circuitbreak.ReconfigureCacheCircuit(CacheCircuit)
+			return nil
+		})
+}
 
+func newPool() *redigo.Pool {
 	options := []redigo.DialOption{
 		redigo.DialReadTimeout(time.Duration(helper.CONFIG.RedisReadTimeout) * time.Second),
 		redigo.DialConnectTimeout(time.Duration(helper.CONFIG.RedisConnectTimeout) * time.Second),
@@ -67,8 +92,7 @@ func Initialize() {
 		return c, nil
 	}
 
-	CacheCircuit = circuitbreak.NewCacheCircuit()
-	redisPool = &redigo.Pool{
+	return &redigo.Pool{
 		MaxIdle:     helper.CONFIG.RedisPoolMaxIdle,
 		IdleTimeout: time.Duration(helper.CONFIG.RedisPoolIdleTimeout) * time.Second,
 		// Other pool configuration not shown in this example.
@@ -77,18 +101,20 @@ func Initialize() {
 }
 
 func Pool() *redigo.Pool {
+	poolLock.RLock()
+	defer poolLock.RUnlock()
 	return redisPool
 }
 
 func Close() {
-	err := redisPool.Close()
+	err := Pool().Close()
 	if err != nil {
 		helper.Logger.Error("Cannot close redis pool:", err)
 	}
 }
 
 func GetClient(ctx context.Context) (redigo.Conn, error) {
-	return redisPool.GetContext(ctx)
+	return Pool().GetContext(ctx)
 }
 
 func Remove(table RedisDatabase, key string) (err error) {
@@ -296,14 +322,11 @@ func Invalid(table RedisDatabase, key string) (err error) {
 			if err != nil {
 				return err
 			}
-			// Use table.String() + hashkey as Redis key
-			r, err := redigo.String(c.Do("PUBLISH", table.InvalidQueue(), hashkey))
-			if err == redigo.ErrNil {
-				return nil
-			}
+			// reply is the number of instances receiving the message
+			_, err = redigo.Int(c.Do("PUBLISH", table.InvalidQueue(), hashkey))
 			if err != nil {
-				helper.Logger.Error(fmt.Sprintf("Cmd: PUBLISH. Queue: %s. Key: %s. Reply: %s.",
-					table.InvalidQueue(), FileTable.String()+key, r))
+				helper.Logger.Error(fmt.Sprintf("Cmd: PUBLISH. Queue: %s. Key: %s. Err: %s.",
+					table.InvalidQueue(), table.String()+key, err))
 			}
 			return err
 		},
@@ -311,6 +334,56 @@ func Invalid(table RedisDatabase, key string) (err error) {
 	)
 }
 
+// Subscribe invalid messages of `tables` published by YIG instances,
+// `onMessage` is called with the hashed key of each message. Reconnects if the
+// subscription breaks, `onSubscribe` is called every time it's (re)established
+// since messages published meanwhile are lost.
+func SubscribeInvalid(tables []RedisDatabase, onSubscribe func(),
+	onMessage func(table RedisDatabase, hashkey string)) {
+
+	channels := make([]interface{}, 0, len(tables))
+	queues := make(map[string]RedisDatabase)
+	for _, table := range tables {
+		channels = append(channels, table.InvalidQueue())
+		queues[table.InvalidQueue()] = table
+	}
+	options := []redigo.DialOption{
+		redigo.DialConnectTimeout(time.Duration(helper.CONFIG.RedisConnectTimeout) * time.Second),
+		redigo.DialKeepAlive(time.Duration(helper.CONFIG.RedisKeepAlive) * time.Second),
+	}
+	if helper.CONFIG.RedisPassword != "" {
+		options = append(options, redigo.DialPassword(helper.CONFIG.RedisPassword))
+	}
+	for {
+		// no read timeout, the connection is idle if nothing is invalidated
+		c, err := redigo.Dial("tcp", helper.CONFIG.RedisAddress, options...)
+		if err != nil {
+			helper.Logger.Error("Redis dial for subscribing error:", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		psc := redigo.PubSubConn{Conn: c}
+		err = psc.Subscribe(channels...)
+		for err == nil {
+			switch v := psc.Receive().(type) {
+			case redigo.Message:
+				if table, ok := queues[v.Channel]; ok {
+					onMessage(table, string(v.Data))
+				}
+			case redigo.Subscription:
+				if v.Count == len(channels) {
+					onSubscribe()
+				}
+			case error:
+				err = v
+			}
+		}
+		helper.Logger.Error("Redis subscription of", channels, "error:", err)
+		psc.Close()
+		time.Sleep(time.Second)
+	}
+}
+
 // Get Object to HighWayHash for redis
 func HashSum(ObjectName string) (string, error) {
 	key, err := hex.DecodeString(keyvalue)
@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const ThrottleKeyPrefix = "throttle:"
+
+// KEYS[1]: key of the token bucket
+// ARGV: rate, burst, n, now in milliseconds, "1" to take n tokens only if enough
+// returns {1 if taken, tokens left}
+var tokenBucketScript = redigo.NewScript(1, `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+if now < ts then
+	now = ts
+end
+tokens = math.min(burst, tokens + (now - ts) * rate / 1000)
+local taken = 1
+if ARGV[5] == "1" and tokens < n then
+	taken = 0
+else
+	tokens = tokens - n
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) * 1000 / rate) + 1000)
+return {taken, tostring(tokens)}
+`)
+
+// TakeTokens takes `n` tokens from the token bucket `key` refilled at `rate`
+// per second and holds at most `burst` tokens. If `onlyIfEnough` is false,
+// tokens are always taken and the bucket could go negative.
+func TakeTokens(key string, rate, burst, n float64, onlyIfEnough bool) (taken bool, tokens float64, err error) {
+	err = CacheCircuit.Execute(
+		context.Background(),
+		func(ctx context.Context) (err error) {
+			c, err := GetClient(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			flag := "0"
+			if onlyIfEnough {
+				flag = "1"
+			}
+			values, err := redigo.Values(tokenBucketScript.Do(c, ThrottleKeyPrefix+key,
+				rate, burst, n, time.Now().UnixNano()/int64(time.Millisecond), flag))
+			if err != nil {
+				return err
+			}
+			var left string
+			var result int
+			if _, err = redigo.Scan(values, &result, &left); err != nil {
+				return err
+			}
+			taken = result == 1
+			tokens, err = strconv.ParseFloat(left, 64)
+			return err
+		},
+		nil,
+	)
+	return
+}
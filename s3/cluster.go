@@ -0,0 +1,179 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/journeymidnight/aws-sdk-go/aws"
+	"github.com/journeymidnight/aws-sdk-go/aws/credentials"
+	"github.com/journeymidnight/aws-sdk-go/aws/session"
+	awss3 "github.com/journeymidnight/aws-sdk-go/service/s3"
+	"github.com/journeymidnight/aws-sdk-go/service/s3/s3manager"
+	"github.com/journeymidnight/yig/backend"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	UPLOAD_PART_SIZE   = 8 << 20 /* 8M */
+	UPLOAD_CONCURRENCY = 2
+)
+
+var ErrAppendNotSupported = errors.New("append is not supported by remote S3 cluster")
+
+// Initialize creates clusters of remote S3 endpoints in config.RemoteClusters,
+// returns cluster ID -> Cluster
+func Initialize(config helper.Config) map[string]backend.Cluster {
+	clusters := make(map[string]backend.Cluster)
+	for id, c := range config.RemoteClusters {
+		cluster, err := NewS3Cluster(id, c)
+		if err != nil {
+			helper.Logger.Error("Failed to create remote cluster", id, err)
+			continue
+		}
+		clusters[id] = cluster
+	}
+	return clusters
+}
+
+// S3Cluster stores objects as keys "<pool>/<oid>" in a bucket of a remote
+// S3 compatible endpoint.
+type S3Cluster struct {
+	Name     string
+	Bucket   string
+	Client   *awss3.S3
+	uploader *s3manager.Uploader
+	counter  uint64
+}
+
+func NewS3Cluster(id string, config helper.RemoteClusterConfig) (*S3Cluster, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("endpoint and bucket should be specified")
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, ""),
+		Endpoint:         aws.String(config.Endpoint),
+		Region:           aws.String(config.Region),
+		S3ForcePathStyle: aws.Bool(!config.VirtualHostStyle),
+	})
+	if err != nil {
+		return nil, err
+	}
+	client := awss3.New(sess)
+	cluster := &S3Cluster{
+		Name:   id,
+		Bucket: config.Bucket,
+		Client: client,
+		uploader: s3manager.NewUploaderWithClient(client, func(u *s3manager.Uploader) {
+			u.PartSize = UPLOAD_PART_SIZE
+			u.Concurrency = UPLOAD_CONCURRENCY
+		}),
+	}
+	helper.Logger.Info("Remote cluster", id, "is ready, endpoint:", config.Endpoint,
+		"bucket:", config.Bucket)
+	return cluster, nil
+}
+
+func (cluster *S3Cluster) getUniqUploadName() string {
+	v := atomic.AddUint64(&cluster.counter, 1)
+	return fmt.Sprintf("%s:%d:%d", helper.CONFIG.InstanceId, time.Now().UnixNano(), v)
+}
+
+func objectKey(poolName, oid string) string {
+	return poolName + "/" + oid
+}
+
+func (cluster *S3Cluster) ID() string {
+	return cluster.Name
+}
+
+// GetUsage checks the bucket is accessible, space of remote endpoint could
+// not be told, so ErrUsageUnknown is returned if accessible.
+func (cluster *S3Cluster) GetUsage() (usage backend.Usage, err error) {
+	return cluster.GetUsageWithContext(context.Background())
+}
+
+func (cluster *S3Cluster) GetUsageWithContext(ctx context.Context) (usage backend.Usage, err error) {
+	_, err = cluster.Client.HeadBucketWithContext(ctx, &awss3.HeadBucketInput{
+		Bucket: aws.String(cluster.Bucket),
+	})
+	if err != nil {
+		return usage, err
+	}
+	return usage, backend.ErrUsageUnknown
+}
+
+// countingReader counts bytes read
+type countingReader struct {
+	io.Reader
+	n uint64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	atomic.AddUint64(&r.n, uint64(n))
+	return
+}
+
+func (cluster *S3Cluster) Put(poolName string, data io.Reader) (oid string,
+	size uint64, err error) {
+
+	oid = cluster.getUniqUploadName()
+	reader := &countingReader{Reader: data}
+	_, err = cluster.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(cluster.Bucket),
+		Key:    aws.String(objectKey(poolName, oid)),
+		Body:   reader,
+	})
+	if err != nil {
+		return oid, 0, err
+	}
+	return oid, atomic.LoadUint64(&reader.n), nil
+}
+
+func (cluster *S3Cluster) Append(poolName, existName string, objectChunk io.Reader,
+	offset int64) (objectName string, bytesWritten uint64, err error) {
+
+	return existName, 0, ErrAppendNotSupported
+}
+
+// GetReader gets the whole object if length is 0, like ceph clusters
+func (cluster *S3Cluster) GetReader(poolName, objectName string,
+	offset int64, length uint64) (io.ReadCloser, error) {
+
+	return cluster.GetReaderWithContext(context.Background(), poolName, objectName, offset, length)
+}
+
+func (cluster *S3Cluster) GetReaderWithContext(ctx context.Context, poolName, objectName string,
+	offset int64, length uint64) (io.ReadCloser, error) {
+
+	input := &awss3.GetObjectInput{
+		Bucket: aws.String(cluster.Bucket),
+		Key:    aws.String(objectKey(poolName, objectName)),
+	}
+	if length > 0 {
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+int64(length)-1))
+	} else if offset > 0 {
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+	output, err := cluster.Client.GetObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return output.Body, nil
+}
+
+func (cluster *S3Cluster) Remove(poolName, objectName string) error {
+	return cluster.RemoveWithContext(context.Background(), poolName, objectName)
+}
+
+func (cluster *S3Cluster) RemoveWithContext(ctx context.Context, poolName, objectName string) error {
+	_, err := cluster.Client.DeleteObjectWithContext(ctx, &awss3.DeleteObjectInput{
+		Bucket: aws.String(cluster.Bucket),
+		Key:    aws.String(objectKey(poolName, objectName)),
+	})
+	return err
+}
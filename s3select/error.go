@@ -0,0 +1,37 @@
+package s3select
+
+import (
+	"fmt"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+// Error is an ApiError with a message describing the cause in detail,
+// e.g. the position of an unexpected token in SQL expression
+type Error struct {
+	code    ApiErrorCode
+	message string
+}
+
+func errorf(code ApiErrorCode, format string, args ...interface{}) *Error {
+	return &Error{
+		code:    code,
+		message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+func (e *Error) AwsErrorCode() string {
+	return e.code.AwsErrorCode()
+}
+
+func (e *Error) Description() string {
+	return e.code.Description() + " " + e.message
+}
+
+func (e *Error) HttpStatusCode() int {
+	return e.code.HttpStatusCode()
+}
@@ -0,0 +1,760 @@
+package s3select
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+// Values in evaluation are nil(NULL or MISSING), bool, int64, float64,
+// string, time.Time, *jsonObject or []interface{}. Values of CSV are all
+// strings, they are converted to numbers implicitly when compared with or
+// computed together with numbers.
+
+const (
+	typeBool      = "BOOL"
+	typeInt       = "INT"
+	typeFloat     = "FLOAT"
+	typeString    = "STRING"
+	typeTimestamp = "TIMESTAMP"
+)
+
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+type expr interface {
+	// eval returns value of the expression for `r`,
+	// `r` is nil when evaluating results of aggregate functions
+	eval(r record) (interface{}, error)
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (e *literal) eval(r record) (interface{}, error) {
+	return e.value, nil
+}
+
+type pathSegment struct {
+	name    string
+	index   int
+	isIndex bool
+	quoted  bool // quoted names are case sensitive
+}
+
+type columnRef struct {
+	path     []pathSegment
+	position int // 1-based position for _N, 0 if referenced by path
+}
+
+// resolve strips the table alias from path of the column,
+// and finds out positional references
+func (c *columnRef) resolve(alias string) {
+	first := c.path[0]
+	if len(c.path) > 1 && !first.isIndex && !first.quoted &&
+		(strings.EqualFold(first.name, alias) || strings.EqualFold(first.name, "S3Object")) {
+		c.path = c.path[1:]
+	}
+	first = c.path[0]
+	if len(c.path) == 1 && !first.quoted && strings.HasPrefix(first.name, "_") {
+		position, err := strconv.Atoi(first.name[1:])
+		if err == nil && position > 0 {
+			c.position = position
+		}
+	}
+}
+
+// name returns the name of column in output
+func (c *columnRef) name() string {
+	for i := len(c.path) - 1; i >= 0; i-- {
+		if !c.path[i].isIndex {
+			return c.path[i].name
+		}
+	}
+	return "_1"
+}
+
+func (c *columnRef) eval(r record) (interface{}, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return r.get(c), nil
+}
+
+type unaryExpr struct {
+	op string // NOT or -
+	x  expr
+}
+
+func (e *unaryExpr) eval(r record) (interface{}, error) {
+	v, err := e.x.eval(r)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	if e.op == "NOT" {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, typeError("NOT", v)
+		}
+		return !b, nil
+	}
+	n, ok := toNumber(v)
+	if !ok {
+		return nil, typeError("-", v)
+	}
+	if i, ok := n.(int64); ok {
+		return -i, nil
+	}
+	return -n.(float64), nil
+}
+
+type binaryExpr struct {
+	op    string
+	left  expr
+	right expr
+}
+
+func (e *binaryExpr) eval(r record) (interface{}, error) {
+	left, err := e.left.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "AND" || e.op == "OR" {
+		return e.evalLogical(r, left)
+	}
+	right, err := e.right.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	switch e.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		c, err := compareValues(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch e.op {
+		case "=":
+			return c == 0, nil
+		case "!=":
+			return c != 0, nil
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	case "||":
+		return formatValue(left) + formatValue(right), nil
+	}
+	return arithmetic(e.op, left, right)
+}
+
+// evalLogical follows three-valued logic of SQL
+func (e *binaryExpr) evalLogical(r record, left interface{}) (interface{}, error) {
+	l, ok := left.(bool)
+	if left != nil && !ok {
+		return nil, typeError(e.op, left)
+	}
+	if left != nil && l == (e.op == "OR") {
+		return l, nil
+	}
+	right, err := e.right.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	rb, ok := right.(bool)
+	if right != nil && !ok {
+		return nil, typeError(e.op, right)
+	}
+	if right != nil && rb == (e.op == "OR") {
+		return rb, nil
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	return rb, nil
+}
+
+type isNullExpr struct {
+	x   expr
+	not bool
+}
+
+func (e *isNullExpr) eval(r record) (interface{}, error) {
+	v, err := e.x.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != e.not, nil
+}
+
+type betweenExpr struct {
+	x    expr
+	low  expr
+	high expr
+	not  bool
+}
+
+func (e *betweenExpr) eval(r record) (interface{}, error) {
+	values := make([]interface{}, 3)
+	for i, x := range []expr{e.x, e.low, e.high} {
+		v, err := x.eval(r)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	low, err := compareValues(values[0], values[1])
+	if err != nil {
+		return nil, err
+	}
+	high, err := compareValues(values[0], values[2])
+	if err != nil {
+		return nil, err
+	}
+	return (low >= 0 && high <= 0) != e.not, nil
+}
+
+type inExpr struct {
+	x    expr
+	list []expr
+	not  bool
+}
+
+func (e *inExpr) eval(r record) (interface{}, error) {
+	v, err := e.x.eval(r)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	hasNull := false
+	for _, x := range e.list {
+		item, err := x.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			hasNull = true
+			continue
+		}
+		c, err := compareValues(v, item)
+		if err != nil {
+			return nil, err
+		}
+		if c == 0 {
+			return !e.not, nil
+		}
+	}
+	if hasNull {
+		return nil, nil
+	}
+	return e.not, nil
+}
+
+type likeExpr struct {
+	x       expr
+	pattern expr
+	escape  expr // nil if not specified
+	not     bool
+	re      *regexp.Regexp // compiled if pattern and escape are literals
+}
+
+func (e *likeExpr) eval(r record) (interface{}, error) {
+	v, err := e.x.eval(r)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, typeError("LIKE", v)
+	}
+	re := e.re
+	if re == nil {
+		pattern, err := e.pattern.eval(r)
+		if err != nil || pattern == nil {
+			return nil, err
+		}
+		var escape interface{}
+		if e.escape != nil {
+			escape, err = e.escape.eval(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+		re, err = compileLike(pattern, escape)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return re.MatchString(s) != e.not, nil
+}
+
+// compileLike converts LIKE pattern to regular expression,
+// "%" matches any string and "_" matches any single character
+func compileLike(pattern, escape interface{}) (*regexp.Regexp, error) {
+	p, ok := pattern.(string)
+	if !ok {
+		return nil, typeError("LIKE", pattern)
+	}
+	var escapeRune rune
+	if escape != nil {
+		s, ok := escape.(string)
+		if !ok || utf8.RuneCountInString(s) != 1 {
+			return nil, errorf(ErrEvaluatorInvalidArguments,
+				"ESCAPE of LIKE should be a single character.")
+		}
+		escapeRune, _ = utf8.DecodeRuneInString(s)
+	}
+	var re strings.Builder
+	re.WriteString("^(?s)")
+	escaped := false
+	for _, c := range p {
+		switch {
+		case escaped:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+			escaped = false
+		case escape != nil && c == escapeRune:
+			escaped = true
+		case c == '%':
+			re.WriteString(".*")
+		case c == '_':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if escaped {
+		return nil, errorf(ErrEvaluatorInvalidArguments,
+			"Pattern of LIKE should not end with ESCAPE character.")
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
+
+type castExpr struct {
+	x   expr
+	typ string
+}
+
+func (e *castExpr) eval(r record) (interface{}, error) {
+	v, err := e.x.eval(r)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	result, ok := castValue(v, e.typ)
+	if !ok {
+		return nil, errorf(ErrCastFailed, "Could not cast %s to %s.",
+			strconv.Quote(formatValue(v)), e.typ)
+	}
+	return result, nil
+}
+
+type functionExpr struct {
+	name string
+	args []expr
+}
+
+func (e *functionExpr) eval(r record) (interface{}, error) {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		v, err := arg.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	switch e.name {
+	case "COALESCE":
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	case "NULLIF":
+		if args[0] == nil || args[1] == nil {
+			return args[0], nil
+		}
+		c, err := compareValues(args[0], args[1])
+		if err != nil || c == 0 {
+			return nil, err
+		}
+		return args[0], nil
+	}
+
+	if args[0] == nil {
+		return nil, nil
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return nil, typeError(e.name, args[0])
+	}
+	switch e.name {
+	case "LOWER":
+		return strings.ToLower(s), nil
+	case "UPPER":
+		return strings.ToUpper(s), nil
+	case "CHAR_LENGTH", "CHARACTER_LENGTH":
+		return int64(utf8.RuneCountInString(s)), nil
+	case "TRIM":
+		return strings.Trim(s, " "), nil
+	}
+
+	// SUBSTRING(string, start[, length]), start is 1-based
+	bounds := make([]int64, len(args)-1)
+	for i, arg := range args[1:] {
+		if arg == nil {
+			return nil, nil
+		}
+		n, ok := castValue(arg, typeInt)
+		if !ok {
+			return nil, typeError(e.name, arg)
+		}
+		bounds[i] = n.(int64)
+	}
+	runes := []rune(s)
+	start := bounds[0] - 1
+	end := int64(len(runes))
+	if len(bounds) > 1 {
+		if bounds[1] < 0 {
+			return nil, errorf(ErrEvaluatorInvalidArguments,
+				"Length of SUBSTRING should not be negative.")
+		}
+		end = start + bounds[1]
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > int64(len(runes)) {
+		end = int64(len(runes))
+	}
+	if end <= start {
+		return "", nil
+	}
+	return string(runes[start:end]), nil
+}
+
+type aggregateExpr struct {
+	name string
+	arg  expr // nil for COUNT(*)
+
+	count  int64
+	sum    interface{} // int64 or float64
+	result interface{} // for MIN and MAX
+}
+
+// update accumulates the aggregate with record `r`
+func (e *aggregateExpr) update(r record) error {
+	if e.arg == nil {
+		e.count++
+		return nil
+	}
+	v, err := e.arg.eval(r)
+	if err != nil || v == nil {
+		return err
+	}
+	e.count++
+	switch e.name {
+	case "SUM", "AVG":
+		n, ok := toNumber(v)
+		if !ok {
+			return typeError(e.name, v)
+		}
+		if e.sum == nil {
+			e.sum = n
+			return nil
+		}
+		e.sum, err = arithmetic("+", e.sum, n)
+		return err
+	case "MIN", "MAX":
+		if e.result == nil {
+			e.result = v
+			return nil
+		}
+		c, err := compareValues(v, e.result)
+		if err != nil {
+			return err
+		}
+		if (e.name == "MIN" && c < 0) || (e.name == "MAX" && c > 0) {
+			e.result = v
+		}
+	}
+	return nil
+}
+
+func (e *aggregateExpr) eval(r record) (interface{}, error) {
+	switch e.name {
+	case "COUNT":
+		return e.count, nil
+	case "SUM":
+		return e.sum, nil
+	case "AVG":
+		if e.count == 0 {
+			return nil, nil
+		}
+		return toFloat(e.sum) / float64(e.count), nil
+	}
+	return e.result, nil
+}
+
+func typeError(operation string, v interface{}) error {
+	return errorf(ErrInvalidDataType, "Invalid %s value %s for %s.",
+		typeName(v), strconv.Quote(formatValue(v)), operation)
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		return typeBool
+	case int64:
+		return typeInt
+	case float64:
+		return typeFloat
+	case string:
+		return typeString
+	case time.Time:
+		return typeTimestamp
+	case *jsonObject:
+		return "STRUCT"
+	case []interface{}:
+		return "LIST"
+	}
+	return "UNKNOWN"
+}
+
+// toNumber returns int64 or float64 value of `v`,
+// strings are parsed as numbers
+func toNumber(v interface{}) (interface{}, bool) {
+	switch n := v.(type) {
+	case int64, float64:
+		return n, true
+	case string:
+		s := strings.TrimSpace(n)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func toFloat(n interface{}) float64 {
+	if i, ok := n.(int64); ok {
+		return float64(i)
+	}
+	return n.(float64)
+}
+
+func parseTimestamp(s string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// compareValues returns -1, 0 or 1 if `a` is less than, equal to or
+// greater than `b`, neither should be nil
+func compareValues(a, b interface{}) (int, error) {
+	switch x := a.(type) {
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case y:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case string:
+		switch y := b.(type) {
+		case string:
+			return strings.Compare(x, y), nil
+		case time.Time:
+			if t, ok := parseTimestamp(x); ok {
+				return compareValues(t, y)
+			}
+		case int64, float64:
+			if n, ok := toNumber(x); ok {
+				return compareNumbers(n, y), nil
+			}
+		}
+	case int64, float64:
+		if n, ok := toNumber(b); ok {
+			return compareNumbers(x, n), nil
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Time:
+			switch {
+			case x.Before(y):
+				return -1, nil
+			case x.After(y):
+				return 1, nil
+			}
+			return 0, nil
+		case string:
+			if t, ok := parseTimestamp(y); ok {
+				return compareValues(x, t)
+			}
+		}
+	}
+	return 0, errorf(ErrInvalidDataType, "Could not compare %s %s with %s %s.",
+		typeName(a), strconv.Quote(formatValue(a)),
+		typeName(b), strconv.Quote(formatValue(b)))
+}
+
+func compareNumbers(a, b interface{}) int {
+	x, xIsInt := a.(int64)
+	y, yIsInt := b.(int64)
+	if xIsInt && yIsInt {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	fx, fy := toFloat(a), toFloat(b)
+	switch {
+	case fx < fy:
+		return -1
+	case fx > fy:
+		return 1
+	}
+	return 0
+}
+
+func arithmetic(op string, a, b interface{}) (interface{}, error) {
+	x, ok := toNumber(a)
+	if !ok {
+		return nil, typeError(op, a)
+	}
+	y, ok := toNumber(b)
+	if !ok {
+		return nil, typeError(op, b)
+	}
+	i, xIsInt := x.(int64)
+	j, yIsInt := y.(int64)
+	if xIsInt && yIsInt {
+		switch op {
+		case "+":
+			return i + j, nil
+		case "-":
+			return i - j, nil
+		case "*":
+			return i * j, nil
+		}
+		if j == 0 {
+			return nil, ErrDivisionByZero
+		}
+		if op == "/" {
+			return i / j, nil
+		}
+		return i % j, nil
+	}
+	f, g := toFloat(x), toFloat(y)
+	switch op {
+	case "+":
+		return f + g, nil
+	case "-":
+		return f - g, nil
+	case "*":
+		return f * g, nil
+	}
+	if g == 0 {
+		return nil, ErrDivisionByZero
+	}
+	if op == "/" {
+		return f / g, nil
+	}
+	return math.Mod(f, g), nil
+}
+
+// castValue converts non-nil `v` to type `typ`
+func castValue(v interface{}, typ string) (interface{}, bool) {
+	switch typ {
+	case typeString:
+		return formatValue(v), true
+	case typeBool:
+		switch x := v.(type) {
+		case bool:
+			return x, true
+		case int64:
+			return x != 0, true
+		case float64:
+			return x != 0, true
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(x))
+			return b, err == nil
+		}
+	case typeInt:
+		if b, ok := v.(bool); ok {
+			if b {
+				return int64(1), true
+			}
+			return int64(0), true
+		}
+		if _, ok := v.(time.Time); ok {
+			return nil, false
+		}
+		n, ok := toNumber(v)
+		if !ok {
+			return nil, false
+		}
+		if f, ok := n.(float64); ok {
+			if math.IsNaN(f) || math.Abs(f) >= math.MaxInt64 {
+				return nil, false
+			}
+			return int64(f), true
+		}
+		return n, true
+	case typeFloat:
+		if b, ok := v.(bool); ok {
+			if b {
+				return float64(1), true
+			}
+			return float64(0), true
+		}
+		if _, ok := v.(time.Time); ok {
+			return nil, false
+		}
+		n, ok := toNumber(v)
+		if !ok {
+			return nil, false
+		}
+		return toFloat(n), true
+	case typeTimestamp:
+		switch x := v.(type) {
+		case time.Time:
+			return x, true
+		case string:
+			return parseTimestamp(strings.TrimSpace(x))
+		}
+	}
+	return nil, false
+}
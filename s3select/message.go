@@ -0,0 +1,90 @@
+package s3select
+
+import (
+	"encoding/binary"
+	"encoding/xml"
+	"hash/crc32"
+)
+
+// Messages of response are encoded in AWS event stream format, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/RESTSelectObjectAppendix.html
+//
+//	total length(4) | headers length(4) | prelude CRC(4) |
+//	headers | payload | message CRC(4)
+//
+// each header is
+//
+//	name length(1) | name | value type(1), 7 for string | value length(2) | value
+
+const headerTypeString = 7
+
+type messageHeader struct {
+	name  string
+	value string
+}
+
+// stats is the payload of Stats and Progress messages
+type stats struct {
+	XMLName        xml.Name
+	BytesScanned   int64
+	BytesProcessed int64
+	BytesReturned  int64
+}
+
+func encodeMessage(headers []messageHeader, payload []byte) []byte {
+	headersLength := 0
+	for _, h := range headers {
+		headersLength += 1 + len(h.name) + 1 + 2 + len(h.value)
+	}
+	totalLength := 12 + headersLength + len(payload) + 4
+
+	message := make([]byte, 12, totalLength)
+	binary.BigEndian.PutUint32(message[0:], uint32(totalLength))
+	binary.BigEndian.PutUint32(message[4:], uint32(headersLength))
+	binary.BigEndian.PutUint32(message[8:], crc32.ChecksumIEEE(message[:8]))
+	for _, h := range headers {
+		message = append(message, byte(len(h.name)))
+		message = append(message, h.name...)
+		message = append(message, headerTypeString, 0, 0)
+		binary.BigEndian.PutUint16(message[len(message)-2:], uint16(len(h.value)))
+		message = append(message, h.value...)
+	}
+	message = append(message, payload...)
+	message = append(message, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(message[len(message)-4:],
+		crc32.ChecksumIEEE(message[:len(message)-4]))
+	return message
+}
+
+func recordsMessage(payload []byte) []byte {
+	return encodeMessage([]messageHeader{
+		{":event-type", "Records"},
+		{":content-type", "application/octet-stream"},
+		{":message-type", "event"},
+	}, payload)
+}
+
+// statsMessage encodes Stats or Progress message by XMLName of `s`
+func statsMessage(s stats) []byte {
+	payload, _ := xml.Marshal(s)
+	return encodeMessage([]messageHeader{
+		{":event-type", s.XMLName.Local},
+		{":content-type", "text/xml"},
+		{":message-type", "event"},
+	}, append([]byte(xml.Header), payload...))
+}
+
+func endMessage() []byte {
+	return encodeMessage([]messageHeader{
+		{":event-type", "End"},
+		{":message-type", "event"},
+	}, nil)
+}
+
+func errorMessage(code, message string) []byte {
+	return encodeMessage([]messageHeader{
+		{":error-code", code},
+		{":error-message", message},
+		{":message-type", "error"},
+	}, nil)
+}
@@ -0,0 +1,538 @@
+package s3select
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+// max size of a CSV record
+const maxRecordSize = 1 << 20
+
+type record interface {
+	// get returns value of column `c`, nil if not exist
+	get(c *columnRef) interface{}
+	// columns returns names and values of all columns, for "SELECT *"
+	columns() ([]string, []interface{})
+}
+
+type recordReader interface {
+	// next returns io.EOF if there is no more record
+	next() (record, error)
+}
+
+type recordWriter interface {
+	write(buffer *bytes.Buffer, names []string, values []interface{}) error
+}
+
+// formatValue returns string of `v` in CSV output
+func formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(x)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case string:
+		return x
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+type csvHeader struct {
+	names  []string
+	exact  map[string]int
+	folded map[string]int
+}
+
+func newCSVHeader(names []string) *csvHeader {
+	header := &csvHeader{
+		names:  names,
+		exact:  make(map[string]int),
+		folded: make(map[string]int),
+	}
+	// the first one wins if names are duplicated
+	for i := len(names) - 1; i >= 0; i-- {
+		header.exact[names[i]] = i
+		header.folded[strings.ToLower(names[i])] = i
+	}
+	return header
+}
+
+type csvRecord struct {
+	fields []string
+	header *csvHeader // nil if FileHeaderInfo is not USE
+}
+
+func (r *csvRecord) get(c *columnRef) interface{} {
+	i := c.position - 1
+	if c.position == 0 {
+		if r.header == nil || len(c.path) != 1 {
+			return nil
+		}
+		var ok bool
+		if c.path[0].quoted {
+			i, ok = r.header.exact[c.path[0].name]
+		} else {
+			i, ok = r.header.folded[strings.ToLower(c.path[0].name)]
+		}
+		if !ok {
+			return nil
+		}
+	}
+	if i >= len(r.fields) {
+		return nil
+	}
+	return r.fields[i]
+}
+
+func (r *csvRecord) columns() ([]string, []interface{}) {
+	names := make([]string, len(r.fields))
+	values := make([]interface{}, len(r.fields))
+	for i, field := range r.fields {
+		if r.header != nil && i < len(r.header.names) {
+			names[i] = r.header.names[i]
+		} else {
+			names[i] = "_" + strconv.Itoa(i+1)
+		}
+		values[i] = field
+	}
+	return names, values
+}
+
+type csvReader struct {
+	reader          *bufio.Reader
+	fileHeaderInfo  string
+	recordDelimiter []rune
+	fieldDelimiter  rune
+	quote           rune
+	escape          rune
+	comment         rune // 0 if no comments
+	header          *csvHeader
+	started         bool
+}
+
+func newCSVReader(reader io.Reader, options *CSVInput) *csvReader {
+	r := &csvReader{
+		reader:          bufio.NewReader(reader),
+		fileHeaderInfo:  options.FileHeaderInfo,
+		recordDelimiter: []rune(options.RecordDelimiter),
+	}
+	r.fieldDelimiter, _ = utf8.DecodeRuneInString(options.FieldDelimiter)
+	r.quote, _ = utf8.DecodeRuneInString(options.QuoteCharacter)
+	r.escape, _ = utf8.DecodeRuneInString(options.QuoteEscapeCharacter)
+	if options.Comments != "" {
+		r.comment, _ = utf8.DecodeRuneInString(options.Comments)
+	}
+	return r
+}
+
+func (r *csvReader) next() (record, error) {
+	if !r.started {
+		r.started = true
+		if r.fileHeaderInfo != FileHeaderNone {
+			names, err := r.readRecord()
+			if err != nil {
+				return nil, err
+			}
+			if r.fileHeaderInfo == FileHeaderUse {
+				r.header = newCSVHeader(names)
+			}
+		}
+	}
+	fields, err := r.readRecord()
+	if err != nil {
+		return nil, err
+	}
+	return &csvRecord{fields: fields, header: r.header}, nil
+}
+
+// readRecord skips empty lines and comments
+func (r *csvReader) readRecord() ([]string, error) {
+	for {
+		fields, err := r.readLine()
+		if err != nil || fields != nil {
+			return fields, err
+		}
+	}
+}
+
+// readLine returns nil fields for empty lines and comments
+func (r *csvReader) readLine() ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	quoted := false
+	hasQuote := false
+	count, size := 0, 0
+	for {
+		c, n, err := r.reader.ReadRune()
+		if err == io.EOF {
+			if count == 0 {
+				return nil, io.EOF
+			}
+			if quoted {
+				return nil, errorf(ErrCSVParsing, "Unterminated quoted field.")
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		count++
+		size += n
+		if size > maxRecordSize {
+			return nil, ErrOverMaxRecordSize
+		}
+
+		if quoted {
+			switch {
+			case c == r.escape && r.escape != r.quote:
+				c, _, err = r.reader.ReadRune()
+				if err != nil {
+					return nil, errorf(ErrCSVParsing, "Unterminated quoted field.")
+				}
+				field.WriteRune(c)
+			case c == r.quote:
+				next, _, err := r.reader.ReadRune()
+				if err == nil && next == r.quote && r.escape == r.quote {
+					field.WriteRune(c)
+					continue
+				}
+				if err == nil {
+					r.reader.UnreadRune()
+				}
+				quoted = false
+			default:
+				field.WriteRune(c)
+			}
+			continue
+		}
+
+		switch {
+		case count == 1 && r.comment != 0 && c == r.comment:
+			for {
+				c, _, err = r.reader.ReadRune()
+				if err == io.EOF || (err == nil && r.isRecordDelimiter(c)) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+		case c == r.quote && field.Len() == 0:
+			quoted = true
+			hasQuote = true
+		case c == r.fieldDelimiter:
+			fields = append(fields, field.String())
+			field.Reset()
+		case r.isRecordDelimiter(c):
+			if len(fields) == 0 && field.Len() == 0 && !hasQuote {
+				return nil, nil
+			}
+			fields = append(fields, r.trimCR(field.String()))
+			return fields, nil
+		default:
+			field.WriteRune(c)
+		}
+	}
+	if len(fields) == 0 && field.Len() == 0 && !hasQuote {
+		return nil, nil
+	}
+	return append(fields, r.trimCR(field.String())), nil
+}
+
+// isRecordDelimiter consumes the rest of record delimiter if `c` starts it
+func (r *csvReader) isRecordDelimiter(c rune) bool {
+	if c != r.recordDelimiter[0] {
+		return false
+	}
+	if len(r.recordDelimiter) == 1 {
+		return true
+	}
+	next, _, err := r.reader.ReadRune()
+	if err != nil {
+		return false
+	}
+	if next == r.recordDelimiter[1] {
+		return true
+	}
+	r.reader.UnreadRune()
+	return false
+}
+
+// trimCR makes default record delimiter "\n" work with "\r\n"
+func (r *csvReader) trimCR(field string) string {
+	if len(r.recordDelimiter) == 1 && r.recordDelimiter[0] == '\n' {
+		return strings.TrimSuffix(field, "\r")
+	}
+	return field
+}
+
+type csvWriter struct {
+	options *CSVOutput
+}
+
+func (w *csvWriter) write(buffer *bytes.Buffer, names []string, values []interface{}) error {
+	options := w.options
+	for i, v := range values {
+		if i > 0 {
+			buffer.WriteString(options.FieldDelimiter)
+		}
+		s := formatValue(v)
+		if options.QuoteFields == QuoteFieldsAlways ||
+			strings.Contains(s, options.FieldDelimiter) ||
+			strings.Contains(s, options.QuoteCharacter) ||
+			strings.Contains(s, options.RecordDelimiter) ||
+			strings.ContainsAny(s, "\r\n") {
+			s = options.QuoteCharacter + strings.Replace(s, options.QuoteCharacter,
+				options.QuoteEscapeCharacter+options.QuoteCharacter, -1) +
+				options.QuoteCharacter
+		}
+		buffer.WriteString(s)
+	}
+	buffer.WriteString(options.RecordDelimiter)
+	return nil
+}
+
+// jsonObject keeps the order of keys in JSON input
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// get returns value of `key`, keys are matched case insensitively
+// unless `caseSensitive`
+func (o *jsonObject) get(key string, caseSensitive bool) (interface{}, bool) {
+	if v, ok := o.values[key]; ok {
+		return v, true
+	}
+	if caseSensitive {
+		return nil, false
+	}
+	for _, k := range o.keys {
+		if strings.EqualFold(k, key) {
+			return o.values[k], true
+		}
+	}
+	return nil, false
+}
+
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	return marshalJSONObject(o.keys, func(i int) interface{} {
+		return o.values[o.keys[i]]
+	})
+}
+
+func marshalJSONObject(keys []string, value func(i int) interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		data, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(data)
+		buffer.WriteByte(':')
+		v := value(i)
+		if t, ok := v.(time.Time); ok {
+			v = t.Format(time.RFC3339Nano)
+		}
+		data, err = json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(data)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// navigate returns the value at `path` of `v`
+func navigate(v interface{}, path []pathSegment) (interface{}, bool) {
+	for _, segment := range path {
+		if segment.isIndex {
+			array, ok := v.([]interface{})
+			if !ok || segment.index < 0 || segment.index >= len(array) {
+				return nil, false
+			}
+			v = array[segment.index]
+			continue
+		}
+		object, ok := v.(*jsonObject)
+		if !ok {
+			return nil, false
+		}
+		v, ok = object.get(segment.name, segment.quoted)
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+type jsonRecord struct {
+	value interface{}
+}
+
+func (r *jsonRecord) get(c *columnRef) interface{} {
+	if c.position > 0 {
+		if _, ok := r.value.(*jsonObject); !ok && c.position == 1 {
+			return r.value
+		}
+	}
+	v, _ := navigate(r.value, c.path)
+	return v
+}
+
+func (r *jsonRecord) columns() ([]string, []interface{}) {
+	object, ok := r.value.(*jsonObject)
+	if !ok {
+		return []string{"_1"}, []interface{}{r.value}
+	}
+	values := make([]interface{}, len(object.keys))
+	for i, key := range object.keys {
+		values[i] = object.values[key]
+	}
+	return object.keys, values
+}
+
+type jsonReader struct {
+	decoder  *json.Decoder
+	path     []pathSegment
+	wildcard bool
+	pending  []interface{}
+}
+
+func newJSONReader(reader io.Reader, path []pathSegment, wildcard bool) *jsonReader {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+	return &jsonReader{
+		decoder:  decoder,
+		path:     path,
+		wildcard: wildcard,
+	}
+}
+
+func (r *jsonReader) next() (record, error) {
+	for len(r.pending) == 0 {
+		v, err := parseJSONValue(r.decoder)
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			if _, ok := err.(*json.SyntaxError); ok || err == io.ErrUnexpectedEOF {
+				return nil, errorf(ErrJSONParsing, "%s.", err)
+			}
+			return nil, err
+		}
+		v, ok := navigate(v, r.path)
+		if !ok {
+			continue
+		}
+		if array, ok := v.([]interface{}); ok && r.wildcard {
+			r.pending = array
+			continue
+		}
+		return &jsonRecord{value: v}, nil
+	}
+	v := r.pending[0]
+	r.pending = r.pending[1:]
+	return &jsonRecord{value: v}, nil
+}
+
+// parseJSONValue parses next JSON value of `decoder` with objects as
+// *jsonObject, and numbers as int64 or float64
+func parseJSONValue(decoder *json.Decoder) (interface{}, error) {
+	t, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch x := t.(type) {
+	case json.Delim:
+		switch x {
+		case '{':
+			object := &jsonObject{values: make(map[string]interface{})}
+			for decoder.More() {
+				t, err := decoder.Token()
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				key := t.(string)
+				v, err := parseJSONValue(decoder)
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				if _, ok := object.values[key]; !ok {
+					object.keys = append(object.keys, key)
+				}
+				object.values[key] = v
+			}
+			_, err = decoder.Token()
+			return object, unexpectedEOF(err)
+		case '[':
+			array := make([]interface{}, 0)
+			for decoder.More() {
+				v, err := parseJSONValue(decoder)
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				array = append(array, v)
+			}
+			_, err = decoder.Token()
+			return array, unexpectedEOF(err)
+		}
+		return nil, errorf(ErrJSONParsing, "Unexpected %s.", x)
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := x.Float64()
+		if err != nil {
+			return nil, errorf(ErrJSONParsing, "Invalid number %s.", x)
+		}
+		return f, nil
+	}
+	return t, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type jsonWriter struct {
+	options *JSONOutput
+}
+
+func (w *jsonWriter) write(buffer *bytes.Buffer, names []string, values []interface{}) error {
+	data, err := marshalJSONObject(names, func(i int) interface{} {
+		return values[i]
+	})
+	if err != nil {
+		return err
+	}
+	buffer.Write(data)
+	buffer.WriteString(w.options.RecordDelimiter)
+	return nil
+}
@@ -0,0 +1,189 @@
+package s3select
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+// MaxRequestSize is the max size of a SelectObjectContent request body
+const MaxRequestSize = 256 << 10
+
+const (
+	CompressionNone = "NONE"
+	CompressionGzip = "GZIP"
+
+	FileHeaderNone   = "NONE"
+	FileHeaderUse    = "USE"
+	FileHeaderIgnore = "IGNORE"
+
+	JSONDocument = "DOCUMENT"
+	JSONLines    = "LINES"
+
+	QuoteFieldsAlways   = "ALWAYS"
+	QuoteFieldsAsNeeded = "ASNEEDED"
+)
+
+// Request is the body of SelectObjectContent, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+type Request struct {
+	XMLName             xml.Name `xml:"SelectObjectContentRequest"`
+	Expression          string
+	ExpressionType      string
+	InputSerialization  InputSerialization
+	OutputSerialization OutputSerialization
+	RequestProgress     struct {
+		Enabled bool
+	}
+}
+
+type InputSerialization struct {
+	CompressionType string
+	CSV             *CSVInput
+	JSON            *JSONInput
+	Parquet         *struct{}
+}
+
+type CSVInput struct {
+	FileHeaderInfo             string
+	Comments                   string
+	QuoteEscapeCharacter       string
+	RecordDelimiter            string
+	FieldDelimiter             string
+	QuoteCharacter             string
+	AllowQuotedRecordDelimiter bool
+}
+
+type JSONInput struct {
+	Type string
+}
+
+type OutputSerialization struct {
+	CSV  *CSVOutput
+	JSON *JSONOutput
+}
+
+type CSVOutput struct {
+	QuoteFields          string
+	QuoteEscapeCharacter string
+	RecordDelimiter      string
+	FieldDelimiter       string
+	QuoteCharacter       string
+}
+
+type JSONOutput struct {
+	RecordDelimiter string
+}
+
+// ParseRequest reads a SelectObjectContent request from `reader`,
+// validates it and fills in defaults of optional parameters
+func ParseRequest(reader io.Reader) (*Request, error) {
+	var request Request
+	err := xml.NewDecoder(io.LimitReader(reader, MaxRequestSize)).Decode(&request)
+	if err != nil {
+		return nil, ErrMalformedXML
+	}
+	if request.Expression == "" || request.ExpressionType == "" {
+		return nil, ErrMissingRequiredParameter
+	}
+	if strings.ToUpper(request.ExpressionType) != "SQL" {
+		return nil, ErrInvalidExpressionType
+	}
+	err = request.InputSerialization.validate()
+	if err != nil {
+		return nil, err
+	}
+	err = request.OutputSerialization.validate()
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (input *InputSerialization) validate() error {
+	input.CompressionType = strings.ToUpper(input.CompressionType)
+	switch input.CompressionType {
+	case "":
+		input.CompressionType = CompressionNone
+	case CompressionNone, CompressionGzip:
+	default:
+		return ErrInvalidCompressionFormat
+	}
+	if input.Parquet != nil {
+		return ErrNotImplemented
+	}
+	if (input.CSV == nil) == (input.JSON == nil) {
+		return ErrUnsupportedSerialization
+	}
+	if input.JSON != nil {
+		input.JSON.Type = strings.ToUpper(input.JSON.Type)
+		if input.JSON.Type != JSONDocument && input.JSON.Type != JSONLines {
+			return ErrInvalidRequestParameter
+		}
+		return nil
+	}
+
+	csv := input.CSV
+	csv.FileHeaderInfo = strings.ToUpper(csv.FileHeaderInfo)
+	switch csv.FileHeaderInfo {
+	case "":
+		csv.FileHeaderInfo = FileHeaderNone
+	case FileHeaderNone, FileHeaderUse, FileHeaderIgnore:
+	default:
+		return ErrInvalidRequestParameter
+	}
+	setDefault(&csv.RecordDelimiter, "\n")
+	setDefault(&csv.FieldDelimiter, ",")
+	setDefault(&csv.QuoteCharacter, "\"")
+	setDefault(&csv.QuoteEscapeCharacter, csv.QuoteCharacter)
+	if !isCharacter(csv.FieldDelimiter) || !isCharacter(csv.QuoteCharacter) ||
+		!isCharacter(csv.QuoteEscapeCharacter) ||
+		(csv.Comments != "" && !isCharacter(csv.Comments)) ||
+		utf8.RuneCountInString(csv.RecordDelimiter) > 2 {
+		return ErrInvalidRequestParameter
+	}
+	return nil
+}
+
+func (output *OutputSerialization) validate() error {
+	if (output.CSV == nil) == (output.JSON == nil) {
+		return ErrUnsupportedSerialization
+	}
+	if output.JSON != nil {
+		setDefault(&output.JSON.RecordDelimiter, "\n")
+		return nil
+	}
+
+	csv := output.CSV
+	csv.QuoteFields = strings.ToUpper(csv.QuoteFields)
+	switch csv.QuoteFields {
+	case "":
+		csv.QuoteFields = QuoteFieldsAsNeeded
+	case QuoteFieldsAlways, QuoteFieldsAsNeeded:
+	default:
+		return ErrInvalidRequestParameter
+	}
+	setDefault(&csv.RecordDelimiter, "\n")
+	setDefault(&csv.FieldDelimiter, ",")
+	setDefault(&csv.QuoteCharacter, "\"")
+	setDefault(&csv.QuoteEscapeCharacter, csv.QuoteCharacter)
+	if !isCharacter(csv.FieldDelimiter) || !isCharacter(csv.QuoteCharacter) ||
+		!isCharacter(csv.QuoteEscapeCharacter) ||
+		utf8.RuneCountInString(csv.RecordDelimiter) > 2 {
+		return ErrInvalidRequestParameter
+	}
+	return nil
+}
+
+func setDefault(s *string, value string) {
+	if *s == "" {
+		*s = value
+	}
+}
+
+func isCharacter(s string) bool {
+	return utf8.RuneCountInString(s) == 1
+}
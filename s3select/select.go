@@ -0,0 +1,239 @@
+package s3select
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+// size of records buffered before sending a Records message
+const recordsMessageSize = 64 << 10
+
+type projection struct {
+	expr expr
+	name string // name of column in output, alias or derived from expr
+}
+
+// Query is a parsed SelectObjectContent request
+type Query struct {
+	request *Request
+
+	star        bool // SELECT *
+	projections []projection
+	alias       string
+	wildcard    bool // FROM S3Object[*]
+	path        []pathSegment
+	where       expr  // nil if no WHERE
+	limit       int64 // -1 if no LIMIT
+	aggregates  []*aggregateExpr
+}
+
+// NewQuery parses the SQL expression of `request`
+func NewQuery(request *Request) (*Query, error) {
+	query, err := parseSQL(request.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(query.path) > 0 && request.InputSerialization.CSV != nil {
+		return nil, errorf(ErrUnsupportedSqlOperation,
+			"Path in FROM clause is only supported for JSON.")
+	}
+	query.request = request
+	return query, nil
+}
+
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// gzipReader reports corrupted data as ErrInvalidCompressionFormat
+type gzipReader struct {
+	reader *gzip.Reader
+}
+
+func (r *gzipReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if _, ok := err.(flate.CorruptInputError); ok ||
+		err == gzip.ErrHeader || err == gzip.ErrChecksum {
+		err = ErrInvalidCompressionFormat
+	}
+	return n, err
+}
+
+type execution struct {
+	query     *Query
+	w         io.Writer
+	scanned   *countingReader
+	processed *countingReader
+	returned  int64
+	buffer    bytes.Buffer
+	writer    recordWriter
+}
+
+// Execute runs the query on object data read from `input`, and writes
+// the results to `w` as event stream messages. Errors occurred after the
+// response header is sent are also reported to client in an error message.
+func (q *Query) Execute(input io.Reader, w io.Writer) error {
+	e := &execution{
+		query:   q,
+		w:       w,
+		scanned: &countingReader{reader: input},
+	}
+	if q.request.OutputSerialization.CSV != nil {
+		e.writer = &csvWriter{options: q.request.OutputSerialization.CSV}
+	} else {
+		e.writer = &jsonWriter{options: q.request.OutputSerialization.JSON}
+	}
+	err := e.run()
+	if err == nil {
+		return nil
+	}
+	// send records selected before the error
+	if e.flush() != nil {
+		return err
+	}
+	code, message := "InternalError", "We encountered an internal error, please try again."
+	if apiErr, ok := err.(ApiError); ok {
+		code, message = apiErr.AwsErrorCode(), apiErr.Description()
+	}
+	w.Write(errorMessage(code, message))
+	return err
+}
+
+func (e *execution) run() error {
+	q := e.query
+	var data io.Reader = e.scanned
+	if q.request.InputSerialization.CompressionType == CompressionGzip {
+		reader, err := gzip.NewReader(e.scanned)
+		if err != nil {
+			return ErrInvalidCompressionFormat
+		}
+		data = &gzipReader{reader: reader}
+	}
+	e.processed = &countingReader{reader: data}
+
+	var reader recordReader
+	if q.request.InputSerialization.CSV != nil {
+		reader = newCSVReader(e.processed, q.request.InputSerialization.CSV)
+	} else {
+		reader = newJSONReader(e.processed, q.path, q.wildcard)
+	}
+
+	var count int64
+	for q.limit < 0 || count < q.limit || len(q.aggregates) > 0 {
+		r, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if q.where != nil {
+			v, err := q.where.eval(r)
+			if err != nil {
+				return err
+			}
+			if v != true {
+				continue
+			}
+		}
+		if len(q.aggregates) > 0 {
+			for _, aggregate := range q.aggregates {
+				err = aggregate.update(r)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		err = e.output(r)
+		if err != nil {
+			return err
+		}
+		count++
+		if e.buffer.Len() >= recordsMessageSize {
+			err = e.flush()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if len(q.aggregates) > 0 && q.limit != 0 {
+		err := e.output(nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := e.flush()
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(statsMessage(e.stats("Stats")))
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(endMessage())
+	return err
+}
+
+// output evaluates projections of `r` and buffers the result
+func (e *execution) output(r record) error {
+	q := e.query
+	if q.star {
+		names, values := r.columns()
+		return e.writer.write(&e.buffer, names, values)
+	}
+	names := make([]string, len(q.projections))
+	values := make([]interface{}, len(q.projections))
+	for i, projection := range q.projections {
+		v, err := projection.expr.eval(r)
+		if err != nil {
+			return err
+		}
+		names[i] = projection.name
+		values[i] = v
+	}
+	return e.writer.write(&e.buffer, names, values)
+}
+
+// flush sends buffered records in a Records message, followed by a
+// Progress message if requested
+func (e *execution) flush() error {
+	if e.buffer.Len() == 0 {
+		return nil
+	}
+	e.returned += int64(e.buffer.Len())
+	_, err := e.w.Write(recordsMessage(e.buffer.Bytes()))
+	e.buffer.Reset()
+	if err != nil {
+		return err
+	}
+	if e.query.request.RequestProgress.Enabled {
+		_, err = e.w.Write(statsMessage(e.stats("Progress")))
+	}
+	return err
+}
+
+func (e *execution) stats(name string) stats {
+	s := stats{
+		XMLName:      xml.Name{Local: name},
+		BytesScanned: e.scanned.n,
+	}
+	if e.processed != nil {
+		s.BytesProcessed = e.processed.n
+	}
+	s.BytesReturned = e.returned
+	return s
+}
@@ -0,0 +1,201 @@
+package s3select
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+// decodeMessages returns payload of Records messages and event types of
+// all messages in `data`
+func decodeMessages(t *testing.T, data []byte) (string, []string) {
+	var records strings.Builder
+	var events []string
+	for len(data) > 0 {
+		total := binary.BigEndian.Uint32(data[0:])
+		headersLength := binary.BigEndian.Uint32(data[4:])
+		if crc32.ChecksumIEEE(data[:8]) != binary.BigEndian.Uint32(data[8:]) {
+			t.Fatalf("Bad prelude CRC")
+		}
+		if crc32.ChecksumIEEE(data[:total-4]) != binary.BigEndian.Uint32(data[total-4:]) {
+			t.Fatalf("Bad message CRC")
+		}
+		headers := make(map[string]string)
+		h := data[12 : 12+headersLength]
+		for len(h) > 0 {
+			name := string(h[1 : 1+h[0]])
+			h = h[1+h[0]+1:]
+			length := binary.BigEndian.Uint16(h)
+			headers[name] = string(h[2 : 2+length])
+			h = h[2+length:]
+		}
+		payload := data[12+headersLength : total-4]
+		if headers[":message-type"] == "error" {
+			events = append(events, "error:"+headers[":error-code"])
+		} else {
+			events = append(events, headers[":event-type"])
+		}
+		if headers[":event-type"] == "Records" {
+			records.Write(payload)
+		}
+		data = data[total:]
+	}
+	return records.String(), events
+}
+
+const csvData = "name,age,city\n" +
+	"alice,30,Beijing\n" +
+	"bob,25,\"Shanghai, China\"\n" +
+	"carol,35,Beijing\n"
+
+const jsonData = `{"name": "alice", "age": 30, "tags": ["a", "b"]}
+{"name": "bob", "age": 25.5, "address": {"city": "Shanghai"}}
+{"name": "carol", "age": null}
+`
+
+func TestExecute(t *testing.T) {
+	csvInput := InputSerialization{CSV: &CSVInput{FileHeaderInfo: "USE"}}
+	jsonInput := InputSerialization{JSON: &JSONInput{Type: "LINES"}}
+	csvOutput := OutputSerialization{CSV: &CSVOutput{}}
+	jsonOutput := OutputSerialization{JSON: &JSONOutput{}}
+
+	var testcases = []struct {
+		expression string
+		input      InputSerialization
+		output     OutputSerialization
+		data       string
+		records    string
+	}{
+		{"SELECT * FROM S3Object", csvInput, csvOutput, csvData,
+			"alice,30,Beijing\nbob,25,\"Shanghai, China\"\ncarol,35,Beijing\n"},
+		{"select s.name, s.age from s3object s where s.city = 'Beijing' limit 1",
+			csvInput, jsonOutput, csvData, "{\"name\":\"alice\",\"age\":\"30\"}\n"},
+		{"SELECT _1 FROM S3Object WHERE CAST(_2 AS INT) > 26", csvInput, csvOutput, csvData,
+			"alice\ncarol\n"},
+		{"SELECT COUNT(*), SUM(CAST(age AS INT)), AVG(age), MAX(name) FROM S3Object",
+			csvInput, csvOutput, csvData, "3,90,30,carol\n"},
+		{"SELECT UPPER(name) AS n, age * 2 FROM S3Object WHERE name LIKE '_o%' OR age IS NULL",
+			csvInput, csvOutput, csvData, "BOB,50\n"},
+		{"SELECT name FROM S3Object WHERE age BETWEEN 26 AND 40 AND city IN ('Beijing')",
+			csvInput, csvOutput, csvData, "alice\ncarol\n"},
+		{"SELECT s.name, s.address.city FROM S3Object s WHERE s.age < 30",
+			jsonInput, jsonOutput, jsonData, "{\"name\":\"bob\",\"city\":\"Shanghai\"}\n"},
+		{"SELECT * FROM S3Object s WHERE s.age IS NULL",
+			jsonInput, jsonOutput, jsonData, "{\"name\":\"carol\",\"age\":null}\n"},
+		{"SELECT s.tags[1], COALESCE(s.age, -1) FROM S3Object s",
+			jsonInput, csvOutput, jsonData, "b,30\n,25.5\n,-1\n"},
+		{"SELECT d.name FROM S3Object[*].people d WHERE d.age > 1",
+			InputSerialization{JSON: &JSONInput{Type: "DOCUMENT"}}, csvOutput,
+			`{"people": [{"name": "x", "age": 1}, {"name": "y", "age": 2}]}`, "y\n"},
+	}
+	for _, c := range testcases {
+		request := &Request{
+			Expression:          c.expression,
+			ExpressionType:      "SQL",
+			InputSerialization:  c.input,
+			OutputSerialization: c.output,
+		}
+		if err := request.InputSerialization.validate(); err != nil {
+			t.Fatalf("Validate input of %s: %v", c.expression, err)
+		}
+		if err := request.OutputSerialization.validate(); err != nil {
+			t.Fatalf("Validate output of %s: %v", c.expression, err)
+		}
+		query, err := NewQuery(request)
+		if err != nil {
+			t.Fatalf("Parse %s: %v", c.expression, err)
+		}
+		var out bytes.Buffer
+		err = query.Execute(strings.NewReader(c.data), &out)
+		if err != nil {
+			t.Fatalf("Execute %s: %v", c.expression, err)
+		}
+		records, events := decodeMessages(t, out.Bytes())
+		if records != c.records {
+			t.Errorf("Execute %s: expected %q, got %q", c.expression, c.records, records)
+		}
+		if last := events[len(events)-1]; last != "End" {
+			t.Errorf("Execute %s: last message is %s", c.expression, last)
+		}
+	}
+}
+
+func TestExecuteGzip(t *testing.T) {
+	var data bytes.Buffer
+	writer := gzip.NewWriter(&data)
+	writer.Write([]byte(csvData))
+	writer.Close()
+
+	request := &Request{
+		Expression:     "SELECT COUNT(*) FROM S3Object",
+		ExpressionType: "SQL",
+		InputSerialization: InputSerialization{
+			CompressionType: "GZIP",
+			CSV:             &CSVInput{FileHeaderInfo: "IGNORE"},
+		},
+		OutputSerialization: OutputSerialization{CSV: &CSVOutput{}},
+	}
+	request.InputSerialization.validate()
+	request.OutputSerialization.validate()
+	query, err := NewQuery(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err = query.Execute(&data, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, events := decodeMessages(t, out.Bytes())
+	if records != "3\n" || strings.Join(events, ",") != "Records,Stats,End" {
+		t.Errorf("Unexpected result %q of events %v", records, events)
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	var testcases = []struct {
+		expression string
+		parseError bool
+		code       string
+	}{
+		{"SELECT FROM S3Object", true, "ParseUnexpectedToken"},
+		{"SELECT name, COUNT(*) FROM S3Object", true, "UnsupportedSqlOperation"},
+		{"SELECT * FROM S3Object WHERE COUNT(*) > 1", true, "UnsupportedSqlOperation"},
+		{"SELECT FOO(name) FROM S3Object", true, "UnsupportedSqlOperation"},
+		{"SELECT CAST(name AS INT) FROM S3Object", false, "CastFailed"},
+		{"SELECT age / 0 FROM S3Object", false, "DivisionByZero"},
+		{"SELECT * FROM S3Object WHERE name > 1", false, "InvalidDataType"},
+	}
+	for _, c := range testcases {
+		request := &Request{
+			Expression:          c.expression,
+			InputSerialization:  InputSerialization{CSV: &CSVInput{FileHeaderInfo: "USE"}},
+			OutputSerialization: OutputSerialization{CSV: &CSVOutput{}},
+		}
+		request.InputSerialization.validate()
+		request.OutputSerialization.validate()
+		query, err := NewQuery(request)
+		if c.parseError {
+			if e, ok := err.(*Error); !ok || e.AwsErrorCode() != c.code {
+				t.Errorf("Parse %s: expected %s, got %v", c.expression, c.code, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Parse %s: %v", c.expression, err)
+		}
+		var out bytes.Buffer
+		err = query.Execute(strings.NewReader(csvData), &out)
+		if err == nil {
+			t.Errorf("Execute %s: expected %s", c.expression, c.code)
+			continue
+		}
+		_, events := decodeMessages(t, out.Bytes())
+		if last := events[len(events)-1]; last != "error:"+c.code {
+			t.Errorf("Execute %s: expected %s, got %s", c.expression, c.code, last)
+		}
+	}
+}
@@ -0,0 +1,764 @@
+package s3select
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+// Supported SQL is a subset of the one of AWS S3 Select:
+//
+//	SELECT * | expression [[AS] alias], ...
+//	FROM S3Object[[*]][.path] [[AS] alias]
+//	[WHERE condition]
+//	[LIMIT number]
+//
+// Expressions consist of literals, column references(_N, names or paths in
+// JSON), arithmetic, comparison, logical operators, LIKE, BETWEEN, IN,
+// IS [NOT] NULL, CAST, aggregate functions COUNT, SUM, AVG, MIN, MAX and
+// scalar functions LOWER, UPPER, CHAR_LENGTH, TRIM, SUBSTRING, COALESCE
+// and NULLIF.
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // position in expression, starting from 1
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+var operators = []string{"<=", ">=", "<>", "!=", "||",
+	"=", "<", ">", "+", "-", "*", "/", "%", "(", ")", ",", ".", "[", "]", ";"}
+
+func lex(expression string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expression)
+	i := 0
+	for i < len(runes) {
+		c := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case c == '_' || unicode.IsLetter(c):
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) ||
+				unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[start:i]), start + 1})
+		case unicode.IsDigit(c):
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			if i+1 < len(runes) && runes[i] == '.' && unicode.IsDigit(runes[i+1]) {
+				i++
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					i = j
+					for i < len(runes) && unicode.IsDigit(runes[i]) {
+						i++
+					}
+				}
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start:i]), start + 1})
+		case c == '\'' || c == '"':
+			// quotes are escaped by doubling them
+			var text strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, errorf(ErrSQLParse,
+						"Unterminated quote at position %d.", start+1)
+				}
+				if runes[i] == c {
+					if i+1 < len(runes) && runes[i+1] == c {
+						text.WriteRune(c)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				text.WriteRune(runes[i])
+				i++
+			}
+			kind := tokenString
+			if c == '"' {
+				kind = tokenQuotedIdent
+			}
+			tokens = append(tokens, token{kind, text.String(), start + 1})
+		default:
+			matched := false
+			end := i + 2
+			if end > len(runes) {
+				end = len(runes)
+			}
+			rest := string(runes[i:end])
+			for _, op := range operators {
+				if strings.HasPrefix(rest, op) {
+					tokens = append(tokens, token{tokenOperator, op, start + 1})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, errorf(ErrSQLParse,
+					"Unexpected character %q at position %d.", c, start+1)
+			}
+		}
+	}
+	tokens = append(tokens, token{tokenEOF, "", len(runes) + 1})
+	return tokens, nil
+}
+
+// keywords could not be used as aliases without quotes
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "LIKE": true, "ESCAPE": true,
+	"BETWEEN": true, "IN": true, "IS": true, "NULL": true, "MISSING": true,
+	"TRUE": true, "FALSE": true, "CAST": true,
+}
+
+var castTypes = map[string]string{
+	"BOOL":      typeBool,
+	"BOOLEAN":   typeBool,
+	"INT":       typeInt,
+	"INTEGER":   typeInt,
+	"FLOAT":     typeFloat,
+	"DECIMAL":   typeFloat,
+	"NUMERIC":   typeFloat,
+	"STRING":    typeString,
+	"VARCHAR":   typeString,
+	"CHAR":      typeString,
+	"TIMESTAMP": typeTimestamp,
+}
+
+// scalar functions and their min and max number of arguments,
+// -1 for unlimited
+var scalarFunctions = map[string][2]int{
+	"LOWER":            {1, 1},
+	"UPPER":            {1, 1},
+	"CHAR_LENGTH":      {1, 1},
+	"CHARACTER_LENGTH": {1, 1},
+	"TRIM":             {1, 1},
+	"SUBSTRING":        {2, 3},
+	"COALESCE":         {1, -1},
+	"NULLIF":           {2, 2},
+}
+
+var aggregateFunctions = map[string]bool{
+	"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+
+	columns    []*columnRef
+	aggregates []*aggregateExpr
+	// states of the expression being parsed
+	inWhere     bool
+	inAggregate bool
+	hasColumn   bool // has column references out of aggregate functions
+}
+
+// parseSQL parses `expression` into a Query without its Request
+func parseSQL(expression string) (*Query, error) {
+	tokens, err := lex(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	return p.parseQuery()
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// lookahead returns the token `n` tokens after next one
+func (p *parser) lookahead(n int) token {
+	if p.pos+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+n]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) unexpected(t token) error {
+	return errorf(ErrSQLParse, "Unexpected %s at position %d.", t, t.pos)
+}
+
+// isKeyword returns true if next token is keyword `word`
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.ToUpper(t.text) == word
+}
+
+func (p *parser) acceptKeyword(word string) bool {
+	if p.isKeyword(word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(word string) error {
+	if !p.acceptKeyword(word) {
+		return p.unexpected(p.peek())
+	}
+	return nil
+}
+
+func (p *parser) isOperator(op string) bool {
+	t := p.peek()
+	return t.kind == tokenOperator && t.text == op
+}
+
+func (p *parser) acceptOperator(op string) bool {
+	if p.isOperator(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOperator(op string) error {
+	if !p.acceptOperator(op) {
+		return p.unexpected(p.peek())
+	}
+	return nil
+}
+
+func (p *parser) parseQuery() (*Query, error) {
+	query := &Query{limit: -1}
+	err := p.expectKeyword("SELECT")
+	if err != nil {
+		return nil, err
+	}
+	err = p.parseSelectList(query)
+	if err != nil {
+		return nil, err
+	}
+	err = p.expectKeyword("FROM")
+	if err != nil {
+		return nil, err
+	}
+	err = p.parseFrom(query)
+	if err != nil {
+		return nil, err
+	}
+	if p.acceptKeyword("WHERE") {
+		p.inWhere = true
+		query.where, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		p.inWhere = false
+	}
+	if p.acceptKeyword("LIMIT") {
+		t := p.next()
+		if t.kind != tokenNumber {
+			return nil, p.unexpected(t)
+		}
+		query.limit, err = strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, errorf(ErrSQLParse, "Invalid LIMIT %s at position %d.", t, t.pos)
+		}
+	}
+	p.acceptOperator(";")
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+
+	for _, column := range p.columns {
+		column.resolve(query.alias)
+	}
+	for i := range query.projections {
+		projection := &query.projections[i]
+		if projection.name != "" {
+			continue
+		}
+		projection.name = "_" + strconv.Itoa(i+1)
+		if column, ok := projection.expr.(*columnRef); ok {
+			projection.name = column.name()
+		}
+	}
+	query.aggregates = p.aggregates
+	return query, nil
+}
+
+func (p *parser) parseSelectList(query *Query) error {
+	if p.acceptOperator("*") {
+		query.star = true
+		return nil
+	}
+	// alias.*
+	if p.peek().kind == tokenIdent && p.lookahead(1).text == "." &&
+		p.lookahead(2).text == "*" {
+		p.pos += 3
+		query.star = true
+		return nil
+	}
+	hasColumn := false
+	for {
+		p.hasColumn = false
+		e, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+		hasColumn = hasColumn || p.hasColumn
+		projection := projection{expr: e}
+		explicit := p.acceptKeyword("AS")
+		t := p.peek()
+		if t.kind == tokenQuotedIdent ||
+			(t.kind == tokenIdent && !keywords[strings.ToUpper(t.text)]) {
+			p.pos++
+			projection.name = t.text
+		} else if explicit {
+			return p.unexpected(t)
+		}
+		query.projections = append(query.projections, projection)
+		if !p.acceptOperator(",") {
+			break
+		}
+	}
+	if hasColumn && len(p.aggregates) > 0 {
+		return errorf(ErrUnsupportedSqlOperation,
+			"Aggregate functions could not be selected with columns.")
+	}
+	return nil
+}
+
+func (p *parser) parseFrom(query *Query) error {
+	t := p.next()
+	if t.kind != tokenIdent || strings.ToUpper(t.text) != "S3OBJECT" {
+		return p.unexpected(t)
+	}
+	if p.isOperator("[") && p.lookahead(1).text == "*" &&
+		p.lookahead(2).text == "]" {
+		p.pos += 3
+		query.wildcard = true
+	}
+	for p.isOperator(".") || p.isOperator("[") {
+		segment, err := p.parsePathSegment()
+		if err != nil {
+			return err
+		}
+		query.path = append(query.path, segment)
+	}
+	explicit := p.acceptKeyword("AS")
+	t = p.peek()
+	if t.kind == tokenQuotedIdent ||
+		(t.kind == tokenIdent && !keywords[strings.ToUpper(t.text)]) {
+		p.pos++
+		query.alias = t.text
+	} else if explicit {
+		return p.unexpected(t)
+	}
+	return nil
+}
+
+// parsePathSegment parses ".name" or "[index]"
+func (p *parser) parsePathSegment() (pathSegment, error) {
+	if p.acceptOperator(".") {
+		t := p.next()
+		if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+			return pathSegment{}, p.unexpected(t)
+		}
+		return pathSegment{name: t.text, quoted: t.kind == tokenQuotedIdent}, nil
+	}
+	err := p.expectOperator("[")
+	if err != nil {
+		return pathSegment{}, err
+	}
+	t := p.next()
+	var segment pathSegment
+	switch t.kind {
+	case tokenNumber:
+		index, err := strconv.Atoi(t.text)
+		if err != nil {
+			return pathSegment{}, p.unexpected(t)
+		}
+		segment = pathSegment{index: index, isIndex: true}
+	case tokenString:
+		segment = pathSegment{name: t.text, quoted: true}
+	default:
+		return pathSegment{}, p.unexpected(t)
+	}
+	return segment, p.expectOperator("]")
+}
+
+func (p *parser) parseExpr() (expr, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.acceptKeyword("NOT") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "NOT", x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind == tokenOperator {
+		switch t.text {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			p.pos++
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			op := t.text
+			if op == "<>" {
+				op = "!="
+			}
+			return &binaryExpr{op: op, left: left, right: right}, nil
+		}
+		return left, nil
+	}
+
+	if p.acceptKeyword("IS") {
+		not := p.acceptKeyword("NOT")
+		if !p.acceptKeyword("NULL") && !p.acceptKeyword("MISSING") {
+			return nil, p.unexpected(p.peek())
+		}
+		return &isNullExpr{x: left, not: not}, nil
+	}
+	not := p.acceptKeyword("NOT")
+	switch {
+	case p.acceptKeyword("LIKE"):
+		return p.parseLike(left, not)
+	case p.acceptKeyword("BETWEEN"):
+		low, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expectKeyword("AND")
+		if err != nil {
+			return nil, err
+		}
+		high, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &betweenExpr{x: left, low: low, high: high, not: not}, nil
+	case p.acceptKeyword("IN"):
+		err = p.expectOperator("(")
+		if err != nil {
+			return nil, err
+		}
+		in := &inExpr{x: left, not: not}
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, e)
+			if !p.acceptOperator(",") {
+				break
+			}
+		}
+		return in, p.expectOperator(")")
+	}
+	if not {
+		return nil, p.unexpected(p.peek())
+	}
+	return left, nil
+}
+
+func (p *parser) parseLike(x expr, not bool) (expr, error) {
+	pattern, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	like := &likeExpr{x: x, pattern: pattern, not: not}
+	if p.acceptKeyword("ESCAPE") {
+		like.escape, err = p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// compile constant patterns only once
+	patternLiteral, ok := pattern.(*literal)
+	if !ok {
+		return like, nil
+	}
+	escapeLiteral, ok := like.escape.(*literal)
+	if like.escape != nil && !ok {
+		return like, nil
+	}
+	var escape interface{}
+	if escapeLiteral != nil {
+		escape = escapeLiteral.value
+	}
+	like.re, err = compileLike(patternLiteral.value, escape)
+	return like, err
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("+") || p.isOperator("-") || p.isOperator("||") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("*") || p.isOperator("/") || p.isOperator("%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.acceptOperator("-") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "-", x: x}, nil
+	}
+	if p.acceptOperator("+") {
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literal{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, errorf(ErrSQLParse, "Invalid number %s at position %d.", t, t.pos)
+		}
+		return &literal{value: f}, nil
+	case tokenString:
+		return &literal{value: t.text}, nil
+	case tokenQuotedIdent:
+		return p.parseColumn(t)
+	case tokenOperator:
+		if t.text != "(" {
+			return nil, p.unexpected(t)
+		}
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expectOperator(")")
+	case tokenIdent:
+		name := strings.ToUpper(t.text)
+		switch name {
+		case "TRUE":
+			return &literal{value: true}, nil
+		case "FALSE":
+			return &literal{value: false}, nil
+		case "NULL", "MISSING":
+			return &literal{value: nil}, nil
+		case "CAST":
+			return p.parseCast()
+		}
+		if keywords[name] {
+			return nil, p.unexpected(t)
+		}
+		if p.isOperator("(") {
+			return p.parseFunction(t)
+		}
+		return p.parseColumn(t)
+	}
+	return nil, p.unexpected(t)
+}
+
+func (p *parser) parseColumn(t token) (expr, error) {
+	column := &columnRef{path: []pathSegment{
+		{name: t.text, quoted: t.kind == tokenQuotedIdent},
+	}}
+	for p.isOperator(".") || p.isOperator("[") {
+		segment, err := p.parsePathSegment()
+		if err != nil {
+			return nil, err
+		}
+		column.path = append(column.path, segment)
+	}
+	p.columns = append(p.columns, column)
+	if !p.inAggregate {
+		p.hasColumn = true
+	}
+	return column, nil
+}
+
+func (p *parser) parseCast() (expr, error) {
+	err := p.expectOperator("(")
+	if err != nil {
+		return nil, err
+	}
+	x, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	err = p.expectKeyword("AS")
+	if err != nil {
+		return nil, err
+	}
+	t := p.next()
+	typ, ok := castTypes[strings.ToUpper(t.text)]
+	if t.kind != tokenIdent || !ok {
+		return nil, errorf(ErrSQLParse, "Unsupported type %s at position %d.", t, t.pos)
+	}
+	return &castExpr{x: x, typ: typ}, p.expectOperator(")")
+}
+
+func (p *parser) parseFunction(t token) (expr, error) {
+	name := strings.ToUpper(t.text)
+	p.pos++ // (
+	if aggregateFunctions[name] {
+		return p.parseAggregate(t, name)
+	}
+	limits, ok := scalarFunctions[name]
+	if !ok {
+		return nil, errorf(ErrUnsupportedSqlOperation,
+			"Unsupported function %s at position %d.", t, t.pos)
+	}
+	function := &functionExpr{name: name}
+	if !p.acceptOperator(")") {
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			function.args = append(function.args, e)
+			if !p.acceptOperator(",") {
+				break
+			}
+		}
+		err := p.expectOperator(")")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(function.args) < limits[0] || (limits[1] >= 0 && len(function.args) > limits[1]) {
+		return nil, errorf(ErrEvaluatorInvalidArguments,
+			"Wrong number of arguments of function %s at position %d.", t, t.pos)
+	}
+	return function, nil
+}
+
+func (p *parser) parseAggregate(t token, name string) (expr, error) {
+	if p.inWhere || p.inAggregate {
+		return nil, errorf(ErrUnsupportedSqlOperation,
+			"Aggregate function %s is not allowed at position %d.", t, t.pos)
+	}
+	aggregate := &aggregateExpr{name: name}
+	if name == "COUNT" && p.acceptOperator("*") {
+		p.aggregates = append(p.aggregates, aggregate)
+		return aggregate, p.expectOperator(")")
+	}
+	p.inAggregate = true
+	arg, err := p.parseExpr()
+	p.inAggregate = false
+	if err != nil {
+		return nil, err
+	}
+	if p.isOperator(",") {
+		return nil, errorf(ErrEvaluatorInvalidArguments,
+			"Function %s at position %d takes 1 argument.", t, t.pos)
+	}
+	aggregate.arg = arg
+	p.aggregates = append(p.aggregates, aggregate)
+	return aggregate, p.expectOperator(")")
+}
@@ -73,7 +73,8 @@ func isRequestPostPolicySignature(r *http.Request) bool {
 
 // Verify if the request has AWS Streaming Signature Version '4'. This is only valid for 'PUT' operation.
 func isRequestSignStreamingV4(r *http.Request) bool {
-	return r.Header.Get("X-Amz-Content-Sha256") == streamingContentSHA256 &&
+	payload := r.Header.Get("X-Amz-Content-Sha256")
+	return (payload == streamingContentSHA256 || payload == streamingContentSHA256Trailer) &&
 		r.Method == http.MethodPut
 }
 
@@ -122,6 +123,30 @@ func sumMD5(data []byte) []byte {
 	return hash.Sum(nil)
 }
 
+// GetAccessKey returns the access key a request claims to be signed with,
+// the signature is NOT verified.
+func GetAccessKey(r *http.Request) string {
+	switch GetRequestAuthType(r) {
+	case AuthTypeSignedV4, AuthTypeStreamingSigned:
+		header := strings.TrimPrefix(r.Header.Get("Authorization"), signV4Algorithm)
+		for _, field := range strings.Split(header, ",") {
+			field = strings.TrimSpace(field)
+			if strings.HasPrefix(field, "Credential=") {
+				credential := strings.TrimPrefix(field, "Credential=")
+				return strings.Split(credential, "/")[0]
+			}
+		}
+	case AuthTypeSignedV2:
+		header := strings.TrimPrefix(r.Header.Get("Authorization"), SignV2Algorithm+" ")
+		return strings.Split(header, ":")[0]
+	case AuthTypePresignedV4:
+		return strings.Split(r.URL.Query().Get("X-Amz-Credential"), "/")[0]
+	case AuthTypePresignedV2:
+		return r.URL.Query().Get("AWSAccessKeyId")
+	}
+	return ""
+}
+
 // A helper function to verify if request has valid AWS Signature
 func IsReqAuthenticated(r *http.Request) (c common.Credential, e error) {
 	payload, err := ioutil.ReadAll(r.Body)
@@ -152,3 +177,17 @@ func IsReqAuthenticated(r *http.Request) (c common.Credential, e error) {
 	}
 	return c, ErrAccessDenied
 }
+
+// IsStsReqAuthenticated verifies requests sent to the STS endpoint, which
+// must be signed by signature version 4 with "sts" as the service scope.
+func IsStsReqAuthenticated(r *http.Request) (c common.Credential, e error) {
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return c, ErrInternalError
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(payload))
+	if GetRequestAuthType(r) != AuthTypeSignedV4 {
+		return c, ErrAccessDenied
+	}
+	return doesSignatureMatchV4(hex.EncodeToString(sum256(payload)), r, false, stsService)
+}
@@ -26,37 +26,53 @@ import (
 	"errors"
 	"hash"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/journeymidnight/yig/api/datatype"
 	. "github.com/journeymidnight/yig/error"
-	"github.com/journeymidnight/yig/iam"
 	"github.com/journeymidnight/yig/iam/common"
 )
 
 // Streaming AWS Signature Version '4' constants.
 const (
-	emptySHA256              = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
-	streamingContentSHA256   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
-	signV4ChunkedAlgorithm   = "AWS4-HMAC-SHA256-PAYLOAD"
-	streamingContentEncoding = "aws-chunked"
+	emptySHA256                   = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	streamingContentSHA256        = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingContentSHA256Trailer = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedTrailer      = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	signV4ChunkedAlgorithm        = "AWS4-HMAC-SHA256-PAYLOAD"
+	signV4TrailerAlgorithm        = "AWS4-HMAC-SHA256-TRAILER"
+	streamingContentEncoding      = "aws-chunked"
+	trailerSignatureHeader        = "x-amz-trailer-signature"
 )
 
+// IsAwsChunked returns true if body of the request is in aws-chunked
+// encoding, i.e. with streaming signature or trailing headers, size of data
+// is in X-Amz-Decoded-Content-Length then
+func IsAwsChunked(r *http.Request) bool {
+	switch r.Header.Get("X-Amz-Content-Sha256") {
+	case streamingContentSHA256, streamingContentSHA256Trailer, streamingUnsignedTrailer:
+		return true
+	}
+	return false
+}
+
 // getChunkSignature - get chunk signature.
 func getChunkSignature(cred common.Credential, seedSignature string, region string, date time.Time, hashedChunk string) string {
 	// Calculate string to sign.
 	stringToSign := signV4ChunkedAlgorithm + "\n" +
 		date.Format(datatype.Iso8601Format) + "\n" +
-		getScope(date, region) + "\n" +
+		getScope(date, region, s3Service) + "\n" +
 		seedSignature + "\n" +
 		emptySHA256 + "\n" +
 		hashedChunk
 
 	// Get hmac signing key.
-	signingKey := getSigningKey(cred.SecretAccessKey, date, region)
+	signingKey := getSigningKey(cred.SecretAccessKey, date, region, s3Service)
 
 	// Calculate signature.
 	newSignature := getSignature(signingKey, stringToSign)
@@ -64,6 +80,18 @@ func getChunkSignature(cred common.Credential, seedSignature string, region stri
 	return newSignature
 }
 
+// getTrailerSignature - get signature of trailing headers, chained after
+// signature of the last chunk.
+func getTrailerSignature(cred common.Credential, seedSignature string, region string, date time.Time, hashedTrailer string) string {
+	stringToSign := signV4TrailerAlgorithm + "\n" +
+		date.Format(datatype.Iso8601Format) + "\n" +
+		getScope(date, region, s3Service) + "\n" +
+		seedSignature + "\n" +
+		hashedTrailer
+	signingKey := getSigningKey(cred.SecretAccessKey, date, region, s3Service)
+	return getSignature(signingKey, stringToSign)
+}
+
 // calculateSeedSignature - Calculate seed signature in accordance with
 //     - http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
 // returns signature, error otherwise if the signature mismatches or any other
@@ -76,16 +104,17 @@ func CalculateSeedSignature(r *http.Request) (credential common.Credential, sign
 	v4Auth := req.Header.Get("Authorization")
 
 	// Parse signature version '4' header.
-	signV4Values, err := parseSignV4(v4Auth, r.Header)
+	signV4Values, err := parseSignV4(v4Auth, r.Header, s3Service)
 	if err != nil {
 		return
 	}
 
 	// Payload streaming.
-	payload := streamingContentSHA256
+	payload := req.Header.Get("X-Amz-Content-Sha256")
 
-	// Payload for STREAMING signature should be 'STREAMING-AWS4-HMAC-SHA256-PAYLOAD'
-	if payload != req.Header.Get("X-Amz-Content-Sha256") {
+	// Payload for STREAMING signature should be 'STREAMING-AWS4-HMAC-SHA256-PAYLOAD',
+	// or 'STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER' with trailing headers
+	if payload != streamingContentSHA256 && payload != streamingContentSHA256Trailer {
 		return credential, "", "", time.Time{}, ErrContentSHA256Mismatch
 	}
 
@@ -95,9 +124,9 @@ func CalculateSeedSignature(r *http.Request) (credential common.Credential, sign
 		return
 	}
 
-	credential, e := iam.GetCredential(signV4Values.Credential.accessKey)
-	if e != nil {
-		return credential, "", "", time.Time{}, ErrInvalidAccessKeyID
+	credential, err = getCredential(signV4Values.Credential.accessKey, req.Header.Get("X-Amz-Security-Token"))
+	if err != nil {
+		return credential, "", "", time.Time{}, err
 	}
 
 	// Verify if region is valid.
@@ -123,10 +152,10 @@ func CalculateSeedSignature(r *http.Request) (credential common.Credential, sign
 	canonicalRequest := getCanonicalRequest(extractedSignedHeaders, payload, queryStr, req.URL.Path, req.Method)
 
 	// Get string to sign from canonical request.
-	stringToSign := getStringToSign(canonicalRequest, date, signV4Values.Credential.scope.region)
+	stringToSign := getStringToSign(canonicalRequest, date, signV4Values.Credential.scope.region, s3Service)
 
 	// Get hmac signing key.
-	signingKey := getSigningKey(credential.SecretAccessKey, signV4Values.Credential.scope.date, region)
+	signingKey := getSigningKey(credential.SecretAccessKey, signV4Values.Credential.scope.date, region, s3Service)
 
 	// Calculate signature.
 	newSignature := getSignature(signingKey, stringToSign)
@@ -163,6 +192,8 @@ func newSignV4ChunkedReader(req *http.Request) (io.ReadCloser, error) {
 	return &s3ChunkedReader{
 		body:              req.Body,
 		reader:            bufio.NewReader(req.Body),
+		signed:            true,
+		trailing:          req.Header.Get("X-Amz-Content-Sha256") == streamingContentSHA256Trailer,
 		cred:              credential,
 		seedSignature:     seedSignature,
 		seedDate:          seedDate,
@@ -172,11 +203,27 @@ func newSignV4ChunkedReader(req *http.Request) (io.ReadCloser, error) {
 	}, nil
 }
 
+// newUnsignedChunkedReader returns a s3ChunkedReader of aws-chunked body
+// without chunk signatures but with trailing headers, i.e.
+// 'STREAMING-UNSIGNED-PAYLOAD-TRAILER', the request itself is signed as usual.
+func newUnsignedChunkedReader(req *http.Request) io.ReadCloser {
+	return &s3ChunkedReader{
+		body:              req.Body,
+		reader:            bufio.NewReader(req.Body),
+		trailing:          true,
+		chunkSHA256Writer: sha256.New(),
+		state:             readChunkHeader,
+	}
+}
+
 // Represents the overall state that is required for decoding a
 // AWS Signature V4 chunked reader.
 type s3ChunkedReader struct {
 	body              io.ReadCloser
 	reader            *bufio.Reader
+	signed            bool // false if chunks are not signed
+	trailing          bool // true if trailing headers follow the last chunk
+	trailer           http.Header
 	cred              common.Credential
 	seedSignature     string
 	seedDate          time.Time
@@ -255,7 +302,11 @@ func (cr *s3ChunkedReader) Read(buf []byte) (n int, err error) {
 			}
 			cr.state = readChunk
 		case readChunkTrailer:
-			cr.err = readCRLF(cr.reader)
+			if cr.lastChunk && cr.trailing {
+				cr.trailer, cr.err = readTrailer(cr.reader)
+			} else {
+				cr.err = readCRLF(cr.reader)
+			}
 			if cr.err != nil {
 				return 0, errMalformedEncoding
 			}
@@ -295,18 +346,12 @@ func (cr *s3ChunkedReader) Read(buf []byte) (n int, err error) {
 				continue
 			}
 		case verifyChunk:
-			// Calculate the hashed chunk.
-			hashedChunk := hex.EncodeToString(cr.chunkSHA256Writer.Sum(nil))
-			// Calculate the chunk signature.
-			newSignature := getChunkSignature(cr.cred, cr.seedSignature, cr.region, cr.seedDate, hashedChunk)
-			if !compareSignatureV4(cr.chunkSignature, newSignature) {
-				// Chunk signature doesn't match we return signature does not match.
-				cr.err = ErrSignatureDoesNotMatch
-				return 0, cr.err
+			if cr.signed {
+				cr.err = cr.verifyChunkSignature()
+				if cr.err != nil {
+					return 0, cr.err
+				}
 			}
-			// Newly calculated signature becomes the seed for the next chunk
-			// this follows the chaining.
-			cr.seedSignature = newSignature
 			cr.chunkSHA256Writer.Reset()
 			if cr.lastChunk {
 				cr.state = eofChunk
@@ -319,10 +364,102 @@ func (cr *s3ChunkedReader) Read(buf []byte) (n int, err error) {
 	}
 }
 
+// verifyChunkSignature verifies signature of the chunk just read, and
+// signature of trailing headers after the last chunk.
+func (cr *s3ChunkedReader) verifyChunkSignature() error {
+	// Calculate the hashed chunk.
+	hashedChunk := hex.EncodeToString(cr.chunkSHA256Writer.Sum(nil))
+	// Calculate the chunk signature.
+	newSignature := getChunkSignature(cr.cred, cr.seedSignature, cr.region, cr.seedDate, hashedChunk)
+	if !compareSignatureV4(cr.chunkSignature, newSignature) {
+		// Chunk signature doesn't match we return signature does not match.
+		return ErrSignatureDoesNotMatch
+	}
+	// Newly calculated signature becomes the seed for the next chunk
+	// this follows the chaining.
+	cr.seedSignature = newSignature
+	if !cr.lastChunk || !cr.trailing {
+		return nil
+	}
+
+	// trailing headers are signed as lines of "name:value\n"
+	signature := cr.trailer.Get(trailerSignatureHeader)
+	cr.trailer.Del(trailerSignatureHeader)
+	var names []string
+	for name := range cr.trailer {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hashedTrailer := sha256.New()
+	for _, name := range names {
+		io.WriteString(hashedTrailer, strings.ToLower(name)+":"+cr.trailer.Get(name)+"\n")
+	}
+	newSignature = getTrailerSignature(cr.cred, cr.seedSignature, cr.region, cr.seedDate,
+		hex.EncodeToString(hashedTrailer.Sum(nil)))
+	if !compareSignatureV4(signature, newSignature) {
+		return ErrSignatureDoesNotMatch
+	}
+	return nil
+}
+
 func (cr *s3ChunkedReader) Close() error {
 	return cr.body.Close()
 }
 
+// Trailer returns trailing headers, available once the body is read to EOF
+func (cr *s3ChunkedReader) Trailer() http.Header {
+	return cr.trailer
+}
+
+// ReadTrailer reads the rest of aws-chunked body `data` to EOF, and returns its
+// trailing headers. It returns nil if `data` is not an aws-chunked body.
+func ReadTrailer(data io.Reader) (http.Header, error) {
+	cr, ok := data.(*s3ChunkedReader)
+	if !ok {
+		return nil, nil
+	}
+	_, err := io.Copy(ioutil.Discard, cr)
+	if err != nil {
+		return nil, err
+	}
+	return cr.trailer, nil
+}
+
+// Trailing headers are buffered in memory before their signature is verified,
+// so there could be only a few of them.
+const (
+	maxTrailerLines = 16
+	maxTrailerSize  = 8 * humanize.KiByte
+)
+
+// readTrailer reads trailing headers after the last chunk, i.e. lines of
+// "name:value" ended by an empty line.
+func readTrailer(reader *bufio.Reader) (http.Header, error) {
+	trailer := make(http.Header)
+	size := 0
+	for lines := 0; ; lines++ {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return nil, errLineTooLong
+		} else if err != nil {
+			return nil, err
+		}
+		size += len(line)
+		line = trimTrailingWhitespace(line)
+		if len(line) == 0 {
+			return trailer, nil
+		}
+		if lines >= maxTrailerLines || size > maxTrailerSize {
+			return nil, errMalformedEncoding
+		}
+		colon := bytes.IndexByte(line, ':')
+		if colon <= 0 {
+			return nil, errMalformedEncoding
+		}
+		trailer.Add(string(line[:colon]), string(bytes.TrimSpace(line[colon+1:])))
+	}
+}
+
 // readCRLF - check if reader only has '\r\n' CRLF character.
 // returns malformed encoding if it doesn't.
 func readCRLF(reader io.Reader) error {
@@ -19,10 +19,17 @@ package signature
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/iam/common"
 )
 
 // Test read chunk line.
@@ -194,3 +201,104 @@ func TestParseHexUint(t *testing.T) {
 		}
 	}
 }
+
+// Test reading unsigned aws-chunked body with trailing headers.
+func TestUnsignedChunkedTrailer(t *testing.T) {
+	body := "5\r\nhello\r\n6\r\n world\r\n0\r\nx-amz-checksum-crc32:DUoRhQ==\r\n\r\n"
+	cr := &s3ChunkedReader{
+		body:              ioutil.NopCloser(nil),
+		reader:            bufio.NewReader(strings.NewReader(body)),
+		trailing:          true,
+		chunkSHA256Writer: sha256.New(),
+		state:             readChunkHeader,
+	}
+	data := make([]byte, 11)
+	if _, err := io.ReadFull(cr, data); err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("Expected hello world, got %s", string(data))
+	}
+	trailer, err := ReadTrailer(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := trailer.Get("X-Amz-Checksum-Crc32"); v != "DUoRhQ==" {
+		t.Errorf("Expected DUoRhQ==, got %s", v)
+	}
+}
+
+// Test verifying signatures of chunks and trailing headers.
+func TestSignedChunkedTrailer(t *testing.T) {
+	cred := common.Credential{SecretAccessKey: "secret"}
+	date := time.Date(2020, 3, 2, 8, 0, 0, 0, time.UTC)
+	seed := "seed"
+	hashed := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	chunkSig := getChunkSignature(cred, seed, "us-east-1", date, hashed("hello"))
+	lastSig := getChunkSignature(cred, chunkSig, "us-east-1", date, emptySHA256)
+	trailerSig := getTrailerSignature(cred, lastSig, "us-east-1", date,
+		hashed("x-amz-checksum-crc32:NhCmhg==\n"))
+	newReader := func(trailerSig string) *s3ChunkedReader {
+		body := "5;chunk-signature=" + chunkSig + "\r\nhello\r\n" +
+			"0;chunk-signature=" + lastSig + "\r\n" +
+			"x-amz-checksum-crc32:NhCmhg==\r\n" +
+			"x-amz-trailer-signature:" + trailerSig + "\r\n\r\n"
+		return &s3ChunkedReader{
+			body:              ioutil.NopCloser(nil),
+			reader:            bufio.NewReader(strings.NewReader(body)),
+			signed:            true,
+			trailing:          true,
+			cred:              cred,
+			seedSignature:     seed,
+			seedDate:          date,
+			region:            "us-east-1",
+			chunkSHA256Writer: sha256.New(),
+			state:             readChunkHeader,
+		}
+	}
+
+	trailer, err := ReadTrailer(newReader(trailerSig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := trailer.Get("X-Amz-Checksum-Crc32"); v != "NhCmhg==" {
+		t.Errorf("Expected NhCmhg==, got %s", v)
+	}
+	for i, badSig := range []string{
+		strings.Repeat("0", 64),
+		"",
+		lastSig,
+		trailerSig[:63],
+	} {
+		_, err = ReadTrailer(newReader(badSig))
+		if err != ErrSignatureDoesNotMatch {
+			t.Errorf("Test %d: expected %s, got %v", i+1, ErrSignatureDoesNotMatch, err)
+		}
+	}
+}
+
+// Test trailing headers beyond the limits.
+func TestReadTrailerLimits(t *testing.T) {
+	manyLines := strings.Repeat("x-amz-meta-a:b\r\n", maxTrailerLines)
+	longLines := strings.Repeat("x-amz-meta-a:"+strings.Repeat("b", 1000)+"\r\n", 9)
+	tests := []struct {
+		body        string
+		expectedErr error
+	}{
+		{manyLines + "\r\n", nil},
+		{manyLines + "x-amz-meta-a:b\r\n\r\n", errMalformedEncoding},
+		{longLines[:maxTrailerSize-100] + "\r\n\r\n", nil},
+		{longLines + "\r\n", errMalformedEncoding},
+		{strings.Repeat("x-amz-meta-a:b\r\n", 10000), errMalformedEncoding},
+		{"x-amz-meta-a:" + strings.Repeat("b", 5000) + "\r\n\r\n", errLineTooLong},
+	}
+	for i, tt := range tests {
+		_, err := readTrailer(bufio.NewReader(strings.NewReader(tt.body)))
+		if err != tt.expectedErr {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, tt.expectedErr, err)
+		}
+	}
+}
@@ -41,7 +41,7 @@ type credentialHeader struct {
 	}
 }
 
-func parseCredential(credentialValue string) (credentialHeader, error) {
+func parseCredential(credentialValue, service string) (credentialHeader, error) {
 	credElements := strings.Split(strings.TrimSpace(credentialValue), "/")
 	if len(credElements) != 5 {
 		return credentialHeader{}, ErrCredMalformed
@@ -62,7 +62,7 @@ func parseCredential(credentialValue string) (credentialHeader, error) {
 		return credentialHeader{}, ErrInvalidRegion
 	}
 	cred.scope.region = credElements[2]
-	if credElements[3] != "s3" {
+	if credElements[3] != service {
 		return credentialHeader{}, ErrInvalidService
 	}
 	cred.scope.service = credElements[3]
@@ -75,8 +75,8 @@ func parseCredential(credentialValue string) (credentialHeader, error) {
 
 // parse credentialHeader string into its structured form.
 // Credential=<your-access-key-id>/<date>/<aws-region>/<aws-service>/aws4_request
-// <aws-service> is always "s3" for us
-func parseCredentialHeader(credElement string) (credentialHeader, error) {
+// <aws-service> must be `service`, i.e. "s3", or "sts" for STS requests
+func parseCredentialHeader(credElement, service string) (credentialHeader, error) {
 	creds := strings.Split(strings.TrimSpace(credElement), "=")
 	if len(creds) != 2 {
 		return credentialHeader{}, ErrMissingFields
@@ -84,7 +84,7 @@ func parseCredentialHeader(credElement string) (credentialHeader, error) {
 	if creds[0] != "Credential" {
 		return credentialHeader{}, ErrMissingCredTag
 	}
-	return parseCredential(creds[1])
+	return parseCredential(creds[1], service)
 }
 
 // Parse signature string.
@@ -189,7 +189,7 @@ func parsePreSignV4(query url.Values, headers http.Header) (preSignValues, error
 
 	var err error
 	// Save credential.
-	preSignV4Values.Credential, err = parseCredential(query.Get("X-Amz-Credential"))
+	preSignV4Values.Credential, err = parseCredential(query.Get("X-Amz-Credential"), s3Service)
 	if err != nil {
 		return preSignValues{}, err
 	}
@@ -224,7 +224,7 @@ func parsePreSignV4(query url.Values, headers http.Header) (preSignValues, error
 //
 //    Authorization: algorithm Credential=XXX,SignedHeaders=XXX,Signature=XXX
 //
-func parseSignV4(v4Auth string, headers http.Header) (signValues, error) {
+func parseSignV4(v4Auth string, headers http.Header, service string) (signValues, error) {
 	// Replace all spaced strings, some clients can send spaced
 	// parameters and some won't. So we pro-actively remove any spaces
 	// to make parsing easier.
@@ -245,7 +245,7 @@ func parseSignV4(v4Auth string, headers http.Header) (signValues, error) {
 
 	var err error
 	// Save credential values.
-	signV4Values.Credential, err = parseCredentialHeader(authFields[0])
+	signV4Values.Credential, err = parseCredentialHeader(authFields[0], service)
 	if err != nil {
 		return signValues{}, err
 	}
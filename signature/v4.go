@@ -43,6 +43,8 @@ import (
 // AWS Signature Version '4' constants.
 const (
 	signV4Algorithm = "AWS4-HMAC-SHA256"
+	s3Service       = "s3"
+	stsService      = "sts"
 )
 
 // getSignedHeaders generate a string i.e alphabetically sorted,
@@ -107,31 +109,31 @@ func getCanonicalHeaders(signedHeaders http.Header) string {
 }
 
 // getScope generate a string of a specific date, an AWS region, and a service.
-func getScope(t time.Time, region string) string {
+func getScope(t time.Time, region, service string) string {
 	scope := strings.Join([]string{
 		t.Format(YYYYMMDD),
 		region,
-		"s3",
+		service,
 		"aws4_request",
 	}, "/")
 	return scope
 }
 
 // getStringToSign a string based on selected query values.
-func getStringToSign(canonicalRequest string, t time.Time, region string) string {
+func getStringToSign(canonicalRequest string, t time.Time, region, service string) string {
 	stringToSign := signV4Algorithm + "\n" + t.Format(Iso8601Format) + "\n"
-	stringToSign = stringToSign + getScope(t, region) + "\n"
+	stringToSign = stringToSign + getScope(t, region, service) + "\n"
 	canonicalRequestBytes := sum256([]byte(canonicalRequest))
 	stringToSign = stringToSign + hex.EncodeToString(canonicalRequestBytes[:])
 	return stringToSign
 }
 
 // getSigningKey hmac seed to calculate final signature.
-func getSigningKey(secretKey string, t time.Time, region string) []byte {
+func getSigningKey(secretKey string, t time.Time, region, service string) []byte {
 	date := sumHMAC([]byte("AWS4"+secretKey), []byte(t.Format(YYYYMMDD)))
 	regionBytes := sumHMAC(date, []byte(region))
-	service := sumHMAC(regionBytes, []byte("s3"))
-	signingKey := sumHMAC(service, []byte("aws4_request"))
+	serviceBytes := sumHMAC(regionBytes, []byte(service))
+	signingKey := sumHMAC(serviceBytes, []byte("aws4_request"))
 	return signingKey
 }
 
@@ -140,12 +142,35 @@ func getSignature(signingKey []byte, stringToSign string) string {
 	return hex.EncodeToString(sumHMAC(signingKey, []byte(stringToSign)))
 }
 
+// getCredential returns the credential of `accessKey`. If `sessionToken` is
+// not empty, `accessKey` must be a temporary key sealed in the token.
+func getCredential(accessKey, sessionToken string) (credential common.Credential, err error) {
+	if sessionToken == "" {
+		credential, err = iam.GetCredential(accessKey)
+		if err != nil {
+			return credential, ErrInvalidAccessKeyID
+		}
+		return credential, nil
+	}
+	credential, err = iam.GetSessionCredential(accessKey, sessionToken)
+	switch err {
+	case nil:
+		return credential, nil
+	case common.ErrExpiredSessionToken:
+		return credential, ErrExpiredToken
+	case common.ErrInvalidSessionToken:
+		return credential, ErrInvalidToken
+	default:
+		return credential, ErrInvalidAccessKeyID
+	}
+}
+
 // doesPolicySignatureMatch - Verify query headers with post policy
 //     - http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html
 // returns true if matches, false otherwise. if error is not nil then it is always false
 func DoesPolicySignatureMatchV4(formValues map[string]string) (credential common.Credential, err error) {
 	// Parse credential tag.
-	credHeader, err := parseCredential(formValues["X-Amz-Credential"])
+	credHeader, err := parseCredential(formValues["X-Amz-Credential"], s3Service)
 	if err != nil {
 		return credential, err
 	}
@@ -162,12 +187,12 @@ func DoesPolicySignatureMatchV4(formValues map[string]string) (credential common
 		return credential, ErrMalformedDate
 	}
 
-	credential, e = iam.GetCredential(credHeader.accessKey)
-	if e != nil {
-		return credential, ErrInvalidAccessKeyID
+	credential, err = getCredential(credHeader.accessKey, formValues["X-Amz-Security-Token"])
+	if err != nil {
+		return credential, err
 	}
 	// Get signing key.
-	signingKey := getSigningKey(credential.SecretAccessKey, t, region)
+	signingKey := getSigningKey(credential.SecretAccessKey, t, region, credHeader.scope.service)
 
 	// Get signature.
 	newSignature := getSignature(signingKey, formValues["Policy"])
@@ -190,9 +215,10 @@ func DoesPresignedSignatureMatchV4(r *http.Request,
 		return credential, err
 	}
 
-	credential, e := iam.GetCredential(preSignValues.Credential.accessKey)
-	if e != nil {
-		return credential, ErrInvalidAccessKeyID
+	credential, err = getCredential(preSignValues.Credential.accessKey,
+		r.URL.Query().Get("X-Amz-Security-Token"))
+	if err != nil {
+		return credential, err
 	}
 
 	if preSignValues.Expires > PresignedUrlExpireLimit {
@@ -237,10 +263,12 @@ func DoesPresignedSignatureMatchV4(r *http.Request,
 		query.Encode(), r.URL.Path, r.Method)
 
 	// Get string to sign from canonical request.
-	presignedStringToSign := getStringToSign(presignedCanonicalReq, preSignValues.Date, region)
+	presignedStringToSign := getStringToSign(presignedCanonicalReq, preSignValues.Date, region,
+		preSignValues.Credential.scope.service)
 
 	// Get hmac presigned signing key.
-	presignedSigningKey := getSigningKey(credential.SecretAccessKey, preSignValues.Date, region)
+	presignedSigningKey := getSigningKey(credential.SecretAccessKey, preSignValues.Date, region,
+		preSignValues.Credential.scope.service)
 
 	// Get new signature.
 	newSignature := getSignature(presignedSigningKey, presignedStringToSign)
@@ -256,17 +284,12 @@ func DoesPresignedSignatureMatchV4(r *http.Request,
 func getCredentialUnverified(r *http.Request) (credential common.Credential, err error) {
 	v4Auth := r.Header.Get("Authorization")
 
-	signV4Values, err := parseSignV4(v4Auth, r.Header)
+	signV4Values, err := parseSignV4(v4Auth, r.Header, s3Service)
 	if err != nil {
 		return credential, err
 	}
 
-	credential, e := iam.GetCredential(signV4Values.Credential.accessKey)
-	if e != nil {
-		return credential, ErrInvalidAccessKeyID
-	}
-
-	return credential, nil
+	return getCredential(signV4Values.Credential.accessKey, r.Header.Get("X-Amz-Security-Token"))
 }
 
 // doesSignatureMatch - Verify authorization header with calculated header in accordance with
@@ -274,11 +297,19 @@ func getCredentialUnverified(r *http.Request) (credential common.Credential, err
 // returns true if matches, false otherwise. if error is not nil then it is always false
 func DoesSignatureMatchV4(hashedPayload string, r *http.Request,
 	validateRegion bool) (credential common.Credential, err error) {
+	return doesSignatureMatchV4(hashedPayload, r, validateRegion, s3Service)
+}
+
+// doesSignatureMatchV4 only accepts requests whose credential scope is
+// signed for `service`, so a signature made for STS is never valid for S3
+// and vice versa.
+func doesSignatureMatchV4(hashedPayload string, r *http.Request,
+	validateRegion bool, service string) (credential common.Credential, err error) {
 	// Save authorization header.
 	v4Auth := r.Header.Get("Authorization")
 
 	// Parse signature version '4' header.
-	signV4Values, err := parseSignV4(v4Auth, r.Header)
+	signV4Values, err := parseSignV4(v4Auth, r.Header, service)
 	if err != nil {
 		return credential, err
 	}
@@ -331,14 +362,14 @@ func DoesSignatureMatchV4(hashedPayload string, r *http.Request,
 		r.URL.Path, r.Method)
 
 	// Get string to sign from canonical request.
-	stringToSign := getStringToSign(canonicalRequest, t, region)
+	stringToSign := getStringToSign(canonicalRequest, t, region, signV4Values.Credential.scope.service)
 
-	credential, e := iam.GetCredential(signV4Values.Credential.accessKey)
-	if e != nil {
-		return credential, ErrInvalidAccessKeyID
+	credential, err = getCredential(signV4Values.Credential.accessKey, r.Header.Get("X-Amz-Security-Token"))
+	if err != nil {
+		return credential, err
 	}
 	// Get hmac signing key.
-	signingKey := getSigningKey(credential.SecretAccessKey, t, region)
+	signingKey := getSigningKey(credential.SecretAccessKey, t, region, signV4Values.Credential.scope.service)
 
 	// Calculate signature.
 	newSignature := getSignature(signingKey, stringToSign)
@@ -89,6 +89,12 @@ func VerifyUpload(r *http.Request) (credential common.Credential,
 	case AuthTypeSignedV2:
 		credential, err = DoesSignatureMatchV2(r)
 	case AuthTypeSignedV4:
+		if r.Header.Get("X-Amz-Content-Sha256") == streamingUnsignedTrailer {
+			// chunks are not signed, only the request is
+			credential, err = DoesSignatureMatchV4(streamingUnsignedTrailer, r, true)
+			dataReader = newUnsignedChunkedReader(r)
+			return
+		}
 		credential, err = getCredentialUnverified(r)
 		dataReader = newSignVerify(r)
 	case AuthTypePresignedV2:
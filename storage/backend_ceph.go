@@ -13,6 +13,7 @@ import (
 	"github.com/journeymidnight/yig/meta"
 	"github.com/journeymidnight/yig/meta/types"
 	"github.com/journeymidnight/yig/redis"
+	"github.com/journeymidnight/yig/s3"
 	"github.com/journeymidnight/yig/signature"
 	"io"
 	"sync"
@@ -29,10 +30,31 @@ func New(metaCacheType int, enableDataCache bool, kms crypto.KMS) *YigStorage {
 		WaitGroup:   new(sync.WaitGroup),
 	}
 
-	yig.DataStorage = ceph.Initialize(helper.CONFIG)
+	for fsid, cluster := range ceph.Initialize(helper.CONFIG) {
+		yig.DataStorage[fsid] = backend.NewCircuitCluster(cluster)
+	}
 	if len(yig.DataStorage) == 0 {
 		panic("No data storage can be used!")
 	}
+	yig.clusterStorageClasses = make(map[string]map[types.StorageClass]bool)
+	for id, cluster := range s3.Initialize(helper.CONFIG) {
+		if _, ok := yig.DataStorage[id]; ok {
+			panic("Remote cluster ID conflicts with ceph cluster: " + id)
+		}
+		yig.DataStorage[id] = backend.NewCircuitCluster(cluster)
+		classes := make(map[types.StorageClass]bool)
+		for _, c := range helper.CONFIG.RemoteClusters[id].StorageClasses {
+			storageClass, err := types.MatchStorageClassIndex(c)
+			if err != nil {
+				panic("Invalid storage class of remote cluster " + id + ": " + c)
+			}
+			classes[storageClass] = true
+		}
+		yig.clusterStorageClasses[id] = classes
+	}
+
+	yig.packer = newVolumePacker(helper.CONFIG.PackVolumeCount)
+	go yig.sealIdleVolumes()
 
 	initializeRecycler(&yig)
 	return &yig
@@ -41,7 +63,8 @@ func New(metaCacheType int, enableDataCache bool, kms crypto.KMS) *YigStorage {
 //TODO: Append Support Encryption
 func (yig *YigStorage) AppendObject(bucketName string, objectName string, credential common.Credential,
 	offset uint64, size int64, data io.ReadCloser, metadata map[string]string, acl datatype.Acl,
-	sseRequest datatype.SseRequest, storageClass types.StorageClass, objInfo *types.Object) (result datatype.AppendObjectResult, err error) {
+	sseRequest datatype.SseRequest, storageClass types.StorageClass, objInfo *types.Object,
+	checksumRequest datatype.ChecksumRequest) (result datatype.AppendObjectResult, err error) {
 
 	defer data.Close()
 	encryptionKey, cipherKey, err := yig.encryptionKeyFromSseRequest(sseRequest, bucketName, objectName)
@@ -92,12 +115,25 @@ func (yig *YigStorage) AppendObject(bucketName string, objectName string, creden
 		helper.Logger.Println(20, "request first append oid:", oid, "iv:", initializationVector, "size:", objSize)
 	}
 
-	dataReader := io.TeeReader(limitedDataReader, md5Writer)
+	var hashWriter io.Writer = md5Writer
+	amzHash := amzChecksumHash(checksumRequest)
+	if amzHash != nil {
+		hashWriter = io.MultiWriter(md5Writer, amzHash)
+	}
+	dataReader := io.TeeReader(limitedDataReader, hashWriter)
 
 	storageReader, err := wrapEncryptionReader(dataReader, encryptionKey, initializationVector)
 	if err != nil {
 		return
 	}
+	// checksum is unknown if data before has no checksum
+	checksum := &checksumWriter{}
+	if objInfo != nil {
+		checksum = continueChecksum(objInfo.Checksum)
+	}
+	if checksum != nil {
+		storageReader = io.TeeReader(storageReader, checksum)
+	}
 	oid, bytesWritten, err := cephCluster.Append(poolName, oid, storageReader, int64(offset))
 	if err != nil {
 		helper.Logger.Error("cephCluster.Append err:", err, poolName, oid, offset)
@@ -114,8 +150,14 @@ func (yig *YigStorage) AppendObject(bucketName string, objectName string, creden
 			return result, ErrBadDigest
 		}
 	}
+	amzChecksum, err := verifyAmzChecksum(checksumRequest, amzHash, data)
+	if err != nil {
+		return
+	}
 
 	result.Md5 = calculatedMd5
+	result.ChecksumAlgorithm = checksumRequest.Algorithm
+	result.ChecksumValue = amzChecksum
 
 	if signVerifyReader, ok := data.(*signature.SignVerifyReadCloser); ok {
 		credential, err = signVerifyReader.Verify()
@@ -145,6 +187,13 @@ func (yig *YigStorage) AppendObject(bucketName string, objectName string, creden
 		CustomAttributes:     metadata,
 		Type:                 types.ObjectTypeAppendable,
 		StorageClass:         storageClass,
+		Checksum:             checksum.Checksum(),
+	}
+	// checksum sent by client covers only the data appended, so it's
+	// the checksum of object only for the first append
+	if objInfo == nil {
+		object.ChecksumAlgorithm = checksumRequest.Algorithm
+		object.ChecksumValue = amzChecksum
 	}
 
 	result.LastModified = object.LastModifiedTime
@@ -14,7 +14,6 @@ import (
 	"github.com/journeymidnight/yig/iam"
 	"github.com/journeymidnight/yig/iam/common"
 	meta "github.com/journeymidnight/yig/meta/types"
-	"github.com/journeymidnight/yig/meta/util"
 	"github.com/journeymidnight/yig/redis"
 )
 
@@ -500,6 +499,59 @@ func (yig *YigStorage) CheckBucketEncryption(bucketName string) (*datatype.Apply
 	return nil, false
 }
 
+func (yig *YigStorage) SetBucketTagging(bucket *meta.Bucket, tagging datatype.Tagging) error {
+	bucket.Tagging = tagging
+	err := yig.MetaStorage.Client.PutBucket(*bucket)
+	if err != nil {
+		return err
+	}
+	yig.MetaStorage.Cache.Remove(redis.BucketTable, bucket.Name)
+	return nil
+}
+
+func (yig *YigStorage) GetBucketTagging(bucketName string) (tagging datatype.Tagging, err error) {
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
+	if err != nil {
+		return
+	}
+	if len(bucket.Tagging.TagSet) == 0 {
+		return tagging, ErrNoSuchTagSet
+	}
+	return bucket.Tagging, nil
+}
+
+func (yig *YigStorage) DeleteBucketTagging(bucket *meta.Bucket) error {
+	bucket.Tagging = datatype.Tagging{}
+	err := yig.MetaStorage.Client.PutBucket(*bucket)
+	if err != nil {
+		return err
+	}
+	yig.MetaStorage.Cache.Remove(redis.BucketTable, bucket.Name)
+	return nil
+}
+
+func (yig *YigStorage) SetBucketRequestPayment(bucket *meta.Bucket, config datatype.RequestPaymentConfiguration) error {
+	bucket.RequestPayer = config.Payer
+	err := yig.MetaStorage.Client.PutBucket(*bucket)
+	if err != nil {
+		return err
+	}
+	yig.MetaStorage.Cache.Remove(redis.BucketTable, bucket.Name)
+	return nil
+}
+
+func (yig *YigStorage) GetBucketRequestPayment(bucketName string) (config datatype.RequestPaymentConfiguration, err error) {
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
+	if err != nil {
+		return
+	}
+	config.Payer = bucket.RequestPayer
+	if config.Payer == "" {
+		config.Payer = datatype.PayerBucketOwner
+	}
+	return config, nil
+}
+
 func (yig *YigStorage) ListBuckets(credential common.Credential) (buckets []meta.Bucket, err error) {
 	bucketNames, err := yig.MetaStorage.GetUserBuckets(credential.UserId, true)
 	if err != nil {
@@ -566,6 +618,11 @@ func (yig *YigStorage) DeleteBucket(bucketName string, credential common.Credent
 		}
 	}
 
+	err = yig.MetaStorage.DeleteBucketInventories(bucketName)
+	if err != nil {
+		helper.Logger.Warn("Remove inventory configurations of bucket error:", err)
+	}
+
 	return nil
 }
 
@@ -580,9 +637,8 @@ func (yig *YigStorage) ListObjectsInternal(bucketName string,
 		verIdMarker = request.VersionIdMarker
 	} else if request.Version == 2 {
 		if request.ContinuationToken != "" {
-			marker, err = util.Decrypt(request.ContinuationToken)
+			marker, err = decodeContinuationToken(bucketName, request.ContinuationToken)
 			if err != nil {
-				err = ErrInvalidContinuationToken
 				return
 			}
 		} else {
@@ -623,12 +679,13 @@ func (yig *YigStorage) ListObjects(credential common.Credential, bucketName stri
 	// TODO validate user policy and ACL
 
 	retObjects, prefixes, truncated, nextMarker, _, err := yig.ListObjectsInternal(bucketName, request)
+	if err != nil {
+		return
+	}
 	if truncated && len(nextMarker) != 0 {
 		result.NextMarker = nextMarker
 	}
-	if request.Version == 2 {
-		result.NextMarker = util.Encrypt(result.NextMarker)
-	}
+	owners := make(map[string]*datatype.Owner)
 	objects := make([]datatype.Object, 0, len(retObjects))
 	for _, obj := range retObjects {
 		helper.Logger.Info("result:", obj.Name)
@@ -645,15 +702,20 @@ func (yig *YigStorage) ListObjects(credential common.Credential, bucketName stri
 		}
 
 		if request.FetchOwner {
-			var owner common.Credential
-			owner, err = iam.GetCredentialByUserId(obj.OwnerId)
-			if err != nil {
-				return
-			}
-			object.Owner = datatype.Owner{
-				ID:          owner.UserId,
-				DisplayName: owner.DisplayName,
+			owner, ok := owners[obj.OwnerId]
+			if !ok {
+				var credential common.Credential
+				credential, err = iam.GetCredentialByUserId(obj.OwnerId)
+				if err != nil {
+					return
+				}
+				owner = &datatype.Owner{
+					ID:          credential.UserId,
+					DisplayName: credential.DisplayName,
+				}
+				owners[obj.OwnerId] = owner
 			}
+			object.Owner = owner
 		}
 		objects = append(objects, object)
 	}
@@ -665,7 +727,13 @@ func (yig *YigStorage) ListObjects(credential common.Credential, bucketName stri
 		result.Prefixes = helper.Map(result.Prefixes, func(s string) string {
 			return url.QueryEscape(s)
 		})
-		result.NextMarker = url.QueryEscape(result.NextMarker)
+		// continuation token of v2 is opaque and needs no encoding
+		if request.Version != 2 {
+			result.NextMarker = url.QueryEscape(result.NextMarker)
+		}
+	}
+	if request.Version == 2 && result.NextMarker != "" {
+		result.NextMarker, err = encodeContinuationToken(bucketName, result.NextMarker)
 	}
 	return
 }
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strconv"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/signature"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksumWriter computes CRC32C of data as stored, i.e. after encryption,
+// so data could be verified without keys. Checksum of appendable objects is
+// continued from checksum of data before.
+type checksumWriter struct {
+	crc uint32
+}
+
+// continueChecksum returns a checksumWriter continued from `checksum` of
+// data before, or nil if `checksum` is unknown
+func continueChecksum(checksum string) *checksumWriter {
+	crc, err := strconv.ParseUint(checksum, 16, 32)
+	if err != nil {
+		return nil
+	}
+	return &checksumWriter{crc: uint32(crc)}
+}
+
+func (w *checksumWriter) Write(p []byte) (int, error) {
+	w.crc = crc32.Update(w.crc, crc32cTable, p)
+	return len(p), nil
+}
+
+// Checksum returns checksum of data written in hex, empty if w is nil
+func (w *checksumWriter) Checksum() string {
+	if w == nil {
+		return ""
+	}
+	return fmt.Sprintf("%08x", w.crc)
+}
+
+// amzChecksumHash returns hash of the additional checksum requested by client,
+// nil if not requested
+func amzChecksumHash(request datatype.ChecksumRequest) hash.Hash {
+	if request.Algorithm == "" {
+		return nil
+	}
+	return datatype.NewChecksumHash(request.Algorithm)
+}
+
+// verifyAmzChecksum verifies the additional checksum requested by client
+// against `h`, hash of plain data received. Checksum in trailer is read from
+// the rest of aws-chunked body `data`. It returns the checksum in base64.
+func verifyAmzChecksum(request datatype.ChecksumRequest, h hash.Hash, data io.Reader) (string, error) {
+	if request.Algorithm == "" {
+		return "", nil
+	}
+	expected := request.Value
+	if request.Trailing {
+		trailer, err := signature.ReadTrailer(data)
+		if err != nil {
+			return "", err
+		}
+		expected = trailer.Get(datatype.ChecksumHeader(request.Algorithm))
+		if !datatype.IsValidChecksum(request.Algorithm, expected) {
+			return "", ErrInvalidChecksum
+		}
+	}
+	if base64.StdEncoding.EncodeToString(h.Sum(nil)) != expected {
+		return "", ErrChecksumMismatch
+	}
+	return expected, nil
+}
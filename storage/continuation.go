@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+)
+
+// Continuation tokens of ListObjectsV2 are opaque to clients, the next marker
+// is sealed by AES-GCM with a key derived from admin_key, so tokens can't be
+// forged or read, and are valid on all YIG instances sharing the config.
+// Version of token and name of the bucket listed are authenticated as well,
+// so a token is only accepted for the bucket it's issued for. Tokens expire
+// after continuationTokenTTL since they're issued.
+//
+//	token = base64url(version | nonce | sealed (issue time | marker))
+const (
+	continuationTokenVersion = 2
+	continuationTokenTTL     = 24 * time.Hour
+)
+
+func continuationTokenAEAD() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("continuation-token:" + helper.CONFIG.AdminKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func continuationTokenData(bucketName string) []byte {
+	return append([]byte{continuationTokenVersion}, bucketName...)
+}
+
+func encodeContinuationToken(bucketName, marker string) (string, error) {
+	return sealContinuationToken(bucketName, marker, time.Now())
+}
+
+func sealContinuationToken(bucketName, marker string, issued time.Time) (string, error) {
+	aead, err := continuationTokenAEAD()
+	if err != nil {
+		return "", err
+	}
+	nonceSize := aead.NonceSize()
+	token := make([]byte, 1+nonceSize, 1+nonceSize+8+len(marker)+aead.Overhead())
+	token[0] = continuationTokenVersion
+	nonce := token[1 : 1+nonceSize]
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	plain := make([]byte, 8, 8+len(marker))
+	binary.BigEndian.PutUint64(plain, uint64(issued.Unix()))
+	plain = append(plain, marker...)
+	token = aead.Seal(token, nonce, plain, continuationTokenData(bucketName))
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func decodeContinuationToken(bucketName, token string) (marker string, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", ErrInvalidContinuationToken
+	}
+	aead, err := continuationTokenAEAD()
+	if err != nil {
+		return "", err
+	}
+	nonceSize := aead.NonceSize()
+	if len(data) < 1+nonceSize || data[0] != continuationTokenVersion {
+		return "", ErrInvalidContinuationToken
+	}
+	plain, err := aead.Open(nil, data[1:1+nonceSize], data[1+nonceSize:],
+		continuationTokenData(bucketName))
+	if err != nil || len(plain) < 8 {
+		return "", ErrInvalidContinuationToken
+	}
+	issued := time.Unix(int64(binary.BigEndian.Uint64(plain)), 0)
+	if time.Since(issued) > continuationTokenTTL {
+		return "", ErrInvalidContinuationToken
+	}
+	return string(plain[8:]), nil
+}
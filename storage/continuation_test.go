@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+)
+
+func TestContinuationToken(t *testing.T) {
+	helper.CONFIG.AdminKey = "admin key of test"
+	for _, marker := range []string{"", "object", "dir/对象 with spaces"} {
+		token, err := encodeContinuationToken("bucket", marker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := decodeContinuationToken("bucket", token)
+		if err != nil || decoded != marker {
+			t.Errorf("Expected marker %q, got %q, err: %v", marker, decoded, err)
+		}
+	}
+
+	token, err := encodeContinuationToken("bucket", "object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := base64.RawURLEncoding.DecodeString(token)
+	tampered := make([]byte, len(data))
+	copy(tampered, data)
+	tampered[len(tampered)-1] ^= 1
+	oldVersion := make([]byte, len(data))
+	copy(oldVersion, data)
+	oldVersion[0] = continuationTokenVersion - 1
+	expired, err := sealContinuationToken("bucket", "object",
+		time.Now().Add(-continuationTokenTTL-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		bucket string
+		token  string
+	}{
+		{"bucket", "object"}, // raw marker
+		{"bucket", "!not base64!"},
+		{"bucket", ""},
+		{"bucket", base64.RawURLEncoding.EncodeToString(tampered)},
+		{"bucket", base64.RawURLEncoding.EncodeToString(oldVersion)},
+		{"bucket", base64.RawURLEncoding.EncodeToString(data[:10])},
+		{"bucket", expired},
+		{"other-bucket", token},
+	}
+	for i, testCase := range testCases {
+		_, err := decodeContinuationToken(testCase.bucket, testCase.token)
+		if err != ErrInvalidContinuationToken {
+			t.Errorf("Test %d: expected error %v, got %v", i+1, ErrInvalidContinuationToken, err)
+		}
+	}
+
+	helper.CONFIG.AdminKey = "another admin key"
+	if _, err := decodeContinuationToken("bucket", token); err != ErrInvalidContinuationToken {
+		t.Error("Token should be rejected after admin_key changed, got", err)
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"path"
+	"time"
+
+	"github.com/journeymidnight/yig/crypto"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	meta "github.com/journeymidnight/yig/meta/types"
+)
+
+// shouldDedup returns true if data of objects encrypted by `sseType` should
+// be deduplicated. Data of SSE-C objects is encrypted by keys of users, and
+// objects of cold storage classes are put to their own pool.
+func shouldDedup(sseType string, storageClass meta.StorageClass) bool {
+	return helper.CONFIG.EnableDedup && sseType != crypto.SSEC.String() &&
+		storageClass != meta.ObjectStorageClassGlacier &&
+		storageClass != meta.ObjectStorageClassDeepArchive
+}
+
+// SSE-S3 keys of deduplicated objects are shared by objects in the bucket
+// with the same data, so they are not bound to object names
+func blobKeyContext(bucket string) crypto.Context {
+	return crypto.Context{bucket: bucket}
+}
+
+// objectKeyContext returns the context SSE-S3 key of the object is sealed with
+func objectKeyContext(object *meta.Object) crypto.Context {
+	if object.ContentHash != "" {
+		return blobKeyContext(object.BucketName)
+	}
+	return crypto.Context{object.BucketName: path.Join(object.BucketName, object.Name)}
+}
+
+// refBlob adds a reference to the blob with the same data as `blob`, whose
+// data is just put. Data of `blob` is not referenced if another blob is returned.
+func (yig *YigStorage) refBlob(blob meta.Blob) (referenced meta.Blob, err error) {
+	blob.MTime = time.Now().UTC()
+	referenced, err = yig.MetaStorage.RefBlob(blob)
+	if err != nil {
+		helper.Logger.Error("Ref blob failed:", blob.Hash, blob.BucketName, err)
+		return
+	}
+	if referenced.Location != blob.Location || referenced.ObjectId != blob.ObjectId {
+		helper.Logger.Info("Deduplicated data", blob.Hash, "to", referenced.Location,
+			referenced.Pool, referenced.ObjectId)
+	}
+	return
+}
+
+// blobToRecycle returns the reference to `blob` to recycle, data of the blob
+// is removed when the last reference is recycled
+func blobToRecycle(blob meta.Blob) objectToRecycle {
+	return objectToRecycle{
+		location:   blob.Location,
+		pool:       blob.Pool,
+		objectId:   blob.ObjectId,
+		blobHash:   blob.Hash,
+		blobBucket: blob.BucketName,
+	}
+}
+
+// partToRecycle returns data of the part of multipart upload to recycle
+func partToRecycle(multipart *meta.Multipart, part *meta.Part) objectToRecycle {
+	return objectToRecycle{
+		location:   multipart.Metadata.Location,
+		pool:       multipart.Metadata.Pool,
+		objectId:   part.ObjectId,
+		blobHash:   part.ContentHash,
+		blobBucket: meta.BlobBucketName(multipart.BucketName, multipart.Metadata.SseRequest.Type),
+	}
+}
+
+// blobEncryptionKey generates SSE-S3 key of deduplicated objects in the bucket
+func (yig *YigStorage) blobEncryptionKey(bucket string) (key []byte, encKey []byte, err error) {
+	if yig.KMS == nil {
+		return nil, nil, ErrKMSNotConfigured
+	}
+	plainKey, encKey, err := yig.KMS.GenerateKey(yig.KMS.GetKeyID(), blobKeyContext(bucket))
+	if err != nil {
+		return nil, nil, err
+	}
+	return plainKey[:], encKey, nil
+}
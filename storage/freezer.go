@@ -1,6 +1,10 @@
 package storage
 
 import (
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
 	meta "github.com/journeymidnight/yig/meta/types"
 )
 
@@ -9,7 +13,15 @@ func (yig *YigStorage) GetFreezerStatus(bucketName string, objectName string, ve
 }
 
 func (yig *YigStorage) CreateFreezer(freezer *meta.Freezer) (err error) {
-	return yig.MetaStorage.CreateFreezer(freezer)
+	var capacity int64
+	if freezer.Tier == meta.RestoreTierExpedited {
+		capacity = helper.CONFIG.RestoreTiers[freezer.Tier.ToString()].Capacity
+	}
+	err = yig.MetaStorage.CreateFreezer(freezer, capacity)
+	if err == ErrGlacierExpeditedRetrievalNotAvailable {
+		helper.Logger.Warn("Expedited restore capacity exhausted:", capacity)
+	}
+	return err
 }
 
 func (yig *YigStorage) GetFreezer(bucketName string, objectName string, version string) (freezer *meta.Freezer, err error) {
@@ -36,3 +48,85 @@ func (yig *YigStorage) UpdateFreezerDate(freezer *meta.Freezer, date int, isIncr
 	freezer.LifeTime = lifeTime
 	return yig.MetaStorage.UpdateFreezerDate(freezer)
 }
+
+// RestoreObject copies data of the glacier object into a hot pool, then
+// saves location of the copy to `freezer` and marks it as restored.
+// `freezer` should be in status ObjectRestoring.
+func (yig *YigStorage) RestoreObject(freezer *meta.Freezer) (err error) {
+	object, err := yig.MetaStorage.GetObject(freezer.BucketName, freezer.Name, false)
+	if err != nil {
+		return err
+	}
+	if object.StorageClass != meta.ObjectStorageClassGlacier {
+		return ErrInvalidGlacierObject
+	}
+	source, err := yig.objectCluster(object)
+	if err != nil {
+		return err
+	}
+	cluster, poolName := yig.pickClusterAndPool(object.BucketName, object.Name,
+		meta.ObjectStorageClassStandard, object.Size, false)
+
+	// data is copied as is, so the copy is encrypted with the same key
+	// and initialization vectors as the glacier object
+	var copied []objectToRecycle
+	copyData := func(objectId string) (newObjectId string, err error) {
+		reader, err := source.GetReader(object.Pool, objectId, 0, 0)
+		if err != nil {
+			return "", err
+		}
+		defer reader.Close()
+		newObjectId, _, err = cluster.Put(poolName, reader)
+		if err != nil {
+			return "", err
+		}
+		copied = append(copied, objectToRecycle{
+			location: cluster.ID(),
+			pool:     poolName,
+			objectId: newObjectId,
+		})
+		return newObjectId, nil
+	}
+	defer func() {
+		if err != nil {
+			for _, o := range copied {
+				RecycleQueue <- o
+			}
+		}
+	}()
+
+	restored := *freezer
+	restored.Location = cluster.ID()
+	restored.Pool = poolName
+	restored.OwnerId = object.OwnerId
+	restored.Size = object.Size
+	restored.Etag = object.Etag
+	restored.Parts = nil
+	if len(object.Parts) == 0 {
+		restored.ObjectId, err = copyData(object.ObjectId)
+		if err != nil {
+			return err
+		}
+	} else {
+		restored.ObjectId = ""
+		restored.Parts = make(map[int]*meta.Part, len(object.Parts))
+		for n, part := range object.Parts {
+			p := *part
+			p.ObjectId, err = copyData(part.ObjectId)
+			if err != nil {
+				return err
+			}
+			restored.Parts[n] = &p
+		}
+	}
+	restored.LastModifiedTime = time.Now()
+	err = yig.MetaStorage.PutFreezer(&restored, meta.ObjectHasRestored)
+	if err != nil {
+		return err
+	}
+	helper.Logger.Info("Restored object", object.BucketName, object.Name, "to",
+		restored.Location, restored.Pool, restored.ObjectId)
+	*freezer = restored
+	freezer.Status = meta.ObjectHasRestored
+	return nil
+}
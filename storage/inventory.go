@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"github.com/journeymidnight/yig/api/datatype"
+	. "github.com/journeymidnight/yig/error"
+	meta "github.com/journeymidnight/yig/meta/types"
+)
+
+// SetBucketInventory creates or replaces an inventory configuration of the
+// bucket. Reports are written to the destination bucket by the inventory
+// tool, so it must exist and be owned by the owner of `bucket`.
+func (yig *YigStorage) SetBucketInventory(bucket *meta.Bucket, config datatype.InventoryConfiguration) error {
+	destination, err := yig.MetaStorage.GetBucket(config.DestinationBucket(), true)
+	if err == ErrNoSuchBucket {
+		return ErrInvalidInventoryDestination
+	} else if err != nil {
+		return err
+	}
+	if destination.OwnerId != bucket.OwnerId {
+		return ErrInvalidInventoryDestination
+	}
+
+	_, err = yig.MetaStorage.GetInventory(bucket.Name, config.Id)
+	if err == ErrNoSuchInventoryConfiguration {
+		inventories, err := yig.MetaStorage.ListInventories(bucket.Name, "",
+			datatype.MaxInventoryConfigurations)
+		if err != nil {
+			return err
+		}
+		if len(inventories) >= datatype.MaxInventoryConfigurations {
+			return ErrTooManyInventoryConfigurations
+		}
+	} else if err != nil {
+		return err
+	}
+
+	return yig.MetaStorage.PutInventory(meta.Inventory{
+		BucketName: bucket.Name,
+		Config:     config,
+	})
+}
+
+func (yig *YigStorage) GetBucketInventory(bucketName, id string) (config datatype.InventoryConfiguration, err error) {
+	inventory, err := yig.MetaStorage.GetInventory(bucketName, id)
+	if err != nil {
+		return
+	}
+	return inventory.Config, nil
+}
+
+func (yig *YigStorage) DeleteBucketInventory(bucketName, id string) error {
+	return yig.MetaStorage.DeleteInventory(bucketName, id)
+}
+
+// ListBucketInventory lists inventory configurations of the bucket, at most
+// MaxInventoryConfigurationsListSize of them in one page
+func (yig *YigStorage) ListBucketInventory(bucketName,
+	continuationToken string) (result datatype.ListInventoryConfigurationsResult, err error) {
+
+	var marker string
+	if continuationToken != "" {
+		marker, err = decodeContinuationToken(bucketName, continuationToken)
+		if err != nil {
+			return
+		}
+	}
+	inventories, err := yig.MetaStorage.ListInventories(bucketName, marker,
+		datatype.MaxInventoryConfigurationsListSize+1)
+	if err != nil {
+		return
+	}
+	result.ContinuationToken = continuationToken
+	if len(inventories) > datatype.MaxInventoryConfigurationsListSize {
+		inventories = inventories[:datatype.MaxInventoryConfigurationsListSize]
+		result.IsTruncated = true
+		result.NextContinuationToken, err = encodeContinuationToken(bucketName,
+			inventories[len(inventories)-1].Config.Id)
+		if err != nil {
+			return
+		}
+	}
+	result.InventoryConfigurations = make([]datatype.InventoryConfiguration, 0, len(inventories))
+	for _, inventory := range inventories {
+		result.InventoryConfigurations = append(result.InventoryConfigurations, inventory.Config)
+	}
+	return result, nil
+}
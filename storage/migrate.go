@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/backend"
+	"github.com/journeymidnight/yig/crypto"
+	"github.com/journeymidnight/yig/helper"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
+)
+
+var (
+	// object is overwritten, appended, deleted or already moved during migration
+	ErrObjectChanged = errors.New("object changed during migration")
+	// no other writable cluster has the pool
+	ErrNoMigrationTarget = errors.New("no cluster to migrate to")
+)
+
+// pickMigrationTarget picks a cluster other than `source` having pool
+// `poolName` by cluster weight, clusters not writable, full or not accepting
+// the object are not picked.
+func (yig *YigStorage) pickMigrationTarget(source, poolName string,
+	object *meta.Object) (cluster backend.Cluster, err error) {
+
+	metaClusters, err := yig.MetaStorage.GetClusters()
+	if err != nil {
+		return nil, err
+	}
+	var totalWeight int
+	clusterWeights := make(map[string]int)
+	for _, c := range metaClusters {
+		if c.Fsid == source || c.Pool != poolName || !c.Writable() {
+			continue
+		}
+		if _, ok := yig.DataStorage[c.Fsid]; !ok {
+			continue
+		}
+		if !yig.clusterAccepts(c.Fsid, object.StorageClass,
+			object.Type == meta.ObjectTypeAppendable) {
+			continue
+		}
+		if yig.isClusterTripped(c.Fsid) || yig.isClusterFull(c.Fsid) {
+			continue
+		}
+		totalWeight += c.Weight
+		clusterWeights[c.Fsid] = c.Weight
+	}
+	if totalWeight == 0 {
+		return nil, ErrNoMigrationTarget
+	}
+	N := rand.Intn(totalWeight)
+	n := 0
+	for fsid, weight := range clusterWeights {
+		n += weight
+		if n > N {
+			return yig.DataStorage[fsid], nil
+		}
+	}
+	return nil, ErrNoMigrationTarget
+}
+
+// MigrateObject moves data of the object, including its parts, out of
+// cluster `fsid` and pool `pool`(any pool if empty) to another cluster.
+// `object` is a version of object from ScanObjectsByLocation.
+// Data is copied as is, except that deduplicated SSE-S3 objects are encrypted
+// with keys of their own, and verified by size and md5, then location of the
+// object is switched and old data is put to gc.
+// It returns bytes copied, ErrObjectChanged if the object is no longer in
+// the source location.
+// An append racing with migration either lands before the copy is read, then
+// the copy is larger than the size recorded and migration fails, or its
+// metadata update fails as the object is no longer in the location appended.
+func (yig *YigStorage) MigrateObject(object *meta.Object, fsid, pool string) (copiedBytes int64, err error) {
+	version := strconv.FormatUint(math.MaxUint64-uint64(object.LastModifiedTime.UnixNano()), 10)
+	source, err := yig.MetaStorage.Client.GetObject(object.BucketName, object.Name, version)
+	if err != nil {
+		return 0, err
+	}
+	if source.Location != fsid || (pool != "" && source.Pool != pool) {
+		return 0, ErrObjectChanged
+	}
+	sourceCluster, err := yig.objectCluster(source)
+	if err != nil {
+		return 0, err
+	}
+	cluster, err := yig.pickMigrationTarget(source.Location, source.Pool, source)
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := *source
+	migrated.Location = cluster.ID()
+	migrated.Parts = nil
+	// packed objects are migrated out of their volume as normal objects
+	migrated.Packed = false
+	migrated.VolumeOffset = 0
+	// the copy is not shared, reference to the blob is released with source
+	migrated.ContentHash = ""
+	// SSE-S3 key of deduplicated objects is sealed with the context of the
+	// blob, which the copy no longer belongs to, so the copy is encrypted
+	// with a new key sealed with the context of the object
+	var decryptionKey, encryptionKey []byte
+	if source.SseType == crypto.S3.String() && source.ContentHash != "" {
+		decryptionKey, err = yig.objectEncryptionKey(source, datatype.SseRequest{})
+		if err != nil {
+			return 0, err
+		}
+		key, sealedKey, err := yig.KMS.GenerateKey(yig.KMS.GetKeyID(), objectKeyContext(&migrated))
+		if err != nil {
+			return 0, err
+		}
+		encryptionKey = key[:]
+		migrated.EncryptionKey = sealedKey
+	}
+
+	var copied []objectToRecycle
+	defer func() {
+		if err != nil {
+			for _, o := range copied {
+				RecycleQueue <- o
+			}
+		}
+	}()
+	// etag is checked only if data is stored in plain text, and it's
+	// the md5 of data, i.e. not for appendable objects. Checksum of the copy
+	// is returned if data is encrypted with the new key.
+	copyData := func(objectId string, size int64, etag string,
+		initializationVector []byte) (newObjectId string, checksum *checksumWriter, err error) {
+
+		reader, err := sourceCluster.GetReader(source.Pool, objectId, 0, 0)
+		if err != nil {
+			return "", nil, err
+		}
+		defer reader.Close()
+		var sourceReader io.Reader = reader
+		if len(encryptionKey) != 0 {
+			// data is encrypted in CTR mode, decrypting is encrypting with
+			// the same key
+			sourceReader, err = wrapEncryptionReader(sourceReader, decryptionKey, initializationVector)
+			if err != nil {
+				return "", nil, err
+			}
+			sourceReader, err = wrapEncryptionReader(sourceReader, encryptionKey, initializationVector)
+			if err != nil {
+				return "", nil, err
+			}
+			checksum = &checksumWriter{}
+			sourceReader = io.TeeReader(sourceReader, checksum)
+		}
+		md5Writer := md5.New()
+		dataReader := io.TeeReader(sourceReader, md5Writer)
+		var written uint64
+		if source.Type == meta.ObjectTypeAppendable {
+			// keep the layout of appendable objects so they could be appended later
+			newObjectId, written, err = cluster.Append(source.Pool, "", dataReader, 0)
+		} else {
+			newObjectId, written, err = cluster.Put(source.Pool, dataReader)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		copied = append(copied, objectToRecycle{
+			location: cluster.ID(),
+			pool:     source.Pool,
+			objectId: newObjectId,
+		})
+		copiedBytes += int64(written)
+		if int64(written) != size {
+			return "", nil, errors.New("size mismatch: " + strconv.FormatUint(written, 10) +
+				" copied, expected " + strconv.FormatInt(size, 10))
+		}
+		sourceMd5 := hex.EncodeToString(md5Writer.Sum(nil))
+		if etag != "" && source.SseType == "" && sourceMd5 != etag {
+			return "", nil, errors.New("etag mismatch: " + sourceMd5 + " read, expected " + etag)
+		}
+		// read the copy back to make sure it's intact
+		copyReader, err := cluster.GetReader(source.Pool, newObjectId, 0, 0)
+		if err != nil {
+			return "", nil, err
+		}
+		defer copyReader.Close()
+		md5Writer = md5.New()
+		_, err = io.Copy(ioutil.Discard, io.TeeReader(copyReader, md5Writer))
+		if err != nil {
+			return "", nil, err
+		}
+		if copyMd5 := hex.EncodeToString(md5Writer.Sum(nil)); copyMd5 != sourceMd5 {
+			return "", nil, errors.New("md5 mismatch: " + copyMd5 + " copied, expected " + sourceMd5)
+		}
+		return newObjectId, checksum, nil
+	}
+
+	if len(source.Parts) == 0 {
+		etag := source.Etag
+		if source.Type == meta.ObjectTypeAppendable {
+			etag = ""
+		}
+		var checksum *checksumWriter
+		migrated.ObjectId, checksum, err = copyData(source.ObjectId, source.Size, etag,
+			source.InitializationVector)
+		if err != nil {
+			return copiedBytes, err
+		}
+		if checksum != nil {
+			migrated.Checksum = checksum.Checksum()
+		}
+	} else {
+		migrated.Parts = make(map[int]*meta.Part, len(source.Parts))
+		for n, part := range source.Parts {
+			p := *part
+			p.ContentHash = ""
+			var checksum *checksumWriter
+			p.ObjectId, checksum, err = copyData(part.ObjectId, part.Size, part.Etag,
+				part.InitializationVector)
+			if err != nil {
+				return copiedBytes, err
+			}
+			if checksum != nil {
+				p.Checksum = checksum.Checksum()
+			}
+			migrated.Parts[n] = &p
+		}
+	}
+
+	ok, err := yig.MetaStorage.MigrateObject(&migrated, source)
+	if err != nil {
+		return copiedBytes, err
+	}
+	if !ok {
+		err = ErrObjectChanged
+		return copiedBytes, err
+	}
+	versionId := (&meta.Object{LastModifiedTime: source.LastModifiedTime,
+		NullVersion: source.NullVersion}).GetVersionId()
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, source.BucketName+":"+source.Name+":")
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, source.BucketName+":"+source.Name+":"+versionId)
+	helper.Logger.Info("Migrated object", source.BucketName, source.Name, versionId, "from",
+		source.Location, source.Pool, "to", migrated.Location, migrated.Pool)
+	return copiedBytes, nil
+}
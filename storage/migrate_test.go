@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/backend"
+	"github.com/journeymidnight/yig/crypto"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/log"
+	metastore "github.com/journeymidnight/yig/meta"
+	"github.com/journeymidnight/yig/meta/client"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
+)
+
+// memoryCluster keeps objects in memory
+type memoryCluster struct {
+	id      string
+	objects map[string][]byte
+	counter int
+}
+
+func newMemoryCluster(id string) *memoryCluster {
+	return &memoryCluster{id: id, objects: make(map[string][]byte)}
+}
+
+func (c *memoryCluster) ID() string { return c.id }
+
+func (c *memoryCluster) GetUsage() (backend.Usage, error) { return backend.Usage{}, nil }
+
+func (c *memoryCluster) Put(poolName string, data io.Reader) (string, uint64, error) {
+	b, err := ioutil.ReadAll(data)
+	if err != nil {
+		return "", 0, err
+	}
+	c.counter++
+	oid := c.id + ":" + strconv.Itoa(c.counter)
+	c.objects[poolName+"/"+oid] = b
+	return oid, uint64(len(b)), nil
+}
+
+func (c *memoryCluster) Append(poolName, existName string, data io.Reader,
+	offset int64) (string, uint64, error) {
+
+	return "", 0, errors.New("not supported")
+}
+
+func (c *memoryCluster) GetReader(poolName, objectName string,
+	offset int64, length uint64) (io.ReadCloser, error) {
+
+	b, ok := c.objects[poolName+"/"+objectName]
+	if !ok {
+		return nil, errors.New("ret=-2")
+	}
+	b = b[offset:]
+	if length > 0 {
+		b = b[:length]
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (c *memoryCluster) Remove(poolName, objectName string) error {
+	delete(c.objects, poolName+"/"+objectName)
+	return nil
+}
+
+// contextKMS binds sealed keys to their context like a real KMS
+type contextKMS struct{}
+
+func contextString(context crypto.Context) string {
+	var s strings.Builder
+	context.WriteTo(&s)
+	return s.String()
+}
+
+func (contextKMS) GenerateKey(keyID string, context crypto.Context) (key [32]byte, sealedKey []byte, err error) {
+	_, err = rand.Read(key[:])
+	sealedKey = []byte(contextString(context) + hex.EncodeToString(key[:]))
+	return
+}
+
+func (contextKMS) UnsealKey(keyID string, sealedKey []byte, context crypto.Context) (key [32]byte, err error) {
+	prefix := contextString(context)
+	if !strings.HasPrefix(string(sealedKey), prefix) {
+		return key, errors.New("context mismatch")
+	}
+	plain, err := hex.DecodeString(string(sealedKey[len(prefix):]))
+	copy(key[:], plain)
+	return
+}
+
+func (contextKMS) GetKeyID() string { return "test" }
+
+// migrateClient implements methods of meta client used by migration
+type migrateClient struct {
+	client.Client
+	object   *meta.Object
+	clusters []meta.Cluster
+}
+
+func (c *migrateClient) NewTrans() (*sql.Tx, error)   { return nil, nil }
+func (c *migrateClient) AbortTrans(tx *sql.Tx) error  { return nil }
+func (c *migrateClient) CommitTrans(tx *sql.Tx) error { return nil }
+
+func (c *migrateClient) GetObject(bucketName, objectName, version string) (*meta.Object, error) {
+	object := *c.object
+	return &object, nil
+}
+
+func (c *migrateClient) GetClusters() ([]meta.Cluster, error) {
+	return c.clusters, nil
+}
+
+func (c *migrateClient) MigrateObject(object, source *meta.Object, tx meta.DB) (bool, error) {
+	c.object = object
+	return true, nil
+}
+
+func (c *migrateClient) PutObjectToGarbageCollection(object *meta.Object, tx meta.DB) error {
+	return nil
+}
+
+type noCache struct{}
+
+func (noCache) Get(table redis.RedisDatabase, key string, onCacheMiss func() (interface{}, error),
+	unmarshaller func([]byte) (interface{}, error), willNeed bool) (interface{}, error) {
+
+	return onCacheMiss()
+}
+
+func (noCache) Remove(table redis.RedisDatabase, key string) {}
+
+func (noCache) GetCacheHitRatio() float64 { return 0 }
+
+// Deduplicated SSE-S3 objects are sealed with the key context of the blob,
+// they should still be readable after migrated out of the blob.
+func TestMigrateDedupEncryptedObject(t *testing.T) {
+	helper.Logger = log.NewLogger(os.Stderr, log.ParseLevel("error"))
+	helper.CONFIG.DownloadBufPoolSize = 1 << 10
+	bucket, pool := "bucket", backend.BIG_FILE_POOLNAME
+	source, target := newMemoryCluster("source"), newMemoryCluster("target")
+	kms := contextKMS{}
+
+	data := []byte(strings.Repeat("deduplicated data ", 1000))
+	key, sealedKey, err := kms.GenerateKey(kms.GetKeyID(), blobKeyContext(bucket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv, err := newInitializationVector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := wrapEncryptionReader(bytes.NewReader(data), key[:], iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oid, _, err := source.Put(pool, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md5Sum := md5.Sum(data)
+	object := &meta.Object{
+		BucketName:           bucket,
+		Name:                 "object",
+		Location:             source.ID(),
+		Pool:                 pool,
+		ObjectId:             oid,
+		Size:                 int64(len(data)),
+		Etag:                 hex.EncodeToString(md5Sum[:]),
+		LastModifiedTime:     time.Now().UTC(),
+		SseType:              crypto.S3.String(),
+		EncryptionKey:        sealedKey,
+		InitializationVector: iv,
+		ContentHash:          "hash",
+		Type:                 meta.ObjectTypeNormal,
+	}
+	metaClient := &migrateClient{
+		object: object,
+		clusters: []meta.Cluster{
+			{Fsid: source.ID(), Pool: pool, Weight: 1},
+			{Fsid: target.ID(), Pool: pool, Weight: 1},
+		},
+	}
+	yig := &YigStorage{
+		DataStorage: map[string]backend.Cluster{source.ID(): source, target.ID(): target},
+		DataCache:   newDataCache(false),
+		MetaStorage: &metastore.Meta{Client: metaClient, Cache: noCache{}},
+		KMS:         kms,
+	}
+
+	_, err = yig.MigrateObject(object, source.ID(), pool)
+	if err != nil {
+		t.Fatal("Migrate failed:", err)
+	}
+	migrated := metaClient.object
+	if migrated.Location != target.ID() || migrated.ContentHash != "" {
+		t.Fatal("Object not migrated:", migrated.Location, migrated.ContentHash)
+	}
+	var out bytes.Buffer
+	err = yig.GetObject(migrated, 0, migrated.Size, &out, datatype.SseRequest{})
+	if err != nil {
+		t.Fatal("Read migrated object failed:", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatal("Migrated object differs from the original")
+	}
+}
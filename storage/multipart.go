@@ -2,6 +2,7 @@ package storage
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"io"
@@ -84,7 +85,8 @@ func (yig *YigStorage) ListMultipartUploads(credential common.Credential, bucket
 
 func (yig *YigStorage) NewMultipartUpload(credential common.Credential, bucketName, objectName string,
 	metadata map[string]string, acl datatype.Acl,
-	sseRequest datatype.SseRequest, storageClass meta.StorageClass) (uploadId string, err error) {
+	sseRequest datatype.SseRequest, storageClass meta.StorageClass,
+	checksumAlgorithm string) (uploadId string, err error) {
 
 	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
 	if err != nil {
@@ -116,6 +118,8 @@ func (yig *YigStorage) NewMultipartUpload(credential common.Credential, bucketNa
 		SseRequest:   sseRequest,
 		Attrs:        metadata,
 		StorageClass: storageClass,
+
+		ChecksumAlgorithm: checksumAlgorithm,
 	}
 	if sseRequest.Type == crypto.S3.String() {
 		multipartMetadata.EncryptionKey, multipartMetadata.CipherKey, err = yig.encryptionKeyFromSseRequest(sseRequest, bucketName, objectName)
@@ -143,13 +147,20 @@ func (yig *YigStorage) NewMultipartUpload(credential common.Credential, bucketNa
 
 func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential common.Credential,
 	uploadId string, partId int, size int64, data io.ReadCloser, md5Hex string,
-	sseRequest datatype.SseRequest) (result datatype.PutObjectPartResult, err error) {
+	sseRequest datatype.SseRequest,
+	checksumRequest datatype.ChecksumRequest) (result datatype.PutObjectPartResult, err error) {
 
 	defer data.Close()
 	multipart, err := yig.MetaStorage.GetMultipart(bucketName, objectName, uploadId)
 	if err != nil {
 		return
 	}
+	// composite checksum of the object is computed from checksums of parts
+	if multipart.Metadata.ChecksumAlgorithm != "" &&
+		checksumRequest.Algorithm != multipart.Metadata.ChecksumAlgorithm {
+		err = ErrChecksumAlgorithmMismatch
+		return
+	}
 
 	if size > MAX_PART_SIZE {
 		err = ErrEntityTooLarge
@@ -173,14 +184,27 @@ func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential c
 		return
 	}
 
+	// all parts of an object share the encryption key, so only parts
+	// not encrypted are deduplicated
+	dedup := multipart.Metadata.SseRequest.Type == "" &&
+		shouldDedup(multipart.Metadata.SseRequest.Type, multipart.Metadata.StorageClass)
 	md5Writer := md5.New()
+	sha256Writer := sha256.New()
 	limitedDataReader := io.LimitReader(data, size)
 	poolName := multipart.Metadata.Pool
 	cluster, err := yig.GetClusterByFsName(multipart.Metadata.Location)
 	if err != nil {
 		return
 	}
-	dataReader := io.TeeReader(limitedDataReader, md5Writer)
+	hashWriters := []io.Writer{md5Writer}
+	if dedup {
+		hashWriters = append(hashWriters, sha256Writer)
+	}
+	amzHash := amzChecksumHash(checksumRequest)
+	if amzHash != nil {
+		hashWriters = append(hashWriters, amzHash)
+	}
+	dataReader := io.TeeReader(limitedDataReader, io.MultiWriter(hashWriters...))
 
 	var initializationVector []byte
 	if len(encryptionKey) != 0 {
@@ -194,6 +218,8 @@ func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential c
 	if err != nil {
 		return
 	}
+	checksum := &checksumWriter{}
+	storageReader = io.TeeReader(storageReader, checksum)
 	objectId, bytesWritten, err := cluster.Put(poolName, storageReader)
 	if err != nil {
 		return
@@ -217,6 +243,11 @@ func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential c
 		err = ErrBadDigest
 		return
 	}
+	amzChecksum, err := verifyAmzChecksum(checksumRequest, amzHash, data)
+	if err != nil {
+		RecycleQueue <- maybeObjectToRecycle
+		return
+	}
 
 	if signVerifyReader, ok := data.(*signature.SignVerifyReadCloser); ok {
 		credential, err = signVerifyReader.Verify()
@@ -241,6 +272,36 @@ func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential c
 		}
 	} // TODO policy and fancy ACL
 
+	partChecksum := checksum.Checksum()
+	var contentHash string
+	if dedup {
+		var blob meta.Blob
+		blob, err = yig.refBlob(meta.Blob{
+			Hash:     hex.EncodeToString(sha256Writer.Sum(nil)),
+			Location: cluster.ID(),
+			Pool:     poolName,
+			ObjectId: objectId,
+			Size:     int64(bytesWritten),
+			Checksum: partChecksum,
+		})
+		if err != nil {
+			RecycleQueue <- maybeObjectToRecycle
+			return
+		}
+		if blob.Location != cluster.ID() || blob.Pool != poolName {
+			// parts are read from location of the object, keep data of the
+			// part if the blob is elsewhere
+			RecycleQueue <- blobToRecycle(blob)
+		} else {
+			if blob.ObjectId != objectId {
+				RecycleQueue <- maybeObjectToRecycle
+			}
+			maybeObjectToRecycle = blobToRecycle(blob)
+			objectId = blob.ObjectId
+			contentHash, partChecksum = blob.Hash, blob.Checksum
+		}
+	}
+
 	part := meta.Part{
 		PartNumber:           partId,
 		Size:                 size,
@@ -248,6 +309,10 @@ func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential c
 		Etag:                 calculatedMd5,
 		LastModified:         time.Now().UTC().Format(meta.CREATE_TIME_LAYOUT),
 		InitializationVector: initializationVector,
+		ContentHash:          contentHash,
+		Checksum:             partChecksum,
+		ChecksumAlgorithm:    checksumRequest.Algorithm,
+		ChecksumValue:        amzChecksum,
 	}
 	err = yig.MetaStorage.PutObjectPart(multipart, part)
 	if err != nil {
@@ -256,11 +321,7 @@ func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential c
 	}
 	// remove possible old object in Ceph
 	if part, ok := multipart.Parts[partId]; ok {
-		RecycleQueue <- objectToRecycle{
-			location: multipart.Metadata.Location,
-			pool:     multipart.Metadata.Pool,
-			objectId: part.ObjectId,
-		}
+		RecycleQueue <- partToRecycle(&multipart, part)
 	}
 
 	result.ETag = calculatedMd5
@@ -268,6 +329,8 @@ func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential c
 	result.SseAwsKmsKeyIdBase64 = base64.StdEncoding.EncodeToString([]byte(sseRequest.SseAwsKmsKeyId))
 	result.SseCustomerAlgorithm = sseRequest.SseCustomerAlgorithm
 	result.SseCustomerKeyMd5Base64 = base64.StdEncoding.EncodeToString(sseRequest.SseCustomerKey)
+	result.ChecksumAlgorithm = checksumRequest.Algorithm
+	result.ChecksumValue = amzChecksum
 	return result, nil
 }
 
@@ -309,7 +372,13 @@ func (yig *YigStorage) CopyObjectPart(bucketName, objectName, uploadId string, p
 	if err != nil {
 		return
 	}
-	dataReader := io.TeeReader(limitedDataReader, md5Writer)
+	var hashWriter io.Writer = md5Writer
+	// checksum of the part is needed for composite checksum of the object
+	amzHash := datatype.NewChecksumHash(multipart.Metadata.ChecksumAlgorithm)
+	if amzHash != nil {
+		hashWriter = io.MultiWriter(md5Writer, amzHash)
+	}
+	dataReader := io.TeeReader(limitedDataReader, hashWriter)
 
 	var initializationVector []byte
 	if len(encryptionKey) != 0 {
@@ -323,6 +392,8 @@ func (yig *YigStorage) CopyObjectPart(bucketName, objectName, uploadId string, p
 	if err != nil {
 		return
 	}
+	checksum := &checksumWriter{}
+	storageReader = io.TeeReader(storageReader, checksum)
 	objectId, bytesWritten, err := cephCluster.Put(poolName, storageReader)
 	if err != nil {
 		return
@@ -370,6 +441,12 @@ func (yig *YigStorage) CopyObjectPart(bucketName, objectName, uploadId string, p
 		Etag:                 result.Md5,
 		LastModified:         now.Format(meta.CREATE_TIME_LAYOUT),
 		InitializationVector: initializationVector,
+		Checksum:             checksum.Checksum(),
+	}
+	if amzHash != nil {
+		part.ChecksumAlgorithm = multipart.Metadata.ChecksumAlgorithm
+		part.ChecksumValue = base64.StdEncoding.EncodeToString(amzHash.Sum(nil))
+		result.ChecksumAlgorithm, result.ChecksumValue = part.ChecksumAlgorithm, part.ChecksumValue
 	}
 	result.LastModified = now
 
@@ -381,11 +458,7 @@ func (yig *YigStorage) CopyObjectPart(bucketName, objectName, uploadId string, p
 
 	// remove possible old object in Ceph
 	if part, ok := multipart.Parts[partId]; ok {
-		RecycleQueue <- objectToRecycle{
-			location: multipart.Metadata.Location,
-			pool:     multipart.Metadata.Pool,
-			objectId: part.ObjectId,
-		}
+		RecycleQueue <- partToRecycle(&multipart, part)
 	}
 
 	return result, nil
@@ -434,6 +507,7 @@ func (yig *YigStorage) ListObjectParts(credential common.Credential, bucketName,
 				LastModified: p.LastModified,
 				Size:         p.Size,
 			}
+			part.Checksums.Set(p.ChecksumAlgorithm, p.ChecksumValue)
 			result.Parts = append(result.Parts, part)
 
 			if len(result.Parts) > request.MaxParts {
@@ -465,6 +539,7 @@ func (yig *YigStorage) ListObjectParts(credential common.Credential, bucketName,
 	result.Key = objectName
 	result.UploadId = request.UploadId
 	result.StorageClass = multipart.Metadata.StorageClass.ToString()
+	result.ChecksumAlgorithm = multipart.Metadata.ChecksumAlgorithm
 	result.PartNumberMarker = request.PartNumberMarker
 	result.MaxParts = request.MaxParts
 	result.EncodingType = request.EncodingType
@@ -503,11 +578,7 @@ func (yig *YigStorage) AbortMultipartUpload(credential common.Credential,
 	// remove parts in Ceph
 	var removedSize int64 = 0
 	for _, p := range multipart.Parts {
-		RecycleQueue <- objectToRecycle{
-			location: multipart.Metadata.Location,
-			pool:     multipart.Metadata.Pool,
-			objectId: p.ObjectId,
-		}
+		RecycleQueue <- partToRecycle(&multipart, p)
 		removedSize += p.Size
 	}
 
@@ -515,8 +586,8 @@ func (yig *YigStorage) AbortMultipartUpload(credential common.Credential,
 }
 
 func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, bucketName,
-	objectName, uploadId string, uploadedParts []meta.CompletePart) (result datatype.CompleteMultipartResult,
-	err error) {
+	objectName, uploadId string, uploadedParts []meta.CompletePart,
+	condition *datatype.WriteCondition) (result datatype.CompleteMultipartResult, err error) {
 
 	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
 	if err != nil {
@@ -532,6 +603,13 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 		}
 	}
 	// TODO policy and fancy ACL
+	if condition != nil {
+		// fail early, it's checked again on putting metadata
+		err = yig.MetaStorage.CheckObjectCondition(bucketName, objectName, condition)
+		if err != nil {
+			return
+		}
+	}
 
 	multipart, err := yig.MetaStorage.GetMultipart(bucketName, objectName, uploadId)
 	if err != nil {
@@ -540,6 +618,7 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 
 	md5Writer := md5.New()
 	var totalSize int64 = 0
+	var partChecksums []string
 	helper.Logger.Info("Upload parts:", uploadedParts, "uploadId:", uploadId)
 	for i := 0; i < len(uploadedParts); i++ {
 		if uploadedParts[i].PartNumber != i+1 {
@@ -570,6 +649,17 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 			err = ErrInvalidPart
 			return
 		}
+		for _, algorithm := range datatype.ChecksumAlgorithms {
+			c := uploadedParts[i].Checksums.Get(algorithm)
+			if c != "" && (algorithm != part.ChecksumAlgorithm || c != part.ChecksumValue) {
+				helper.Logger.Error("Checksum of part mismatch;", "i:", i, "checksum:",
+					part.ChecksumAlgorithm, part.ChecksumValue, "reqChecksum:", algorithm, c,
+					"uploadId:", uploadId)
+				err = ErrInvalidPart
+				return
+			}
+		}
+		partChecksums = append(partChecksums, part.ChecksumValue)
 		var etagBytes []byte
 		etagBytes, err = hex.DecodeString(part.Etag)
 		if err != nil {
@@ -586,6 +676,16 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 	result.ETag += "-" + strconv.Itoa(len(uploadedParts))
 	// See http://stackoverflow.com/questions/12186993
 	// for how to calculate multipart Etag
+	if algorithm := multipart.Metadata.ChecksumAlgorithm; algorithm != "" {
+		var ok bool
+		result.ChecksumValue, ok = datatype.CompositeChecksum(algorithm, partChecksums)
+		if !ok {
+			// parts are uploaded with checksums of the algorithm
+			err = ErrInvalidPart
+			return
+		}
+		result.ChecksumAlgorithm = algorithm
+	}
 
 	// Add to objects table
 	contentType := multipart.Metadata.ContentType
@@ -608,10 +708,13 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 		CustomAttributes: multipart.Metadata.Attrs,
 		Type:             meta.ObjectTypeMultipart,
 		StorageClass:     multipart.Metadata.StorageClass,
+
+		ChecksumAlgorithm: result.ChecksumAlgorithm,
+		ChecksumValue:     result.ChecksumValue,
 	}
 
 	var nullVerNum uint64
-	nullVerNum, err = yig.checkOldObject(bucketName, objectName, bucket.Versioning)
+	nullVerNum, err = yig.checkOldObject(bucketName, objectName, bucket.Versioning, condition == nil)
 	if err != nil {
 		return
 	}
@@ -641,9 +744,9 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 	}
 
 	if nullVerNum != 0 {
-		err = yig.MetaStorage.PutObject(object, &multipart, objMap, false)
+		err = yig.MetaStorage.PutObject(object, &multipart, objMap, false, condition)
 	} else {
-		err = yig.MetaStorage.PutObject(object, &multipart, nil, false)
+		err = yig.MetaStorage.PutObject(object, &multipart, nil, false, condition)
 	}
 
 	sseRequest := multipart.Metadata.SseRequest
@@ -653,6 +756,9 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 	result.SseCustomerKeyMd5Base64 = base64.StdEncoding.EncodeToString(sseRequest.SseCustomerKey)
 
 	if err == nil {
+		if condition != nil {
+			yig.removeReplacedObjects(object, bucket.Versioning)
+		}
 		yig.MetaStorage.Cache.Remove(redis.ObjectTable, bucketName+":"+objectName+":")
 		yig.DataCache.Remove(bucketName + ":" + objectName + ":" + object.GetVersionId())
 	}
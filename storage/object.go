@@ -2,11 +2,11 @@ package storage
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"io"
 	"math/rand"
-	"path"
 	"sync"
 	"time"
 
@@ -23,18 +23,40 @@ import (
 	"github.com/journeymidnight/yig/signature"
 )
 
-var latestQueryTime [3]time.Time // 0 is for SMALL_FILE_POOLNAME, 1 is for BIG_FILE_POOLNAME, 2 is for GLACIER_FILE_POOLNAME
 const (
-	CLUSTER_MAX_USED_SPACE_PERCENT = 85
-	BIG_FILE_THRESHOLD             = 128 << 10 /* 128K */
+	BIG_FILE_THRESHOLD = 128 << 10 /* 128K */
 )
 
+// used space of clusters, checked every ClusterUsageCheckInterval seconds
+type clusterUsage struct {
+	usedSpacePercent int
+	// false until usage is got from the cluster once
+	known      bool
+	checkTime  time.Time
+	refreshing bool
+}
+
+var (
+	clusterUsageLock sync.Mutex
+	clusterUsages    = make(map[string]*clusterUsage)
+)
+
+// pickRandomCluster picks a configured cluster when table cluster could not be
+// read. Remote clusters are never picked, since storage classes they accept
+// and whether the object is appendable are not known here, so nil is returned
+// if only remote clusters are configured.
 func (yig *YigStorage) pickRandomCluster() (cluster backend.Cluster) {
 	helper.Logger.Warn("Error picking cluster from table cluster in DB, " +
 		"use first cluster in config to write.")
-	for _, c := range yig.DataStorage {
+	for fsid, c := range yig.DataStorage {
+		// remote clusters don't accept all objects
+		if _, ok := yig.clusterStorageClasses[fsid]; ok {
+			continue
+		}
 		cluster = c
-		break
+		if !yig.isClusterTripped(fsid) {
+			break
+		}
 	}
 	return
 }
@@ -42,31 +64,28 @@ func (yig *YigStorage) pickRandomCluster() (cluster backend.Cluster) {
 func (yig *YigStorage) pickClusterAndPool(bucket string, object string, storageClass meta.StorageClass,
 	size int64, isAppend bool) (cluster backend.Cluster, poolName string) {
 
-	var idx int
-	if storageClass == meta.ObjectStorageClassGlacier {
+	return yig.pickClusterAndPoolExcept(bucket, object, storageClass, size, isAppend, nil)
+}
+
+// pickClusterAndPoolExcept picks a cluster by weight like pickClusterAndPool,
+// clusters in `excluded` are not picked.
+func (yig *YigStorage) pickClusterAndPoolExcept(bucket string, object string, storageClass meta.StorageClass,
+	size int64, isAppend bool, excluded map[string]bool) (cluster backend.Cluster, poolName string) {
+
+	if storageClass == meta.ObjectStorageClassGlacier ||
+		storageClass == meta.ObjectStorageClassDeepArchive {
 		poolName = backend.GLACIER_FILE_POOLNAME
-		idx = 2
 	} else {
 		if isAppend {
 			poolName = backend.BIG_FILE_POOLNAME
-			idx = 1
 		} else if size < 0 { // request.ContentLength is -1 if length is unknown
 			poolName = backend.BIG_FILE_POOLNAME
-			idx = 1
 		} else if size < BIG_FILE_THRESHOLD {
 			poolName = backend.SMALL_FILE_POOLNAME
-			idx = 0
 		} else {
 			poolName = backend.BIG_FILE_POOLNAME
-			idx = 1
 		}
 	}
-	var needCheck bool
-	queryTime := latestQueryTime[idx]
-	if time.Since(queryTime).Hours() > 24 { // check used space every 24 hours
-		latestQueryTime[idx] = time.Now()
-		needCheck = true
-	}
 	var totalWeight int
 	clusterWeights := make(map[string]int, len(yig.DataStorage))
 	metaClusters, err := yig.MetaStorage.GetClusters()
@@ -75,29 +94,32 @@ func (yig *YigStorage) pickClusterAndPool(bucket string, object string, storageC
 		return
 	}
 	for _, cluster := range metaClusters {
-		if cluster.Weight == 0 {
+		if !cluster.Writable() {
 			continue
 		}
 		if cluster.Pool != poolName {
 			continue
 		}
-		if needCheck {
-			usage, err := yig.DataStorage[cluster.Fsid].GetUsage()
-			if err != nil {
-				helper.Logger.Warn("Error getting used space: ", err,
-					"fsid: ", cluster.Fsid)
-				continue
-			}
-			if usage.UsedSpacePercent > CLUSTER_MAX_USED_SPACE_PERCENT {
-				helper.Logger.Warn("Cluster used space exceed ",
-					CLUSTER_MAX_USED_SPACE_PERCENT, cluster.Fsid)
-				continue
-			}
+		if _, ok := yig.DataStorage[cluster.Fsid]; !ok {
+			continue
+		}
+		if excluded[cluster.Fsid] || yig.isClusterTripped(cluster.Fsid) {
+			continue
+		}
+		if !yig.clusterAccepts(cluster.Fsid, storageClass, isAppend) {
+			continue
+		}
+		if yig.isClusterFull(cluster.Fsid) {
+			continue
 		}
 		totalWeight += cluster.Weight
 		clusterWeights[cluster.Fsid] = cluster.Weight
 	}
 	if len(clusterWeights) == 0 || totalWeight == 0 {
+		if excluded != nil {
+			// no other cluster to fail over to
+			return nil, poolName
+		}
 		cluster = yig.pickRandomCluster()
 		return
 	}
@@ -113,6 +135,115 @@ func (yig *YigStorage) pickClusterAndPool(bucket string, object string, storageC
 	return
 }
 
+// clusterAccepts returns false if the cluster doesn't store objects of
+// `storageClass`, remote clusters accept configured storage classes only
+// and don't support append.
+func (yig *YigStorage) clusterAccepts(fsid string, storageClass meta.StorageClass, isAppend bool) bool {
+	classes, ok := yig.clusterStorageClasses[fsid]
+	if !ok {
+		return true
+	}
+	return !isAppend && classes[storageClass]
+}
+
+// isClusterTripped returns true if the circuit of the cluster is open
+func (yig *YigStorage) isClusterTripped(fsid string) bool {
+	if c, ok := yig.DataStorage[fsid].(*backend.CircuitCluster); ok {
+		return c.IsOpen()
+	}
+	return false
+}
+
+// putToCluster puts data to a cluster picked by pickClusterAndPool, if the
+// cluster fails before any data is read, data is put to another cluster.
+// Data streamed from the client could not be read again, so there is no
+// failover once some data is read. Parts of multipart uploads are put to
+// the cluster of the upload and appends to the cluster of the object, they
+// don't fail over either.
+func (yig *YigStorage) putToCluster(bucket string, object string, storageClass meta.StorageClass,
+	size int64, data io.Reader) (cluster backend.Cluster, poolName string,
+	objectId string, bytesWritten uint64, err error) {
+
+	cluster, poolName = yig.pickClusterAndPool(bucket, object, storageClass, size, false)
+	if cluster == nil {
+		return nil, "", "", 0, ErrInternalError
+	}
+	tried := make(map[string]bool)
+	for {
+		objectId, bytesWritten, err = cluster.Put(poolName, data)
+		if err == nil || !backend.IsUncommitted(err) {
+			return
+		}
+		helper.Logger.Warn("Put to cluster", cluster.ID(), poolName,
+			"failed, try another cluster:", err)
+		tried[cluster.ID()] = true
+		next, _ := yig.pickClusterAndPoolExcept(bucket, object, storageClass, size, false, tried)
+		if next == nil {
+			return
+		}
+		cluster = next
+	}
+}
+
+// isClusterFull returns true if used space of the cluster exceeds
+// ClusterMaxUsedSpacePercent. Usage is refreshed in the background once
+// expired, the last known usage is used meanwhile, and a cluster whose usage
+// is unknown is not treated as full.
+func (yig *YigStorage) isClusterFull(fsid string) bool {
+	interval := time.Duration(helper.CONFIG.ClusterUsageCheckInterval) * time.Second
+	clusterUsageLock.Lock()
+	usage, ok := clusterUsages[fsid]
+	if !ok {
+		usage = &clusterUsage{}
+		clusterUsages[fsid] = usage
+	}
+	refresh := !usage.refreshing && time.Since(usage.checkTime) > interval
+	if refresh {
+		usage.refreshing = true
+	}
+	full := usage.known && usage.usedSpacePercent > helper.CONFIG.ClusterMaxUsedSpacePercent
+	clusterUsageLock.Unlock()
+
+	if refresh {
+		if ok {
+			go yig.refreshClusterUsage(fsid, usage)
+		} else {
+			// usage is got for the first time, others see it as unknown
+			// until done
+			yig.refreshClusterUsage(fsid, usage)
+			clusterUsageLock.Lock()
+			full = usage.known && usage.usedSpacePercent > helper.CONFIG.ClusterMaxUsedSpacePercent
+			clusterUsageLock.Unlock()
+		}
+	}
+	return full
+}
+
+// refreshClusterUsage gets used space of the cluster, the last known usage
+// is kept on error, and checked again after ClusterUsageCheckInterval.
+func (yig *YigStorage) refreshClusterUsage(fsid string, usage *clusterUsage) {
+	u, err := yig.DataStorage[fsid].GetUsage()
+	clusterUsageLock.Lock()
+	defer clusterUsageLock.Unlock()
+	usage.refreshing = false
+	usage.checkTime = time.Now()
+	if err == backend.ErrUsageUnknown {
+		usage.known = false
+		return
+	}
+	if err != nil {
+		helper.Logger.Warn("Error getting used space: ", err,
+			"fsid: ", fsid)
+		return
+	}
+	usage.usedSpacePercent = u.UsedSpacePercent
+	usage.known = true
+	if usage.usedSpacePercent > helper.CONFIG.ClusterMaxUsedSpacePercent {
+		helper.Logger.Warn("Cluster used space exceed ",
+			helper.CONFIG.ClusterMaxUsedSpacePercent, fsid)
+	}
+}
+
 func (yig *YigStorage) GetClusterByFsName(fsName string) (cluster backend.Cluster, err error) {
 	if c, ok := yig.DataStorage[fsName]; ok {
 		cluster = c
@@ -185,29 +316,64 @@ func getAlignedReader(cluster backend.Cluster, poolName, objectName string,
 
 func (yig *YigStorage) GetObject(object *meta.Object, startOffset int64,
 	length int64, writer io.Writer, sseRequest datatype.SseRequest) (err error) {
-	var encryptionKey []byte
-	if object.SseType == crypto.S3.String() {
-		if yig.KMS == nil {
-			return ErrKMSNotConfigured
+	encryptionKey, err := yig.objectEncryptionKey(object, sseRequest)
+	if err != nil {
+		return err
+	}
+	return yig.getObject(object, startOffset, length, writer, encryptionKey)
+}
+
+// GetObjectRanges writes multiple ranges of object, `rangeWriter` is called
+// before each range to get the writer for it. Encryption key is unsealed only
+// once for all the ranges.
+func (yig *YigStorage) GetObjectRanges(object *meta.Object, ranges []*datatype.HttpRange,
+	rangeWriter func(hrange *datatype.HttpRange) (io.Writer, error),
+	sseRequest datatype.SseRequest) (err error) {
+
+	encryptionKey, err := yig.objectEncryptionKey(object, sseRequest)
+	if err != nil {
+		return err
+	}
+	for _, hrange := range ranges {
+		writer, err := rangeWriter(hrange)
+		if err != nil {
+			return err
 		}
-		key, err := yig.KMS.UnsealKey(yig.KMS.GetKeyID(), object.EncryptionKey,
-			crypto.Context{object.BucketName: path.Join(object.BucketName, object.Name)})
+		err = yig.getObject(object, hrange.OffsetBegin, hrange.GetLength(), writer, encryptionKey)
 		if err != nil {
 			return err
 		}
-		encryptionKey = key[:]
-	} else { // SSE-C
-		if len(sseRequest.CopySourceSseCustomerKey) != 0 {
-			encryptionKey = sseRequest.CopySourceSseCustomerKey
-		} else {
-			encryptionKey = sseRequest.SseCustomerKey
+	}
+	return nil
+}
+
+func (yig *YigStorage) objectEncryptionKey(object *meta.Object,
+	sseRequest datatype.SseRequest) ([]byte, error) {
+
+	if object.SseType == crypto.S3.String() {
+		if yig.KMS == nil {
+			return nil, ErrKMSNotConfigured
 		}
+		key, err := yig.KMS.UnsealKey(yig.KMS.GetKeyID(), object.EncryptionKey, objectKeyContext(object))
+		if err != nil {
+			return nil, err
+		}
+		return key[:], nil
+	}
+	// SSE-C
+	if len(sseRequest.CopySourceSseCustomerKey) != 0 {
+		return sseRequest.CopySourceSseCustomerKey, nil
 	}
+	return sseRequest.SseCustomerKey, nil
+}
+
+func (yig *YigStorage) getObject(object *meta.Object, startOffset int64,
+	length int64, writer io.Writer, encryptionKey []byte) (err error) {
 
 	if len(object.Parts) == 0 { // this object has only one part
-		cephCluster, ok := yig.DataStorage[object.Location]
-		if !ok {
-			return errors.New("Cannot find specified ceph cluster: " + object.Location)
+		cephCluster, err := yig.objectCluster(object)
+		if err != nil {
+			return err
 		}
 
 		transWholeObjectWriter := generateTransWholeObjectFunc(cephCluster, object)
@@ -500,16 +666,25 @@ func (yig *YigStorage) SetObjectAcl(bucketName string, objectName string, versio
 //                   |            |          |           |
 //                   |            |          +-----------+
 //                   v            v
-//                  SHA256      MD5(ETag)
+//                  SHA256      MD5(ETag), x-amz-checksum-*
 //
 // SHA256 is calculated only for v4 signed authentication
 // Encryptor is enabled when user set SSE headers
 func (yig *YigStorage) PutObject(bucketName string, objectName string, credential common.Credential,
 	size int64, data io.ReadCloser, metadata map[string]string, acl datatype.Acl,
-	sseRequest datatype.SseRequest, storageClass meta.StorageClass) (result datatype.PutObjectResult, err error) {
+	sseRequest datatype.SseRequest, storageClass meta.StorageClass,
+	checksumRequest datatype.ChecksumRequest,
+	condition *datatype.WriteCondition) (result datatype.PutObjectResult, err error) {
 
 	defer data.Close()
-	encryptionKey, cipherKey, err := yig.encryptionKeyFromSseRequest(sseRequest, bucketName, objectName)
+	packed := shouldPack(size, storageClass)
+	dedup := !packed && shouldDedup(sseRequest.Type, storageClass)
+	var encryptionKey, cipherKey []byte
+	if dedup && sseRequest.Type == crypto.S3.String() {
+		encryptionKey, cipherKey, err = yig.blobEncryptionKey(bucketName)
+	} else {
+		encryptionKey, cipherKey, err = yig.encryptionKeyFromSseRequest(sseRequest, bucketName, objectName)
+	}
 	helper.Logger.Info("get encryptionKey:", encryptionKey, "cipherKey:", cipherKey, "err:", err)
 	if err != nil {
 		return
@@ -529,8 +704,16 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 			return result, ErrBucketAccessForbidden
 		}
 	}
+	if condition != nil {
+		// fail early before writing data, it's checked again on putting metadata
+		err = yig.MetaStorage.CheckObjectCondition(bucketName, objectName, condition)
+		if err != nil {
+			return
+		}
+	}
 
 	md5Writer := md5.New()
+	sha256Writer := sha256.New()
 
 	// Limit the reader to its provided size if specified.
 	var limitedDataReader io.Reader
@@ -540,12 +723,15 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 		limitedDataReader = data
 	}
 
-	cluster, poolName := yig.pickClusterAndPool(bucketName, objectName, storageClass, size, false)
-	if cluster == nil {
-		return result, ErrInternalError
+	hashWriters := []io.Writer{md5Writer}
+	if dedup {
+		hashWriters = append(hashWriters, sha256Writer)
 	}
-
-	dataReader := io.TeeReader(limitedDataReader, md5Writer)
+	amzHash := amzChecksumHash(checksumRequest)
+	if amzHash != nil {
+		hashWriters = append(hashWriters, amzHash)
+	}
+	dataReader := io.TeeReader(limitedDataReader, io.MultiWriter(hashWriters...))
 
 	var initializationVector []byte
 	if len(encryptionKey) != 0 {
@@ -559,7 +745,19 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 	if err != nil {
 		return
 	}
-	objectId, bytesWritten, err := cluster.Put(poolName, storageReader)
+	checksum := &checksumWriter{}
+	storageReader = io.TeeReader(storageReader, checksum)
+	var cluster backend.Cluster
+	var poolName, objectId string
+	var volumeOffset int64
+	var bytesWritten uint64
+	if packed {
+		cluster, poolName, objectId, volumeOffset, bytesWritten, err = yig.packObject(bucketName,
+			objectName, storageClass, storageReader, size)
+	} else {
+		cluster, poolName, objectId, bytesWritten, err = yig.putToCluster(bucketName, objectName,
+			storageClass, size, storageReader)
+	}
 	if err != nil {
 		return
 	}
@@ -569,6 +767,8 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 		location: cluster.ID(),
 		pool:     poolName,
 		objectId: objectId,
+		packed:   packed,
+		size:     int64(bytesWritten),
 	}
 	if int64(bytesWritten) < size {
 		RecycleQueue <- maybeObjectToRecycle
@@ -588,6 +788,14 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 
 	result.Md5 = calculatedMd5
 
+	amzChecksum, err := verifyAmzChecksum(checksumRequest, amzHash, data)
+	if err != nil {
+		RecycleQueue <- maybeObjectToRecycle
+		return
+	}
+	result.ChecksumAlgorithm = checksumRequest.Algorithm
+	result.ChecksumValue = amzChecksum
+
 	if signVerifyReader, ok := data.(*signature.SignVerifyReadCloser); ok {
 		credential, err = signVerifyReader.Verify()
 		if err != nil {
@@ -595,11 +803,40 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 			return
 		}
 	}
+
+	location := cluster.ID()
+	objectChecksum := checksum.Checksum()
+	var contentHash string
+	if dedup {
+		var blob meta.Blob
+		blob, err = yig.refBlob(meta.Blob{
+			Hash:                 hex.EncodeToString(sha256Writer.Sum(nil)),
+			BucketName:           meta.BlobBucketName(bucketName, sseRequest.Type),
+			Location:             location,
+			Pool:                 poolName,
+			ObjectId:             objectId,
+			Size:                 int64(bytesWritten),
+			EncryptionKey:        cipherKey,
+			InitializationVector: initializationVector,
+			Checksum:             objectChecksum,
+		})
+		if err != nil {
+			RecycleQueue <- maybeObjectToRecycle
+			return
+		}
+		if blob.Location != location || blob.ObjectId != objectId {
+			RecycleQueue <- maybeObjectToRecycle
+		}
+		maybeObjectToRecycle = blobToRecycle(blob)
+		location, poolName, objectId = blob.Location, blob.Pool, blob.ObjectId
+		cipherKey, initializationVector = blob.EncryptionKey, blob.InitializationVector
+		contentHash, objectChecksum = blob.Hash, blob.Checksum
+	}
 	// TODO validate bucket policy and fancy ACL
 	object := &meta.Object{
 		Name:             objectName,
 		BucketName:       bucketName,
-		Location:         cluster.ID(),
+		Location:         location,
 		Pool:             poolName,
 		OwnerId:          credential.UserId,
 		Size:             int64(bytesWritten),
@@ -617,11 +854,17 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 		CustomAttributes:     metadata,
 		Type:                 meta.ObjectTypeNormal,
 		StorageClass:         storageClass,
+		Packed:               packed,
+		VolumeOffset:         volumeOffset,
+		ContentHash:          contentHash,
+		Checksum:             objectChecksum,
+		ChecksumAlgorithm:    checksumRequest.Algorithm,
+		ChecksumValue:        amzChecksum,
 	}
 
 	result.LastModified = object.LastModifiedTime
 	var nullVerNum uint64
-	nullVerNum, err = yig.checkOldObject(bucketName, objectName, bucket.Versioning)
+	nullVerNum, err = yig.checkOldObject(bucketName, objectName, bucket.Versioning, condition == nil)
 	if err != nil {
 		RecycleQueue <- maybeObjectToRecycle
 		return
@@ -651,15 +894,18 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 			Name:       objectName,
 			BucketName: bucketName,
 		}
-		err = yig.MetaStorage.PutObject(object, nil, objMap, true)
+		err = yig.MetaStorage.PutObject(object, nil, objMap, true, condition)
 	} else {
-		err = yig.MetaStorage.PutObject(object, nil, nil, true)
+		err = yig.MetaStorage.PutObject(object, nil, nil, true, condition)
 	}
 
 	if err != nil {
 		RecycleQueue <- maybeObjectToRecycle
 		return
 	}
+	if condition != nil {
+		yig.removeReplacedObjects(object, bucket.Versioning)
+	}
 
 	if err == nil {
 		yig.MetaStorage.Cache.Remove(redis.ObjectTable, bucketName+":"+objectName+":")
@@ -727,7 +973,8 @@ func (yig *YigStorage) RenameObject(targetObject *meta.Object, sourceObject stri
 }
 
 func (yig *YigStorage) CopyObject(targetObject *meta.Object, sourceObject *meta.Object, source io.Reader, credential common.Credential,
-	sseRequest datatype.SseRequest, isMetadataOnly bool) (result datatype.PutObjectResult, err error) {
+	sseRequest datatype.SseRequest, isMetadataOnly bool,
+	condition *datatype.WriteCondition) (result datatype.PutObjectResult, err error) {
 
 	var oid string
 	var maybeObjectToRecycle objectToRecycle
@@ -750,6 +997,13 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, sourceObject *meta.
 			return result, ErrBucketAccessForbidden
 		}
 	}
+	if condition != nil {
+		// fail early before writing data, it's checked again on putting metadata
+		err = yig.MetaStorage.CheckObjectCondition(targetObject.BucketName, targetObject.Name, condition)
+		if err != nil {
+			return
+		}
+	}
 
 	if isMetadataOnly {
 		if sourceObject.StorageClass == meta.ObjectStorageClassGlacier {
@@ -786,10 +1040,11 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, sourceObject *meta.
 	var limitedDataReader io.Reader
 	limitedDataReader = io.LimitReader(source, targetObject.Size)
 
-	cephCluster, poolName := yig.pickClusterAndPool(targetObject.BucketName,
-		targetObject.Name, targetObject.StorageClass, targetObject.Size, false)
-
+	var cephCluster backend.Cluster
+	var poolName string
 	if len(targetObject.Parts) != 0 {
+		// all parts are put to the same cluster, which is picked by putting
+		// the first part, so it fails over like copying a single object
 		var targetParts map[int]*meta.Part = make(map[int]*meta.Part, len(targetObject.Parts))
 		//		etaglist := make([]string, len(sourceObject.Parts))
 		for i := 1; i <= len(targetObject.Parts); i++ {
@@ -818,7 +1073,17 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, sourceObject *meta.
 					}
 				}
 				storageReader, err = wrapEncryptionReader(dataReader, encryptionKey, initializationVector)
-				oid, bytesW, err = cephCluster.Put(poolName, storageReader)
+				checksum := &checksumWriter{}
+				if cephCluster == nil {
+					cephCluster, poolName, oid, bytesW, err = yig.putToCluster(targetObject.BucketName,
+						targetObject.Name, targetObject.StorageClass, targetObject.Size,
+						io.TeeReader(storageReader, checksum))
+					if cephCluster == nil {
+						return result, err
+					}
+				} else {
+					oid, bytesW, err = cephCluster.Put(poolName, io.TeeReader(storageReader, checksum))
+				}
 				maybeObjectToRecycle = objectToRecycle{
 					location: cephCluster.ID(),
 					pool:     poolName,
@@ -840,6 +1105,8 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, sourceObject *meta.
 				}
 				part.LastModified = time.Now().UTC().Format(meta.CREATE_TIME_LAYOUT)
 				part.ObjectId = oid
+				part.ContentHash = ""
+				part.Checksum = checksum.Checksum()
 
 				part.InitializationVector = initializationVector
 				return result, nil
@@ -868,8 +1135,10 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, sourceObject *meta.
 		if err != nil {
 			return
 		}
+		checksum := &checksumWriter{}
 		var bytesWritten uint64
-		oid, bytesWritten, err = cephCluster.Put(poolName, storageReader)
+		cephCluster, poolName, oid, bytesWritten, err = yig.putToCluster(targetObject.BucketName,
+			targetObject.Name, targetObject.StorageClass, targetObject.Size, io.TeeReader(storageReader, checksum))
 		if err != nil {
 			return
 		}
@@ -893,6 +1162,7 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, sourceObject *meta.
 		result.Md5 = calculatedMd5
 		targetObject.ObjectId = oid
 		targetObject.InitializationVector = initializationVector
+		targetObject.Checksum = checksum.Checksum()
 	}
 	// TODO validate bucket policy and fancy ACL
 
@@ -911,7 +1181,8 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, sourceObject *meta.
 	result.LastModified = targetObject.LastModifiedTime
 
 	var nullVerNum uint64
-	nullVerNum, err = yig.checkOldObject(targetObject.BucketName, targetObject.Name, bucket.Versioning)
+	nullVerNum, err = yig.checkOldObject(targetObject.BucketName, targetObject.Name, bucket.Versioning,
+		condition == nil)
 	if err != nil {
 		RecycleQueue <- maybeObjectToRecycle
 		return
@@ -936,9 +1207,9 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, sourceObject *meta.
 	} else {
 		if nullVerNum != 0 {
 			objMap.NullVerNum = nullVerNum
-			err = yig.MetaStorage.PutObject(targetObject, nil, objMap, true)
+			err = yig.MetaStorage.PutObject(targetObject, nil, objMap, true, condition)
 		} else {
-			err = yig.MetaStorage.PutObject(targetObject, nil, nil, true)
+			err = yig.MetaStorage.PutObject(targetObject, nil, nil, true, condition)
 		}
 	}
 
@@ -946,6 +1217,9 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, sourceObject *meta.
 		RecycleQueue <- maybeObjectToRecycle
 		return
 	}
+	if condition != nil {
+		yig.removeReplacedObjects(targetObject, bucket.Versioning)
+	}
 
 	yig.MetaStorage.Cache.Remove(redis.ObjectTable, targetObject.BucketName+":"+targetObject.Name+":")
 	yig.DataCache.Remove(targetObject.BucketName + ":" + targetObject.Name + ":" + targetObject.GetVersionId())
@@ -984,20 +1258,7 @@ func (yig *YigStorage) removeAllObjectsEntryByName(bucketName, objectName string
 		return err
 	}
 	for _, obj := range objs {
-		if obj.StorageClass == meta.ObjectStorageClassGlacier {
-			freezer, err := yig.GetFreezer(bucketName, objectName, "")
-			if err == nil {
-				if freezer.Name == objectName {
-					err = yig.MetaStorage.DeleteFreezer(freezer)
-					if err != nil {
-						return err
-					}
-				}
-			} else if err != ErrNoSuchKey {
-				return err
-			}
-		}
-		err = yig.removeByObject(obj, nil)
+		err = yig.removeObjectEntry(obj)
 		if err != nil {
 			return err
 		}
@@ -1005,10 +1266,63 @@ func (yig *YigStorage) removeAllObjectsEntryByName(bucketName, objectName string
 	return
 }
 
-func (yig *YigStorage) checkOldObject(bucketName, objectName, versioning string) (version uint64, err error) {
+// removeReplacedObjects removes old entries replaced by `object` just put,
+// which are kept for conditional writes until the condition is met, see
+// checkOldObject. Entries put after `object` by concurrent requests are kept.
+func (yig *YigStorage) removeReplacedObjects(object *meta.Object, versioning string) {
+	if versioning == meta.VersionEnabled {
+		return
+	}
+	objs, err := yig.MetaStorage.GetAllObject(object.BucketName, object.Name)
+	if err != nil {
+		helper.Logger.Error("Get replaced objects of", object.BucketName, object.Name,
+			"error:", err)
+		return
+	}
+	for _, obj := range objs {
+		if !obj.LastModifiedTime.Before(object.LastModifiedTime) {
+			continue
+		}
+		// only the null version is replaced if versioning is suspended
+		if versioning == meta.VersionSuspended && !obj.NullVersion {
+			continue
+		}
+		err = yig.removeObjectEntry(obj)
+		if err != nil {
+			helper.Logger.Error("Remove replaced object", obj.BucketName, obj.Name,
+				obj.GetVersionId(), "error:", err)
+		}
+	}
+}
+
+func (yig *YigStorage) removeObjectEntry(obj *meta.Object) error {
+	if obj.StorageClass == meta.ObjectStorageClassGlacier {
+		freezer, err := yig.GetFreezer(obj.BucketName, obj.Name, "")
+		if err == nil {
+			if freezer.Name == obj.Name {
+				err = yig.MetaStorage.DeleteFreezer(freezer)
+				if err != nil {
+					return err
+				}
+			}
+		} else if err != ErrNoSuchKey {
+			return err
+		}
+	}
+	return yig.removeByObject(obj, nil)
+}
+
+// checkOldObject removes old entries to be replaced by the new object, and
+// returns version of the old null version object to put in object map.
+// Old entries are kept if `removeOld` is false, they must be removed by
+// removeReplacedObjects after the new object is put.
+func (yig *YigStorage) checkOldObject(bucketName, objectName, versioning string,
+	removeOld bool) (version uint64, err error) {
 
 	if versioning == meta.VersionDisabled {
-		err = yig.removeAllObjectsEntryByName(bucketName, objectName)
+		if removeOld {
+			err = yig.removeAllObjectsEntryByName(bucketName, objectName)
+		}
 		return
 	}
 
@@ -1055,7 +1369,7 @@ func (yig *YigStorage) checkOldObject(bucketName, objectName, versioning string)
 			}
 		} else {
 			helper.Logger.Info("object.NullVersion:", object.NullVersion)
-			if objectExist && object.NullVersion {
+			if removeOld && objectExist && object.NullVersion {
 				err = yig.MetaStorage.DeleteObject(object, object.DeleteMarker, nil)
 				if err != nil {
 					return
@@ -1109,9 +1423,9 @@ func (yig *YigStorage) addDeleteMarker(bucket meta.Bucket, objectName string,
 	}
 
 	if nullVersion {
-		err = yig.MetaStorage.PutObject(deleteMarker, nil, objMap, false)
+		err = yig.MetaStorage.PutObject(deleteMarker, nil, objMap, false, nil)
 	} else {
-		err = yig.MetaStorage.PutObject(deleteMarker, nil, nil, false)
+		err = yig.MetaStorage.PutObject(deleteMarker, nil, nil, false, nil)
 	}
 
 	return
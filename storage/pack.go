@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/journeymidnight/yig/backend"
+	"github.com/journeymidnight/yig/helper"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
+)
+
+var ErrNoVolumeCluster = errors.New("no cluster to create volume")
+
+const (
+	// open volumes are sealed after this long, so volumes left open are
+	// known to be abandoned, e.g. by a crashed instance
+	VOLUME_MAX_OPEN_TIME     = time.Hour
+	VOLUME_SEAL_INTERVAL     = time.Minute
+	VOLUME_ABANDONED_TIMEOUT = 24 * time.Hour
+)
+
+// openVolume is a volume being appended by this instance
+type openVolume struct {
+	cluster  backend.Cluster
+	pool     string
+	id       string // empty until the first object is appended
+	size     int64
+	openTime time.Time
+}
+
+// volumePacker hands out open volumes, each volume is appended by one
+// request at a time. A nil volume in the queue is a slot for a new volume.
+type volumePacker struct {
+	volumes chan *openVolume
+}
+
+func newVolumePacker(count int) *volumePacker {
+	p := &volumePacker{volumes: make(chan *openVolume, count)}
+	for i := 0; i < count; i++ {
+		p.volumes <- nil
+	}
+	return p
+}
+
+// shouldPack returns true if the object should be packed into a volume,
+// objects of cold storage classes are put to their own pool.
+func shouldPack(size int64, storageClass meta.StorageClass) bool {
+	return helper.CONFIG.PackSmallObjects && size > 0 &&
+		size <= helper.CONFIG.PackObjectMaxSize &&
+		storageClass != meta.ObjectStorageClassGlacier &&
+		storageClass != meta.ObjectStorageClassDeepArchive
+}
+
+// packObject appends data of the object to an open volume, data is read
+// before taking the volume so slow clients don't hold it.
+func (yig *YigStorage) packObject(bucket, object string, storageClass meta.StorageClass,
+	data io.Reader, size int64) (cluster backend.Cluster, poolName, volumeId string,
+	offset int64, bytesWritten uint64, err error) {
+
+	buf := make([]byte, size)
+	n, err := io.ReadFull(data, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, "", "", 0, 0, err
+	}
+	buf = buf[:n]
+
+	v := <-yig.packer.volumes
+	defer func() {
+		yig.packer.volumes <- v
+	}()
+	if v != nil && yig.isClusterTripped(v.cluster.ID()) {
+		yig.sealVolume(v)
+		v = nil
+	}
+	if v == nil {
+		c, pool := yig.pickClusterAndPool(bucket, object, storageClass, size, true)
+		if c == nil {
+			return nil, "", "", 0, 0, ErrNoVolumeCluster
+		}
+		v = &openVolume{cluster: c, pool: pool, openTime: time.Now()}
+	}
+	cluster, poolName, offset = v.cluster, v.pool, v.size
+	volumeId, bytesWritten, err = v.cluster.Append(v.pool, v.id, bytes.NewReader(buf), v.size)
+	if err != nil {
+		// data may be partially appended, don't append to the volume anymore
+		helper.Logger.Error("Append to volume failed:", v.cluster.ID(), v.pool, v.id, err)
+		yig.sealVolume(v)
+		v = nil
+		return
+	}
+	if v.id == "" {
+		err = yig.MetaStorage.PutVolume(meta.Volume{
+			Location: v.cluster.ID(),
+			Pool:     v.pool,
+			VolumeId: volumeId,
+			MTime:    time.Now().UTC(),
+		})
+		if err != nil {
+			RecycleQueue <- objectToRecycle{
+				location: v.cluster.ID(),
+				pool:     v.pool,
+				objectId: volumeId,
+			}
+			v = nil
+			return
+		}
+		v.id = volumeId
+	}
+	v.size += int64(bytesWritten)
+	if v.size >= helper.CONFIG.PackVolumeSize {
+		yig.sealVolume(v)
+		v = nil
+	}
+	return
+}
+
+func (yig *YigStorage) sealVolume(v *openVolume) {
+	if v.id == "" {
+		return
+	}
+	err := yig.MetaStorage.SealVolume(v.cluster.ID(), v.pool, v.id, v.size)
+	if err != nil {
+		helper.Logger.Error("Seal volume failed:", v.cluster.ID(), v.pool, v.id, err)
+	}
+}
+
+// sealIdleVolumes seals volumes open for over VOLUME_MAX_OPEN_TIME
+func (yig *YigStorage) sealIdleVolumes() {
+	tick := time.NewTicker(VOLUME_SEAL_INTERVAL)
+	defer tick.Stop()
+	for range tick.C {
+		if yig.Stopping {
+			return
+		}
+		for i := 0; i < cap(yig.packer.volumes); i++ {
+			select {
+			case v := <-yig.packer.volumes:
+				if v != nil && time.Since(v.openTime) > VOLUME_MAX_OPEN_TIME {
+					yig.sealVolume(v)
+					v = nil
+				}
+				yig.packer.volumes <- v
+			default:
+			}
+		}
+	}
+}
+
+// sealOpenVolumes seals all open volumes, waiting for volumes being appended
+func (yig *YigStorage) sealOpenVolumes() {
+	for i := 0; i < cap(yig.packer.volumes); i++ {
+		v := <-yig.packer.volumes
+		if v != nil {
+			yig.sealVolume(v)
+		}
+		yig.packer.volumes <- nil
+	}
+}
+
+// volumeCluster reads data of a packed object from its volume, offsets are
+// relative to start of the object.
+type volumeCluster struct {
+	backend.Cluster
+	offset int64
+	size   int64
+}
+
+func (c volumeCluster) GetReader(poolName, objectName string,
+	offset int64, length uint64) (io.ReadCloser, error) {
+
+	if length == 0 || offset+int64(length) > c.size {
+		length = uint64(c.size - offset)
+	}
+	return c.Cluster.GetReader(poolName, objectName, c.offset+offset, length)
+}
+
+// objectCluster returns the cluster to read data of the object from
+func (yig *YigStorage) objectCluster(object *meta.Object) (backend.Cluster, error) {
+	cluster, ok := yig.DataStorage[object.Location]
+	if !ok {
+		return nil, errors.New("Cannot find specified ceph cluster: " + object.Location)
+	}
+	if object.Packed {
+		return volumeCluster{Cluster: cluster, offset: object.VolumeOffset, size: object.Size}, nil
+	}
+	return cluster, nil
+}
+
+// CompactObject moves a packed object out of `volume` into an open volume,
+// `object` is a version of object from ListVolumeObjects. It returns
+// ErrObjectChanged if the object is no longer in the volume.
+func (yig *YigStorage) CompactObject(object *meta.Object, volume meta.Volume) (err error) {
+	version := strconv.FormatUint(math.MaxUint64-uint64(object.LastModifiedTime.UnixNano()), 10)
+	source, err := yig.MetaStorage.Client.GetObject(object.BucketName, object.Name, version)
+	if err != nil {
+		return err
+	}
+	if !source.Packed || source.Location != volume.Location ||
+		source.Pool != volume.Pool || source.ObjectId != volume.VolumeId {
+		return ErrObjectChanged
+	}
+	sourceCluster, err := yig.objectCluster(source)
+	if err != nil {
+		return err
+	}
+	reader, err := sourceCluster.GetReader(source.Pool, source.ObjectId, 0, 0)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	md5Writer := md5.New()
+	cluster, poolName, volumeId, offset, written, err := yig.packObject(source.BucketName,
+		source.Name, source.StorageClass, io.TeeReader(reader, md5Writer), source.Size)
+	if err != nil {
+		return err
+	}
+	packed := objectToRecycle{
+		location: cluster.ID(),
+		pool:     poolName,
+		objectId: volumeId,
+		packed:   true,
+		size:     int64(written),
+	}
+	if int64(written) != source.Size {
+		RecycleQueue <- packed
+		return errors.New("size mismatch when compacting object")
+	}
+	// etag is md5 of data only if data is stored in plain text
+	if source.SseType == "" && hex.EncodeToString(md5Writer.Sum(nil)) != source.Etag {
+		RecycleQueue <- packed
+		return errors.New("etag mismatch when compacting object")
+	}
+
+	compacted := *source
+	compacted.Location = cluster.ID()
+	compacted.Pool = poolName
+	compacted.ObjectId = volumeId
+	compacted.VolumeOffset = offset
+	ok, err := yig.MetaStorage.MigrateObject(&compacted, source)
+	if err != nil || !ok {
+		RecycleQueue <- packed
+		if err == nil {
+			err = ErrObjectChanged
+		}
+		return err
+	}
+	versionId := (&meta.Object{LastModifiedTime: source.LastModifiedTime,
+		NullVersion: source.NullVersion}).GetVersionId()
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, source.BucketName+":"+source.Name+":")
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, source.BucketName+":"+source.Name+":"+versionId)
+	return nil
+}
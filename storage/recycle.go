@@ -20,6 +20,13 @@ type objectToRecycle struct {
 	pool       string
 	objectId   string
 	triedTimes int
+	// data of packed objects is in volume `objectId`, only accounted as
+	// deleted so the volume would be compacted
+	packed bool
+	size   int64
+	// data is a deduplicated blob, only removed with its last reference
+	blobHash   string
+	blobBucket string
 }
 
 var RecycleQueue chan objectToRecycle
@@ -38,7 +45,22 @@ func removeFailed(yig *YigStorage) {
 	for {
 		select {
 		case object := <-RecycleQueue:
-			err := yig.DataStorage[object.location].Remove(object.pool, object.objectId)
+			var err error
+			switch {
+			case object.blobHash != "":
+				var released bool
+				released, err = yig.MetaStorage.UnrefBlob(object.blobHash, object.blobBucket)
+				if err == nil && released {
+					// not referenced anymore, retries only remove the data
+					object.blobHash = ""
+					err = yig.DataStorage[object.location].Remove(object.pool, object.objectId)
+				}
+			case object.packed:
+				err = yig.MetaStorage.AddVolumeDeletedSize(object.location, object.pool,
+					object.objectId, object.size)
+			default:
+				err = yig.DataStorage[object.location].Remove(object.pool, object.objectId)
+			}
 			if err != nil {
 				object.triedTimes += 1
 				if object.triedTimes > MAX_TRY_TIMES {
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/journeymidnight/yig/backend"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	meta "github.com/journeymidnight/yig/meta/types"
+)
+
+// scrubReader calls `wait` with bytes read, to throttle scrubbing
+type scrubReader struct {
+	io.Reader
+	wait func(n int64)
+}
+
+func (r *scrubReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	r.wait(int64(n))
+	return
+}
+
+// ScrubObject re-reads data of the object and its parts, and verifies size
+// and checksum of the data. `object` is a version of object from
+// ScanObjectsByLocation. Data stored without checksum is only verified by size.
+// Failures found are saved, and failures saved before are removed if the object
+// is intact now. It returns failures found and bytes read, `wait` is called
+// with bytes read to throttle.
+func (yig *YigStorage) ScrubObject(object *meta.Object,
+	wait func(n int64)) (failures []meta.ScrubFailure, scrubbed int64, err error) {
+
+	iversion := math.MaxUint64 - uint64(object.LastModifiedTime.UnixNano())
+	source, err := yig.MetaStorage.Client.GetObject(object.BucketName, object.Name,
+		strconv.FormatUint(iversion, 10))
+	if err == ErrNoSuchKey {
+		// deleted since scanned
+		return nil, 0, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+	if source.DeleteMarker {
+		return nil, 0, nil
+	}
+	cluster, err := yig.objectCluster(source)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	scrub := func(partNumber int, objectId string, size int64, checksum string) error {
+		failure := meta.ScrubFailure{
+			BucketName: source.BucketName,
+			ObjectName: source.Name,
+			Version:    iversion,
+			PartNumber: partNumber,
+			Location:   source.Location,
+			Pool:       source.Pool,
+			ObjectId:   objectId,
+			MTime:      time.Now().UTC(),
+		}
+		reader, err := cluster.GetReader(source.Pool, objectId, 0, 0)
+		if backend.IsNotFound(err) {
+			failure.Reason = meta.ScrubDataMissing
+			failures = append(failures, failure)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer reader.Close()
+		crc := &checksumWriter{}
+		n, err := io.Copy(ioutil.Discard, io.TeeReader(&scrubReader{reader, wait}, crc))
+		scrubbed += n
+		if err != nil {
+			return err
+		}
+		if n != size {
+			failure.Reason = meta.ScrubSizeMismatch
+			failure.Expected = strconv.FormatInt(size, 10)
+			failure.Actual = strconv.FormatInt(n, 10)
+			failures = append(failures, failure)
+		} else if checksum != "" && crc.Checksum() != checksum {
+			failure.Reason = meta.ScrubChecksumMismatch
+			failure.Expected = checksum
+			failure.Actual = crc.Checksum()
+			failures = append(failures, failure)
+		}
+		return nil
+	}
+	if len(source.Parts) == 0 {
+		err = scrub(0, source.ObjectId, source.Size, source.Checksum)
+	} else {
+		for n, part := range source.Parts {
+			err = scrub(n, part.ObjectId, part.Size, part.Checksum)
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, scrubbed, err
+	}
+
+	if len(failures) == 0 {
+		err = yig.MetaStorage.RemoveScrubFailures(source.BucketName, source.Name, iversion)
+		return nil, scrubbed, err
+	}
+	for _, failure := range failures {
+		helper.Logger.Error("Scrub failed:", failure.BucketName, failure.ObjectName, failure.Version,
+			"part:", failure.PartNumber, failure.Location, failure.Pool, failure.ObjectId,
+			failure.Reason, "expected:", failure.Expected, "actual:", failure.Actual)
+		err = yig.MetaStorage.PutScrubFailure(failure)
+		if err != nil {
+			return failures, scrubbed, err
+		}
+	}
+	return failures, scrubbed, nil
+}
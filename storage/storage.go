@@ -12,6 +12,7 @@ import (
 	. "github.com/journeymidnight/yig/error"
 	"github.com/journeymidnight/yig/helper"
 	"github.com/journeymidnight/yig/meta"
+	"github.com/journeymidnight/yig/meta/types"
 	"github.com/journeymidnight/yig/redis"
 	"io"
 	"path"
@@ -33,11 +34,15 @@ type YigStorage struct {
 	KMS         crypto.KMS
 	Stopping    bool
 	WaitGroup   *sync.WaitGroup
+	// storage classes accepted by clusters, clusters not listed accept all
+	clusterStorageClasses map[string]map[types.StorageClass]bool
+	packer                *volumePacker
 }
 
 func (y *YigStorage) Stop() {
 	y.Stopping = true
 	helper.Logger.Info("Stopping storage...")
+	y.sealOpenVolumes()
 	y.WaitGroup.Wait()
 	helper.Logger.Info("done")
 }
@@ -72,6 +77,30 @@ func (y *YigStorage) PingCache(interval time.Duration) {
 	}
 }
 
+// check health of clusters with open circuit per `interval`, so they could be
+// picked again once recovered, since no requests go to them while tripped
+func (y *YigStorage) PingClusters(interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for !y.Stopping {
+		select {
+		case <-tick.C:
+			for fsid, cluster := range y.DataStorage {
+				c, ok := cluster.(*backend.CircuitCluster)
+				if !ok || !c.IsOpen() {
+					continue
+				}
+				_, err := c.GetUsage()
+				if err != nil && err != backend.ErrUsageUnknown {
+					helper.Logger.Warn("Cluster", fsid, "circuit is open, ping error:", err)
+				} else if !c.IsOpen() {
+					helper.Logger.Info("Cluster", fsid, "circuit is closed")
+				}
+			}
+		}
+	}
+}
+
 func (yig *YigStorage) encryptionKeyFromSseRequest(sseRequest datatype.SseRequest, bucket, object string) (key []byte, encKey []byte, err error) {
 	switch sseRequest.Type {
 	case "": // no encryption
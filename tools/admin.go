@@ -21,11 +21,15 @@ var config Config
 
 func printHelp() {
 	fmt.Println("Usage: admin <commands> [options...] ")
-	fmt.Println("Commands: usage|bucket|object|user|cachehit")
+	fmt.Println("Commands: usage|bucket|object|user|cachehit|cluster|addcluster|setweight|setstatus|circuit")
 	fmt.Println("Options:")
 	fmt.Println(" -b, --bucket   Specify bucket to operate")
 	fmt.Println(" -u, --uid      Specify user name to operate")
 	fmt.Println(" -o, --object   Specify object to operate")
+	fmt.Println(" -f, --fsid     Specify cluster to operate")
+	fmt.Println(" -p, --pool     Specify pool of cluster, all pools if empty for addcluster and setstatus")
+	fmt.Println(" -w, --weight   Specify weight of cluster")
+	fmt.Println(" -s, --status   Specify status of cluster, active|readonly|draining")
 }
 
 func isParaEmpty(p string) bool {
@@ -212,6 +216,83 @@ func getCacheHit() {
 
 }
 
+func sendClusterRequest(method, path string, claims jwt.MapClaims) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(config.AdminKey))
+	if err != nil {
+		fmt.Println("internal error", err)
+		return
+	}
+
+	url := config.RequestUrl + path
+	request, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		fmt.Println("create request failed", err)
+		return
+	}
+	request.Header.Set("Authorization", "Bearer "+tokenString)
+	response, err := client.Do(request)
+	if err != nil {
+		fmt.Println("send request failed", err)
+		return
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != 200 {
+		fmt.Println(path, "failed as status != 200", response.StatusCode, string(body))
+		return
+	}
+	fmt.Println(string(body))
+}
+
+func getClusters() {
+	sendClusterRequest("GET", "/admin/cluster", jwt.MapClaims{})
+}
+
+func getCircuits() {
+	sendClusterRequest("GET", "/admin/circuit", jwt.MapClaims{})
+}
+
+func addCluster(fsid, pool string, weight int) {
+	if isParaEmpty(fsid) {
+		return
+	}
+	claims := jwt.MapClaims{
+		"fsid": fsid,
+		"pool": pool,
+	}
+	if weight >= 0 {
+		claims["weight"] = weight
+	}
+	sendClusterRequest("POST", "/admin/cluster", claims)
+}
+
+func setClusterWeight(fsid, pool string, weight int) {
+	if isParaEmpty(fsid) || isParaEmpty(pool) {
+		return
+	}
+	if weight < 0 {
+		fmt.Println("Bad usage, weight must be specified")
+		return
+	}
+	sendClusterRequest("PUT", "/admin/cluster/weight", jwt.MapClaims{
+		"fsid":   fsid,
+		"pool":   pool,
+		"weight": weight,
+	})
+}
+
+func setClusterStatus(fsid, pool, status string) {
+	if isParaEmpty(fsid) || isParaEmpty(status) {
+		return
+	}
+	sendClusterRequest("PUT", "/admin/cluster/status", jwt.MapClaims{
+		"fsid":   fsid,
+		"pool":   pool,
+		"status": status,
+	})
+}
+
 func main() {
 	f, err := os.Open("./admin.json")
 	if err != nil {
@@ -231,6 +312,10 @@ func main() {
 	bucket := mySet.String("b", "", "bucket name")
 	uid := mySet.String("u", "", "user name")
 	object := mySet.String("o", "", "object name")
+	fsid := mySet.String("f", "", "cluster fsid")
+	pool := mySet.String("p", "", "pool name")
+	weight := mySet.Int("w", -1, "cluster weight")
+	status := mySet.String("s", "", "cluster status")
 	mySet.Parse(os.Args[2:])
 	fmt.Println("command:", os.Args[1], "bucket:", *bucket, "user:", *uid, "object:", *object)
 	switch os.Args[1] {
@@ -244,6 +329,16 @@ func main() {
 		getObjectInfo(*bucket, *object)
 	case "cachehit":
 		getCacheHit()
+	case "cluster":
+		getClusters()
+	case "addcluster":
+		addCluster(*fsid, *pool, *weight)
+	case "setweight":
+		setClusterWeight(*fsid, *pool, *weight)
+	case "setstatus":
+		setClusterStatus(*fsid, *pool, *status)
+	case "circuit":
+		getCircuits()
 	default:
 		printHelp()
 		return
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/journeymidnight/yig/crypto"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/log"
+	"github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/mods"
+	"github.com/journeymidnight/yig/redis"
+	"github.com/journeymidnight/yig/storage"
+)
+
+const (
+	SCAN_LIMIT               = 100
+	DEFAULT_COMPACT_LOG_PATH = "/var/log/yig/compact.log"
+)
+
+var (
+	yig  *storage.YigStorage
+	stop bool
+)
+
+// needCompact returns true if enough of the volume is deleted, or the volume
+// is abandoned open, e.g. by a crashed instance
+func needCompact(volume types.Volume, deletedPercent int) bool {
+	if !volume.Sealed {
+		return time.Since(volume.MTime) > storage.VOLUME_ABANDONED_TIMEOUT
+	}
+	return volume.DeletedPercent() >= deletedPercent
+}
+
+// compactVolume moves objects still in the volume to open volumes, then
+// removes the volume and puts its data to gc
+func compactVolume(volume types.Volume) (moved int, err error) {
+	objects, err := yig.MetaStorage.ListVolumeObjects(volume)
+	if err != nil {
+		return 0, err
+	}
+	for _, object := range objects {
+		if stop {
+			return moved, nil
+		}
+		err = yig.CompactObject(object, volume)
+		switch err {
+		case nil:
+			moved++
+		case storage.ErrObjectChanged:
+			helper.Logger.Info("Object changed, skip:", object.BucketName, object.Name)
+		default:
+			return moved, err
+		}
+	}
+	// objects renamed or copied meanwhile are still in the volume
+	objects, err = yig.MetaStorage.ListVolumeObjects(volume)
+	if err != nil {
+		return moved, err
+	}
+	if len(objects) > 0 {
+		return moved, fmt.Errorf("%d objects left in volume", len(objects))
+	}
+	return moved, yig.MetaStorage.RemoveVolume(volume)
+}
+
+func compactWorker(taskQ chan types.Volume, wg *sync.WaitGroup) {
+	for volume := range taskQ {
+		moved, err := compactVolume(volume)
+		if err != nil {
+			helper.Logger.Error("Compact volume failed:", volume.Location, volume.Pool,
+				volume.VolumeId, "objects moved:", moved, err)
+		} else if !stop {
+			helper.Logger.Info("Compacted volume", volume.Location, volume.Pool, volume.VolumeId,
+				"size:", volume.Size, "deleted:", volume.DeletedSize, "objects moved:", moved)
+		}
+		wg.Done()
+	}
+}
+
+func compact(threads, deletedPercent int) {
+	taskQ := make(chan types.Volume)
+	defer close(taskQ)
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		go compactWorker(taskQ, &wg)
+	}
+	var location, pool, volumeId string
+	for !stop {
+		volumes, err := yig.MetaStorage.ScanVolumes(SCAN_LIMIT, location, pool, volumeId)
+		if err != nil {
+			helper.Logger.Error("Scan volumes failed:", err)
+			return
+		}
+		for _, volume := range volumes {
+			if stop {
+				break
+			}
+			if !needCompact(volume, deletedPercent) {
+				continue
+			}
+			wg.Add(1)
+			taskQ <- volume
+		}
+		wg.Wait()
+		if len(volumes) < SCAN_LIMIT {
+			return
+		}
+		last := volumes[len(volumes)-1]
+		location, pool, volumeId = last.Location, last.Pool, last.VolumeId
+	}
+}
+
+func main() {
+	threads := flag.Int("thread", 4, "number of volumes compacted concurrently")
+	deletedPercent := flag.Int("deleted", 0,
+		"compact volumes with this percent of data deleted, default pack_compact_deleted_percent")
+	flag.Parse()
+
+	helper.SetupConfig()
+	logLevel := log.ParseLevel(helper.CONFIG.LogLevel)
+
+	helper.Logger = log.NewFileLogger(DEFAULT_COMPACT_LOG_PATH, logLevel)
+	defer helper.Logger.Close()
+	if *threads <= 0 || *deletedPercent < 0 || *deletedPercent > 100 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *deletedPercent == 0 {
+		*deletedPercent = helper.CONFIG.PackCompactDeletedPercent
+	}
+	if helper.CONFIG.MetaCacheType > 0 || helper.CONFIG.EnableDataCache {
+		redis.Initialize()
+		defer redis.Close()
+	}
+
+	// Read all *.so from plugins directory, and fill the variable allPlugins
+	allPluginMap := mods.InitialPlugins()
+	kms := crypto.NewKMS(allPluginMap)
+
+	yig = storage.New(helper.CONFIG.MetaCacheType, helper.CONFIG.EnableDataCache, kms)
+
+	signalQueue := make(chan os.Signal, 1)
+	signal.Notify(signalQueue, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		<-signalQueue
+		helper.Logger.Info("Stopping, wait for volumes in progress...")
+		stop = true
+	}()
+
+	compact(*threads, *deletedPercent)
+	// seal volumes objects are moved to
+	yig.Stop()
+	fmt.Println("Compaction finished, see", DEFAULT_COMPACT_LOG_PATH, "for details")
+}
@@ -32,6 +32,8 @@ var (
 	gcStop      bool
 )
 
+// deleteFromCeph removes data of entries in gc, deduplicated data shared
+// by objects is only put to gc when the last object referencing it is deleted
 func deleteFromCeph(index int) {
 	for {
 		if gcStop {
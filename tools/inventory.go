@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/crypto"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+	"github.com/journeymidnight/yig/log"
+	"github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/mods"
+	"github.com/journeymidnight/yig/redis"
+	"github.com/journeymidnight/yig/storage"
+)
+
+const (
+	SCAN_LIMIT                 = 50
+	OBJECT_SCAN_LIMIT          = 1000
+	MAX_REPORT_FILE_SIZE       = 64 << 20 // compressed size of a data file
+	DEFAULT_INVENTORY_LOG_PATH = "/var/log/yig/inventory.log"
+	MANIFEST_VERSION           = "2016-11-30"
+	TIME_FORMAT_AMZ            = "2006-01-02T15:04:05.000Z"
+	// reports are generated a bit earlier than a full period, so a daily
+	// job started at about the same time every day won't skip a day
+	SCHEDULE_SLACK = time.Hour
+)
+
+var (
+	yig         *storage.YigStorage
+	taskQ       chan types.Inventory
+	signalQueue chan os.Signal
+	waitgroup   sync.WaitGroup
+	empty       bool
+	stop        bool
+	// number of workers still processing reports
+	workers int32
+)
+
+var errReportAborted = errors.New("report aborted by shutting down")
+
+func getInventories() {
+	var bucketName, id string
+	helper.Logger.Info("all bucket inventory handle start")
+	waitgroup.Add(1)
+	defer waitgroup.Done()
+	for {
+		if stop {
+			helper.Logger.Info("shutting down...")
+			return
+		}
+
+		inventories, err := yig.MetaStorage.ScanInventories(SCAN_LIMIT, bucketName, id)
+		if err != nil {
+			helper.Logger.Error("ScanInventories failed:", err)
+			signalQueue <- syscall.SIGQUIT
+			return
+		}
+		for _, inventory := range inventories {
+			if isDue(inventory, time.Now()) {
+				taskQ <- inventory
+			}
+			bucketName, id = inventory.BucketName, inventory.Config.Id
+		}
+
+		if len(inventories) < SCAN_LIMIT {
+			empty = true
+			return
+		}
+	}
+}
+
+func isDue(inventory types.Inventory, now time.Time) bool {
+	if !inventory.Config.IsEnabled {
+		return false
+	}
+	period := 24 * time.Hour
+	if inventory.Config.Schedule.Frequency == datatype.InventoryFrequencyWeekly {
+		period = 7 * 24 * time.Hour
+	}
+	return now.Sub(inventory.LastRun) >= period-SCHEDULE_SLACK
+}
+
+type manifestFile struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	MD5checksum string `json:"MD5checksum"`
+}
+
+// manifest lists data files of a report, in the same format as AWS S3
+type manifest struct {
+	SourceBucket      string         `json:"sourceBucket"`
+	DestinationBucket string         `json:"destinationBucket"`
+	Version           string         `json:"version"`
+	CreationTimestamp string         `json:"creationTimestamp"`
+	FileFormat        string         `json:"fileFormat"`
+	FileSchema        string         `json:"fileSchema"`
+	Files             []manifestFile `json:"files"`
+}
+
+// report writes records of objects into gzipped data files under
+//
+//	destination-prefix/source-bucket/config-id/data/
+//
+// and finally the manifest files under
+//
+//	destination-prefix/source-bucket/config-id/YYYY-MM-DDTHH-MMZ/
+type report struct {
+	config      datatype.InventoryConfiguration
+	destination string
+	credential  common.Credential
+	keyPrefix   string
+	now         time.Time
+	fields      []string
+
+	buffer   bytes.Buffer
+	gzip     *gzip.Writer
+	csv      *csv.Writer
+	records  int
+	manifest manifest
+}
+
+func newReport(inventory types.Inventory, ownerId string, now time.Time) *report {
+	config := inventory.Config
+	r := &report{
+		config:      config,
+		destination: config.DestinationBucket(),
+		credential:  common.Credential{UserId: ownerId},
+		keyPrefix: path.Join(config.Destination.S3BucketDestination.Prefix,
+			inventory.BucketName, config.Id),
+		now:    now,
+		fields: []string{"Bucket", "Key"},
+	}
+	if config.IncludedObjectVersions == datatype.InventoryVersionsAll {
+		r.fields = append(r.fields, "VersionId", "IsLatest", "IsDeleteMarker")
+	}
+	for _, field := range datatype.InventoryOptionalFields {
+		if config.HasField(field) {
+			r.fields = append(r.fields, field)
+		}
+	}
+	r.manifest = manifest{
+		SourceBucket:      inventory.BucketName,
+		DestinationBucket: datatype.InventoryBucketArnPrefix + r.destination,
+		Version:           MANIFEST_VERSION,
+		CreationTimestamp: strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10),
+		FileFormat:        config.Destination.S3BucketDestination.Format,
+		FileSchema:        strings.Join(r.fields, ", "),
+		Files:             []manifestFile{},
+	}
+	return r
+}
+
+func (r *report) values(object *types.Object, isLatest bool) []interface{} {
+	values := make([]interface{}, 0, len(r.fields))
+	for _, field := range r.fields {
+		switch field {
+		case "Bucket":
+			values = append(values, object.BucketName)
+		case "Key":
+			values = append(values, object.Name)
+		case "VersionId":
+			values = append(values, object.GetVersionId())
+		case "IsLatest":
+			values = append(values, isLatest)
+		case "IsDeleteMarker":
+			values = append(values, object.DeleteMarker)
+		case "Size":
+			values = append(values, object.Size)
+		case "LastModifiedDate":
+			values = append(values, object.LastModifiedTime.UTC().Format(TIME_FORMAT_AMZ))
+		case "StorageClass":
+			values = append(values, object.StorageClass.ToString())
+		case "ETag":
+			values = append(values, object.Etag)
+		case "EncryptionStatus":
+			if object.SseType == "" {
+				values = append(values, "NOT-SSE")
+			} else {
+				values = append(values, object.SseType)
+			}
+		}
+	}
+	return values
+}
+
+func (r *report) add(object *types.Object, isLatest bool) error {
+	if r.gzip == nil {
+		r.gzip = gzip.NewWriter(&r.buffer)
+		r.csv = csv.NewWriter(r.gzip)
+	}
+	values := r.values(object, isLatest)
+	if r.config.Destination.S3BucketDestination.Format == datatype.InventoryFormatCSV {
+		record := make([]string, len(values))
+		for i, v := range values {
+			switch v := v.(type) {
+			case string:
+				record[i] = v
+			case bool:
+				record[i] = strconv.FormatBool(v)
+			case int64:
+				record[i] = strconv.FormatInt(v, 10)
+			}
+		}
+		err := r.csv.Write(record)
+		if err != nil {
+			return err
+		}
+	} else {
+		// write fields in the order of schema, so not a map
+		line := []byte{'{'}
+		for i, v := range values {
+			if i > 0 {
+				line = append(line, ',')
+			}
+			name, _ := json.Marshal(r.fields[i])
+			value, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			line = append(line, name...)
+			line = append(line, ':')
+			line = append(line, value...)
+		}
+		line = append(line, '}', '\n')
+		_, err := r.gzip.Write(line)
+		if err != nil {
+			return err
+		}
+	}
+	r.records++
+	if r.buffer.Len() >= MAX_REPORT_FILE_SIZE {
+		return r.flush()
+	}
+	return nil
+}
+
+// flush uploads records added as a data file
+func (r *report) flush() error {
+	if r.gzip == nil {
+		return nil
+	}
+	r.csv.Flush()
+	if err := r.csv.Error(); err != nil {
+		return err
+	}
+	if err := r.gzip.Close(); err != nil {
+		return err
+	}
+	extension := ".csv.gz"
+	if r.config.Destination.S3BucketDestination.Format == datatype.InventoryFormatJSON {
+		extension = ".json.gz"
+	}
+	key := path.Join(r.keyPrefix, "data",
+		r.now.UTC().Format("20060102T150405Z")+"-"+strconv.Itoa(len(r.manifest.Files))+extension)
+	data := r.buffer.Bytes()
+	md5sum, err := r.put(key, data, "application/x-gzip")
+	if err != nil {
+		return err
+	}
+	r.manifest.Files = append(r.manifest.Files, manifestFile{
+		Key:         key,
+		Size:        int64(len(data)),
+		MD5checksum: md5sum,
+	})
+	r.buffer.Reset()
+	r.gzip = nil
+	r.csv = nil
+	return nil
+}
+
+// finish uploads the remaining records and manifest files
+func (r *report) finish() error {
+	err := r.flush()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(r.manifest)
+	if err != nil {
+		return err
+	}
+	manifestPrefix := path.Join(r.keyPrefix, r.now.UTC().Format("2006-01-02T15-04Z"))
+	md5sum, err := r.put(path.Join(manifestPrefix, "manifest.json"), data, "application/json")
+	if err != nil {
+		return err
+	}
+	_, err = r.put(path.Join(manifestPrefix, "manifest.checksum"), []byte(md5sum), "text/plain")
+	return err
+}
+
+func (r *report) put(key string, data []byte, contentType string) (md5sum string, err error) {
+	sum := md5.Sum(data)
+	md5sum = hex.EncodeToString(sum[:])
+	metadata := map[string]string{
+		"Content-Type": contentType,
+		"md5Sum":       md5sum,
+	}
+	_, err = yig.PutObject(r.destination, key, r.credential, int64(len(data)),
+		ioutil.NopCloser(bytes.NewReader(data)), metadata, datatype.Acl{CannedAcl: "private"},
+		datatype.SseRequest{}, types.ObjectStorageClassStandard, datatype.ChecksumRequest{}, nil)
+	return md5sum, err
+}
+
+func generateReport(inventory types.Inventory) error {
+	now := time.Now()
+	bucket, err := yig.MetaStorage.GetBucket(inventory.BucketName, false)
+	if err != nil {
+		return err
+	}
+	r := newReport(inventory, bucket.OwnerId, now)
+
+	prefix := inventory.Config.FilterPrefix()
+	currentOnly := inventory.Config.IncludedObjectVersions == datatype.InventoryVersionsCurrent
+	objectName, version := prefix, uint64(0)
+	var lastName string
+	for {
+		if stop {
+			return errReportAborted
+		}
+		result, err := yig.MetaStorage.ScanBucketObjects(inventory.BucketName, prefix,
+			OBJECT_SCAN_LIMIT, objectName, version)
+		if err != nil {
+			return err
+		}
+		for _, object := range result.Objects {
+			// versions of an object are scanned newest first
+			isLatest := object.Name != lastName
+			lastName = object.Name
+			if currentOnly && (!isLatest || object.DeleteMarker) {
+				continue
+			}
+			err = r.add(object, isLatest)
+			if err != nil {
+				return err
+			}
+		}
+		if !result.Truncated {
+			break
+		}
+		objectName, version = result.NextObjectName, result.NextVersion
+	}
+
+	err = r.finish()
+	if err != nil {
+		return err
+	}
+	helper.Logger.Info("Inventory report of", inventory.BucketName, inventory.Config.Id,
+		"generated,", r.records, "records in", len(r.manifest.Files), "files")
+	return yig.MetaStorage.UpdateInventoryLastRun(inventory.BucketName, inventory.Config.Id, now)
+}
+
+func processInventory() {
+	time.Sleep(time.Second * 1)
+	for {
+		if stop {
+			helper.Logger.Info("Shutting down...")
+			return
+		}
+		waitgroup.Add(1)
+		select {
+		case item := <-taskQ:
+			err := generateReport(item)
+			if err != nil {
+				helper.Logger.Error("Bucket", item.BucketName, "inventory", item.Config.Id,
+					"report error:", err)
+				waitgroup.Done()
+				continue
+			}
+		default:
+			if empty == true {
+				// other workers may be still generating reports, only the
+				// last one to finish signals completion
+				waitgroup.Done()
+				if atomic.AddInt32(&workers, -1) == 0 {
+					helper.Logger.Info("All bucket inventory handle complete. QUIT")
+					signalQueue <- syscall.SIGQUIT
+				}
+				return
+			}
+		}
+		waitgroup.Done()
+	}
+}
+
+func main() {
+	threads := flag.Int("thread", 4, "number of reports generated concurrently")
+	flag.Parse()
+	if *threads <= 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	stop = false
+
+	helper.SetupConfig()
+	logLevel := log.ParseLevel(helper.CONFIG.LogLevel)
+
+	helper.Logger = log.NewFileLogger(DEFAULT_INVENTORY_LOG_PATH, logLevel)
+	defer helper.Logger.Close()
+	if helper.CONFIG.MetaCacheType > 0 || helper.CONFIG.EnableDataCache {
+		redis.Initialize()
+		defer redis.Close()
+	}
+
+	// Read all *.so from plugins directory, and fill the variable allPlugins
+	allPluginMap := mods.InitialPlugins()
+	kms := crypto.NewKMS(allPluginMap)
+
+	yig = storage.New(helper.CONFIG.MetaCacheType, helper.CONFIG.EnableDataCache, kms)
+	taskQ = make(chan types.Inventory, SCAN_LIMIT)
+	signal.Ignore()
+	signalQueue = make(chan os.Signal)
+
+	helper.Logger.Info("start inventory thread:", *threads)
+	empty = false
+	workers = int32(*threads)
+	for i := 0; i < *threads; i++ {
+		go processInventory()
+	}
+	go getInventories()
+	signal.Notify(signalQueue, syscall.SIGINT, syscall.SIGTERM,
+		syscall.SIGQUIT, syscall.SIGHUP)
+	for {
+		s := <-signalQueue
+		switch s {
+		case syscall.SIGHUP:
+			// reload config file
+			helper.SetupConfig()
+		default:
+			// stop, order matters
+			stop = true
+			waitgroup.Wait()
+			return
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/journeymidnight/yig/crypto"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/log"
+	"github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/mods"
+	"github.com/journeymidnight/yig/redis"
+	"github.com/journeymidnight/yig/storage"
+)
+
+const (
+	SCAN_LIMIT               = 100
+	DEFAULT_MIGRATE_LOG_PATH = "/var/log/yig/migrate.log"
+)
+
+var (
+	yig      *storage.YigStorage
+	stop     bool
+	limiter  *bandwidthLimiter
+	progress *migrateProgress
+)
+
+// migrateProgress is saved to the progress file after each batch, so an
+// interrupted migration resumes from where it stopped. Objects migrated are
+// no longer in the source location, so objects before the marker are those
+// skipped or failed.
+type migrateProgress struct {
+	lock sync.Mutex
+	path string
+
+	Fsid       string
+	Pool       string
+	BucketName string
+	ObjectName string
+	Version    uint64
+	Migrated   int64
+	Skipped    int64
+	Failed     int64
+	Bytes      int64
+}
+
+func loadProgress(path, fsid, pool string) (*migrateProgress, error) {
+	p := &migrateProgress{path: path, Fsid: fsid, Pool: pool}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, p)
+	if err != nil {
+		return nil, err
+	}
+	if p.Fsid != fsid || p.Pool != pool {
+		return nil, fmt.Errorf("progress file %s is for %s/%s, not %s/%s",
+			path, p.Fsid, p.Pool, fsid, pool)
+	}
+	return p, nil
+}
+
+func (p *migrateProgress) save() error {
+	p.lock.Lock()
+	data, err := json.Marshal(p)
+	p.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	// write to a temporary file then rename, so the progress file is never
+	// half written
+	tmp := p.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.path)
+}
+
+func (p *migrateProgress) add(migrated, skipped, failed, bytes int64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.Migrated += migrated
+	p.Skipped += skipped
+	p.Failed += failed
+	p.Bytes += bytes
+}
+
+func (p *migrateProgress) String() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return fmt.Sprintf("migrated: %d, skipped: %d, failed: %d, bytes: %d",
+		p.Migrated, p.Skipped, p.Failed, p.Bytes)
+}
+
+// bandwidthLimiter limits bytes copied per second of all workers,
+// 0 means unlimited
+type bandwidthLimiter struct {
+	lock sync.Mutex
+	rate float64
+	next time.Time
+}
+
+func (l *bandwidthLimiter) wait(n int64) {
+	if l.rate <= 0 || n <= 0 {
+		return
+	}
+	l.lock.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	wait := l.next.Sub(now)
+	l.lock.Unlock()
+	time.Sleep(wait)
+}
+
+func migrateWorker(taskQ chan *types.Object, fsid, pool string, wg *sync.WaitGroup) {
+	for object := range taskQ {
+		copied, err := yig.MigrateObject(object, fsid, pool)
+		switch err {
+		case nil:
+			progress.add(1, 0, 0, copied)
+		case storage.ErrObjectChanged:
+			helper.Logger.Info("Object changed, skip:", object.BucketName, object.Name)
+			progress.add(0, 1, 0, 0)
+		default:
+			helper.Logger.Error("Migrate failed:", object.BucketName, object.Name, err)
+			progress.add(0, 0, 1, 0)
+		}
+		limiter.wait(copied)
+		wg.Done()
+	}
+}
+
+func migrate(fsid, pool string, threads int) (finished bool) {
+	taskQ := make(chan *types.Object)
+	defer close(taskQ)
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		go migrateWorker(taskQ, fsid, pool, &wg)
+	}
+	for {
+		if stop {
+			return false
+		}
+		result, err := yig.MetaStorage.ScanObjectsByLocation(fsid, pool, SCAN_LIMIT,
+			progress.BucketName, progress.ObjectName, progress.Version)
+		if err != nil {
+			helper.Logger.Error("Scan objects failed:", err)
+			return false
+		}
+		for _, object := range result.Objects {
+			if stop {
+				break
+			}
+			wg.Add(1)
+			taskQ <- object
+		}
+		wg.Wait()
+		if stop {
+			return false
+		}
+		if !result.Truncated {
+			return true
+		}
+		progress.BucketName = result.NextBucketName
+		progress.ObjectName = result.NextObjectName
+		progress.Version = result.NextVersion
+		err = progress.save()
+		if err != nil {
+			helper.Logger.Error("Save progress failed:", err)
+		}
+		helper.Logger.Info("Migrate progress:", progress.String(), "marker:",
+			progress.BucketName, progress.ObjectName)
+	}
+}
+
+func main() {
+	fsid := flag.String("fsid", "", "fsid of the ceph cluster to move objects out of")
+	pool := flag.String("pool", "", "pool to move objects out of, all pools if empty")
+	threads := flag.Int("thread", 4, "number of objects migrated concurrently")
+	rate := flag.Int64("rate", 0, "max bandwidth in MB/s, 0 means unlimited")
+	progressPath := flag.String("progress", "", "file to save progress to, default migrate_<fsid>.progress")
+	flag.Parse()
+	if *fsid == "" || *threads <= 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *progressPath == "" {
+		*progressPath = "migrate_" + *fsid + ".progress"
+	}
+
+	helper.SetupConfig()
+	logLevel := log.ParseLevel(helper.CONFIG.LogLevel)
+
+	helper.Logger = log.NewFileLogger(DEFAULT_MIGRATE_LOG_PATH, logLevel)
+	defer helper.Logger.Close()
+	if helper.CONFIG.MetaCacheType > 0 || helper.CONFIG.EnableDataCache {
+		redis.Initialize()
+		defer redis.Close()
+	}
+
+	var err error
+	progress, err = loadProgress(*progressPath, *fsid, *pool)
+	if err != nil {
+		fmt.Println("Load progress failed:", err)
+		os.Exit(1)
+	}
+	limiter = &bandwidthLimiter{rate: float64(*rate << 20)}
+
+	// Read all *.so from plugins directory, and fill the variable allPlugins
+	allPluginMap := mods.InitialPlugins()
+	kms := crypto.NewKMS(allPluginMap)
+
+	yig = storage.New(helper.CONFIG.MetaCacheType, helper.CONFIG.EnableDataCache, kms)
+	if _, ok := yig.DataStorage[*fsid]; !ok {
+		fmt.Println("Cannot find specified ceph cluster:", *fsid)
+		os.Exit(1)
+	}
+
+	signalQueue := make(chan os.Signal, 1)
+	signal.Notify(signalQueue, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		<-signalQueue
+		helper.Logger.Info("Stopping, wait for objects in progress...")
+		stop = true
+	}()
+
+	finished := migrate(*fsid, *pool, *threads)
+	if finished {
+		// start over next time, to retry failed objects
+		os.Remove(*progressPath)
+		fmt.Println("Migration finished,", progress.String())
+		// parts of uploads in progress cannot be moved, they become objects
+		// in the source location once completed
+		uploads, err := yig.MetaStorage.CountMultipartsByLocation(*fsid, *pool)
+		if err != nil {
+			fmt.Println("Count multipart uploads failed:", err)
+		} else if uploads > 0 {
+			fmt.Println(uploads, "multipart uploads in progress still store data in", *fsid,
+				"run again once they are completed or aborted")
+		}
+	} else {
+		progress.save()
+		fmt.Println("Migration stopped,", progress.String(), "run again to resume")
+	}
+	yig.Stop()
+}
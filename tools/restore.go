@@ -0,0 +1,286 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/journeymidnight/yig/crypto"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/log"
+	"github.com/journeymidnight/yig/meta"
+	"github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/mods"
+	"github.com/journeymidnight/yig/storage"
+)
+
+const (
+	SCAN_LIMIT               = 50
+	TASKQ_LENGTH_PER_WORKER  = 4
+	SCAN_INTERVAL            = 10 * time.Second
+	DEFAULT_RESTORE_LOG_PATH = "/var/log/yig/restore.log"
+	// restoring freezers not updated for this long are considered abandoned,
+	// e.g. the restore daemon crashed, and will be restored again
+	RESTORING_TIMEOUT = 6 * time.Hour
+)
+
+var (
+	yig         *storage.YigStorage
+	taskQs      map[types.RestoreTier]chan restoreTask
+	signalQueue chan os.Signal
+	waitgroup   sync.WaitGroup
+	stop        bool
+)
+
+// scanFreezers calls `handle` for each freezer in `status`
+func scanFreezers(status types.Status, handle func(freezer types.Freezer)) {
+	var bucketName, objectName string
+	for {
+		if stop {
+			return
+		}
+		result, err := yig.MetaStorage.ScanFreezers(SCAN_LIMIT, bucketName, objectName, status)
+		if err != nil {
+			helper.Logger.Error("ScanFreezers failed:", status.ToString(), err)
+			return
+		}
+		for _, freezer := range result.Freezers {
+			handle(freezer)
+		}
+		if !result.Truncated {
+			return
+		}
+		bucketName, objectName = result.NextBucketName, result.NextObjectName
+	}
+}
+
+// restoreTask is a claimed freezer
+type restoreTask struct {
+	freezer types.Freezer
+}
+
+// tiers in order of priority
+var restoreTiers = []types.RestoreTier{
+	types.RestoreTierExpedited,
+	types.RestoreTierStandard,
+	types.RestoreTierBulk,
+}
+
+func tierConfig(tier types.RestoreTier) helper.RestoreTierConfig {
+	return helper.CONFIG.RestoreTiers[tier.ToString()]
+}
+
+// submitRestore claims freezers need restore in `tier` and sends them to
+// workers of the tier, earliest requested first. Free slots left are filled
+// with freezers of lower tiers already past their target completion time,
+// so a backlog of lower tiers doesn't delay them further.
+func submitRestore(tier types.RestoreTier) {
+	taskQ := taskQs[tier]
+	free := cap(taskQ) - len(taskQ)
+	now := time.Now()
+	free -= claimRestore(tier, taskQ, now, free)
+	lower := false
+	for _, t := range restoreTiers {
+		if t == tier {
+			lower = true
+			continue
+		}
+		if !lower || free <= 0 {
+			continue
+		}
+		target := time.Duration(tierConfig(t).TargetCompletionMinutes) * time.Minute
+		free -= claimRestore(t, taskQ, now.Add(-target), free)
+	}
+}
+
+// claimRestore claims at most `limit` freezers of `tier` requested before
+// `requestedBefore`, and sends them to `taskQ`. Returns number of freezers
+// claimed.
+func claimRestore(tier types.RestoreTier, taskQ chan restoreTask, requestedBefore time.Time,
+	limit int) (claimed int) {
+
+	if limit <= 0 {
+		return 0
+	}
+	freezers, err := yig.MetaStorage.ListFreezersByTier(tier, types.ObjectNeedRestore, requestedBefore, limit)
+	if err != nil {
+		helper.Logger.Error("List freezers failed:", tier.ToString(), err)
+		return 0
+	}
+	for _, freezer := range freezers {
+		if stop {
+			return
+		}
+		updated, err := yig.MetaStorage.UpdateFreezerStatus(&freezer, types.ObjectRestoring)
+		if err != nil {
+			helper.Logger.Error("Update freezer status failed:", freezer.BucketName, freezer.Name, err)
+			continue
+		}
+		if !updated { // claimed by other restore daemons
+			continue
+		}
+		freezer.Status = types.ObjectRestoring
+		taskQ <- restoreTask{freezer: freezer}
+		claimed++
+	}
+	return claimed
+}
+
+func resetAbandonedRestore(freezer types.Freezer) {
+	if time.Since(freezer.LastModifiedTime) < RESTORING_TIMEOUT {
+		return
+	}
+	helper.Logger.Warn("Restore abandoned, retry later:", freezer.BucketName, freezer.Name)
+	_, err := yig.MetaStorage.UpdateFreezerStatus(&freezer, types.ObjectNeedRestore)
+	if err != nil {
+		helper.Logger.Error("Update freezer status failed:", freezer.BucketName, freezer.Name, err)
+	}
+}
+
+// remove the restored copy once its lifetime elapses
+func expireRestored(freezer types.Freezer) {
+	if time.Now().Before(freezer.ExpireTime()) {
+		return
+	}
+	f, err := yig.MetaStorage.GetFreezer(freezer.BucketName, freezer.Name, "")
+	if err != nil {
+		helper.Logger.Error("Get freezer failed:", freezer.BucketName, freezer.Name, err)
+		return
+	}
+	// lifetime might be extended after scan
+	if f.Status != types.ObjectHasRestored || time.Now().Before(f.ExpireTime()) {
+		return
+	}
+	err = yig.MetaStorage.DeleteFreezer(f)
+	if err != nil {
+		helper.Logger.Error("Delete freezer failed:", freezer.BucketName, freezer.Name, err)
+		return
+	}
+	helper.Logger.Info("Restored copy expired:", freezer.BucketName, freezer.Name)
+}
+
+func scanLoop() {
+	waitgroup.Add(1)
+	defer waitgroup.Done()
+	for {
+		if stop {
+			helper.Logger.Info("Shutting down...")
+			return
+		}
+		for _, tier := range restoreTiers {
+			submitRestore(tier)
+		}
+		scanFreezers(types.ObjectRestoring, resetAbandonedRestore)
+		scanFreezers(types.ObjectHasRestored, expireRestored)
+		time.Sleep(SCAN_INTERVAL)
+	}
+}
+
+func restoreWorker(tier types.RestoreTier) {
+	for {
+		if stop {
+			helper.Logger.Info("Shutting down...")
+			return
+		}
+		// added before the worker blocks, so main never misses a task
+		// received while it's waiting for workers to finish
+		waitgroup.Add(1)
+		select {
+		case task, ok := <-taskQs[tier]:
+			// the queue is closed only after stop is set
+			if ok {
+				restore(task)
+			}
+		case <-time.After(time.Second):
+		}
+		waitgroup.Done()
+	}
+}
+
+func restore(task restoreTask) {
+	freezer := task.freezer
+	helper.Logger.Info("Restoring:", freezer.BucketName, freezer.Name, "tier:", freezer.Tier.ToString())
+	err := yig.RestoreObject(&freezer)
+	if err == nil {
+		target := time.Duration(tierConfig(freezer.Tier).TargetCompletionMinutes) * time.Minute
+		if elapsed := time.Since(freezer.RequestTime); elapsed > target {
+			helper.Logger.Warn("Restore missed target completion time:", freezer.BucketName, freezer.Name,
+				"tier:", freezer.Tier.ToString(), "elapsed:", elapsed, "target:", target)
+		}
+		return
+	}
+	helper.Logger.Error("Restore failed:", freezer.BucketName, freezer.Name, err)
+	if err == ErrNoSuchKey || err == ErrInvalidGlacierObject {
+		// object is deleted or transited out of glacier
+		err = yig.MetaStorage.DeleteFreezer(&freezer)
+		if err != nil {
+			helper.Logger.Error("Delete freezer failed:", freezer.BucketName, freezer.Name, err)
+		}
+		return
+	}
+	_, err = yig.MetaStorage.UpdateFreezerStatus(&freezer, types.ObjectNeedRestore)
+	if err != nil {
+		helper.Logger.Error("Update freezer status failed:", freezer.BucketName, freezer.Name, err)
+	}
+}
+
+func main() {
+	stop = false
+
+	helper.SetupConfig()
+	logLevel := log.ParseLevel(helper.CONFIG.LogLevel)
+
+	helper.Logger = log.NewFileLogger(DEFAULT_RESTORE_LOG_PATH, logLevel)
+	defer helper.Logger.Close()
+
+	// Read all *.so from plugins directory, and fill the variable allPlugins
+	allPluginMap := mods.InitialPlugins()
+	kms := crypto.NewKMS(allPluginMap)
+
+	yig = storage.New(int(meta.NoCache), false, kms)
+	signal.Ignore()
+	signalQueue = make(chan os.Signal, 1)
+
+	// each tier has its own queue and workers, so restorations of
+	// lower tiers never hold up higher ones
+	taskQs = make(map[types.RestoreTier]chan restoreTask)
+	for _, tier := range restoreTiers {
+		numOfWorkers := tierConfig(tier).Concurrency
+		taskQs[tier] = make(chan restoreTask, numOfWorkers*TASKQ_LENGTH_PER_WORKER)
+		helper.Logger.Info("start restore thread:", tier.ToString(), numOfWorkers)
+		for i := 0; i < numOfWorkers; i++ {
+			go restoreWorker(tier)
+		}
+	}
+	go scanLoop()
+	signal.Notify(signalQueue, syscall.SIGINT, syscall.SIGTERM,
+		syscall.SIGQUIT, syscall.SIGHUP)
+	for {
+		s := <-signalQueue
+		switch s {
+		case syscall.SIGHUP:
+			// reload config file
+			helper.SetupConfig()
+		default:
+			// stop restore daemon, freezers claimed but not restored yet
+			// are put back
+			stop = true
+			waitgroup.Wait()
+			for _, taskQ := range taskQs {
+				close(taskQ)
+				for task := range taskQ {
+					_, err := yig.MetaStorage.UpdateFreezerStatus(&task.freezer, types.ObjectNeedRestore)
+					if err != nil {
+						helper.Logger.Error("Update freezer status failed:",
+							task.freezer.BucketName, task.freezer.Name, err)
+					}
+				}
+			}
+			yig.Stop()
+			return
+		}
+	}
+}